@@ -0,0 +1,161 @@
+// Package aggregate builds a response from several of the gateway's own
+// routes, so a failing or slow backend costs only its own section rather
+// than the whole response.
+package aggregate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Section is one part of an aggregate response.
+type Section struct {
+	Name string
+	// Path is a gateway GET route, with its query string.
+	Path string
+}
+
+// Result is a section's outcome: the route's response body on success, or
+// the error that took its place.
+type Result struct {
+	Status int                `json:"status"`
+	Data   json.RawMessage    `json:"data,omitempty"`
+	Error  *apierror.APIError `json:"error,omitempty"`
+}
+
+// Fetcher issues sections as sub-requests to the gateway's router, so each
+// goes through the same authentication, scope checks and caching as if the
+// client had called the route itself.
+type Fetcher struct {
+	endpoint string
+	handler  http.Handler
+	timeout  time.Duration
+	scorer   *Scorer
+	budget   BudgetFunc
+}
+
+// NewFetcher serves the sections of endpoint (a metrics label) from
+// handler, giving each at most timeout. With scorer set, a request whose
+// sections cost more than budget allows the caller is refused before any
+// section runs.
+func NewFetcher(endpoint string, handler http.Handler, timeout time.Duration, scorer *Scorer, budget BudgetFunc) *Fetcher {
+	return &Fetcher{endpoint: endpoint, handler: handler, timeout: timeout, scorer: scorer, budget: budget}
+}
+
+// Fetch runs the sections concurrently with the request's credentials and
+// returns their results by name, and whether all of them succeeded. It
+// returns a *ComplexityError, running nothing, if the sections are over
+// the caller's budget.
+func (f *Fetcher) Fetch(c *gin.Context, sections []Section) (map[string]Result, bool, error) {
+	if f.scorer != nil && f.budget != nil {
+		if budget := f.budget(c); budget > 0 {
+			if cost := f.scorer.Cost(sections); cost > budget {
+				metrics.AggregateRejected.WithLabelValues(f.endpoint).Inc()
+				return nil, false, &ComplexityError{Cost: cost, Budget: budget}
+			}
+		}
+	}
+
+	results := make(map[string]Result, len(sections))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	complete := true
+
+	for _, section := range sections {
+		wg.Add(1)
+		go func(section Section) {
+			defer wg.Done()
+			result, reason := f.fetch(c, section)
+			if reason != "" {
+				metrics.AggregateSectionErrors.WithLabelValues(f.endpoint, section.Name, reason).Inc()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[section.Name] = result
+			if result.Error != nil {
+				complete = false
+			}
+		}(section)
+	}
+	wg.Wait()
+	return results, complete, nil
+}
+
+// fetch returns the section's result, and the reason it failed, if it did.
+func (f *Fetcher) fetch(c *gin.Context, section Section) (Result, string) {
+	orig := c.Request
+	ctx, cancel := context.WithTimeout(orig.Context(), f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, section.Path, nil)
+	if err != nil {
+		return failed(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Invalid section path"), "invalid"
+	}
+	req.Header = orig.Header.Clone()
+	req.Header.Set("Accept", "application/json")
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("If-None-Match")
+	req.RemoteAddr = orig.RemoteAddr
+	req.Host = orig.Host
+
+	// The buffered channel lets a sub-request that outlives its timeout
+	// finish without anyone waiting for it.
+	done := make(chan *recorder, 1)
+	go func() {
+		rec := newRecorder()
+		f.handler.ServeHTTP(rec, req)
+		done <- rec
+	}()
+
+	var rec *recorder
+	select {
+	case rec = <-done:
+	case <-ctx.Done():
+		return failed(c, http.StatusGatewayTimeout, "UPSTREAM_TIMEOUT", "Section timed out"), "timeout"
+	}
+
+	if rec.status >= http.StatusBadRequest {
+		var apiErr apierror.APIError
+		if json.Unmarshal(rec.body.Bytes(), &apiErr) != nil || apiErr.Code == "" {
+			apiErr = apierror.APIError{Code: "UPSTREAM_ERROR", Message: http.StatusText(rec.status)}
+		}
+		reason := "client_error"
+		if rec.status >= http.StatusInternalServerError {
+			reason = "server_error"
+		}
+		return Result{Status: rec.status, Error: &apiErr}, reason
+	}
+	if !json.Valid(rec.body.Bytes()) {
+		return failed(c, http.StatusBadGateway, "INVALID_RESPONSE", "Section returned a non-JSON response"), "invalid"
+	}
+	return Result{Status: rec.status, Data: rec.body.Bytes()}, ""
+}
+
+func failed(c *gin.Context, status int, code, message string) Result {
+	apiErr := apierror.New(c, code, message)
+	return Result{Status: status, Error: &apiErr}
+}
+
+// recorder captures a sub-request's response.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *recorder) Header() http.Header            { return r.header }
+func (r *recorder) Write(data []byte) (int, error) { return r.body.Write(data) }
+func (r *recorder) WriteHeader(status int)         { r.status = status }
@@ -0,0 +1,94 @@
+package aggregate
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BudgetFunc returns the complexity budget for the caller of c; zero or
+// less is unlimited.
+type BudgetFunc func(c *gin.Context) float64
+
+// ComplexityError rejects an aggregate request whose sections cost more
+// than the caller's budget.
+type ComplexityError struct {
+	Cost   float64
+	Budget float64
+}
+
+func (e *ComplexityError) Error() string {
+	return fmt.Sprintf("request complexity %g exceeds budget %g", e.Cost, e.Budget)
+}
+
+// Scorer prices sections by the cost their route declares in the routes
+// file, the same cost the load balancer weighs calls by; a section whose
+// route declares none costs 1.
+type Scorer struct {
+	templates []costTemplate
+}
+
+type costTemplate struct {
+	segments []string
+	cost     float64
+}
+
+func NewScorer(routes config.RouteTable) *Scorer {
+	s := &Scorer{}
+	for _, route := range routes {
+		if route.Cost > 0 && strings.EqualFold(route.Method, http.MethodGet) {
+			s.templates = append(s.templates, costTemplate{segments: strings.Split(route.Path, "/"), cost: route.Cost})
+		}
+	}
+	return s
+}
+
+// Cost sums the sections' costs.
+func (s *Scorer) Cost(sections []Section) float64 {
+	var total float64
+	for _, section := range sections {
+		total += s.cost(section.Path)
+	}
+	return total
+}
+
+// cost returns the cost of the route path falls under. Of several
+// matching templates, the one with the most static segments wins, as gin
+// prefers static segments over parameters.
+func (s *Scorer) cost(path string) float64 {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(path, "/")
+	cost, bestStatic := 1.0, -1
+	for _, t := range s.templates {
+		if static, ok := matchSegments(segments, t.segments); ok && static > bestStatic {
+			cost, bestStatic = t.cost, static
+		}
+	}
+	return cost
+}
+
+func matchSegments(segments, tmpl []string) (static int, ok bool) {
+	for i, seg := range tmpl {
+		if strings.HasPrefix(seg, "*") {
+			return static, len(segments) > i
+		}
+		if i >= len(segments) {
+			return 0, false
+		}
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			if segments[i] == "" {
+				return 0, false
+			}
+		case segments[i] == seg:
+			static++
+		default:
+			return 0, false
+		}
+	}
+	return static, len(segments) == len(tmpl)
+}
@@ -0,0 +1,56 @@
+package apierror
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey is the gin context key the RequestID middleware stores the
+// request ID under.
+const RequestIDKey = "request_id"
+
+// APIError is the JSON error envelope returned by every gateway-generated
+// error response.
+type APIError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	// Retryable, when set, tells clients whether sending the same request
+	// again may succeed.
+	Retryable *bool `json:"retryable,omitempty"`
+}
+
+// New builds an APIError for the current request. The message is
+// translated from the catalogs into the client's Accept-Language when a
+// catalog has the code, with Content-Language set to match and Vary
+// listing Accept-Language; the code never changes.
+func New(c *gin.Context, code, message string) APIError {
+	message, lang := localize(c, code, message)
+	if lang != "" {
+		c.Header("Content-Language", lang)
+	}
+	return APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: c.GetString(RequestIDKey),
+	}
+}
+
+// Abort writes an APIError with the given status and stops the handler chain.
+func Abort(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, New(c, code, message))
+}
+
+// AbortWithDetails is Abort with additional structured details.
+func AbortWithDetails(c *gin.Context, status int, code, message string, details map[string]interface{}) {
+	apiErr := New(c, code, message)
+	apiErr.Details = details
+	c.AbortWithStatusJSON(status, apiErr)
+}
+
+// AbortRetryable is Abort with the retryable flag set.
+func AbortRetryable(c *gin.Context, status int, code, message string, retryable bool) {
+	apiErr := New(c, code, message)
+	apiErr.Retryable = &retryable
+	c.AbortWithStatusJSON(status, apiErr)
+}
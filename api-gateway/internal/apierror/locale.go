@@ -0,0 +1,143 @@
+package apierror
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"dharmaguard/api-gateway/internal/httpheader"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Catalogs holds translated error messages: language tag ("de", "pt-br")
+// to error code to message.
+type Catalogs map[string]map[string]string
+
+var (
+	catalogsMu sync.RWMutex
+	catalogs   Catalogs
+)
+
+// LoadCatalogs reads one catalog per locale from dir. Each file is named
+// after its language tag ("de.yaml", "pt-BR.yaml") and maps error codes to
+// messages, as YAML or JSON.
+func LoadCatalogs(dir string) (Catalogs, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read error catalogs: %w", err)
+	}
+	loaded := make(Catalogs)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read error catalog %s: %w", entry.Name(), err)
+		}
+		messages := make(map[string]string)
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("failed to parse error catalog %s: %w", entry.Name(), err)
+		}
+		loaded[normalizeTag(strings.TrimSuffix(entry.Name(), ext))] = messages
+	}
+	return loaded, nil
+}
+
+// SetCatalogs sets the catalogs New localizes messages from. Call it at
+// startup.
+func SetCatalogs(c Catalogs) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	catalogs = c
+}
+
+// localize returns the message for code in the client's preferred language
+// that has a translation for it, and that language. It returns message,
+// the English default, when there is none. While catalogs are loaded the
+// response varies on Accept-Language whichever wins, and says so.
+func localize(c *gin.Context, code, message string) (string, string) {
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+	if len(catalogs) == 0 {
+		return message, ""
+	}
+	httpheader.AddVary(c.Writer.Header(), "Accept-Language")
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return message, ""
+	}
+	for _, tag := range preferredLanguages(header) {
+		if tag == "en" || strings.HasPrefix(tag, "en-") || tag == "*" {
+			break
+		}
+		for _, candidate := range candidateLocales(tag) {
+			if translated, ok := catalogs[candidate][code]; ok {
+				return translated, candidate
+			}
+		}
+	}
+	return message, ""
+}
+
+// candidateLocales lists the catalogs that can serve tag, best first: the
+// tag itself, its base language ("de" for "de-ch"), then regional variants
+// of the base language ("pt-br" for "pt"). Call with catalogsMu held.
+func candidateLocales(tag string) []string {
+	base, _, _ := strings.Cut(tag, "-")
+	candidates := []string{tag}
+	if base != tag {
+		candidates = append(candidates, base)
+	}
+	var regional []string
+	for locale := range catalogs {
+		if strings.HasPrefix(locale, base+"-") && locale != tag {
+			regional = append(regional, locale)
+		}
+	}
+	sort.Strings(regional)
+	return append(candidates, regional...)
+}
+
+// preferredLanguages returns the normalized tags of an Accept-Language
+// header, most preferred first, without those refused with q=0.
+func preferredLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag = normalizeTag(tag); tag != "" && q > 0 {
+			ranges = append(ranges, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
+
+// normalizeTag lowercases a language tag and writes "de_DE" as "de-de".
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
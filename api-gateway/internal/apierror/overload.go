@@ -0,0 +1,76 @@
+package apierror
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OverloadReasonHeader tells clients which protection turned their request
+// away, so SDKs can back off appropriately.
+const OverloadReasonHeader = "X-Overload-Reason"
+
+// Overload reasons. Rate limiting is the client's own doing and answered
+// with 429; the rest are the gateway or a backend lacking capacity, and
+// answered with 503.
+const (
+	// OverloadRateLimited: the caller exceeded its rate limit or quota.
+	OverloadRateLimited = "rate_limited"
+	// OverloadConcurrency: a backend or endpoint is at its in-flight limit.
+	OverloadConcurrency = "concurrency"
+	// OverloadShed: the gateway is shedding load to stay up.
+	OverloadShed = "shed"
+	// OverloadCircuitOpen: the backend is failing and calls to it are
+	// paused.
+	OverloadCircuitOpen = "circuit_open"
+)
+
+var (
+	retryAfterMu sync.RWMutex
+	retryAfter   = map[string]time.Duration{
+		OverloadRateLimited: time.Second,
+		OverloadConcurrency: time.Second,
+		OverloadShed:        time.Second,
+		OverloadCircuitOpen: 5 * time.Second,
+	}
+)
+
+// SetOverloadRetryAfter sets the Retry-After sent for each overload reason
+// when the protection itself doesn't know when to come back. Reasons left
+// out keep their defaults. Call it at startup.
+func SetOverloadRetryAfter(delays map[string]time.Duration) {
+	retryAfterMu.Lock()
+	defer retryAfterMu.Unlock()
+	for reason, delay := range delays {
+		retryAfter[reason] = delay
+	}
+}
+
+// AbortOverloaded writes the overload response for reason, marked
+// retryable: 429 or 503 with Retry-After and X-Overload-Reason. wait is
+// when the client may retry, as known to the protection that refused it;
+// zero uses the configured delay for reason. Retry-After is whole seconds,
+// at least one.
+func AbortOverloaded(c *gin.Context, reason string, wait time.Duration, code, message string) {
+	if wait <= 0 {
+		retryAfterMu.RLock()
+		wait = retryAfter[reason]
+		retryAfterMu.RUnlock()
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	status := http.StatusServiceUnavailable
+	if reason == OverloadRateLimited {
+		status = http.StatusTooManyRequests
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.Header(OverloadReasonHeader, reason)
+	AbortRetryable(c, status, code, message, true)
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/httpclient"
+
+	"go.uber.org/zap"
+)
+
+// Event mirrors the audit-service CreateAuditEventRequest.
+type Event struct {
+	TenantID     string                 `json:"tenant_id"`
+	UserID       string                 `json:"user_id,omitempty"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id,omitempty"`
+	OldValues    interface{}            `json:"old_values,omitempty"`
+	NewValues    interface{}            `json:"new_values,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Client delivers gateway-originated audit events to the audit service.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	redactor   Redactor
+	logger     *zap.Logger
+}
+
+// NewClient delivers events redacted by redactor; nil redacts the
+// DefaultSensitiveFields. tlsConfig, if set, secures the connection.
+func NewClient(auditServiceURL string, redactor Redactor, tlsConfig *tls.Config, logger *zap.Logger) *Client {
+	if redactor == nil {
+		redactor, _ = NewFieldRedactor(nil, nil)
+	}
+	return &Client{
+		endpoint:   strings.TrimRight(auditServiceURL, "/") + "/audit/events",
+		httpClient: httpclient.NewTLS(5*time.Second, tlsConfig),
+		redactor:   redactor,
+		logger:     logger,
+	}
+}
+
+// Emit sends the event in the background so audit delivery never adds latency
+// to the audited request. The event is redacted first, so neither the
+// audit service nor the log of a failed delivery sees its secrets.
+// Delivery failures are logged with the full redacted event so they can be
+// replayed.
+func (c *Client) Emit(event Event) {
+	event = c.redactor.Redact(event)
+	go func() {
+		if err := c.send(context.Background(), event); err != nil {
+			c.logger.Error("Failed to deliver audit event",
+				zap.String("action", event.Action),
+				zap.String("resource_type", event.ResourceType),
+				zap.Any("event", event),
+				zap.Error(err))
+		}
+	}()
+}
+
+func (c *Client) send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("audit service returned %d", resp.StatusCode)
+	}
+	return nil
+}
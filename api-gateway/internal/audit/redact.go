@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces the values of sensitive fields in audit events.
+const Redacted = "[REDACTED]"
+
+// DefaultSensitiveFields are redacted wherever they appear in an event's
+// payloads, whatever the field redactor is configured with. Names match
+// case-insensitively and ignoring "_" and "-", so "apiKey" matches
+// "api_key".
+var DefaultSensitiveFields = []string{
+	"password", "secret", "client_secret", "api_key", "api_secret",
+	"token", "access_token", "refresh_token", "id_token",
+	"private_key", "credentials", "authorization", "cookie",
+}
+
+// Redactor strips secrets from an event before it leaves the gateway.
+type Redactor interface {
+	Redact(event Event) Event
+}
+
+// FieldRedactor redacts fields by name at any depth of an event's old
+// values, new values and metadata, and by JSON path from the event root,
+// e.g. "$.new_values.keys[*].value" or "$.metadata.headers.*".
+type FieldRedactor struct {
+	fields map[string]bool
+	paths  [][]pathStep
+}
+
+// NewFieldRedactor redacts the named fields, besides the
+// DefaultSensitiveFields, and the values at paths.
+func NewFieldRedactor(fields, paths []string) (*FieldRedactor, error) {
+	r := &FieldRedactor{fields: make(map[string]bool)}
+	for _, name := range append(append([]string{}, DefaultSensitiveFields...), fields...) {
+		if name = normalizeField(name); name != "" {
+			r.fields[name] = true
+		}
+	}
+	for _, p := range paths {
+		steps, err := parsePath(p)
+		if err != nil {
+			return nil, fmt.Errorf("audit redaction path %q: %w", p, err)
+		}
+		r.paths = append(r.paths, steps)
+	}
+	return r, nil
+}
+
+// Redact returns event with its sensitive values replaced by Redacted.
+// Payloads are redacted in their JSON form; one that can't be marshaled
+// is dropped whole rather than sent unredacted.
+func (r *FieldRedactor) Redact(event Event) Event {
+	var doc map[string]interface{}
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		event.OldValues, event.NewValues, event.Metadata = nil, nil, map[string]interface{}{"redaction": "payload dropped"}
+		return event
+	}
+
+	for _, key := range []string{"old_values", "new_values", "metadata"} {
+		if v, ok := doc[key]; ok {
+			doc[key] = r.redactFields(v)
+		}
+	}
+	for _, steps := range r.paths {
+		redactPath(doc, steps)
+	}
+
+	event.OldValues = doc["old_values"]
+	event.NewValues = doc["new_values"]
+	event.Metadata, _ = doc["metadata"].(map[string]interface{})
+	return event
+}
+
+func (r *FieldRedactor) redactFields(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if r.fields[normalizeField(k)] {
+				v[k] = Redacted
+			} else {
+				v[k] = r.redactFields(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = r.redactFields(e)
+		}
+	}
+	return v
+}
+
+func normalizeField(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(strings.TrimSpace(name)))
+}
+
+// pathStep is one step of a redaction path: a field name, an array index,
+// or a wildcard over either.
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parsePath parses the JSON path subset redaction rules use: "$" followed
+// by ".name", ".*", "[n]" and "[*]" steps.
+func parsePath(p string) ([]pathStep, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(p), "$")
+	if !ok {
+		return nil, fmt.Errorf("must start with $")
+	}
+	var steps []pathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("empty field name")
+			}
+			steps = append(steps, pathStep{key: name, wildcard: name == "*"})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [")
+			}
+			inner := rest[1:end]
+			if inner == "*" {
+				steps = append(steps, pathStep{isIndex: true, wildcard: true})
+			} else if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				steps = append(steps, pathStep{isIndex: true, index: n})
+			} else {
+				return nil, fmt.Errorf("index must be a number or *")
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest[0])
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("must name a field")
+	}
+	return steps, nil
+}
+
+// redactPath replaces the values steps select within v.
+func redactPath(v interface{}, steps []pathStep) {
+	step, last := steps[0], len(steps) == 1
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if step.isIndex {
+			return
+		}
+		for k, e := range v {
+			if !step.wildcard && k != step.key {
+				continue
+			}
+			if last {
+				v[k] = Redacted
+			} else {
+				redactPath(e, steps[1:])
+			}
+		}
+	case []interface{}:
+		if !step.isIndex {
+			return
+		}
+		for i, e := range v {
+			if !step.wildcard && i != step.index {
+				continue
+			}
+			if last {
+				v[i] = Redacted
+			} else {
+				redactPath(e, steps[1:])
+			}
+		}
+	}
+}
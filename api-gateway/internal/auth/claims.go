@@ -0,0 +1,55 @@
+package auth
+
+import "github.com/golang-jwt/jwt/v5"
+
+// Claims is the identity the gateway attaches to an authenticated request,
+// regardless of whether the token was validated locally or introspected.
+type Claims struct {
+	UserID   string   `json:"user_id"`
+	TenantID string   `json:"tenant_id"`
+	Email    string   `json:"email,omitempty"`
+	Roles    []string `json:"roles"`
+	Scope    Scopes   `json:"scope,omitempty"`
+	// Org and Plan identify the customer organization and its plan, so
+	// limits can be pooled across an organization's users.
+	Org  string `json:"org,omitempty"`
+	Plan string `json:"plan,omitempty"`
+	// SessionID identifies the login the token belongs to, and stays the
+	// same across refreshes.
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Session returns the token's session ID: its sid claim, or for tokens
+// without one, its jti.
+func (c *Claims) Session() string {
+	if c.SessionID != "" {
+		return c.SessionID
+	}
+	return c.ID
+}
+
+// ClaimNames are the claims Value can look up.
+var ClaimNames = []string{"user_id", "sub", "tenant_id", "email", "org", "plan", "iss"}
+
+// Value returns the named claim, or "" if it is unset or not one of
+// ClaimNames.
+func (c *Claims) Value(name string) string {
+	switch name {
+	case "user_id":
+		return c.UserID
+	case "sub":
+		return c.Subject
+	case "tenant_id":
+		return c.TenantID
+	case "email":
+		return c.Email
+	case "org":
+		return c.Org
+	case "plan":
+		return c.Plan
+	case "iss":
+		return c.Issuer
+	}
+	return ""
+}
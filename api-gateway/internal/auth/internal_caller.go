@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// InternalCallerVerifier verifies the internal tokens trusted backend
+// services present when calling the gateway itself. Each caller signs with
+// its own Ed25519 key and names itself as the token's issuer; the audience
+// must be the gateway.
+type InternalCallerVerifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewInternalCallerVerifier loads each caller's Ed25519 PKIX PEM public key
+// from keyFiles, keyed by caller name.
+func NewInternalCallerVerifier(keyFiles map[string]string) (*InternalCallerVerifier, error) {
+	v := &InternalCallerVerifier{keys: make(map[string]ed25519.PublicKey, len(keyFiles))}
+	for caller, path := range keyFiles {
+		key, err := loadEd25519PublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", caller, err)
+		}
+		v.keys[caller] = key
+	}
+	return v, nil
+}
+
+// Verify checks token and returns the name of the caller that signed it.
+func (v *InternalCallerVerifier) Verify(token string) (string, error) {
+	claims := &InternalClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		issuer, _ := t.Claims.GetIssuer()
+		key, ok := v.keys[issuer]
+		if !ok {
+			return nil, fmt.Errorf("untrusted caller %q", issuer)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithAudience(InternalTokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims.Issuer, nil
+}
+
+func loadEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key must be an Ed25519 key")
+	}
+	return key, nil
+}
@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// InternalTokenIssuer is the iss claim of gateway-minted internal tokens.
+const InternalTokenIssuer = "dharmaguard-api-gateway"
+
+// InternalClaims is what the gateway asserts to a backend about a request:
+// that it came through the gateway, and on behalf of which end user.
+type InternalClaims struct {
+	TenantID  string   `json:"tenant_id,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type signingKey struct {
+	id      string
+	private ed25519.PrivateKey
+	retired time.Time
+}
+
+// InternalTokenSigner mints short-lived EdDSA tokens for calls to backends.
+// Backends verify them against the public keys published by JWKS, so they
+// can trust the forwarded identity without re-validating the user's token.
+//
+// Keys rotate by replacing the key file: Reload picks up the new key and
+// keeps the previous one published for verification until retention passes.
+type InternalTokenSigner struct {
+	keyFile   string
+	ttl       time.Duration
+	retention time.Duration
+
+	mu      sync.RWMutex
+	current *signingKey
+	retired []*signingKey
+	modTime time.Time
+}
+
+// NewInternalTokenSigner loads the Ed25519 PKCS#8 key in keyFile. With an
+// empty keyFile an ephemeral key is generated, which only suits single-replica
+// development setups.
+func NewInternalTokenSigner(keyFile string, ttl, retention time.Duration) (*InternalTokenSigner, error) {
+	s := &InternalTokenSigner{keyFile: keyFile, ttl: ttl, retention: retention}
+	if keyFile == "" {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		s.current = newSigningKey(private)
+		return s, nil
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the key file if it changed, rotating to the new key. It
+// reports whether a rotation happened.
+func (s *InternalTokenSigner) Reload() (bool, error) {
+	if s.keyFile == "" {
+		return false, nil
+	}
+	info, err := os.Stat(s.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat internal token key: %w", err)
+	}
+
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	private, err := loadEd25519Key(s.keyFile)
+	if err != nil {
+		return false, err
+	}
+	key := newSigningKey(private)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modTime = info.ModTime()
+	if s.current != nil && s.current.id == key.id {
+		return false, nil
+	}
+	if s.current != nil {
+		s.current.retired = time.Now()
+		s.retired = append(s.retired, s.current)
+	}
+	s.current = key
+	s.pruneRetired()
+	return true, nil
+}
+
+// Mint returns a token for a call to audience (the backend service name).
+func (s *InternalTokenSigner) Mint(audience, userID, tenantID string, roles []string, requestID string) (string, error) {
+	s.mu.RLock()
+	key := s.current
+	s.mu.RUnlock()
+
+	now := time.Now()
+	claims := InternalClaims{
+		TenantID:  tenantID,
+		Roles:     roles,
+		RequestID: requestID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    InternalTokenIssuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// JWK is an OKP public key in JSON Web Key form (RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JWKS returns the current and still-retained public keys.
+func (s *InternalTokenSigner) JWKS() []JWK {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneRetired()
+
+	keys := []JWK{s.current.jwk()}
+	for _, k := range s.retired {
+		keys = append(keys, k.jwk())
+	}
+	return keys
+}
+
+func (s *InternalTokenSigner) pruneRetired() {
+	kept := s.retired[:0]
+	for _, k := range s.retired {
+		if time.Since(k.retired) < s.retention {
+			kept = append(kept, k)
+		}
+	}
+	s.retired = kept
+}
+
+func newSigningKey(private ed25519.PrivateKey) *signingKey {
+	sum := sha256.Sum256(private.Public().(ed25519.PublicKey))
+	return &signingKey{
+		id:      base64.RawURLEncoding.EncodeToString(sum[:8]),
+		private: private,
+	}
+}
+
+func (k *signingKey) jwk() JWK {
+	return JWK{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(k.private.Public().(ed25519.PublicKey)),
+		KeyID:     k.id,
+		Use:       "sig",
+		Algorithm: "EdDSA",
+	}
+}
+
+func loadEd25519Key(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read internal token key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("internal token key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse internal token key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("internal token key must be an Ed25519 key")
+	}
+	return private, nil
+}
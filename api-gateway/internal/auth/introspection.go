@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/httpclient"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const introspectionCachePrefix = "auth:introspection:"
+
+// IntrospectionResponse is the RFC 7662 introspection response, plus the
+// DharmaGuard-specific claims partners include for tenant and role mapping.
+type IntrospectionResponse struct {
+	Active    bool             `json:"active"`
+	Scope     Scopes           `json:"scope,omitempty"`
+	ClientID  string           `json:"client_id,omitempty"`
+	Username  string           `json:"username,omitempty"`
+	Subject   string           `json:"sub,omitempty"`
+	Issuer    string           `json:"iss,omitempty"`
+	Audience  jwt.ClaimStrings `json:"aud,omitempty"`
+	ExpiresAt int64            `json:"exp,omitempty"`
+	IssuedAt  int64            `json:"iat,omitempty"`
+	NotBefore int64            `json:"nbf,omitempty"`
+	TenantID  string           `json:"tenant_id,omitempty"`
+	Roles     []string         `json:"roles,omitempty"`
+	Org       string           `json:"org,omitempty"`
+	Plan      string           `json:"plan,omitempty"`
+}
+
+// Introspector validates opaque tokens against an RFC 7662 introspection
+// endpoint. Results, active or not, are cached in Redis for a short TTL.
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	cacheTTL     time.Duration
+	leeway       time.Duration
+	httpClient   *http.Client
+	redisClient  *redis.Client
+	logger       *zap.Logger
+}
+
+func NewIntrospector(endpoint, clientID, clientSecret string, cacheTTL, leeway time.Duration, redisClient *redis.Client, logger *zap.Logger) *Introspector {
+	if leeway > MaxLeeway {
+		leeway = MaxLeeway
+	}
+	return &Introspector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		cacheTTL:     cacheTTL,
+		leeway:       leeway,
+		httpClient:   httpclient.New(5 * time.Second),
+		redisClient:  redisClient,
+		logger:       logger,
+	}
+}
+
+// Validate introspects the token and maps an active response into Claims.
+// Any failure to reach or decode the endpoint is treated as an invalid token.
+// exp, nbf and iat are checked with the same leeway as local tokens.
+func (i *Introspector) Validate(ctx context.Context, token string) (*Claims, error) {
+	resp, err := i.introspect(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: introspection failed: %v", ErrInvalidToken, err)
+	}
+	if !resp.Active {
+		return nil, fmt.Errorf("%w: token is not active", ErrInvalidToken)
+	}
+	now := time.Now()
+	if resp.ExpiresAt != 0 && now.After(time.Unix(resp.ExpiresAt, 0).Add(i.leeway)) {
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	}
+	if resp.NotBefore != 0 && now.Add(i.leeway).Before(time.Unix(resp.NotBefore, 0)) {
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if resp.IssuedAt != 0 && now.Add(i.leeway).Before(time.Unix(resp.IssuedAt, 0)) {
+		return nil, fmt.Errorf("%w: token used before issued", ErrInvalidToken)
+	}
+	claims := resp.claims()
+	logLeewayAcceptance(i.logger, claims, i.leeway)
+	return claims, nil
+}
+
+func (i *Introspector) introspect(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	key := introspectionCachePrefix + hashToken(token)
+
+	if cached, err := i.redisClient.Get(ctx, key).Bytes(); err == nil {
+		var resp IntrospectionResponse
+		if err := json.Unmarshal(cached, &resp); err == nil {
+			return &resp, nil
+		}
+	}
+
+	resp, err := i.call(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl := i.ttlFor(resp); ttl > 0 {
+		if data, err := json.Marshal(resp); err == nil {
+			i.redisClient.Set(ctx, key, data, ttl)
+		}
+	}
+	return resp, nil
+}
+
+func (i *Introspector) call(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+
+	httpResp, err := i.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned %d", httpResp.StatusCode)
+	}
+
+	var resp IntrospectionResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decoding introspection response: %w", err)
+	}
+	return &resp, nil
+}
+
+// ttlFor never caches an active result past the token's own expiry.
+func (i *Introspector) ttlFor(resp *IntrospectionResponse) time.Duration {
+	ttl := i.cacheTTL
+	if resp.Active && resp.ExpiresAt != 0 {
+		if remaining := time.Until(time.Unix(resp.ExpiresAt, 0)); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
+
+func (r *IntrospectionResponse) claims() *Claims {
+	userID := r.Subject
+	if userID == "" {
+		userID = r.Username
+	}
+
+	claims := &Claims{
+		UserID:   userID,
+		TenantID: r.TenantID,
+		Roles:    r.Roles,
+		Scope:    r.Scope,
+		Org:      r.Org,
+		Plan:     r.Plan,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   r.Issuer,
+			Subject:  r.Subject,
+			Audience: r.Audience,
+		},
+	}
+	if r.ExpiresAt != 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(r.ExpiresAt, 0))
+	}
+	if r.IssuedAt != 0 {
+		claims.IssuedAt = jwt.NewNumericDate(time.Unix(r.IssuedAt, 0))
+	}
+	if r.NotBefore != 0 {
+		claims.NotBefore = jwt.NewNumericDate(time.Unix(r.NotBefore, 0))
+	}
+	return claims
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
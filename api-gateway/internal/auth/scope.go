@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Scopes is an OAuth scope claim. Providers encode it either as a single
+// space-delimited string (RFC 8693) or as a JSON array; both decode here.
+type Scopes []string
+
+func ParseScopes(scope string) Scopes {
+	return Scopes(strings.Fields(scope))
+}
+
+func (s *Scopes) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+
+	var scope string
+	if err := json.Unmarshal(data, &scope); err != nil {
+		return err
+	}
+	*s = ParseScopes(scope)
+	return nil
+}
+
+// MarshalJSON always emits the space-delimited form.
+func (s Scopes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.Join(s, " "))
+}
+
+// Has reports whether scope is granted.
+func (s Scopes) Has(scope string) bool {
+	for _, granted := range s {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
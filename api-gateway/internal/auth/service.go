@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// MaxLeeway bounds the configurable clock skew tolerance; anything larger
+// would meaningfully extend token lifetimes.
+const MaxLeeway = 5 * time.Minute
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// TokenValidator validates a raw access token and returns the claims it carries.
+type TokenValidator interface {
+	Validate(ctx context.Context, token string) (*Claims, error)
+}
+
+// Service validates access tokens issued by the gateway itself and, per
+// issuer, by external identity providers.
+type Service struct {
+	// secret signs the gateway's own tokens. After a rotation the previous
+	// secret stays valid until previousUntil, so tokens signed before the
+	// rotation are honoured until they expire.
+	mu            sync.RWMutex
+	secret        []byte
+	previous      []byte
+	previousUntil time.Time
+
+	issuer      string
+	redisClient *redis.Client
+	logger      *zap.Logger
+	// leeway is the clock skew tolerated on exp, nbf and iat.
+	leeway time.Duration
+
+	// validators maps an issuer to the validator responsible for its tokens.
+	// Issuers without an entry fall back to local JWT validation.
+	validators map[string]TokenValidator
+	// opaqueValidator handles tokens that are not JWTs and therefore carry no
+	// readable issuer.
+	opaqueValidator TokenValidator
+	// sessions, if set, rejects tokens whose session has been revoked.
+	sessions *SessionStore
+}
+
+// Option customizes a Service.
+type Option func(*Service)
+
+// WithLeeway sets the clock skew tolerance, capped at MaxLeeway.
+func WithLeeway(leeway time.Duration) Option {
+	return func(s *Service) {
+		if leeway > MaxLeeway {
+			leeway = MaxLeeway
+		}
+		s.leeway = leeway
+	}
+}
+
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Service) { s.logger = logger }
+}
+
+// WithSessions rejects tokens of sessions revoked in store.
+func WithSessions(store *SessionStore) Option {
+	return func(s *Service) { s.sessions = store }
+}
+
+func NewService(secret, issuer string, redisClient *redis.Client, opts ...Option) *Service {
+	s := &Service{
+		secret:      []byte(secret),
+		issuer:      issuer,
+		redisClient: redisClient,
+		logger:      zap.NewNop(),
+		validators:  make(map[string]TokenValidator),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// RotateSecret replaces the token signing secret. Tokens signed with the
+// old one are still accepted for grace.
+func (s *Service) RotateSecret(secret string, grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(s.secret) == secret {
+		return
+	}
+	s.previous, s.previousUntil = s.secret, time.Now().Add(grace)
+	s.secret = []byte(secret)
+}
+
+// verificationKeys returns the secrets tokens may currently be signed with.
+func (s *Service) verificationKeys() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.previous == nil || time.Now().After(s.previousUntil) {
+		return s.secret
+	}
+	return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{s.secret, s.previous}}
+}
+
+// RegisterValidator routes tokens from issuer to v instead of local JWT
+// validation. When opaque is true, v also handles tokens that are not JWTs.
+func (s *Service) RegisterValidator(issuer string, v TokenValidator, opaque bool) {
+	s.validators[issuer] = v
+	if opaque {
+		s.opaqueValidator = v
+	}
+}
+
+// ValidateToken selects the validator for the token's issuer and validates
+// it, then checks that its session hasn't been revoked.
+func (s *Service) ValidateToken(ctx context.Context, token string) (*Claims, error) {
+	claims, err := s.validate(ctx, token)
+	if err != nil || s.sessions == nil || claims.Session() == "" {
+		return claims, err
+	}
+	revoked, err := s.sessions.Revoked(ctx, claims.Session())
+	if err != nil {
+		// Failing closed would log everyone out with Redis; revocation
+		// lapses until it is back instead.
+		s.logger.Warn("Failed to check session revocation", zap.String("user_id", claims.UserID), zap.Error(err))
+		return claims, nil
+	}
+	if revoked {
+		return nil, fmt.Errorf("%w: session revoked", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (s *Service) validate(ctx context.Context, token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	if !looksLikeJWT(token) {
+		if s.opaqueValidator == nil {
+			return nil, ErrInvalidToken
+		}
+		return s.opaqueValidator.Validate(ctx, token)
+	}
+
+	issuer, err := unverifiedIssuer(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if v, ok := s.validators[issuer]; ok {
+		return v.Validate(ctx, token)
+	}
+	return s.validateLocal(token)
+}
+
+func (s *Service) validateLocal(token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return s.verificationKeys(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.leeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	logLeewayAcceptance(s.logger, claims, s.leeway)
+	return claims, nil
+}
+
+// logLeewayAcceptance notes tokens that were only valid thanks to the skew
+// tolerance; a steady stream of these points at a real clock problem.
+func logLeewayAcceptance(logger *zap.Logger, claims *Claims, leeway time.Duration) {
+	now := time.Now()
+	var reason string
+	switch {
+	case claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Time):
+		reason = "expired"
+	case claims.NotBefore != nil && now.Before(claims.NotBefore.Time):
+		reason = "not_yet_valid"
+	case claims.IssuedAt != nil && now.Before(claims.IssuedAt.Time):
+		reason = "issued_in_future"
+	default:
+		return
+	}
+	logger.Debug("Token accepted within clock skew leeway",
+		zap.String("reason", reason),
+		zap.String("issuer", claims.Issuer),
+		zap.String("user_id", claims.UserID),
+		zap.Duration("leeway", leeway))
+}
+
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// unverifiedIssuer reads the iss claim without checking the signature. It is
+// only used to pick a validator; the chosen validator does the real checks.
+func unverifiedIssuer(token string) (string, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return "", err
+	}
+	return claims.GetIssuer()
+}
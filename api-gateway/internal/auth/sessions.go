@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	sessionsKeyPrefix = "auth:sessions:"
+	sessionKeyPrefix  = "auth:session:"
+	revokedKeyPrefix  = "auth:revoked:"
+)
+
+// Session limit policies: what Admit does with a login beyond the limit.
+const (
+	SessionPolicyReject      = "reject"
+	SessionPolicyEvictOldest = "evict_oldest"
+)
+
+// ErrSessionLimit is returned by Admit when the user already has the
+// maximum number of sessions and the policy is to reject.
+var ErrSessionLimit = errors.New("session limit reached")
+
+// Session is one active login, identified by the session ID its tokens
+// carry.
+type Session struct {
+	ID        string    `json:"id"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// admitScript prunes sessions older than ARGV[2], then adds session ARGV[4]
+// created at ARGV[1]. When that would exceed ARGV[3] sessions (zero is
+// unlimited) it returns false if ARGV[5] isn't "1", and otherwise evicts
+// and returns the oldest. A session already present is left as it is.
+var admitScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[2])
+local evicted = {}
+if not redis.call("ZSCORE", KEYS[1], ARGV[4]) then
+	local max = tonumber(ARGV[3])
+	local excess = redis.call("ZCARD", KEYS[1]) - max + 1
+	if max > 0 and excess > 0 then
+		if ARGV[5] ~= "1" then
+			return false
+		end
+		evicted = redis.call("ZRANGE", KEYS[1], 0, excess - 1)
+		redis.call("ZREM", KEYS[1], unpack(evicted))
+	end
+end
+redis.call("ZADD", KEYS[1], "NX", ARGV[1], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[6])
+return evicted
+`)
+
+// SessionStore tracks each user's active sessions in Redis and enforces a
+// limit on them. Sessions live for lifetime from login, the lifetime of
+// their refresh token; revoked session IDs are remembered as long.
+type SessionStore struct {
+	client      *redis.Client
+	max         int
+	evictOldest bool
+	lifetime    time.Duration
+}
+
+// NewSessionStore limits users to max sessions, zero meaning unlimited,
+// applying policy to logins beyond it.
+func NewSessionStore(client *redis.Client, max int, policy string, lifetime time.Duration) *SessionStore {
+	return &SessionStore{
+		client:      client,
+		max:         max,
+		evictOldest: policy == SessionPolicyEvictOldest,
+		lifetime:    lifetime,
+	}
+}
+
+// Limit returns the maximum number of sessions per user, zero if
+// unlimited.
+func (s *SessionStore) Limit() int {
+	return s.max
+}
+
+// Admit records a new session for the user. Past the limit it either
+// fails with ErrSessionLimit or evicts the oldest sessions, which it
+// revokes and returns.
+func (s *SessionStore) Admit(ctx context.Context, tenantID, userID string, session Session) ([]Session, error) {
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	session.ExpiresAt = session.CreatedAt.Add(s.lifetime)
+
+	evict := "0"
+	if s.evictOldest {
+		evict = "1"
+	}
+	ids, err := admitScript.Run(ctx, s.client, []string{sessionsKey(tenantID, userID)},
+		session.CreatedAt.UnixMilli(),
+		time.Now().Add(-s.lifetime).UnixMilli(),
+		s.max,
+		session.ID,
+		evict,
+		s.lifetime.Milliseconds(),
+	).StringSlice()
+	if err == redis.Nil {
+		return nil, ErrSessionLimit
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.SetNX(ctx, sessionKeyPrefix+session.ID, data, s.lifetime)
+	if len(ids) > 0 {
+		details := make([]*redis.StringCmd, len(ids))
+		for i, id := range ids {
+			details[i] = pipe.Get(ctx, sessionKeyPrefix+id)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, err
+		}
+		evicted := make([]Session, 0, len(ids))
+		for i, id := range ids {
+			evicted = append(evicted, decodeSession(id, details[i].Val()))
+		}
+		return evicted, s.revoke(ctx, ids...)
+	}
+	_, err = pipe.Exec(ctx)
+	return nil, err
+}
+
+// Remove ends a session, as at logout, and revokes it.
+func (s *SessionStore) Remove(ctx context.Context, tenantID, userID, sessionID string) error {
+	if err := s.client.ZRem(ctx, sessionsKey(tenantID, userID), sessionID).Err(); err != nil {
+		return err
+	}
+	return s.revoke(ctx, sessionID)
+}
+
+// Revoke revokes a session that was issued but never admitted, so its
+// tokens stop working.
+func (s *SessionStore) Revoke(ctx context.Context, sessionID string) error {
+	return s.revoke(ctx, sessionID)
+}
+
+func (s *SessionStore) revoke(ctx context.Context, ids ...string) error {
+	pipe := s.client.TxPipeline()
+	for _, id := range ids {
+		pipe.Set(ctx, revokedKeyPrefix+id, "1", s.lifetime)
+		pipe.Del(ctx, sessionKeyPrefix+id)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Revoked reports whether the session has been revoked.
+func (s *SessionStore) Revoked(ctx context.Context, sessionID string) (bool, error) {
+	n, err := s.client.Exists(ctx, revokedKeyPrefix+sessionID).Result()
+	return n > 0, err
+}
+
+// List returns the user's active sessions, oldest first.
+func (s *SessionStore) List(ctx context.Context, tenantID, userID string) ([]Session, error) {
+	key := sessionsKey(tenantID, userID)
+	cutoff := strconv.FormatInt(time.Now().Add(-s.lifetime).UnixMilli(), 10)
+	ids, err := s.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "(" + cutoff, Max: "+inf"}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKeyPrefix + id
+	}
+	details, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, len(ids))
+	for i, id := range ids {
+		data, _ := details[i].(string)
+		sessions[i] = decodeSession(id, data)
+	}
+	return sessions, nil
+}
+
+// decodeSession parses a session's stored details, keeping at least its ID
+// if they are missing.
+func decodeSession(id, data string) Session {
+	session := Session{ID: id}
+	if data != "" {
+		json.Unmarshal([]byte(data), &session)
+	}
+	return session
+}
+
+func sessionsKey(tenantID, userID string) string {
+	return fmt.Sprintf("%s%s:%s", sessionsKeyPrefix, tenantID, userID)
+}
@@ -0,0 +1,161 @@
+// Package authz asks an Open Policy Agent server whether a request is
+// allowed, so authorization policy can live in Rego and change without a
+// gateway deploy.
+package authz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/httpclient"
+)
+
+// maxCachedDecisions bounds the decision cache; when full, expired
+// decisions are dropped, and if none have expired the cache starts over.
+const maxCachedDecisions = 10000
+
+// Subject is the authenticated caller.
+type Subject struct {
+	UserID   string   `json:"user_id,omitempty"`
+	TenantID string   `json:"tenant_id,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Org      string   `json:"org,omitempty"`
+	Plan     string   `json:"plan,omitempty"`
+	Issuer   string   `json:"iss,omitempty"`
+}
+
+// Input is the document policies are evaluated against, as input.
+type Input struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Route  string            `json:"route"`
+	Params map[string]string `json:"params,omitempty"`
+	// Tenant is the request's resolved tenant, which may differ from the
+	// token's when a tenant resolver other than jwt supplied it.
+	Tenant  string  `json:"tenant,omitempty"`
+	Subject Subject `json:"subject"`
+	// Resource is set on routes that declare the resource they act on.
+	Resource *Resource `json:"resource,omitempty"`
+}
+
+// Client evaluates a policy decision through OPA's data API, e.g.
+// http://localhost:8181/v1/data/dharmaguard/authz/allow. The decision must
+// be a boolean, or an object with a boolean "allow"; anything else,
+// including an undefined decision, denies.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedDecision
+}
+
+type cachedDecision struct {
+	allow   bool
+	expires time.Time
+}
+
+func NewClient(endpoint string, timeout, cacheTTL time.Duration) *Client {
+	return &Client{
+		endpoint:   endpoint,
+		httpClient: httpclient.New(timeout),
+		cacheTTL:   cacheTTL,
+		cache:      make(map[string]cachedDecision),
+	}
+}
+
+// Allow returns the policy's decision for input. Identical inputs reuse a
+// decision for the cache TTL. An error means no decision was made; callers
+// must treat it as a denial.
+func (c *Client) Allow(ctx context.Context, input Input) (allow, cached bool, err error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, false, err
+	}
+	sum := sha256.Sum256(body)
+	key := hex.EncodeToString(sum[:])
+	if allow, ok := c.cached(key); ok {
+		return allow, true, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpclient.SetDeadlineHeader(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, false, fmt.Errorf("policy engine returned %d", resp.StatusCode)
+	}
+
+	var decision struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, false, fmt.Errorf("decode policy decision: %w", err)
+	}
+	allow = parseDecision(decision.Result)
+	c.store(key, allow)
+	return allow, false, nil
+}
+
+func parseDecision(result json.RawMessage) bool {
+	var allow bool
+	if err := json.Unmarshal(result, &allow); err == nil {
+		return allow
+	}
+	var obj struct {
+		Allow bool `json:"allow"`
+	}
+	if err := json.Unmarshal(result, &obj); err == nil {
+		return obj.Allow
+	}
+	return false
+}
+
+func (c *Client) cached(key string) (bool, bool) {
+	if c.cacheTTL <= 0 {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.cache[key]
+	if !ok || time.Now().After(d.expires) {
+		return false, false
+	}
+	return d.allow, true
+}
+
+func (c *Client) store(key string, allow bool) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.cache) >= maxCachedDecisions {
+		for k, d := range c.cache {
+			if now.After(d.expires) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= maxCachedDecisions {
+			c.cache = make(map[string]cachedDecision)
+		}
+	}
+	c.cache[key] = cachedDecision{allow: allow, expires: now.Add(c.cacheTTL)}
+}
@@ -0,0 +1,42 @@
+// Package buildinfo holds version details injected at build time, e.g.
+//
+//	go build -ldflags "-X dharmaguard/api-gateway/internal/buildinfo.Version=1.4.0 \
+//	  -X dharmaguard/api-gateway/internal/buildinfo.Commit=$(git rev-parse HEAD)"
+package buildinfo
+
+import (
+	"runtime"
+	"time"
+)
+
+// Set with -ldflags -X; the defaults mark a local build.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+var startTime = time.Now()
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build's version details.
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// Uptime is how long the process has been running.
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/httpheader"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/proxy"
+	"dharmaguard/api-gateway/internal/servertiming"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const keyPrefix = "cache:response:"
+
+// Cache results reported in the X-Cache header and cache metrics.
+const (
+	ResultHit   = "HIT"
+	ResultMiss  = "MISS"
+	ResultStale = "STALE"
+	// ResultRefresh and ResultBypass report a trusted client's no-cache and
+	// no-store requests.
+	ResultRefresh = "REFRESH"
+	ResultBypass  = "BYPASS"
+)
+
+// BypassSecretHeader lets tooling without an admin token bypass the cache
+// by presenting the configured secret.
+const BypassSecretHeader = "X-Cache-Bypass-Secret"
+
+// entry is a cached response as stored in Redis.
+type entry struct {
+	Status          int       `json:"status"`
+	ContentType     string    `json:"content_type"`
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Vary            string    `json:"vary,omitempty"`
+	Body            []byte    `json:"body"`
+	StoredAt        time.Time `json:"stored_at"`
+}
+
+// ResponseCache caches successful GET responses in Redis for routes that
+// declare a cache policy in the routes config.
+type ResponseCache struct {
+	redisClient *redis.Client
+	routes      config.RouteTable
+	bypass      config.CacheBypassConfig
+	// vary lists the request headers cached responses vary on: the
+	// representation headers in the key, and those the tenant is resolved
+	// from.
+	vary   []string
+	logger *zap.Logger
+}
+
+func NewResponseCache(redisClient *redis.Client, routes config.RouteTable, bypass config.CacheBypassConfig, tenants config.TenantConfig, logger *zap.Logger) *ResponseCache {
+	return &ResponseCache{
+		redisClient: redisClient,
+		routes:      routes,
+		bypass:      bypass,
+		vary:        append([]string{"Accept", "Accept-Encoding"}, tenantHeaders(tenants)...),
+		logger:      logger,
+	}
+}
+
+// Middleware serves fresh cache hits directly and caches 2xx responses on a
+// miss. On routes with stale_if_error set, a 5xx from the handler is replaced
+// by the expired cached copy if it is still within the grace period.
+//
+// Trusted clients can skip the cache: Cache-Control: no-cache (or Pragma:
+// no-cache) fetches a fresh response and refreshes the entry, no-store
+// fetches one without touching the entry. Other clients' directives are
+// ignored, since letting anyone skip the cache would let them drive load
+// straight to the backends.
+//
+// Entries are keyed on the encodings the client accepts, and a hit whose
+// Content-Encoding the client can't decode is treated as a miss, so a
+// compressed body never reaches a client that didn't ask for it. Cached
+// routes' responses carry Vary for everything the key depends on.
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		route, ok := rc.routes.Lookup(c.Request.Method, c.FullPath())
+		if !ok || route.Cache == nil || route.Cache.TTL <= 0 {
+			c.Next()
+			return
+		}
+		policy := route.Cache
+		ttl := time.Duration(policy.TTL) * time.Second
+		grace := time.Duration(policy.StaleIfError) * time.Second
+		key := rc.key(c)
+
+		directive := rc.bypassDirective(c)
+		if directive == ResultBypass {
+			metrics.CacheResults.WithLabelValues(ResultBypass).Inc()
+			c.Header("X-Cache", ResultBypass)
+			writer := newBufferedWriter(c.Writer)
+			c.Writer = writer
+			c.Next()
+			c.Writer = writer.ResponseWriter
+			rc.addVary(writer.Header())
+			writer.flush()
+			return
+		}
+
+		var cached entry
+		var found bool
+		if directive != ResultRefresh {
+			cached, found = rc.get(c.Request.Context(), key)
+			found = found && acceptsEncoding(c.GetHeader("Accept-Encoding"), cached.ContentEncoding)
+			if found && time.Since(cached.StoredAt) < ttl {
+				rc.serve(c, cached, ResultHit)
+				return
+			}
+		}
+
+		// header is restored before serving a stale entry, so nothing the
+		// failed response set (Retry-After, say) is sent with it.
+		header := c.Writer.Header().Clone()
+		writer := newBufferedWriter(c.Writer)
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		rc.addVary(writer.Header())
+
+		status := writer.Status()
+		switch {
+		case found && proxy.FallbackRequested(c):
+			// The backend's circuit is open; the entry is still in Redis,
+			// so it is within ttl+grace of being stored.
+			rc.logger.Warn("Serving cached response while backend circuit is open",
+				zap.String("route", c.FullPath()),
+				zap.Duration("age", time.Since(cached.StoredAt)))
+			resetHeader(c.Writer.Header(), header)
+			c.Header("Warning", `110 - "Response is Stale"`)
+			c.Header(proxy.FallbackHeader, "cache")
+			rc.serve(c, cached, ResultStale)
+			return
+		case status >= 200 && status < 300 && c.Writer.Header().Get(proxy.FallbackHeader) == "":
+			rc.store(c.Request.Context(), key, entry{
+				Status:          status,
+				ContentType:     writer.Header().Get("Content-Type"),
+				ContentEncoding: writer.Header().Get("Content-Encoding"),
+				Vary:            writer.Header().Get("Vary"),
+				Body:            writer.body.Bytes(),
+				StoredAt:        time.Now(),
+			}, ttl+grace)
+		case status >= 500 && found && grace > 0 && time.Since(cached.StoredAt) < ttl+grace:
+			rc.logger.Warn("Serving stale response after upstream error",
+				zap.String("route", c.FullPath()),
+				zap.Int("upstream_status", status),
+				zap.Duration("age", time.Since(cached.StoredAt)))
+			resetHeader(c.Writer.Header(), header)
+			c.Header("Warning", `110 - "Response is Stale"`)
+			rc.serve(c, cached, ResultStale)
+			return
+		}
+
+		result := ResultMiss
+		if directive == ResultRefresh {
+			result = ResultRefresh
+		}
+		metrics.CacheResults.WithLabelValues(result).Inc()
+		c.Header("X-Cache", result)
+		writer.flush()
+	}
+}
+
+// bypassDirective returns ResultRefresh or ResultBypass when a trusted
+// client asked to skip the cache, or "" to use it normally.
+func (rc *ResponseCache) bypassDirective(c *gin.Context) string {
+	cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+	var directive string
+	switch {
+	case strings.Contains(cacheControl, "no-store"):
+		directive = ResultBypass
+	case strings.Contains(cacheControl, "no-cache"),
+		strings.Contains(strings.ToLower(c.GetHeader("Pragma")), "no-cache"):
+		directive = ResultRefresh
+	default:
+		return ""
+	}
+	if !rc.trusted(c) {
+		return ""
+	}
+	return directive
+}
+
+func (rc *ResponseCache) trusted(c *gin.Context) bool {
+	if secret := rc.bypass.Secret; secret != "" {
+		if presented := c.GetHeader(BypassSecretHeader); presented != "" &&
+			subtle.ConstantTimeCompare([]byte(presented), []byte(secret)) == 1 {
+			return true
+		}
+	}
+	roles := c.GetStringSlice(middleware.ContextKeyRoles)
+	for _, role := range roles {
+		for _, allowed := range rc.bypass.Roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key scopes cache entries to the tenant, the negotiated representation
+// and encodings and the experiment variant so responses never cross
+// tenants, formats, encodings or experiment arms.
+func (rc *ResponseCache) key(c *gin.Context) string {
+	h := sha256.New()
+	h.Write([]byte(c.GetString(middleware.ContextKeyTenantID)))
+	h.Write([]byte{0})
+	h.Write([]byte(c.GetHeader("Accept")))
+	h.Write([]byte{0})
+	h.Write([]byte(normalizeAcceptEncoding(c.GetHeader("Accept-Encoding"))))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Writer.Header().Get(proxy.VariantHeader)))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Request.URL.RequestURI()))
+	return keyPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+func (rc *ResponseCache) get(ctx context.Context, key string) (entry, bool) {
+	defer servertiming.Start(ctx, "cache")()
+	var e entry
+	data, err := rc.redisClient.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			rc.logger.Warn("Response cache read failed", zap.Error(err))
+		}
+		return e, false
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, false
+	}
+	return e, true
+}
+
+func (rc *ResponseCache) store(ctx context.Context, key string, e entry, expiry time.Duration) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := rc.redisClient.Set(ctx, key, data, expiry).Err(); err != nil {
+		rc.logger.Warn("Response cache write failed", zap.Error(err))
+	}
+}
+
+// resetHeader replaces h's contents with saved's.
+func resetHeader(h, saved http.Header) {
+	for name := range h {
+		delete(h, name)
+	}
+	for name, values := range saved {
+		h[name] = values
+	}
+}
+
+func (rc *ResponseCache) serve(c *gin.Context, e entry, result string) {
+	metrics.CacheResults.WithLabelValues(result).Inc()
+	c.Header("X-Cache", result)
+	c.Header("Age", strconv.Itoa(int(time.Since(e.StoredAt)/time.Second)))
+	if e.ContentEncoding != "" {
+		c.Header("Content-Encoding", e.ContentEncoding)
+	}
+	httpheader.AddVary(c.Writer.Header(), e.Vary)
+	rc.addVary(c.Writer.Header())
+	c.Data(e.Status, e.ContentType, e.Body)
+	c.Abort()
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const testBody = `{"status":"ok"}`
+
+// newTestRouter serves a cached route whose handler gzips its body for
+// clients that accept it, as a compressing backend would.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	routes := config.RouteTable{
+		config.RouteKey(http.MethodGet, "/things"): {Cache: &config.CachePolicy{TTL: 60}},
+	}
+	rc := NewResponseCache(client, routes, config.CacheBypassConfig{}, config.TenantConfig{}, zap.NewNop())
+
+	router := gin.New()
+	router.Use(rc.Middleware())
+	router.GET("/things", func(c *gin.Context) {
+		if !acceptsEncoding(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Data(http.StatusOK, "application/json", []byte(testBody))
+			return
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(testBody))
+		zw.Close()
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, "application/json", buf.Bytes())
+	})
+	return router
+}
+
+func get(router *gin.Engine, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/things", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareEncodings(t *testing.T) {
+	tests := []struct {
+		name         string
+		storeWith    string
+		fetchWith    string
+		wantCache    string
+		wantEncoding string
+	}{
+		{"gzip then none", "gzip", "", ResultMiss, ""},
+		{"gzip then refused gzip", "gzip", "gzip;q=0", ResultMiss, ""},
+		{"gzip then identity", "gzip", "identity", ResultMiss, ""},
+		{"gzip then gzip", "gzip", "gzip", ResultHit, "gzip"},
+		{"gzip then equivalent list", "gzip, br", "br;q=0.5, gzip", ResultHit, "gzip"},
+		{"none then gzip", "", "gzip", ResultMiss, "gzip"},
+		{"none then none", "", "", ResultHit, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(t)
+			if rec := get(router, tt.storeWith); rec.Header().Get("X-Cache") != ResultMiss {
+				t.Fatalf("first request: X-Cache = %q, want %q", rec.Header().Get("X-Cache"), ResultMiss)
+			}
+
+			rec := get(router, tt.fetchWith)
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if tt.wantEncoding == "" && rec.Body.String() != testBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), testBody)
+			}
+			if vary := strings.Join(rec.Header().Values("Vary"), ","); !strings.Contains(vary, "Accept-Encoding") {
+				t.Errorf("Vary = %q, want it to list Accept-Encoding", vary)
+			}
+		})
+	}
+}
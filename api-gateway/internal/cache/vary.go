@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/httpheader"
+)
+
+// knownEncodings are the content codings, sorted, an entry's key
+// distinguishes.
+// Any other coding a client lists can't change which entry it gets.
+var knownEncodings = []string{"br", "deflate", "gzip", "zstd"}
+
+// tenantHeaders lists the request headers the tenant resolvers read the
+// tenant from. The host resolver's Host is part of the URL already.
+func tenantHeaders(cfg config.TenantConfig) []string {
+	var headers []string
+	for _, resolver := range cfg.Resolvers {
+		switch resolver {
+		case "jwt":
+			headers = append(headers, "Authorization", "Cookie")
+		case "api_key":
+			headers = append(headers, "X-API-Key")
+		case "header":
+			headers = append(headers, cfg.Header)
+		}
+	}
+	return headers
+}
+
+// addVary merges the cache's Vary headers into h, keeping those already
+// set and listing each once.
+func (rc *ResponseCache) addVary(h http.Header) {
+	httpheader.AddVary(h, rc.vary...)
+}
+
+// normalizeAcceptEncoding reduces an Accept-Encoding header to the sorted
+// known codings it accepts, so equivalent headers share cache entries.
+func normalizeAcceptEncoding(header string) string {
+	accepted := make([]string, 0, len(knownEncodings))
+	for _, coding := range knownEncodings {
+		if acceptsEncoding(header, coding) {
+			accepted = append(accepted, coding)
+		}
+	}
+	return strings.Join(accepted, ",")
+}
+
+// acceptsEncoding reports whether a client sending the Accept-Encoding
+// header can decode a body with the given Content-Encoding. Unencoded
+// bodies are always acceptable.
+func acceptsEncoding(header, contentEncoding string) bool {
+	qualities := parseAcceptEncoding(header)
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		if coding == "" || coding == "identity" {
+			continue
+		}
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAcceptEncoding maps each listed coding to its quality value.
+func parseAcceptEncoding(header string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+	return qualities
+}
@@ -1,10 +1,21 @@
 package config
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/secrets"
+
+	"google.golang.org/grpc/codes"
 )
 
 type Config struct {
@@ -16,13 +27,124 @@ type Config struct {
 	RateLimit   RateLimitConfig `mapstructure:"ratelimit"`
 	Observability ObservabilityConfig `mapstructure:"observability"`
 	Metrics     MetricsConfig  `mapstructure:"metrics"`
+	OpenAPI     OpenAPIConfig  `mapstructure:"openapi"`
+	InternalAuth InternalAuthConfig `mapstructure:"internal_auth"`
+	Session     SessionConfig  `mapstructure:"session"`
+	CSRF        CSRFConfig     `mapstructure:"csrf"`
+	Metering    MeteringConfig `mapstructure:"metering"`
+	Audit       AuditConfig    `mapstructure:"audit"`
+	Upload      UploadConfig   `mapstructure:"upload"`
+	Storage     StorageConfig  `mapstructure:"storage"`
+	CacheBypass CacheBypassConfig `mapstructure:"cache_bypass"`
+	Outbound    OutboundConfig `mapstructure:"outbound"`
+	Aggregate   AggregateConfig `mapstructure:"aggregate"`
+	Tenant      TenantConfig   `mapstructure:"tenant"`
+	LoadShed    LoadShedConfig `mapstructure:"load_shed"`
+	Drain       DrainConfig    `mapstructure:"drain"`
+	Warmup      WarmupConfig   `mapstructure:"warmup"`
+	Jobs        JobsConfig     `mapstructure:"jobs"`
+	FaultInjection FaultInjectionConfig `mapstructure:"fault_injection"`
+	Secrets     SecretsConfig  `mapstructure:"secrets"`
+	GeoIP       GeoIPConfig    `mapstructure:"geoip"`
+	Authz       AuthzConfig    `mapstructure:"authz"`
+	Transcoding TranscodingConfig `mapstructure:"transcoding"`
+	Routes      RouteTable     `mapstructure:"-"`
 }
 
 type ServerConfig struct {
 	Port         int    `mapstructure:"port"`
-	ReadTimeout  int    `mapstructure:"read_timeout"`
-	WriteTimeout int    `mapstructure:"write_timeout"`
-	IdleTimeout  int    `mapstructure:"idle_timeout"`
+	// ReadHeaderTimeout bounds reading request headers alone, so slow
+	// clients can't hold connections open; ReadTimeout covers the body too.
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	// RequestTimeout is the default per-request deadline for routes
+	// without their own timeout.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	// HardTimeout is the watchdog ceiling: requests still running after it
+	// are cancelled outright. 0 disables the watchdog.
+	HardTimeout time.Duration `mapstructure:"hard_timeout"`
+	// ClientTimeout lets clients shorten their request's deadline.
+	ClientTimeout ClientTimeoutConfig `mapstructure:"client_timeout"`
+	// WebSocketOrigins allowlists the Origin values accepted on WebSocket
+	// upgrades. Empty means same-origin only.
+	WebSocketOrigins []string `mapstructure:"websocket_origins"`
+	// TokenExpiryWarning is how long before its token expires an opted-in
+	// WebSocket connection is sent a token_expiring message. 0 disables it.
+	TokenExpiryWarning time.Duration `mapstructure:"token_expiry_warning"`
+	// WebSocket tunes the messages written to WebSocket connections.
+	WebSocket WebSocketConfig `mapstructure:"websocket"`
+	// LongPollMaxHold caps how long a long-poll request is held waiting
+	// for events. Long polls get no request deadline, so it must stay
+	// below HardTimeout. LongPollMaxConnections bounds the requests held at
+	// once.
+	LongPollMaxHold        time.Duration `mapstructure:"long_poll_max_hold"`
+	LongPollMaxConnections int           `mapstructure:"long_poll_max_connections"`
+	PathNormalization      PathNormalizationConfig `mapstructure:"path_normalization"`
+	MethodOverride         MethodOverrideConfig    `mapstructure:"method_override"`
+	// ErrorCatalogDir holds translated error message catalogs, one file
+	// per locale. Empty serves English messages only.
+	ErrorCatalogDir string `mapstructure:"error_catalog_dir"`
+	// MaxHeaderCount and MaxHeaderBytes bound the header lines and header
+	// bytes of a request; MaxHeaderBytes also caps what the server reads.
+	MaxHeaderCount int `mapstructure:"max_header_count"`
+	MaxHeaderBytes int `mapstructure:"max_header_bytes"`
+	// ContentTypes are the media types a request body may be sent as on
+	// routes that don't list their own content_types, e.g.
+	// application/json. Empty leaves such routes unchecked.
+	ContentTypes []string `mapstructure:"content_types"`
+	// TrustedProxies are the IPs and CIDRs of the load balancers in front
+	// of the gateway. Only when the peer is one of them is the client IP
+	// taken from ProxyHeaders, so rate limiting, tenant header trust and
+	// logs can't be fooled by a forged X-Forwarded-For. Empty trusts none.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	ProxyHeaders   []string `mapstructure:"proxy_headers"`
+	// OverloadRetryAfter is the Retry-After sent with 503 overload
+	// responses, by X-Overload-Reason (concurrency, shed, circuit_open),
+	// when the protection that refused the request can't tell when to
+	// retry. Reasons left out keep the gateway's defaults.
+	OverloadRetryAfter map[string]time.Duration `mapstructure:"overload_retry_after"`
+	// RouteSwitchRefresh is how often each replica reloads the routes
+	// disabled at runtime, and so how long a change takes to reach them
+	// all.
+	RouteSwitchRefresh time.Duration `mapstructure:"route_switch_refresh"`
+}
+
+// ClientTimeoutConfig honors the X-Request-Timeout header, with which a
+// client asks for a shorter deadline than its route's so it can fail fast
+// and retry elsewhere. The requested timeout is raised to at least Min and
+// capped at Max; it never lengthens the route's own timeout.
+type ClientTimeoutConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Min     time.Duration `mapstructure:"min"`
+	Max     time.Duration `mapstructure:"max"`
+}
+
+// PathNormalizationConfig controls how request paths are mapped onto
+// registered routes before routing. Mode is "redirect" (answer with the
+// canonical path) or "rewrite" (serve the canonical path in place).
+//
+// TrailingSlash applies whether or not the rest is enabled, to paths that
+// differ from a route only by a trailing slash: "redirect", "rewrite", or
+// "off" to leave them to gin, which redirects them itself.
+type PathNormalizationConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	CaseFold        bool   `mapstructure:"case_fold"`
+	CollapseSlashes bool   `mapstructure:"collapse_slashes"`
+	Mode            string `mapstructure:"mode"`
+	TrailingSlash   string `mapstructure:"trailing_slash"`
+}
+
+// MethodOverrideConfig lets clients behind proxies that only pass GET and
+// POST send other methods as a POST with X-HTTP-Method-Override. Only the
+// Allowed methods may be requested. OnGet also honors the header on GET
+// requests, which turns a safe request into an unsafe one; leave it off
+// unless a client can't send POST either.
+type MethodOverrideConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Allowed []string `mapstructure:"allowed"`
+	OnGet   bool     `mapstructure:"on_get"`
 }
 
 type JWTConfig struct {
@@ -30,6 +152,23 @@ type JWTConfig struct {
 	Issuer        string `mapstructure:"issuer"`
 	ExpiryHours   int    `mapstructure:"expiry_hours"`
 	RefreshHours  int    `mapstructure:"refresh_hours"`
+	Introspection []IntrospectionConfig `mapstructure:"introspection"`
+	// ClockSkew is the leeway applied to exp, nbf and iat.
+	ClockSkew time.Duration `mapstructure:"clock_skew"`
+	// Audiences lists the aud values each route group accepts, keyed by
+	// group ("api", "admin", "ws"). A group without entries accepts any.
+	Audiences map[string][]string `mapstructure:"audiences"`
+}
+
+// IntrospectionConfig routes tokens from Issuer to an RFC 7662 introspection
+// endpoint instead of local JWT validation.
+type IntrospectionConfig struct {
+	Issuer       string `mapstructure:"issuer"`
+	Endpoint     string `mapstructure:"endpoint"`
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	CacheTTL     time.Duration `mapstructure:"cache_ttl"`
+	Opaque       bool          `mapstructure:"opaque"`
 }
 
 type RedisConfig struct {
@@ -45,39 +184,692 @@ type ServicesConfig struct {
 	ReportingService    string `mapstructure:"reporting_service"`
 	AuditService        string `mapstructure:"audit_service"`
 	NotificationService string `mapstructure:"notification_service"`
+	// DefaultResponseFormat is "json" or "protobuf", used when a client on a
+	// protobuf-enabled route sends no Accept preference.
+	DefaultResponseFormat string `mapstructure:"default_response_format"`
+	// DeadlineBuffer is kept back from each request's deadline when
+	// calling backends so the response can still be returned.
+	DeadlineBuffer time.Duration `mapstructure:"deadline_buffer"`
+	// ForwardHeaders and StripHeaders override the proxy's default header
+	// allowlist and denylist for headers passed to backends as metadata.
+	// Empty keeps the defaults: request ID, correlation ID, tenant, language
+	// and user agent are forwarded; credentials and cookies are stripped.
+	// Hop-by-hop headers are always stripped.
+	ForwardHeaders []string `mapstructure:"forward_headers"`
+	StripHeaders   []string `mapstructure:"strip_headers"`
+	// GRPC holds per-service client tuning, keyed by service name.
+	GRPC map[string]GRPCClientConfig `mapstructure:"grpc"`
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	RetryBudget    RetryBudgetConfig    `mapstructure:"retry_budget"`
+	// MaxRedirects bounds the redirects followed on calls to HTTP backends;
+	// redirects must stay on the backend's host. Zero follows none.
+	MaxRedirects int `mapstructure:"max_redirects"`
+	// Instances lists, per service, the addresses of individual backend
+	// instances that hold per-connection state. Streaming calls to such a
+	// service go to one of them by consistent hash of the caller, so
+	// reconnects land on the same instance while it is healthy. Unary
+	// calls are spread over the healthy instances, round-robin or, when
+	// routes declare costs, by least outstanding cost. Read from
+	// <SERVICE>_INSTANCES, e.g. SURVEILLANCE_ENGINE_INSTANCES.
+	Instances map[string][]string `mapstructure:"instances"`
+	// StickyKey is what the hash is on: "user" (tenant and user ID) or
+	// "session", a session ID the client sends as X-Session-ID or
+	// ?session_id=, falling back to the user without one.
+	StickyKey string `mapstructure:"sticky_key"`
+	// PinTargets are the instance addresses trusted callers may pin a
+	// request to with X-Upstream-Target. Empty allows every instance in
+	// Instances; entries must be among them.
+	PinTargets []string `mapstructure:"pin_targets"`
+	// HealthCheckTimeout bounds each backend's answer to the admin system
+	// health check.
+	HealthCheckTimeout time.Duration `mapstructure:"health_check_timeout"`
+	// TLS holds, per service, the TLS settings for calls to it, gRPC and
+	// HTTP alike. Services without an entry are called in plaintext. Read
+	// from <SERVICE>_TLS_*, e.g. AUDIT_SERVICE_TLS_PINS; a service with an
+	// entry and a URL must have an https one.
+	TLS map[string]UpstreamTLSConfig `mapstructure:"tls"`
+	// ErrorMap overrides, per gRPC status code, how backend errors are
+	// answered. Codes without an entry keep the proxy's defaults.
+	ErrorMap map[codes.Code]UpstreamErrorMapping `mapstructure:"error_map"`
+}
+
+// UpstreamErrorMapping answers a backend's gRPC status with the HTTP Status
+// and stable error Code given, telling clients whether the request is
+// Retryable.
+type UpstreamErrorMapping struct {
+	Status    int    `mapstructure:"status"`
+	Code      string `mapstructure:"code"`
+	Retryable bool   `mapstructure:"retryable"`
+}
+
+// UpstreamTLSConfig secures calls to one backend. The backend's chain is
+// checked against CAFile (the system roots if empty) for ServerName (the
+// dialed host if empty); CertFile and KeyFile present a client certificate
+// for mTLS. Pins, if set, are "sha256/<base64>" SPKI hashes: the connection
+// is refused unless a certificate on the verified chain has one of them,
+// even when the chain is valid. List the next key's pin alongside the
+// current one before rotating.
+type UpstreamTLSConfig struct {
+	CAFile     string   `mapstructure:"ca_file"`
+	CertFile   string   `mapstructure:"cert_file"`
+	KeyFile    string   `mapstructure:"key_file"`
+	ServerName string   `mapstructure:"server_name"`
+	Pins       []string `mapstructure:"pins"`
+}
+
+// GRPCClientConfig tunes the gateway's gRPC connection to one backend.
+// Message sizes are in bytes.
+//
+// Keepalive pings detect a dead connection within KeepaliveTime plus
+// KeepaliveTimeout, after which grpc-go reconnects using the backoff settings.
+// Readiness checks only observe connection state and never force a reconnect
+// themselves, so the two do not race. Backends must allow pings at least this
+// frequent or they will close the connection with GOAWAY "too_many_pings".
+// grpc-go servers enforce a 5 minute minimum by default, which is why
+// KeepaliveTime defaults to 5 minutes; set it lower only for backends whose
+// keepalive.EnforcementPolicy MinTime permits it.
+type GRPCClientConfig struct {
+	MaxRecvMsgSize int `mapstructure:"max_recv_msg_size"`
+	MaxSendMsgSize int `mapstructure:"max_send_msg_size"`
+
+	KeepaliveTime                time.Duration `mapstructure:"keepalive_time"`
+	KeepaliveTimeout             time.Duration `mapstructure:"keepalive_timeout"`
+	KeepalivePermitWithoutStream bool          `mapstructure:"keepalive_permit_without_stream"`
+
+	BackoffBaseDelay  time.Duration `mapstructure:"backoff_base_delay"`
+	BackoffMaxDelay   time.Duration `mapstructure:"backoff_max_delay"`
+	MinConnectTimeout time.Duration `mapstructure:"min_connect_timeout"`
+
+	// Compression is the gRPC compressor for calls to this backend: "gzip"
+	// or empty for none.
+	Compression string `mapstructure:"compression"`
+
+	// MaxInFlight caps the unary calls in flight to this backend, so a slow
+	// backend can't tie up every gateway worker; zero means no cap. Calls
+	// over the cap wait up to QueueMaxWait for a slot, at most
+	// QueueMaxDepth of them at a time, and otherwise fail fast with a 503.
+	// A zero QueueMaxWait fails them at once.
+	MaxInFlight   int           `mapstructure:"max_in_flight"`
+	QueueMaxWait  time.Duration `mapstructure:"queue_max_wait"`
+	QueueMaxDepth int           `mapstructure:"queue_max_depth"`
+}
+
+// Addresses maps each backend service name to its configured address.
+func (s ServicesConfig) Addresses() map[string]string {
+	return map[string]string{
+		"surveillance-engine":  s.SurveillanceEngine,
+		"user-service":         s.UserService,
+		"compliance-service":   s.ComplianceService,
+		"reporting-service":    s.ReportingService,
+		"audit-service":        s.AuditService,
+		"notification-service": s.NotificationService,
+	}
+}
+
+// validPin reports whether pin is "sha256/" followed by a base64-encoded
+// SHA-256 hash.
+func validPin(pin string) bool {
+	hash, ok := strings.CutPrefix(pin, "sha256/")
+	if !ok {
+		return false
+	}
+	raw, err := base64.StdEncoding.DecodeString(hash)
+	return err == nil && len(raw) == sha256.Size
+}
+
+// InstanceConnName names the gRPC connection for one instance of a service.
+func InstanceConnName(service, address string) string {
+	return service + "#" + address
+}
+
+// CircuitBreakerConfig controls per-backend circuit breaking. A zero
+// FailureThreshold disables it.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// Open is how long an open circuit fails calls fast before letting a
+	// trial call through.
+	Open time.Duration `mapstructure:"open"`
+	// Share tells other replicas when a breaker opens; their breakers for
+	// the backend then open at half the threshold, and retry after half
+	// the open time, for Caution. Breaker states are published every
+	// Heartbeat for the admin API.
+	Share     bool          `mapstructure:"share"`
+	Caution   time.Duration `mapstructure:"caution"`
+	Heartbeat time.Duration `mapstructure:"heartbeat"`
+}
+
+// RetryBudgetConfig caps the backend call retries routes may make: within
+// the last Window, retries may not exceed Ratio of the calls made
+// plus MinPerSecond for every second of the window, so a trickle of
+// traffic can still retry.
+type RetryBudgetConfig struct {
+	Ratio        float64 `mapstructure:"ratio"`
+	MinPerSecond int     `mapstructure:"min_per_second"`
+	Window       time.Duration `mapstructure:"window"`
 }
 
 type RateLimitConfig struct {
 	RequestsPerMinute int `mapstructure:"requests_per_minute"`
 	BurstSize        int `mapstructure:"burst_size"`
+	// QueueMaxWait is how long an over-limit request may wait for a token
+	// before being rejected. Zero rejects immediately.
+	QueueMaxWait  time.Duration `mapstructure:"queue_max_wait"`
+	QueueMaxDepth int           `mapstructure:"queue_max_depth"`
+	// KeyTemplate derives the bucket from token claims, e.g. "{org}:{plan}";
+	// empty limits per user.
+	KeyTemplate string `mapstructure:"key_template"`
+	// Mode is how limits span regions: "local" buckets in each region's
+	// Redis, "global" buckets in the one Redis at GlobalRedisAddress, or
+	// "reconciled" local buckets charged with the admissions of the Peers
+	// (region name to Redis address) every ReconcileInterval.
+	Mode               string            `mapstructure:"mode"`
+	GlobalRedisAddress string            `mapstructure:"global_redis_address"`
+	Region             string            `mapstructure:"region"`
+	Peers              map[string]string `mapstructure:"peers"`
+	ReconcileInterval  time.Duration     `mapstructure:"reconcile_interval"`
+	// RedisPassword authenticates to the global or peer Redis.
+	RedisPassword string `mapstructure:"redis_password"`
+	// Alert notifies ops when a key keeps hitting its limit.
+	Alert RateLimitAlertConfig `mapstructure:"alert"`
+	// Fallback enforces limits from each replica's memory while the
+	// limiter's Redis is unavailable, instead of failing open.
+	Fallback RateLimitFallbackConfig `mapstructure:"fallback"`
+	// Exempt lists the traffic that is never rate limited.
+	Exempt RateLimitExemptConfig `mapstructure:"exempt"`
+}
+
+// RateLimitExemptConfig exempts health checks and internal traffic from
+// rate limiting: requests whose path starts with one of Paths, requests
+// from CIDRs, and, with InternalCallers, requests bearing a valid internal
+// token from a trusted backend service (INTERNAL_TRUSTED_CALLERS).
+type RateLimitExemptConfig struct {
+	Paths           []string `mapstructure:"paths"`
+	CIDRs           []string `mapstructure:"cidrs"`
+	InternalCallers bool     `mapstructure:"internal_callers"`
+}
+
+// RateLimitFallbackConfig sizes the in-memory fallback. Each replica keeps
+// the configured limits multiplied by Scale (set it below 1, e.g. to one
+// over the replica count, to approximate the shared limit) for at most
+// MaxKeys keys.
+type RateLimitFallbackConfig struct {
+	Enabled bool    `mapstructure:"enabled"`
+	Scale   float64 `mapstructure:"scale"`
+	MaxKeys int     `mapstructure:"max_keys"`
+}
+
+// RateLimitAlertConfig raises an alert to the notification service, and an
+// audit event, when one rate-limit key is rejected Threshold times within a
+// Window, at most once per Cooldown for the key. Zero Threshold disables
+// alerting.
+type RateLimitAlertConfig struct {
+	Threshold int           `mapstructure:"threshold"`
+	Window    time.Duration `mapstructure:"window"`
+	Cooldown  time.Duration `mapstructure:"cooldown"`
 }
 
 type ObservabilityConfig struct {
 	JaegerEndpoint string `mapstructure:"jaeger_endpoint"`
+	RequestLog     RequestLogConfig `mapstructure:"request_log"`
+	ServerTiming   ServerTimingConfig `mapstructure:"server_timing"`
+	TraceBodies    TraceBodyConfig    `mapstructure:"trace_bodies"`
+}
+
+// TraceBodyConfig attaches request and response bodies to a SampleRate
+// fraction of the traces that are sampled for export, capped at
+// MaxBodyBytes each and redacted like the request log's. Paths under
+// ExcludePrefixes never carry bodies; auth endpoints never do, whatever it
+// says.
+type TraceBodyConfig struct {
+	SampleRate      float64  `mapstructure:"sample_rate"`
+	MaxBodyBytes    int      `mapstructure:"max_body_bytes"`
+	ExcludePrefixes []string `mapstructure:"exclude_prefixes"`
+}
+
+// ServerTimingConfig controls the Server-Timing response header. Paths
+// under ExcludePrefixes never get it.
+type ServerTimingConfig struct {
+	Enabled         bool     `mapstructure:"enabled"`
+	ExcludePrefixes []string `mapstructure:"exclude_prefixes"`
+}
+
+// RequestLogConfig controls detailed request logging. Requests that fail
+// with a 5xx or take at least SlowThreshold are always logged
+// with their (redacted) headers and bodies; SampleRate of the rest are too.
+// Bodies are captured up to MaxBodyBytes. RedactFields are JSON body and
+// query parameter names whose values are masked, on top of the built-in
+// credential fields.
+type RequestLogConfig struct {
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"`
+	SampleRate    float64       `mapstructure:"sample_rate"`
+	MaxBodyBytes  int           `mapstructure:"max_body_bytes"`
+	RedactFields  []string      `mapstructure:"redact_fields"`
 }
 
 type MetricsConfig struct {
 	Port int `mapstructure:"port"`
+	// TenantLabels breaks request counts down by tenant.
+	TenantLabels TenantLabelsConfig `mapstructure:"tenant_labels"`
+}
+
+// TenantLabelsConfig bounds the tenant label on per-tenant metrics. Every
+// distinct label is a set of series in every scrape, kept for the life of
+// the process, so the label is capped: Tenants are always labelled, and up
+// to MaxTenants others get labels first come, first served, the rest
+// counting under "other". Which tenants win the unlisted slots depends on
+// traffic since each replica started, so it differs between replicas and
+// across restarts; dashboards that need a tenant reliably should list it.
+// Totals stay accurate either way.
+type TenantLabelsConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	MaxTenants int      `mapstructure:"max_tenants"`
+	Tenants    []string `mapstructure:"tenants"`
+}
+
+// TranscodingConfig controls how backend proto messages are rendered as JSON.
+// The fields mirror protojson.MarshalOptions.
+type TranscodingConfig struct {
+	UseProtoNames   bool `mapstructure:"use_proto_names"`
+	EmitUnpopulated bool `mapstructure:"emit_unpopulated"`
+	UseEnumNumbers  bool `mapstructure:"use_enum_numbers"`
+}
+
+// InternalAuthConfig configures the tokens the gateway signs to identify
+// itself, and the end user, to backends.
+type InternalAuthConfig struct {
+	// KeyFile is an Ed25519 PKCS#8 PEM key. Replacing the file rotates the key.
+	KeyFile        string        `mapstructure:"key_file"`
+	TokenTTL       time.Duration `mapstructure:"token_ttl"`
+	KeyRetention   time.Duration `mapstructure:"key_retention"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// TrustedCallers maps internal services allowed to call the gateway
+	// with their own internal tokens, e.g. to pin an upstream instance, to
+	// the Ed25519 PKIX PEM public key file their tokens verify against.
+	TrustedCallers map[string]string `mapstructure:"trusted_callers"`
+}
+
+// SessionConfig controls cookie-based sessions for browser clients. Client
+// types listed in CookieClientTypes receive their tokens as HttpOnly cookies
+// at login; all other clients keep using the Authorization header.
+type SessionConfig struct {
+	CookieClientTypes []string `mapstructure:"cookie_client_types"`
+	CookieName        string   `mapstructure:"cookie_name"`
+	RefreshCookieName string   `mapstructure:"refresh_cookie_name"`
+	RefreshPath       string   `mapstructure:"refresh_path"`
+	LogoutPath        string   `mapstructure:"logout_path"`
+	Domain            string   `mapstructure:"domain"`
+	Secure            bool     `mapstructure:"secure"`
+	// SameSite is "strict", "lax" or "none".
+	SameSite      string `mapstructure:"same_site"`
+	MaxAge        int    `mapstructure:"max_age"`
+	RefreshMaxAge int    `mapstructure:"refresh_max_age"`
+	// MaxActive limits each user's concurrent sessions; zero is unlimited.
+	// LimitPolicy is what a login beyond it does: "reject" it, or
+	// "evict_oldest" to sign the oldest session out.
+	MaxActive   int    `mapstructure:"max_active"`
+	LimitPolicy string `mapstructure:"limit_policy"`
+}
+
+// UsesCookies reports whether clientType gets cookie sessions.
+func (s SessionConfig) UsesCookies(clientType string) bool {
+	for _, t := range s.CookieClientTypes {
+		if strings.EqualFold(t, clientType) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFConfig names the double-submit token cookie and the header clients
+// echo it in.
+type CSRFConfig struct {
+	CookieName string `mapstructure:"cookie_name"`
+	HeaderName string `mapstructure:"header_name"`
+}
+
+// AuditConfig lists what is redacted from audit events before they are
+// sent, besides the audit package's default sensitive field names:
+// RedactFields are field names matched at any depth, RedactPaths JSON paths
+// from the event root such as "$.new_values.keys[*].value".
+type AuditConfig struct {
+	RedactFields []string `mapstructure:"redact_fields"`
+	RedactPaths  []string `mapstructure:"redact_paths"`
+}
+
+// MeteringConfig controls per-API-key usage counting for billing.
+type MeteringConfig struct {
+	// Period is "hourly", "daily" or "monthly"; counters roll over with it.
+	Period string `mapstructure:"period"`
+	// RetentionDays keeps a finished period's counters around for export.
+	RetentionDays int `mapstructure:"retention_days"`
+}
+
+// UploadConfig sets the default limits for multipart file uploads.
+type UploadConfig struct {
+	MaxBytes     int64    `mapstructure:"max_bytes"`
+	AllowedTypes []string `mapstructure:"allowed_types"`
+	// ScannerAddress is a clamd host:port. Empty disables virus scanning.
+	ScannerAddress string `mapstructure:"scanner_address"`
+	ScanTimeout time.Duration `mapstructure:"scan_timeout"`
+	// ResumableDir stages resumable uploads; replicas must share it.
+	ResumableDir string `mapstructure:"resumable_dir"`
+	// ResumableTTL is how long a resumable upload may take to finish
+	// before it expires.
+	ResumableTTL time.Duration `mapstructure:"resumable_ttl"`
+}
+
+// StorageConfig enables signed direct-download URLs for files kept in
+// S3-compatible object storage. Without an Endpoint, downloads are proxied.
+type StorageConfig struct {
+	Endpoint        string `mapstructure:"endpoint"`
+	Region          string `mapstructure:"region"`
+	Bucket          string `mapstructure:"bucket"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	// URLTTL is how long a signed download URL stays valid.
+	URLTTL time.Duration `mapstructure:"url_ttl"`
+	// FileServiceURL is where file permissions and locations are looked up.
+	FileServiceURL string `mapstructure:"file_service_url"`
+}
+
+// CacheBypassConfig decides which clients may skip the response cache with
+// Cache-Control: no-cache or no-store: holders of one of Roles, or callers
+// presenting Secret. An empty Secret disables the secret.
+type CacheBypassConfig struct {
+	Roles  []string `mapstructure:"roles"`
+	Secret string   `mapstructure:"secret"`
+}
+
+// OutboundConfig allowlists internal destinations for outbound HTTP, which
+// otherwise may not reach loopback, private or link-local addresses. The
+// configured backend service hosts are always allowed.
+type OutboundConfig struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+	AllowedCIDRs []string `mapstructure:"allowed_cidrs"`
+}
+
+// TenantConfig sets how the request's tenant is resolved. Resolvers are
+// tried in order, the first to produce a tenant winning: "jwt" (the token's
+// tenant_id claim), "api_key" (the tenant the X-API-Key is mapped to, for
+// requests whose token names none), "header" (TenantHeader, believed only
+// from TrustedHeaderCIDRs) and "host" (the tenant the request's Host is
+// mapped to). Required rejects requests no resolver could place; routes may
+// override it.
+type TenantConfig struct {
+	Resolvers          []string `mapstructure:"resolvers"`
+	Header             string   `mapstructure:"header"`
+	TrustedHeaderCIDRs []string `mapstructure:"trusted_header_cidrs"`
+	Required           bool     `mapstructure:"required"`
+	// Hosts maps hostnames, or "*.example.com" wildcards, to tenants, ahead
+	// of the mappings kept in Redis. SharedHosts are served to every tenant
+	// and resolve none.
+	Hosts       map[string]string `mapstructure:"hosts"`
+	SharedHosts []string          `mapstructure:"shared_hosts"`
+}
+
+// AuthzConfig points the gateway at an Open Policy Agent decision. With
+// PolicyURL empty no policy is consulted. Mode "layer" applies the policy
+// on top of the gateway's role checks; "replace" drops the role checks and
+// leaves authorization to the policy. CacheTTL is how long a decision is
+// reused.
+//
+// ResourceURLs maps each resource type routes may declare to the URL its
+// attributes are looked up at, with {id} standing for the resource ID;
+// lookups are cached for ResourceCacheTTL.
+type AuthzConfig struct {
+	PolicyURL string `mapstructure:"policy_url"`
+	Mode      string `mapstructure:"mode"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	CacheTTL  time.Duration `mapstructure:"cache_ttl"`
+
+	ResourceURLs     map[string]string `mapstructure:"resource_urls"`
+	ResourceCacheTTL time.Duration     `mapstructure:"resource_cache_ttl"`
+}
+
+// LoadShedConfig sets when the gateway sheds load. Each signal names the
+// level at which shedding starts and the level at which it is total; a
+// zero start disables the signal. GC is the percentage of CPU time spent
+// in garbage collection. Requests whose path starts with one of
+// ExemptPaths, health and readiness checks by default, are never shed, so
+// an overloaded replica isn't restarted for failing its probes.
+type LoadShedConfig struct {
+	GoroutinesStart int `mapstructure:"goroutines_start"`
+	GoroutinesFull  int `mapstructure:"goroutines_full"`
+	HeapStartMB     int `mapstructure:"heap_start_mb"`
+	HeapFullMB      int `mapstructure:"heap_full_mb"`
+	GCPercentStart  int `mapstructure:"gc_percent_start"`
+	GCPercentFull   int `mapstructure:"gc_percent_full"`
+
+	ExemptPaths []string `mapstructure:"exempt_paths"`
+}
+
+// Enabled reports whether any signal is configured.
+func (c LoadShedConfig) Enabled() bool {
+	return c.GoroutinesStart > 0 || c.HeapStartMB > 0 || c.GCPercentStart > 0
+}
+
+// DrainConfig coordinates shutdowns across replicas through Redis. A
+// replica shutting down waits until MinHealthy other replicas are
+// heartbeating, but no longer than MaxWait. Heartbeat is the heartbeat
+// interval; a replica is presumed gone after three missed beats.
+type DrainConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	MinHealthy int           `mapstructure:"min_healthy"`
+	MaxWait    time.Duration `mapstructure:"max_wait"`
+	Heartbeat  time.Duration `mapstructure:"heartbeat"`
+}
+
+// WarmupConfig readies a replica before traffic: connect every backend
+// within Timeout. With OnStartup it runs at startup and the readiness
+// check fails until it has finished; it can also be run from the admin
+// API.
+type WarmupConfig struct {
+	OnStartup bool          `mapstructure:"on_startup"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// JobsConfig bounds async jobs: each runs for at most Timeout and its
+// record, result included, is kept for Retention after it finishes.
+// Completion webhooks are signed with WebhookSecret when it is set and given
+// WebhookTimeout per attempt.
+type JobsConfig struct {
+	Timeout        time.Duration `mapstructure:"timeout"`
+	Retention      time.Duration `mapstructure:"retention"`
+	WebhookSecret  string        `mapstructure:"webhook_secret"`
+	WebhookTimeout time.Duration `mapstructure:"webhook_timeout"`
+}
+
+// WebSocketConfig tunes WebSocket connections. Msgpack lets clients select
+// msgpack-encoded binary messages by offering the dharmaguard.msgpack
+// subprotocol; everyone else gets JSON text messages.
+//
+// Compression offers the permessage-deflate extension on upgrades. Messages
+// of at least CompressionThreshold bytes are then compressed at
+// CompressionLevel, from 1 (fastest) to 9 (smallest); smaller ones aren't
+// worth the CPU.
+//
+// A client message larger than MaxMessageBytes closes the connection with
+// 1009 (message too big). Clients may send MessageRate messages a second,
+// in bursts of up to MessageBurst; one sending faster is disconnected with
+// 1008 (policy violation). A zero MessageRate leaves inbound messages
+// unlimited.
+type WebSocketConfig struct {
+	Msgpack bool `mapstructure:"msgpack"`
+
+	Compression          bool `mapstructure:"compression"`
+	CompressionLevel     int  `mapstructure:"compression_level"`
+	CompressionThreshold int  `mapstructure:"compression_threshold"`
+
+	MaxMessageBytes int     `mapstructure:"max_message_bytes"`
+	MessageRate     float64 `mapstructure:"message_rate"`
+	MessageBurst    int     `mapstructure:"message_burst"`
+}
+
+// FaultInjectionConfig turns on the faults routes declare in the routes
+// file, for exercising circuit breakers, retries and timeouts in staging.
+// Faults only reach verified internal callers and super admins. Injected
+// failures count against the backend's circuit breaker like real ones, so
+// enough of them open it for every caller. Enabling it in production also
+// takes AllowProduction. Header, if set, further limits faults to requests
+// that carry it, so only test traffic sees them.
+type FaultInjectionConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	AllowProduction bool   `mapstructure:"allow_production"`
+	Header          string `mapstructure:"header"`
+}
+
+// SecretsConfig selects where secrets such as JWT_SECRET come from besides
+// JWT_SECRET_FILE and the environment. Provider is empty or "vault"; Vault
+// is read at VaultAddress from the KV secret at VaultPath, authenticating
+// with VAULT_TOKEN (or VAULT_TOKEN_FILE). ReloadInterval is how often the
+// JWT secret is re-read to pick up rotations.
+type SecretsConfig struct {
+	Provider       string        `mapstructure:"provider"`
+	VaultAddress   string        `mapstructure:"vault_address"`
+	VaultPath      string        `mapstructure:"vault_path"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// Loader returns the secret loader the config describes.
+func (c SecretsConfig) Loader() (*secrets.Loader, error) {
+	switch c.Provider {
+	case "":
+		return secrets.NewLoader(nil), nil
+	case "vault":
+		token, ok, err := secrets.NewLoader(nil).Lookup(context.Background(), "VAULT_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+		if !ok || c.VaultAddress == "" || c.VaultPath == "" {
+			return nil, fmt.Errorf("SECRETS_PROVIDER=vault needs VAULT_ADDR, VAULT_SECRET_PATH and VAULT_TOKEN")
+		}
+		return secrets.NewLoader(secrets.NewVault(c.VaultAddress, token, c.VaultPath, 5*time.Second)), nil
+	default:
+		return nil, fmt.Errorf("SECRETS_PROVIDER must be empty or vault, got %q", c.Provider)
+	}
+}
+
+// AggregateConfig bounds the sub-requests of aggregate endpoints such as
+// the dashboard. A request's complexity is the summed route cost of its
+// sub-requests (1 for routes without one); MaxCost caps it, or PlanMaxCost
+// for callers whose token's plan claim is listed. Zero is unlimited.
+type AggregateConfig struct {
+	SectionTimeout time.Duration      `mapstructure:"section_timeout"`
+	MaxCost        float64            `mapstructure:"max_cost"`
+	PlanMaxCost    map[string]float64 `mapstructure:"plan_max_cost"`
+}
+
+// Budget returns the complexity budget for a caller on plan.
+func (a AggregateConfig) Budget(plan string) float64 {
+	if budget, ok := a.PlanMaxCost[plan]; ok {
+		return budget
+	}
+	return a.MaxCost
+}
+
+// GeoIPConfig turns on looking up each client IP's country and autonomous
+// system, which backends receive as metadata. CountryDB is a MaxMind
+// GeoIP2 or GeoLite2 Country or City database and ASNDB an ASN database;
+// either may be left empty. Both are polled every ReloadInterval and
+// reloaded when they change.
+type GeoIPConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	CountryDB      string        `mapstructure:"country_db"`
+	ASNDB          string        `mapstructure:"asn_db"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+type OpenAPIConfig struct {
+	SpecPath       string        `mapstructure:"spec_path"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// RequireDocumented fails startup when an API route is missing from
+	// the spec, so CI catches drift.
+	RequireDocumented bool `mapstructure:"require_documented"`
+	// ResponseSampleRate is the fraction of responses validated against
+	// the spec before they are sent; 0 disables response validation.
+	ResponseSampleRate float64 `mapstructure:"response_sample_rate"`
 }
 
 func LoadConfig() (*Config, error) {
+	envErrors = nil
+	secretsCfg := SecretsConfig{
+		Provider:       getEnvString("SECRETS_PROVIDER", ""),
+		VaultAddress:   getEnvString("VAULT_ADDR", ""),
+		VaultPath:      getEnvString("VAULT_SECRET_PATH", ""),
+		ReloadInterval: getEnvDuration("SECRETS_RELOAD_INTERVAL", 30*time.Second, time.Second),
+	}
+	var err error
+	if secretLoader, err = secretsCfg.Loader(); err != nil {
+		return nil, err
+	}
+	environment := getEnvString("ENVIRONMENT", "development")
+	production := environment == "production"
 	cfg := &Config{
-		Environment: getEnvString("ENVIRONMENT", "development"),
+		Environment: environment,
 		Server: ServerConfig{
 			Port:         getEnvInt("PORT", 8080),
-			ReadTimeout:  getEnvInt("READ_TIMEOUT", 15),
-			WriteTimeout: getEnvInt("WRITE_TIMEOUT", 15),
-			IdleTimeout:  getEnvInt("IDLE_TIMEOUT", 60),
+			ReadTimeout:       getEnvDuration("READ_TIMEOUT", 15*time.Second, time.Second),
+			ReadHeaderTimeout: getEnvDuration("READ_HEADER_TIMEOUT", 5*time.Second, time.Second),
+			WriteTimeout:      getEnvDuration("WRITE_TIMEOUT", 15*time.Second, time.Second),
+			IdleTimeout:       getEnvDuration("IDLE_TIMEOUT", 60*time.Second, time.Second),
+			RequestTimeout:    getEnvDuration("REQUEST_TIMEOUT", 10*time.Second, time.Second),
+			HardTimeout:       getEnvDuration("REQUEST_HARD_TIMEOUT", 60*time.Second, time.Second),
+			ClientTimeout: ClientTimeoutConfig{
+				Enabled: getEnvBool("REQUEST_TIMEOUT_HEADER_ENABLED", true),
+				Min:     getEnvDuration("REQUEST_TIMEOUT_HEADER_MIN_MS", 100*time.Millisecond, time.Millisecond),
+				Max:     getEnvDuration("REQUEST_TIMEOUT_HEADER_MAX_MS", 30*time.Second, time.Millisecond),
+			},
+			WebSocketOrigins: getEnvList("WEBSOCKET_ALLOWED_ORIGINS", nil),
+			TokenExpiryWarning: getEnvDuration("WEBSOCKET_TOKEN_EXPIRY_WARNING", 5*time.Minute, time.Second),
+			WebSocket: WebSocketConfig{
+				Msgpack: getEnvBool("WEBSOCKET_MSGPACK_ENABLED", true),
+
+				Compression:          getEnvBool("WEBSOCKET_COMPRESSION_ENABLED", false),
+				CompressionLevel:     getEnvInt("WEBSOCKET_COMPRESSION_LEVEL", 1),
+				CompressionThreshold: getEnvBytes("WEBSOCKET_COMPRESSION_THRESHOLD", 512),
+
+				MaxMessageBytes: getEnvBytes("WEBSOCKET_MAX_MESSAGE_BYTES", 64<<10),
+				MessageRate:     getEnvFloat("WEBSOCKET_MESSAGE_RATE", 20),
+				MessageBurst:    getEnvInt("WEBSOCKET_MESSAGE_BURST", 40),
+			},
+			LongPollMaxHold:        getEnvDuration("LONG_POLL_MAX_HOLD", 25*time.Second, time.Second),
+			LongPollMaxConnections: getEnvInt("LONG_POLL_MAX_CONNECTIONS", 1000),
+			RouteSwitchRefresh:     getEnvDuration("ROUTE_SWITCH_REFRESH_INTERVAL", 5*time.Second, time.Second),
+			MaxHeaderCount: getEnvInt("MAX_HEADER_COUNT", 100),
+			MaxHeaderBytes: getEnvBytes("MAX_HEADER_BYTES", 64<<10),
+			ContentTypes:   getEnvList("REQUEST_CONTENT_TYPES", nil),
+			TrustedProxies: getEnvList("TRUSTED_PROXIES", nil),
+			ProxyHeaders:   getEnvList("TRUSTED_PROXY_HEADERS", []string{"X-Forwarded-For", "X-Real-IP"}),
+			PathNormalization: PathNormalizationConfig{
+				Enabled:         getEnvBool("PATH_NORMALIZE", false),
+				CaseFold:        getEnvBool("PATH_NORMALIZE_CASE", true),
+				CollapseSlashes: getEnvBool("PATH_NORMALIZE_COLLAPSE_SLASHES", true),
+				Mode:            getEnvString("PATH_NORMALIZE_MODE", "redirect"),
+				TrailingSlash:   strings.ToLower(getEnvString("PATH_TRAILING_SLASH", "rewrite")),
+			},
+			ErrorCatalogDir: getEnvString("ERROR_CATALOG_DIR", ""),
+			MethodOverride: MethodOverrideConfig{
+				Enabled: getEnvBool("METHOD_OVERRIDE_ENABLED", false),
+				Allowed: getEnvList("METHOD_OVERRIDE_ALLOWED", []string{"PUT", "PATCH", "DELETE"}),
+				OnGet:   getEnvBool("METHOD_OVERRIDE_ON_GET", false),
+			},
 		},
 		JWT: JWTConfig{
-			Secret:       getEnvString("JWT_SECRET", "your-secret-key"),
+			Secret:       getRequiredSecret("JWT_SECRET", "your-secret-key", production),
 			Issuer:       getEnvString("JWT_ISSUER", "dharmaguard"),
 			ExpiryHours:  getEnvInt("JWT_EXPIRY_HOURS", 24),
 			RefreshHours: getEnvInt("JWT_REFRESH_HOURS", 168),
+			Introspection: loadIntrospectionConfig(),
+			ClockSkew:     getEnvDuration("JWT_CLOCK_SKEW_SECONDS", 30*time.Second, time.Second),
+			Audiences: map[string][]string{
+				"api":   getEnvList("JWT_AUDIENCES_API", nil),
+				"admin": getEnvList("JWT_AUDIENCES_ADMIN", nil),
+				"ws":    getEnvList("JWT_AUDIENCES_WS", nil),
+			},
 		},
 		Redis: RedisConfig{
 			Address:  getEnvString("REDIS_URL", "localhost:6379"),
-			Password: getEnvString("REDIS_PASSWORD", ""),
+			Password: getEnvSecret("REDIS_PASSWORD", ""),
 			DB:       getEnvInt("REDIS_DB", 0),
 		},
 		Services: ServicesConfig{
@@ -87,27 +879,741 @@ func LoadConfig() (*Config, error) {
 			ReportingService:   getEnvString("REPORTING_SERVICE_URL", "http://localhost:8083"),
 			AuditService:       getEnvString("AUDIT_SERVICE_URL", "http://localhost:8084"),
 			NotificationService: getEnvString("NOTIFICATION_SERVICE_URL", "http://localhost:8085"),
+			DefaultResponseFormat: getEnvString("DEFAULT_RESPONSE_FORMAT", "json"),
+			DeadlineBuffer:        getEnvDuration("DEADLINE_BUFFER_MS", 50*time.Millisecond, time.Millisecond),
+			ForwardHeaders:        getEnvList("PROXY_FORWARD_HEADERS", nil),
+			StripHeaders:          getEnvList("PROXY_STRIP_HEADERS", nil),
+			MaxRedirects:          getEnvInt("UPSTREAM_MAX_REDIRECTS", 3),
+			StickyKey:             strings.ToLower(getEnvString("STICKY_ROUTING_KEY", "user")),
+			PinTargets:            getEnvList("UPSTREAM_PIN_TARGETS", nil),
+			HealthCheckTimeout:    getEnvDuration("BACKEND_HEALTH_TIMEOUT", 2*time.Second, time.Second),
+			CircuitBreaker: CircuitBreakerConfig{
+				FailureThreshold: getEnvInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5),
+				Open:             getEnvDuration("CIRCUIT_BREAKER_OPEN_SECONDS", 30*time.Second, time.Second),
+				Share:            getEnvBool("CIRCUIT_BREAKER_SHARE", false),
+				Caution:          getEnvDuration("CIRCUIT_BREAKER_CAUTION_SECONDS", 60*time.Second, time.Second),
+				Heartbeat:        getEnvDuration("CIRCUIT_BREAKER_HEARTBEAT_SECONDS", 5*time.Second, time.Second),
+			},
+			RetryBudget: RetryBudgetConfig{
+				Ratio:        getEnvFloat("RETRY_BUDGET_RATIO", 0.1),
+				MinPerSecond: getEnvInt("RETRY_BUDGET_MIN_PER_SECOND", 10),
+				Window:       getEnvDuration("RETRY_BUDGET_WINDOW", 10*time.Second, time.Second),
+			},
 		},
 		RateLimit: RateLimitConfig{
 			RequestsPerMinute: getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 1000),
 			BurstSize:        getEnvInt("RATE_LIMIT_BURST_SIZE", 100),
+			QueueMaxWait:     getEnvDuration("RATE_LIMIT_QUEUE_MAX_WAIT_MS", 0, time.Millisecond),
+			QueueMaxDepth:    getEnvInt("RATE_LIMIT_QUEUE_MAX_DEPTH", 1000),
+			KeyTemplate:      getEnvString("RATE_LIMIT_KEY_TEMPLATE", ""),
+			Mode:               getEnvString("RATE_LIMIT_MODE", "local"),
+			GlobalRedisAddress: getEnvString("RATE_LIMIT_GLOBAL_REDIS_URL", ""),
+			Region:             getEnvString("RATE_LIMIT_REGION", ""),
+			ReconcileInterval:  getEnvDuration("RATE_LIMIT_RECONCILE_INTERVAL", time.Second, time.Millisecond),
+			RedisPassword:      getEnvSecret("RATE_LIMIT_REDIS_PASSWORD", ""),
+			Fallback: RateLimitFallbackConfig{
+				Enabled: getEnvBool("RATE_LIMIT_FALLBACK_ENABLED", false),
+				Scale:   getEnvFloat("RATE_LIMIT_FALLBACK_SCALE", 1),
+				MaxKeys: getEnvInt("RATE_LIMIT_FALLBACK_MAX_KEYS", 100000),
+			},
+			Exempt: RateLimitExemptConfig{
+				Paths:           getEnvList("RATE_LIMIT_EXEMPT_PATHS", []string{"/health", "/ready", "/metrics", "/api/v1/admin/system/health"}),
+				CIDRs:           getEnvList("RATE_LIMIT_EXEMPT_CIDRS", nil),
+				InternalCallers: getEnvBool("RATE_LIMIT_EXEMPT_INTERNAL_CALLERS", true),
+			},
+			Alert: RateLimitAlertConfig{
+				Threshold: getEnvInt("RATE_LIMIT_ALERT_THRESHOLD", 0),
+				Window:    getEnvDuration("RATE_LIMIT_ALERT_WINDOW", 5*time.Minute, time.Second),
+				Cooldown:  getEnvDuration("RATE_LIMIT_ALERT_COOLDOWN", time.Hour, time.Second),
+			},
 		},
 		Observability: ObservabilityConfig{
 			JaegerEndpoint: getEnvString("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
+			RequestLog: RequestLogConfig{
+				SlowThreshold: getEnvDuration("REQUEST_LOG_SLOW_MS", time.Second, time.Millisecond),
+				SampleRate:    getEnvFloat("REQUEST_LOG_SAMPLE_RATE", 0.001),
+				MaxBodyBytes:  getEnvBytes("REQUEST_LOG_MAX_BODY_BYTES", 4096),
+				RedactFields:  getEnvList("REQUEST_LOG_REDACT_FIELDS", nil),
+			},
+			ServerTiming: ServerTimingConfig{
+				Enabled:         getEnvBool("SERVER_TIMING_ENABLED", false),
+				ExcludePrefixes: getEnvList("SERVER_TIMING_EXCLUDE", []string{"/api/v1/auth"}),
+			},
+			TraceBodies: TraceBodyConfig{
+				SampleRate:      getEnvFloat("TRACE_BODY_SAMPLE_RATE", 0),
+				MaxBodyBytes:    getEnvBytes("TRACE_BODY_MAX_BYTES", 2048),
+				ExcludePrefixes: getEnvList("TRACE_BODY_EXCLUDE", nil),
+			},
 		},
 		Metrics: MetricsConfig{
 			Port: getEnvInt("METRICS_PORT", 9090),
+			TenantLabels: TenantLabelsConfig{
+				Enabled:    getEnvBool("METRICS_TENANT_LABELS_ENABLED", false),
+				MaxTenants: getEnvInt("METRICS_TENANT_LABELS_MAX", 50),
+				Tenants:    getEnvList("METRICS_TENANT_LABELS_TENANTS", nil),
+			},
+		},
+		Transcoding: TranscodingConfig{
+			UseProtoNames:   getEnvBool("JSON_USE_PROTO_NAMES", false),
+			EmitUnpopulated: getEnvBool("JSON_EMIT_UNPOPULATED", false),
+			UseEnumNumbers:  getEnvBool("JSON_USE_ENUM_NUMBERS", false),
+		},
+		InternalAuth: InternalAuthConfig{
+			KeyFile:        getEnvString("INTERNAL_TOKEN_KEY_FILE", ""),
+			TokenTTL:       getEnvDuration("INTERNAL_TOKEN_TTL", time.Minute, time.Second),
+			KeyRetention:   getEnvDuration("INTERNAL_TOKEN_KEY_RETENTION", time.Hour, time.Second),
+			ReloadInterval: getEnvDuration("INTERNAL_TOKEN_RELOAD_INTERVAL", 30*time.Second, time.Second),
+		},
+		Session: SessionConfig{
+			CookieClientTypes: getEnvList("SESSION_COOKIE_CLIENT_TYPES", nil),
+			CookieName:        getEnvString("SESSION_COOKIE_NAME", "dg_session"),
+			RefreshCookieName: getEnvString("SESSION_REFRESH_COOKIE_NAME", "dg_refresh"),
+			RefreshPath:       "/api/v1/auth",
+			LogoutPath:        "/api/v1/auth/logout",
+			Domain:            getEnvString("SESSION_COOKIE_DOMAIN", ""),
+			Secure:            getEnvBool("SESSION_COOKIE_SECURE", true),
+			SameSite:          strings.ToLower(getEnvString("SESSION_COOKIE_SAMESITE", "strict")),
+			MaxAge:            getEnvInt("JWT_EXPIRY_HOURS", 24) * 3600,
+			RefreshMaxAge:     getEnvInt("JWT_REFRESH_HOURS", 168) * 3600,
+			MaxActive:         getEnvInt("SESSION_MAX_ACTIVE", 0),
+			LimitPolicy:       strings.ToLower(getEnvString("SESSION_LIMIT_POLICY", "reject")),
+		},
+		CSRF: CSRFConfig{
+			CookieName: getEnvString("CSRF_COOKIE_NAME", "dg_csrf"),
+			HeaderName: getEnvString("CSRF_HEADER_NAME", "X-CSRF-Token"),
+		},
+		Metering: MeteringConfig{
+			Period:        strings.ToLower(getEnvString("METERING_PERIOD", "monthly")),
+			RetentionDays: getEnvInt("METERING_RETENTION_DAYS", 35),
+		},
+		Audit: AuditConfig{
+			RedactFields: getEnvList("AUDIT_REDACT_FIELDS", nil),
+			RedactPaths:  getEnvList("AUDIT_REDACT_PATHS", nil),
+		},
+		Upload: UploadConfig{
+			MaxBytes: int64(getEnvBytes("UPLOAD_MAX_BYTES", 50<<20)),
+			AllowedTypes: getEnvList("UPLOAD_ALLOWED_TYPES", []string{
+				"application/pdf",
+				"image/png",
+				"image/jpeg",
+				"text/csv",
+				"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			}),
+			ScannerAddress: getEnvString("UPLOAD_SCANNER_ADDRESS", ""),
+			ScanTimeout:    getEnvDuration("UPLOAD_SCAN_TIMEOUT", 30*time.Second, time.Second),
+			ResumableDir:   getEnvString("UPLOAD_RESUMABLE_DIR", ""),
+			ResumableTTL:   getEnvDuration("UPLOAD_RESUMABLE_TTL", 24*time.Hour, time.Second),
+		},
+		Storage: StorageConfig{
+			Endpoint:        getEnvString("OBJECT_STORAGE_ENDPOINT", ""),
+			Region:          getEnvString("OBJECT_STORAGE_REGION", "us-east-1"),
+			Bucket:          getEnvString("OBJECT_STORAGE_BUCKET", ""),
+			AccessKeyID:     getEnvString("OBJECT_STORAGE_ACCESS_KEY_ID", ""),
+			SecretAccessKey: getEnvSecret("OBJECT_STORAGE_SECRET_ACCESS_KEY", ""),
+			URLTTL:          getEnvDuration("SIGNED_URL_TTL", 5*time.Minute, time.Second),
+			FileServiceURL:  getEnvString("FILE_SERVICE_URL", getEnvString("COMPLIANCE_SERVICE_URL", "http://localhost:8082")+"/files"),
+		},
+		CacheBypass: CacheBypassConfig{
+			Roles:  getEnvList("CACHE_BYPASS_ROLES", []string{"SUPER_ADMIN"}),
+			Secret: getEnvSecret("CACHE_BYPASS_SECRET", ""),
+		},
+		Outbound: OutboundConfig{
+			AllowedHosts: getEnvList("OUTBOUND_ALLOWED_HOSTS", nil),
+			AllowedCIDRs: getEnvList("OUTBOUND_ALLOWED_CIDRS", nil),
+		},
+		Tenant: TenantConfig{
+			Resolvers:          getEnvList("TENANT_RESOLVERS", []string{"jwt"}),
+			Header:             getEnvString("TENANT_HEADER", "X-Tenant-ID"),
+			TrustedHeaderCIDRs: getEnvList("TENANT_HEADER_TRUSTED_CIDRS", nil),
+			Required:           getEnvBool("TENANT_REQUIRED", false),
+			SharedHosts:        getEnvList("TENANT_SHARED_HOSTS", nil),
+		},
+		Authz: AuthzConfig{
+			PolicyURL:        getEnvString("AUTHZ_POLICY_URL", ""),
+			Mode:             getEnvString("AUTHZ_MODE", "layer"),
+			Timeout:          getEnvDuration("AUTHZ_TIMEOUT_MS", 500*time.Millisecond, time.Millisecond),
+			CacheTTL:         getEnvDuration("AUTHZ_CACHE_TTL", 5*time.Second, time.Second),
+			ResourceCacheTTL: getEnvDuration("AUTHZ_RESOURCE_CACHE_TTL", 30*time.Second, time.Second),
+		},
+		LoadShed: LoadShedConfig{
+			GoroutinesStart: getEnvInt("LOAD_SHED_GOROUTINES_START", 0),
+			GoroutinesFull:  getEnvInt("LOAD_SHED_GOROUTINES_FULL", 0),
+			HeapStartMB:     getEnvInt("LOAD_SHED_HEAP_START_MB", 0),
+			HeapFullMB:      getEnvInt("LOAD_SHED_HEAP_FULL_MB", 0),
+			GCPercentStart:  getEnvInt("LOAD_SHED_GC_PERCENT_START", 0),
+			GCPercentFull:   getEnvInt("LOAD_SHED_GC_PERCENT_FULL", 0),
+			ExemptPaths:     getEnvList("LOAD_SHED_EXEMPT_PATHS", []string{"/health", "/ready"}),
+		},
+		Secrets: secretsCfg,
+		Drain: DrainConfig{
+			Enabled:    getEnvBool("DRAIN_COORDINATION", false),
+			MinHealthy: getEnvInt("DRAIN_MIN_HEALTHY", 1),
+			MaxWait:    getEnvDuration("DRAIN_MAX_WAIT", time.Minute, time.Second),
+			Heartbeat:  getEnvDuration("DRAIN_HEARTBEAT", 5*time.Second, time.Second),
+		},
+		Warmup: WarmupConfig{
+			OnStartup: getEnvBool("WARMUP_ON_STARTUP", false),
+			Timeout:   getEnvDuration("WARMUP_TIMEOUT", 30*time.Second, time.Second),
+		},
+		Jobs: JobsConfig{
+			Timeout:        getEnvDuration("JOB_TIMEOUT", 30*time.Minute, time.Second),
+			Retention:      getEnvDuration("JOB_RETENTION", 24*time.Hour, time.Second),
+			WebhookSecret:  getEnvSecret("JOB_WEBHOOK_SECRET", ""),
+			WebhookTimeout: getEnvDuration("JOB_WEBHOOK_TIMEOUT", 10*time.Second, time.Second),
+		},
+		FaultInjection: FaultInjectionConfig{
+			Enabled:         getEnvBool("FAULT_INJECTION_ENABLED", false),
+			AllowProduction: getEnvBool("FAULT_INJECTION_ALLOW_PRODUCTION", false),
+			Header:          getEnvString("FAULT_INJECTION_HEADER", ""),
+		},
+		Aggregate: AggregateConfig{
+			SectionTimeout: getEnvDuration("AGGREGATE_SECTION_TIMEOUT_MS", 3*time.Second, time.Millisecond),
+			MaxCost:        getEnvFloat("AGGREGATE_MAX_COST", 0),
+		},
+		OpenAPI: OpenAPIConfig{
+			SpecPath:       getEnvString("OPENAPI_SPEC_PATH", "./docs/api/openapi.yaml"),
+			ReloadInterval: getEnvDuration("OPENAPI_RELOAD_INTERVAL", 30*time.Second, time.Second),
+			RequireDocumented: getEnvBool("OPENAPI_REQUIRE_DOCUMENTED", false),
+			ResponseSampleRate: getEnvFloat("OPENAPI_VALIDATE_RESPONSE_RATE", 0),
+		},
+		GeoIP: GeoIPConfig{
+			Enabled:        getEnvBool("GEOIP_ENABLED", false),
+			CountryDB:      getEnvString("GEOIP_COUNTRY_DB", ""),
+			ASNDB:          getEnvString("GEOIP_ASN_DB", ""),
+			ReloadInterval: getEnvDuration("GEOIP_RELOAD_INTERVAL", 5*time.Minute, time.Second),
 		},
 	}
 
 	// Validate required configuration
-	if cfg.JWT.Secret == "your-secret-key" && cfg.Environment == "production" {
-		return nil, fmt.Errorf("JWT_SECRET must be set in production environment")
+	for _, proxy := range cfg.Server.TrustedProxies {
+		cidr := proxy
+		if !strings.Contains(cidr, "/") {
+			cidr += "/128"
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				cidr = proxy + "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an IP or CIDR", proxy)
+		}
+		// Trusting every address lets any client choose its own IP.
+		if ones, _ := network.Mask.Size(); ones == 0 && cfg.Environment == "production" {
+			return nil, fmt.Errorf("TRUSTED_PROXIES must not trust every address (%s) in production", proxy)
+		}
+	}
+
+	if cfg.InternalAuth.KeyFile == "" && cfg.Environment == "production" {
+		return nil, fmt.Errorf("INTERNAL_TOKEN_KEY_FILE must be set in production environment")
+	}
+
+	routes, err := loadRoutes(getEnvString("ROUTES_CONFIG_FILE", ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Routes = routes
+
+	cfg.Services.GRPC = loadGRPCClientConfig(cfg.Services)
+	cfg.Services.Instances = make(map[string][]string)
+	cfg.Services.TLS = make(map[string]UpstreamTLSConfig)
+	for name := range cfg.Services.Addresses() {
+		prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if instances := getEnvList(prefix+"_INSTANCES", nil); len(instances) > 0 {
+			cfg.Services.Instances[name] = instances
+		}
+		if getEnvBool(prefix+"_TLS_ENABLED", false) {
+			cfg.Services.TLS[name] = UpstreamTLSConfig{
+				CAFile:     getEnvString(prefix+"_TLS_CA_FILE", ""),
+				CertFile:   getEnvString(prefix+"_TLS_CERT_FILE", ""),
+				KeyFile:    getEnvString(prefix+"_TLS_KEY_FILE", ""),
+				ServerName: getEnvString(prefix+"_TLS_SERVER_NAME", ""),
+				Pins:       getEnvList(prefix+"_TLS_PINS", nil),
+			}
+		}
+	}
+	if err := errors.Join(envErrors...); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+
+	for name, client := range cfg.Services.GRPC {
+		if client.Compression != "" && client.Compression != "gzip" {
+			return nil, fmt.Errorf("unsupported gRPC compression %q for %s", client.Compression, name)
+		}
+	}
+
+	if ws := cfg.Server.WebSocket; ws.CompressionLevel < 1 || ws.CompressionLevel > 9 || ws.CompressionThreshold < 0 {
+		return nil, fmt.Errorf("WEBSOCKET_COMPRESSION_LEVEL must be between 1 and 9 and WEBSOCKET_COMPRESSION_THRESHOLD must not be negative")
+	}
+	if ws := cfg.Server.WebSocket; ws.MaxMessageBytes <= 0 {
+		return nil, fmt.Errorf("WEBSOCKET_MAX_MESSAGE_BYTES must be positive")
+	}
+	if ws := cfg.Server.WebSocket; ws.MessageRate < 0 || (ws.MessageRate > 0 && ws.MessageBurst < 1) {
+		return nil, fmt.Errorf("WEBSOCKET_MESSAGE_RATE must not be negative and WEBSOCKET_MESSAGE_BURST must be at least 1 when it is set")
+	}
+	for _, target := range cfg.Services.PinTargets {
+		known := false
+		for _, instances := range cfg.Services.Instances {
+			for _, addr := range instances {
+				known = known || addr == target
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("UPSTREAM_PIN_TARGETS: %q is not a configured service instance", target)
+		}
+	}
+	for name, t := range cfg.Services.TLS {
+		// A URL's scheme decides whether its HTTP client uses TLS at all,
+		// so an http URL would silently skip the settings.
+		if addr := cfg.Services.Addresses()[name]; strings.Contains(addr, "://") && !strings.HasPrefix(addr, "https://") {
+			prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+			return nil, fmt.Errorf("%s_URL must be an https URL when %s_TLS_ENABLED is set", prefix, prefix)
+		}
+		if (t.CertFile == "") != (t.KeyFile == "") {
+			return nil, fmt.Errorf("TLS client certificate for %s needs both a cert file and a key file", name)
+		}
+		for _, pin := range t.Pins {
+			if !validPin(pin) {
+				return nil, fmt.Errorf("TLS pin %q for %s must be sha256/ followed by a base64 SHA-256 hash", pin, name)
+			}
+		}
+	}
+	if t := cfg.Observability.TraceBodies; t.SampleRate < 0 || t.SampleRate > 1 || t.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("TRACE_BODY_SAMPLE_RATE must be in [0, 1] and TRACE_BODY_MAX_BYTES must not be negative")
+	}
+	if cfg.Services.HealthCheckTimeout <= 0 {
+		return nil, fmt.Errorf("BACKEND_HEALTH_TIMEOUT must be positive")
+	}
+	if k := cfg.Services.StickyKey; k != "user" && k != "session" {
+		return nil, fmt.Errorf("STICKY_ROUTING_KEY must be user or session, got %q", k)
+	}
+	if cfg.Server.MaxHeaderCount < 0 || cfg.Server.MaxHeaderBytes < 0 {
+		return nil, fmt.Errorf("MAX_HEADER_COUNT and MAX_HEADER_BYTES must not be negative")
+	}
+	if m := cfg.Server.PathNormalization.Mode; m != "redirect" && m != "rewrite" {
+		return nil, fmt.Errorf("PATH_NORMALIZE_MODE must be redirect or rewrite, got %q", m)
+	}
+	switch m := cfg.Server.PathNormalization.TrailingSlash; m {
+	case "redirect", "rewrite", "off":
+	default:
+		return nil, fmt.Errorf("PATH_TRAILING_SLASH must be redirect, rewrite or off, got %q", m)
+	}
+	for i, method := range cfg.Server.MethodOverride.Allowed {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		switch method {
+		case "GET", "PUT", "PATCH", "DELETE":
+		default:
+			return nil, fmt.Errorf("METHOD_OVERRIDE_ALLOWED may only list GET, PUT, PATCH and DELETE, got %q", method)
+		}
+		cfg.Server.MethodOverride.Allowed[i] = method
+	}
+
+	if f := cfg.Services.DefaultResponseFormat; f != "json" && f != "protobuf" {
+		return nil, fmt.Errorf("DEFAULT_RESPONSE_FORMAT must be json or protobuf, got %q", f)
+	}
+
+	if rate := cfg.Observability.RequestLog.SampleRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("REQUEST_LOG_SAMPLE_RATE must be between 0 and 1, got %g", rate)
+	}
+	if rate := cfg.OpenAPI.ResponseSampleRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("OPENAPI_VALIDATE_RESPONSE_RATE must be between 0 and 1, got %g", rate)
+	}
+	if geo := cfg.GeoIP; geo.Enabled {
+		if geo.CountryDB == "" && geo.ASNDB == "" {
+			return nil, fmt.Errorf("GEOIP_ENABLED requires GEOIP_COUNTRY_DB or GEOIP_ASN_DB")
+		}
+		if geo.ReloadInterval <= 0 {
+			return nil, fmt.Errorf("GEOIP_RELOAD_INTERVAL must be positive")
+		}
+	}
+
+	for _, resolver := range cfg.Tenant.Resolvers {
+		switch resolver {
+		case "jwt", "api_key", "header", "host":
+		default:
+			return nil, fmt.Errorf("TENANT_RESOLVERS: unknown resolver %q (want jwt, api_key, header or host)", resolver)
+		}
+	}
+	// OVERLOAD_RETRY_AFTER is a list of reason=seconds pairs
+	delays := getEnvList("OVERLOAD_RETRY_AFTER", nil)
+	cfg.Server.OverloadRetryAfter = make(map[string]time.Duration, len(delays))
+	for _, pair := range delays {
+		reason, value, _ := strings.Cut(pair, "=")
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("OVERLOAD_RETRY_AFTER: %q is not a reason=seconds pair with positive seconds", pair)
+		}
+		switch reason {
+		case "concurrency", "shed", "circuit_open":
+		default:
+			return nil, fmt.Errorf("OVERLOAD_RETRY_AFTER: unknown reason %q (want concurrency, shed or circuit_open)", reason)
+		}
+		cfg.Server.OverloadRetryAfter[reason] = time.Duration(seconds) * time.Second
+	}
+	// UPSTREAM_ERROR_MAP is a list of GRPC_CODE=status:ERROR_CODE:retryable
+	// entries, e.g. NOT_FOUND=404:RESOURCE_NOT_FOUND:false
+	errorMap := getEnvList("UPSTREAM_ERROR_MAP", nil)
+	cfg.Services.ErrorMap = make(map[codes.Code]UpstreamErrorMapping, len(errorMap))
+	for _, entry := range errorMap {
+		name, value, _ := strings.Cut(entry, "=")
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil || code == codes.OK {
+			return nil, fmt.Errorf("UPSTREAM_ERROR_MAP: %q does not name a gRPC error code", name)
+		}
+		fields := strings.Split(value, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("UPSTREAM_ERROR_MAP: %q is not a GRPC_CODE=status:ERROR_CODE:retryable entry", entry)
+		}
+		status, err := strconv.Atoi(fields[0])
+		if err != nil || status < 400 || status > 599 {
+			return nil, fmt.Errorf("UPSTREAM_ERROR_MAP: %q must map to a 4xx or 5xx status", entry)
+		}
+		retryable, err := strconv.ParseBool(fields[2])
+		if err != nil || fields[1] == "" {
+			return nil, fmt.Errorf("UPSTREAM_ERROR_MAP: %q needs an error code and a true or false retryable flag", entry)
+		}
+		cfg.Services.ErrorMap[code] = UpstreamErrorMapping{Status: status, Code: fields[1], Retryable: retryable}
+	}
+	// RATE_LIMIT_PEERS is a list of region=host:port pairs
+	peers := getEnvList("RATE_LIMIT_PEERS", nil)
+	cfg.RateLimit.Peers = make(map[string]string, len(peers))
+	for _, pair := range peers {
+		region, address, ok := strings.Cut(pair, "=")
+		if !ok || region == "" || address == "" {
+			return nil, fmt.Errorf("RATE_LIMIT_PEERS: %q is not a region=address pair", pair)
+		}
+		cfg.RateLimit.Peers[region] = address
+	}
+	switch rl := cfg.RateLimit; rl.Mode {
+	case "local":
+	case "global":
+		if rl.GlobalRedisAddress == "" {
+			return nil, fmt.Errorf("RATE_LIMIT_MODE=global needs RATE_LIMIT_GLOBAL_REDIS_URL")
+		}
+	case "reconciled":
+		if rl.Region == "" || len(rl.Peers) == 0 || rl.ReconcileInterval <= 0 {
+			return nil, fmt.Errorf("RATE_LIMIT_MODE=reconciled needs RATE_LIMIT_REGION, RATE_LIMIT_PEERS and a positive RATE_LIMIT_RECONCILE_INTERVAL")
+		}
+		if _, ok := rl.Peers[rl.Region]; ok {
+			return nil, fmt.Errorf("RATE_LIMIT_PEERS must not list this region (%s)", rl.Region)
+		}
+	default:
+		return nil, fmt.Errorf("RATE_LIMIT_MODE must be local, global or reconciled, got %q", rl.Mode)
+	}
+	if cfg.Warmup.Timeout <= 0 {
+		return nil, fmt.Errorf("WARMUP_TIMEOUT must be positive")
+	}
+	if f := cfg.RateLimit.Fallback; f.Enabled && (f.Scale <= 0 || f.MaxKeys <= 0) {
+		return nil, fmt.Errorf("RATE_LIMIT_FALLBACK_SCALE and RATE_LIMIT_FALLBACK_MAX_KEYS must be positive")
+	}
+	if a := cfg.RateLimit.Alert; a.Threshold < 0 || (a.Threshold > 0 && (a.Window <= 0 || a.Cooldown < 0)) {
+		return nil, fmt.Errorf("RATE_LIMIT_ALERT_THRESHOLD must not be negative, and needs a positive RATE_LIMIT_ALERT_WINDOW")
+	}
+	// AGGREGATE_PLAN_MAX_COST is a list of plan=budget pairs
+	planCosts := getEnvList("AGGREGATE_PLAN_MAX_COST", nil)
+	cfg.Aggregate.PlanMaxCost = make(map[string]float64, len(planCosts))
+	for _, pair := range planCosts {
+		plan, value, ok := strings.Cut(pair, "=")
+		budget, err := strconv.ParseFloat(value, 64)
+		if !ok || plan == "" || err != nil || budget < 0 {
+			return nil, fmt.Errorf("AGGREGATE_PLAN_MAX_COST: %q is not a plan=budget pair", pair)
+		}
+		cfg.Aggregate.PlanMaxCost[plan] = budget
+	}
+	// INTERNAL_TRUSTED_CALLERS is a list of service=keyfile pairs
+	callers := getEnvList("INTERNAL_TRUSTED_CALLERS", nil)
+	cfg.InternalAuth.TrustedCallers = make(map[string]string, len(callers))
+	for _, pair := range callers {
+		caller, keyFile, ok := strings.Cut(pair, "=")
+		if !ok || caller == "" || keyFile == "" {
+			return nil, fmt.Errorf("INTERNAL_TRUSTED_CALLERS: %q is not a service=keyfile pair", pair)
+		}
+		cfg.InternalAuth.TrustedCallers[caller] = keyFile
+	}
+	// TENANT_HOSTS is a list of host=tenant pairs
+	hosts := getEnvList("TENANT_HOSTS", nil)
+	cfg.Tenant.Hosts = make(map[string]string, len(hosts))
+	for _, pair := range hosts {
+		host, tenantID, ok := strings.Cut(pair, "=")
+		if !ok || host == "" || tenantID == "" {
+			return nil, fmt.Errorf("TENANT_HOSTS: %q is not a host=tenant pair", pair)
+		}
+		cfg.Tenant.Hosts[host] = tenantID
+	}
+	for _, cidr := range cfg.RateLimit.Exempt.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_EXEMPT_CIDRS: %w", err)
+		}
+	}
+	for _, cidr := range cfg.Tenant.TrustedHeaderCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("TENANT_HEADER_TRUSTED_CIDRS: %w", err)
+		}
+	}
+
+	if b := cfg.Services.RetryBudget; b.Ratio < 0 || b.MinPerSecond < 0 || b.Window <= 0 {
+		return nil, fmt.Errorf("RETRY_BUDGET_RATIO and RETRY_BUDGET_MIN_PER_SECOND must not be negative, and RETRY_BUDGET_WINDOW must be positive")
+	}
+	if m := cfg.Authz.Mode; m != "layer" && m != "replace" {
+		return nil, fmt.Errorf("AUTHZ_MODE must be layer or replace, got %q", m)
+	}
+	if cfg.Authz.Mode == "replace" && cfg.Authz.PolicyURL == "" {
+		return nil, fmt.Errorf("AUTHZ_MODE=replace needs AUTHZ_POLICY_URL")
+	}
+	// AUTHZ_RESOURCE_URLS is a list of type=url pairs
+	resourceURLs := getEnvList("AUTHZ_RESOURCE_URLS", nil)
+	cfg.Authz.ResourceURLs = make(map[string]string, len(resourceURLs))
+	for _, pair := range resourceURLs {
+		typ, rawURL, ok := strings.Cut(pair, "=")
+		if !ok || typ == "" || !strings.Contains(rawURL, "{id}") {
+			return nil, fmt.Errorf("AUTHZ_RESOURCE_URLS: %q is not a type=url pair with {id} in the url", pair)
+		}
+		cfg.Authz.ResourceURLs[typ] = rawURL
+	}
+	for name, d := range map[string]time.Duration{
+		"READ_TIMEOUT":                 cfg.Server.ReadTimeout,
+		"READ_HEADER_TIMEOUT":          cfg.Server.ReadHeaderTimeout,
+		"WRITE_TIMEOUT":                cfg.Server.WriteTimeout,
+		"IDLE_TIMEOUT":                 cfg.Server.IdleTimeout,
+		"REQUEST_TIMEOUT":              cfg.Server.RequestTimeout,
+		"UPLOAD_SCAN_TIMEOUT":          cfg.Upload.ScanTimeout,
+		"AUTHZ_TIMEOUT_MS":             cfg.Authz.Timeout,
+		"AGGREGATE_SECTION_TIMEOUT_MS": cfg.Aggregate.SectionTimeout,
+	} {
+		if d <= 0 {
+			return nil, fmt.Errorf("%s must be positive, got %s", name, d)
+		}
+	}
+	for name, client := range cfg.Services.GRPC {
+		if client.KeepaliveTime <= 0 || client.KeepaliveTimeout <= 0 || client.MinConnectTimeout <= 0 ||
+			client.BackoffBaseDelay <= 0 || client.BackoffMaxDelay < client.BackoffBaseDelay {
+			return nil, fmt.Errorf("gRPC keepalive, backoff and connect times for %s must be positive, with the max backoff delay at least the base", name)
+		}
+		if client.MaxInFlight < 0 || client.QueueMaxWait < 0 || client.QueueMaxDepth < 0 {
+			return nil, fmt.Errorf("gRPC max in-flight calls and queue limits for %s must not be negative", name)
+		}
+	}
+	if ct := cfg.Server.ClientTimeout; ct.Enabled && (ct.Min <= 0 || ct.Max < ct.Min) {
+		return nil, fmt.Errorf("REQUEST_TIMEOUT_HEADER_MIN_MS must be positive and REQUEST_TIMEOUT_HEADER_MAX_MS at least as large")
+	}
+	if hard := cfg.Server.HardTimeout; hard < 0 {
+		return nil, fmt.Errorf("REQUEST_HARD_TIMEOUT must not be negative")
+	} else if hard > 0 && hard <= cfg.Server.RequestTimeout {
+		return nil, fmt.Errorf("REQUEST_HARD_TIMEOUT (%s) must be greater than REQUEST_TIMEOUT (%s)", hard, cfg.Server.RequestTimeout)
+	}
+	// Long polls run without a request deadline, so only the watchdog
+	// bounds them
+	if hold, hard := cfg.Server.LongPollMaxHold, cfg.Server.HardTimeout; hold <= 0 {
+		return nil, fmt.Errorf("LONG_POLL_MAX_HOLD must be positive")
+	} else if hard > 0 && hold >= hard {
+		return nil, fmt.Errorf("LONG_POLL_MAX_HOLD (%s) must be below REQUEST_HARD_TIMEOUT (%s)", hold, hard)
+	}
+	for _, route := range cfg.Routes {
+		if hard := cfg.Server.HardTimeout; hard > 0 && route.Timeout >= hard {
+			return nil, fmt.Errorf("timeout on %s %s (%s) must be below REQUEST_HARD_TIMEOUT (%s)", route.Method, route.Path, route.Timeout, hard)
+		}
+		if r := route.Resource; r != nil {
+			if _, ok := cfg.Authz.ResourceURLs[r.Type]; !ok {
+				return nil, fmt.Errorf("resource type %q on %s %s has no lookup in AUTHZ_RESOURCE_URLS", r.Type, route.Method, route.Path)
+			}
+		}
+	}
+	if cfg.Server.TokenExpiryWarning < 0 {
+		return nil, fmt.Errorf("WEBSOCKET_TOKEN_EXPIRY_WARNING must not be negative")
+	}
+	if cfg.Server.RouteSwitchRefresh <= 0 {
+		return nil, fmt.Errorf("ROUTE_SWITCH_REFRESH_INTERVAL must be positive")
+	}
+	if cb := cfg.Services.CircuitBreaker; cb.FailureThreshold > 0 && cb.Open <= 0 {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_OPEN_SECONDS must be positive")
+	}
+	if cb := cfg.Services.CircuitBreaker; cb.Share && (cb.Caution <= 0 || cb.Heartbeat <= 0) {
+		return nil, fmt.Errorf("CIRCUIT_BREAKER_CAUTION_SECONDS and CIRCUIT_BREAKER_HEARTBEAT_SECONDS must be positive")
+	}
+	if cfg.Secrets.ReloadInterval <= 0 {
+		return nil, fmt.Errorf("SECRETS_RELOAD_INTERVAL must be positive")
+	}
+	if cfg.OpenAPI.ReloadInterval <= 0 {
+		return nil, fmt.Errorf("OPENAPI_RELOAD_INTERVAL must be positive")
+	}
+	if cfg.InternalAuth.ReloadInterval <= 0 {
+		return nil, fmt.Errorf("INTERNAL_TOKEN_RELOAD_INTERVAL must be positive")
+	}
+	if a := cfg.InternalAuth; a.TokenTTL <= 0 || a.KeyRetention <= 0 {
+		return nil, fmt.Errorf("INTERNAL_TOKEN_TTL and INTERNAL_TOKEN_KEY_RETENTION must be positive")
+	}
+	if d := cfg.Drain; d.Enabled && (d.MinHealthy < 0 || d.MaxWait <= 0 || d.Heartbeat <= 0) {
+		return nil, fmt.Errorf("DRAIN_MIN_HEALTHY must not be negative and DRAIN_MAX_WAIT and DRAIN_HEARTBEAT must be positive")
+	}
+	if cfg.Upload.ResumableTTL <= 0 {
+		return nil, fmt.Errorf("UPLOAD_RESUMABLE_TTL must be positive")
+	}
+	if a := cfg.Authz; a.CacheTTL < 0 || a.ResourceCacheTTL < 0 {
+		return nil, fmt.Errorf("AUTHZ_CACHE_TTL and AUTHZ_RESOURCE_CACHE_TTL must not be negative")
+	}
+	if cfg.Services.DeadlineBuffer < 0 || cfg.RateLimit.QueueMaxWait < 0 || cfg.Observability.RequestLog.SlowThreshold < 0 {
+		return nil, fmt.Errorf("DEADLINE_BUFFER_MS, RATE_LIMIT_QUEUE_MAX_WAIT_MS and REQUEST_LOG_SLOW_MS must not be negative")
+	}
+	if j := cfg.Jobs; j.Timeout <= 0 || j.WebhookTimeout <= 0 || j.Retention < j.Timeout {
+		return nil, fmt.Errorf("JOB_TIMEOUT and JOB_WEBHOOK_TIMEOUT must be positive and JOB_RETENTION at least JOB_TIMEOUT")
+	}
+	if f := cfg.FaultInjection; f.Enabled && cfg.Environment == "production" && !f.AllowProduction {
+		return nil, fmt.Errorf("FAULT_INJECTION_ENABLED needs FAULT_INJECTION_ALLOW_PRODUCTION in production environment")
+	}
+	for name, signal := range map[string][2]int{
+		"GOROUTINES": {cfg.LoadShed.GoroutinesStart, cfg.LoadShed.GoroutinesFull},
+		"HEAP":       {cfg.LoadShed.HeapStartMB, cfg.LoadShed.HeapFullMB},
+		"GC_PERCENT": {cfg.LoadShed.GCPercentStart, cfg.LoadShed.GCPercentFull},
+	} {
+		if signal[0] > 0 && signal[1] <= signal[0] {
+			return nil, fmt.Errorf("LOAD_SHED_%s_FULL must be greater than its start", name)
+		}
+	}
+	if cfg.Services.MaxRedirects < 0 {
+		return nil, fmt.Errorf("UPSTREAM_MAX_REDIRECTS must not be negative, got %d", cfg.Services.MaxRedirects)
+	}
+
+	if cfg.Storage.URLTTL <= 0 || cfg.Storage.URLTTL > time.Hour {
+		return nil, fmt.Errorf("SIGNED_URL_TTL must be positive and at most 1h, got %s", cfg.Storage.URLTTL)
+	}
+
+	switch cfg.Metering.Period {
+	case "hourly", "daily", "monthly":
+	default:
+		return nil, fmt.Errorf("METERING_PERIOD must be hourly, daily or monthly, got %q", cfg.Metering.Period)
+	}
+
+	if cfg.Session.SameSite == "none" && !cfg.Session.Secure {
+		return nil, fmt.Errorf("SESSION_COOKIE_SAMESITE=none requires SESSION_COOKIE_SECURE=true")
+	}
+	if cfg.Metrics.TenantLabels.MaxTenants < 0 {
+		return nil, fmt.Errorf("METRICS_TENANT_LABELS_MAX must not be negative, got %d", cfg.Metrics.TenantLabels.MaxTenants)
+	}
+	if cfg.Session.MaxActive < 0 {
+		return nil, fmt.Errorf("SESSION_MAX_ACTIVE must not be negative, got %d", cfg.Session.MaxActive)
+	}
+	switch cfg.Session.LimitPolicy {
+	case "reject", "evict_oldest":
+	default:
+		return nil, fmt.Errorf("SESSION_LIMIT_POLICY must be reject or evict_oldest, got %q", cfg.Session.LimitPolicy)
+	}
+
+	if cfg.JWT.ClockSkew < 0 || cfg.JWT.ClockSkew > 5*time.Minute {
+		return nil, fmt.Errorf("JWT_CLOCK_SKEW_SECONDS must be between 0 and 5m, got %s", cfg.JWT.ClockSkew)
+	}
+
+	for _, p := range cfg.JWT.Introspection {
+		if p.Endpoint == "" {
+			return nil, fmt.Errorf("introspection endpoint must be set for issuer %q", p.Issuer)
+		}
+		if p.CacheTTL < 0 {
+			return nil, fmt.Errorf("introspection cache TTL for issuer %q must not be negative", p.Issuer)
+		}
 	}
 
 	return cfg, nil
 }
 
+// loadIntrospectionConfig reads one introspection provider per name listed in
+// TOKEN_INTROSPECTION_PROVIDERS, e.g. TOKEN_INTROSPECTION_PARTNER_ENDPOINT.
+func loadIntrospectionConfig() []IntrospectionConfig {
+	var providers []IntrospectionConfig
+	for _, name := range getEnvList("TOKEN_INTROSPECTION_PROVIDERS", nil) {
+		prefix := "TOKEN_INTROSPECTION_" + strings.ToUpper(name) + "_"
+		providers = append(providers, IntrospectionConfig{
+			Issuer:       getEnvString(prefix+"ISSUER", name),
+			Endpoint:     getEnvString(prefix+"ENDPOINT", ""),
+			ClientID:     getEnvString(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnvSecret(prefix+"CLIENT_SECRET", ""),
+			CacheTTL:     getEnvDuration(prefix+"CACHE_TTL", time.Minute, time.Second),
+			Opaque:       getEnvBool(prefix+"OPAQUE", false),
+		})
+	}
+	return providers
+}
+
+// loadGRPCClientConfig applies the GRPC_* defaults to every service, then
+// per-service overrides such as REPORTING_SERVICE_GRPC_MAX_RECV_MSG_SIZE.
+func loadGRPCClientConfig(services ServicesConfig) map[string]GRPCClientConfig {
+	defaults := GRPCClientConfig{
+		MaxRecvMsgSize: getEnvBytes("GRPC_MAX_RECV_MSG_SIZE", 4*1024*1024),
+		MaxSendMsgSize: getEnvBytes("GRPC_MAX_SEND_MSG_SIZE", 4*1024*1024),
+
+		KeepaliveTime:                getEnvDuration("GRPC_KEEPALIVE_TIME", 5*time.Minute, time.Second),
+		KeepaliveTimeout:             getEnvDuration("GRPC_KEEPALIVE_TIMEOUT", 5*time.Second, time.Second),
+		KeepalivePermitWithoutStream: getEnvBool("GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM", false),
+
+		BackoffBaseDelay:  getEnvDuration("GRPC_BACKOFF_BASE_DELAY", time.Second, time.Second),
+		BackoffMaxDelay:   getEnvDuration("GRPC_BACKOFF_MAX_DELAY", 30*time.Second, time.Second),
+		MinConnectTimeout: getEnvDuration("GRPC_MIN_CONNECT_TIMEOUT", 5*time.Second, time.Second),
+
+		Compression: getEnvString("GRPC_COMPRESSION", ""),
+
+		MaxInFlight:   getEnvInt("GRPC_MAX_IN_FLIGHT", 0),
+		QueueMaxWait:  getEnvDuration("GRPC_QUEUE_MAX_WAIT_MS", 0, time.Millisecond),
+		QueueMaxDepth: getEnvInt("GRPC_QUEUE_MAX_DEPTH", 100),
+	}
+
+	clients := make(map[string]GRPCClientConfig)
+	for name := range services.Addresses() {
+		prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_GRPC_"
+		clients[name] = GRPCClientConfig{
+			MaxRecvMsgSize: getEnvBytes(prefix+"MAX_RECV_MSG_SIZE", defaults.MaxRecvMsgSize),
+			MaxSendMsgSize: getEnvBytes(prefix+"MAX_SEND_MSG_SIZE", defaults.MaxSendMsgSize),
+
+			KeepaliveTime:                getEnvDuration(prefix+"KEEPALIVE_TIME", defaults.KeepaliveTime, time.Second),
+			KeepaliveTimeout:             getEnvDuration(prefix+"KEEPALIVE_TIMEOUT", defaults.KeepaliveTimeout, time.Second),
+			KeepalivePermitWithoutStream: getEnvBool(prefix+"KEEPALIVE_PERMIT_WITHOUT_STREAM", defaults.KeepalivePermitWithoutStream),
+
+			BackoffBaseDelay:  getEnvDuration(prefix+"BACKOFF_BASE_DELAY", defaults.BackoffBaseDelay, time.Second),
+			BackoffMaxDelay:   getEnvDuration(prefix+"BACKOFF_MAX_DELAY", defaults.BackoffMaxDelay, time.Second),
+			MinConnectTimeout: getEnvDuration(prefix+"MIN_CONNECT_TIMEOUT", defaults.MinConnectTimeout, time.Second),
+
+			Compression: getEnvString(prefix+"COMPRESSION", defaults.Compression),
+
+			MaxInFlight:   getEnvInt(prefix+"MAX_IN_FLIGHT", defaults.MaxInFlight),
+			QueueMaxWait:  getEnvDuration(prefix+"QUEUE_MAX_WAIT_MS", defaults.QueueMaxWait, time.Millisecond),
+			QueueMaxDepth: getEnvInt(prefix+"QUEUE_MAX_DEPTH", defaults.QueueMaxDepth),
+		}
+	}
+	return clients
+}
+
+// envErrors collects the variables LoadConfig found set but unparseable.
+// The getters fall back to their default so loading can carry on and
+// report every bad variable at once, but the config is refused.
+var envErrors []error
+
+func invalidEnv(key, value string, err error) {
+	envErrors = append(envErrors, fmt.Errorf("%s=%q: %v", key, value, err))
+}
+
+// secretLoader resolves the getEnvSecret variables.
+var secretLoader *secrets.Loader
+
+// getEnvSecret reads a secret from KEY_FILE, the secrets provider or KEY,
+// in that order.
+func getEnvSecret(key, defaultValue string) string {
+	return getRequiredSecret(key, defaultValue, false)
+}
+
+// getRequiredSecret is getEnvSecret for a secret the deployment must
+// provide when required is set: if no source has it, the config is
+// refused instead of running on defaultValue.
+func getRequiredSecret(key, defaultValue string, required bool) string {
+	value, ok, err := secretLoader.Lookup(context.Background(), key)
+	if err != nil {
+		envErrors = append(envErrors, err)
+		return defaultValue
+	}
+	if !ok {
+		if required {
+			envErrors = append(envErrors, fmt.Errorf("%s (or %s_FILE) is required", key, key))
+		}
+		return defaultValue
+	}
+	return value
+}
+
 func getEnvString(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -116,17 +1622,98 @@ func getEnvString(key, defaultValue string) string {
 }
 
 func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-	return defaultValue
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		invalidEnv(key, value, fmt.Errorf("not an integer"))
+		return defaultValue
+	}
+	return intValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		invalidEnv(key, value, fmt.Errorf("not a number"))
+		return defaultValue
+	}
+	return floatValue
 }
 
 func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		return strings.ToLower(value) == "true"
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-	return defaultValue
+	boolValue, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		invalidEnv(key, value, fmt.Errorf("not true or false"))
+		return defaultValue
+	}
+	return boolValue
+}
+
+// getEnvDuration reads a Go duration ("15s", "500ms"). A bare integer is
+// taken in unit, which keeps values written before durations were
+// accepted meaning what they did.
+func getEnvDuration(key string, defaultValue, unit time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * unit
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		invalidEnv(key, value, fmt.Errorf("not a duration such as 15s or 500ms"))
+		return defaultValue
+	}
+	return d
+}
+
+var byteUnits = map[string]int{
+	"": 1, "B": 1,
+	"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+}
+
+// getEnvBytes reads a byte size: a bare number of bytes, or a number with a
+// unit such as 64KB or 10MiB. KB, MB and GB are powers of 1024, as
+// elsewhere in ops tooling.
+func getEnvBytes(key string, defaultValue int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	digits := strings.TrimRightFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+	unit, ok := byteUnits[strings.ToUpper(strings.TrimSpace(value[len(digits):]))]
+	n, err := strconv.Atoi(digits)
+	if !ok || err != nil || n > math.MaxInt/unit {
+		invalidEnv(key, value, fmt.Errorf("not a byte size such as 512, 64KB or 10MB"))
+		return defaultValue
+	}
+	return n * unit
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
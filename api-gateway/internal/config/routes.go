@@ -0,0 +1,661 @@
+package config
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+)
+
+// RouteConfig holds per-route policy declared in the routes file. Routes are
+// matched on method and gin path template, e.g. "GET /api/v1/trading/trades".
+//
+//	routes:
+//	  - method: GET
+//	    path: /api/v1/trading/trades
+//	    scopes: [trades:read]
+//	    priority: critical
+//	    protobuf: true
+//	    timeout: 10
+//	    json:
+//	      use_proto_names: true
+//	    cache:
+//	      ttl: 30
+//	      stale_if_error: 300
+//	    headers:
+//	      Cache-Control: "private, max-age={cache_ttl}"
+//	      Vary: Accept
+//	    fallback:
+//	      cache: true
+//	      body: {data: [], total: 0}
+//	    variants:
+//	      - name: scoring-v2
+//	        header: X-Experiment
+//	        value: scoring-v2
+//	        service: surveillance-engine
+//	        address: surveillance-engine-v2:50051
+//	    shadow:
+//	      service: surveillance-engine
+//	      address: surveillance-engine-next:50051
+//	      sample_rate: 0.1
+//	      compare_body: true
+//	      diff_sample_rate: 0.01
+//	    retry:
+//	      attempts: 2
+//	      backoff: 50
+//	    cost: 1
+//	    faults:
+//	      - kind: latency
+//	        probability: 0.2
+//	        latency: 1500ms
+//	      - kind: error
+//	        probability: 0.05
+//	        code: UNAVAILABLE
+//	    query:
+//	      unknown: reject
+//	      params:
+//	        - name: limit
+//	          type: integer
+//	        - name: symbol
+//	          repeated: true
+//	    versions:
+//	      - version: 1
+//	        remove: [data.risk_factors]
+//	        rename:
+//	          - from: data.risk_score
+//	            to: score
+//	  - method: POST
+//	    path: /api/v1/surveillance/alerts/:id/resolve
+//	    resource:
+//	      type: alert
+//	  - method: POST
+//	    path: /api/v1/files/upload
+//	    content_types: [multipart/form-data]
+//	    upload:
+//	      max_bytes: 104857600
+//	      allowed_types: [application/pdf, image/*]
+//	  - method: POST
+//	    path: /api/v1/reports/generate
+//	    cost: 20
+//	  - method: GET
+//	    path: /api/v1/reports/legacy
+//	    deprecation:
+//	      since: 2025-01-01
+//	      sunset: 2025-07-01
+//	      link: https://docs.dharmaguard.example/migrations/reports
+type RouteConfig struct {
+	Method string   `mapstructure:"method"`
+	Path   string   `mapstructure:"path"`
+	Scopes []string `mapstructure:"scopes"`
+	// Protobuf allows clients to request the raw backend message with
+	// Accept: application/x-protobuf. Only set it on routes whose response is
+	// a single proto message.
+	Protobuf bool `mapstructure:"protobuf"`
+	// JSON overrides the global transcoding options for this route so
+	// existing consumers can keep the rendering they were built against.
+	JSON *TranscodingOverride `mapstructure:"json"`
+	// Timeout is the request deadline, overriding the server's default
+	// request timeout. Like every duration in the routes file it is a Go
+	// duration ("2s", "750ms") or a bare number of seconds.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Cache enables the response cache for GET routes.
+	Cache *CachePolicy `mapstructure:"cache"`
+	// Upload overrides the global upload limits for multipart upload routes.
+	Upload *UploadPolicy `mapstructure:"upload"`
+	// Fallback answers requests while the backend's circuit breaker is
+	// open, instead of a 503.
+	Fallback *FallbackPolicy `mapstructure:"fallback"`
+	// Variants route matching requests to alternate backends for
+	// experiments. Rules are evaluated in order; the first match wins.
+	Variants []VariantRule `mapstructure:"variants"`
+	// Shadow mirrors the route's calls to a second backend for comparison.
+	// Only GET routes may be shadowed.
+	Shadow *ShadowPolicy `mapstructure:"shadow"`
+	// Retry retries the route's backend calls that fail as unavailable,
+	// within the global retry budget. Only set it on idempotent routes.
+	Retry *RetryPolicy `mapstructure:"retry"`
+	// Headers are added to the route's responses; a header the handler
+	// sets itself replaces the configured value. Values may reference
+	// {cache_ttl}, {stale_if_error}, {timeout} or a name from HeaderVars,
+	// and are expanded when the routes file is loaded.
+	Headers    map[string]string `mapstructure:"headers"`
+	HeaderVars map[string]string `mapstructure:"header_vars"`
+	// Resource names the resource the route acts on, so authorization
+	// policy can see its attributes (e.g. its tenant and owner).
+	Resource *ResourcePolicy `mapstructure:"resource"`
+	// Priority is "critical", "normal" (the default) or "low". Under load
+	// low-priority routes are shed first and critical ones last.
+	Priority string `mapstructure:"priority"`
+	// APIKey lets a request whose only credential is a known X-API-Key
+	// reach the route. Such a request acts for the key's tenant with no
+	// user, roles or scopes, so the route can't also declare scopes. Keys
+	// are refused everywhere else.
+	APIKey bool `mapstructure:"api_key"`
+	// RequireTenant overrides TenantConfig.Required for this route.
+	RequireTenant *bool `mapstructure:"require_tenant"`
+	// ValidateResponseRate overrides the fraction of the route's responses
+	// checked against the OpenAPI spec; 0 turns checking off for the route.
+	ValidateResponseRate *float64 `mapstructure:"validate_response_rate"`
+	// Deprecation marks the route deprecated; its responses carry
+	// Deprecation, Sunset, Link and Warning headers announcing it.
+	Deprecation *DeprecationPolicy `mapstructure:"deprecation"`
+	// Versions reshape the route's JSON responses for the API version a
+	// client asks for with Accept-Version, so one backend can serve
+	// several response shapes. The route serves the listed versions and
+	// the one in its path; an entry with no changes serves the backend's
+	// response as is.
+	Versions []VersionTransform `mapstructure:"versions"`
+	// Query validates the route's query parameters. Routes without it
+	// accept any.
+	Query *QueryPolicy `mapstructure:"query"`
+	// Faults are injected into the route's backend calls while fault
+	// injection is enabled (see FaultInjectionConfig), for chaos testing.
+	Faults []FaultRule `mapstructure:"faults"`
+	// Cost weighs the route's unary backend calls for load balancing
+	// across a service's instances; unset counts as 1. While any route
+	// sets one, calls go to the instance with the least outstanding cost
+	// instead of round-robin, so expensive calls don't pile onto one. It
+	// also prices the route as a sub-request of aggregate endpoints (see
+	// AggregateConfig).
+	Cost float64 `mapstructure:"cost"`
+	// ContentTypes are the media types the route's requests may send a
+	// body as, e.g. [application/json] or [multipart/form-data]; "type/*"
+	// and "*/*" are wildcards. Unset falls back to the server's
+	// REQUEST_CONTENT_TYPES. A body of another type is refused with 415.
+	ContentTypes []string `mapstructure:"content_types"`
+}
+
+// Fault kinds.
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+	FaultDrop    = "drop"
+)
+
+// FaultRule injects a fault into a Probability fraction of a route's unary
+// backend calls, retries included. "latency" delays the call by Latency;
+// "error" fails it with the gRPC status Code (e.g. UNAVAILABLE) without
+// calling the backend; "drop" doesn't call the backend and holds the call
+// until its deadline, as if the request was lost. Rules apply in order, so
+// a latency rule can precede an error or drop.
+type FaultRule struct {
+	Kind        string        `mapstructure:"kind"`
+	Probability float64       `mapstructure:"probability"`
+	Latency     time.Duration `mapstructure:"latency"`
+	Code        string        `mapstructure:"code"`
+}
+
+// GRPCCode parses Code as an upper-case gRPC status code name.
+func (f FaultRule) GRPCCode() (codes.Code, error) {
+	var code codes.Code
+	err := code.UnmarshalJSON([]byte(strconv.Quote(f.Code)))
+	return code, err
+}
+
+// Query parameter policies for unknown parameters.
+const (
+	QueryUnknownAllow  = "allow"
+	QueryUnknownStrip  = "strip"
+	QueryUnknownReject = "reject"
+)
+
+// QueryPolicy lists a route's query parameters; those its OpenAPI operation
+// declares are allowed too, with the spec's types. A value that doesn't
+// parse as its parameter's type is rejected with a 400. Unknown says what
+// happens to parameters the route doesn't list, and to repeats of ones not
+// marked repeated: "allow" (the default) passes them to the backend,
+// "strip" drops them and "reject" answers 400.
+type QueryPolicy struct {
+	Params  []QueryParam `mapstructure:"params"`
+	Unknown string       `mapstructure:"unknown"`
+}
+
+// QueryParam is an allowed query parameter. Type is "string" (the
+// default), "integer", "number" or "boolean"; Repeated allows it more than
+// once.
+type QueryParam struct {
+	Name     string `mapstructure:"name"`
+	Type     string `mapstructure:"type"`
+	Repeated bool   `mapstructure:"repeated"`
+}
+
+var headerVarPattern = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+
+// expandHeaders replaces the template references in the route's header
+// values.
+func (r *RouteConfig) expandHeaders() error {
+	vars := map[string]string{"timeout": strconv.FormatFloat(r.Timeout.Seconds(), 'f', -1, 64)}
+	if r.Cache != nil {
+		vars["cache_ttl"] = strconv.Itoa(r.Cache.TTL)
+		vars["stale_if_error"] = strconv.Itoa(r.Cache.StaleIfError)
+	}
+	for name, value := range r.HeaderVars {
+		vars[strings.ToLower(name)] = value
+	}
+
+	for name, value := range r.Headers {
+		var missing string
+		expanded := headerVarPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			v, ok := vars[ref[1:len(ref)-1]]
+			if !ok {
+				missing = ref
+			}
+			return v
+		})
+		if missing != "" {
+			return fmt.Errorf("header %s on %s %s references unknown value %s", name, r.Method, r.Path, missing)
+		}
+		r.Headers[name] = expanded
+	}
+	return nil
+}
+
+// VersionTransform turns a backend response into the shape of Version.
+// Fields are dotted paths from the top of the body, and arrays along a path
+// apply the rest of it to each element. Remove drops fields, then Rename
+// renames them in place.
+type VersionTransform struct {
+	Version string        `mapstructure:"version"`
+	Remove  []string      `mapstructure:"remove"`
+	Rename  []FieldRename `mapstructure:"rename"`
+}
+
+// FieldRename renames the field at path From to the name To, in the same
+// object.
+type FieldRename struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// Version returns the route's transform for version, if it declares one.
+func (r RouteConfig) Version(version string) (VersionTransform, bool) {
+	for _, v := range r.Versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+	return VersionTransform{}, false
+}
+
+// DeprecationPolicy announces a route's deprecation. Since is when it was
+// deprecated and Sunset, if set, when it will stop working, both as
+// YYYY-MM-DD or RFC 3339 timestamps. Link points to migration docs.
+type DeprecationPolicy struct {
+	Since  string `mapstructure:"since"`
+	Sunset string `mapstructure:"sunset"`
+	Link   string `mapstructure:"link"`
+}
+
+// addDeprecationHeaders adds the route's deprecation announcement to its
+// response headers: Deprecation (RFC 9745), Sunset (RFC 8594), Link with
+// rel="deprecation" and a 299 Warning for clients that only log warnings.
+func (r *RouteConfig) addDeprecationHeaders() error {
+	d := r.Deprecation
+	if d == nil {
+		return nil
+	}
+	since, err := parseRouteDate(d.Since)
+	if err != nil {
+		return fmt.Errorf("deprecation on %s %s: since: %w", r.Method, r.Path, err)
+	}
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	r.Headers["Deprecation"] = "@" + strconv.FormatInt(since.Unix(), 10)
+	warning := "Deprecated API"
+	if d.Sunset != "" {
+		sunset, err := parseRouteDate(d.Sunset)
+		if err != nil {
+			return fmt.Errorf("deprecation on %s %s: sunset: %w", r.Method, r.Path, err)
+		}
+		if sunset.Before(since) {
+			return fmt.Errorf("deprecation on %s %s: sunset is before since", r.Method, r.Path)
+		}
+		r.Headers["Sunset"] = sunset.UTC().Format(http.TimeFormat)
+		warning += ", removed after " + sunset.UTC().Format("2006-01-02")
+	}
+	if d.Link != "" {
+		link := "<" + d.Link + `>; rel="deprecation"; type="text/html"`
+		if existing := r.Headers["Link"]; existing != "" {
+			link = existing + ", " + link
+		}
+		r.Headers["Link"] = link
+		warning += "; see " + d.Link
+	}
+	r.Headers["Warning"] = "299 - " + strconv.Quote(warning)
+	return nil
+}
+
+func parseRouteDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("date is required")
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD or RFC 3339 date", value)
+	}
+	return t, nil
+}
+
+// ShadowPolicy mirrors a sample of a route's calls to Service onto the
+// backend at Address. Clients only ever see the primary response; the
+// shadow's is compared (status code, and with CompareBody the message
+// content) and discarded.
+type ShadowPolicy struct {
+	Service    string  `mapstructure:"service"`
+	Address    string  `mapstructure:"address"`
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// Timeout bounds each shadow call.
+	Timeout     time.Duration `mapstructure:"timeout"`
+	CompareBody bool          `mapstructure:"compare_body"`
+	// DiffSampleRate is the fraction of differing responses whose full
+	// diff is kept for inspection; the diff summary metric counts all.
+	DiffSampleRate float64 `mapstructure:"diff_sample_rate"`
+}
+
+// ResourcePolicy identifies a route's target resource: its Type, which
+// selects the lookup in AuthzConfig.ResourceURLs, and the path parameter
+// holding its ID (default "id").
+type ResourcePolicy struct {
+	Type  string `mapstructure:"type"`
+	Param string `mapstructure:"param"`
+}
+
+// RetryPolicy retries a failed backend call up to Attempts more times,
+// waiting about Backoff milliseconds before the first retry and twice as
+// long before each one after.
+type RetryPolicy struct {
+	Attempts int `mapstructure:"attempts"`
+	Backoff  int `mapstructure:"backoff"`
+}
+
+// ShadowConnName names the gRPC connection for a service's shadow backend.
+func ShadowConnName(service string) string {
+	return service + "@shadow"
+}
+
+// VariantRule sends a route's calls to Service at Address instead of the
+// default backend when the request's Header (or, if Header is empty,
+// Cookie) equals Value.
+type VariantRule struct {
+	Name    string `mapstructure:"name"`
+	Header  string `mapstructure:"header"`
+	Cookie  string `mapstructure:"cookie"`
+	Value   string `mapstructure:"value"`
+	Service string `mapstructure:"service"`
+	Address string `mapstructure:"address"`
+}
+
+// VariantConnName names the gRPC connection for a service's variant.
+func VariantConnName(service, variant string) string {
+	return service + "@" + variant
+}
+
+// FallbackPolicy is what a route returns while its backend is unavailable.
+// With Cache set, the last good cached response is served if there is one
+// (the route also needs a cache policy); otherwise Body, if set, is returned
+// as JSON with Status (default 200). For list endpoints an empty but valid
+// envelope is usually the most useful Body.
+type FallbackPolicy struct {
+	Cache  bool        `mapstructure:"cache"`
+	Status int         `mapstructure:"status"`
+	Body   interface{} `mapstructure:"body"`
+}
+
+// UploadPolicy restricts file uploads on a route. Unset fields inherit the
+// global UploadConfig.
+type UploadPolicy struct {
+	MaxBytes     int64    `mapstructure:"max_bytes"`
+	AllowedTypes []string `mapstructure:"allowed_types"`
+}
+
+// CachePolicy configures response caching for a route. Durations are seconds.
+type CachePolicy struct {
+	TTL int `mapstructure:"ttl"`
+	// StaleIfError serves an expired entry for up to this long past TTL when
+	// the upstream fails, instead of returning the error. Zero disables it.
+	StaleIfError int `mapstructure:"stale_if_error"`
+}
+
+// TranscodingOverride replaces individual TranscodingConfig fields; unset
+// fields inherit the global value.
+type TranscodingOverride struct {
+	UseProtoNames   *bool `mapstructure:"use_proto_names"`
+	EmitUnpopulated *bool `mapstructure:"emit_unpopulated"`
+	UseEnumNumbers  *bool `mapstructure:"use_enum_numbers"`
+}
+
+// Apply returns base with the override's set fields replaced.
+func (o *TranscodingOverride) Apply(base TranscodingConfig) TranscodingConfig {
+	if o == nil {
+		return base
+	}
+	if o.UseProtoNames != nil {
+		base.UseProtoNames = *o.UseProtoNames
+	}
+	if o.EmitUnpopulated != nil {
+		base.EmitUnpopulated = *o.EmitUnpopulated
+	}
+	if o.UseEnumNumbers != nil {
+		base.UseEnumNumbers = *o.UseEnumNumbers
+	}
+	return base
+}
+
+// RouteTable indexes route policies by RouteKey.
+type RouteTable map[string]RouteConfig
+
+// RouteKey is the canonical lookup key for a route.
+func RouteKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// Lookup returns the policy for the route, if one is declared.
+func (t RouteTable) Lookup(method, path string) (RouteConfig, bool) {
+	route, ok := t[RouteKey(method, path)]
+	return route, ok
+}
+
+// HasCosts reports whether any route declares a cost.
+func (t RouteTable) HasCosts() bool {
+	for _, route := range t {
+		if route.Cost > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadPolicy returns the effective upload limits for a route: its own
+// policy where set, the global defaults otherwise.
+func (t RouteTable) UploadPolicy(method, path string, defaults UploadConfig) UploadPolicy {
+	policy := UploadPolicy{MaxBytes: defaults.MaxBytes, AllowedTypes: defaults.AllowedTypes}
+	if route, ok := t.Lookup(method, path); ok && route.Upload != nil {
+		if route.Upload.MaxBytes > 0 {
+			policy.MaxBytes = route.Upload.MaxBytes
+		}
+		if len(route.Upload.AllowedTypes) > 0 {
+			policy.AllowedTypes = route.Upload.AllowedTypes
+		}
+	}
+	return policy
+}
+
+// routeDecodeHook reads durations in the routes file as Go durations, or
+// bare numbers as seconds, and comma-separated strings as lists, as
+// viper's default hooks would.
+func routeDecodeHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	switch {
+	case to == reflect.TypeOf(time.Duration(0)):
+		switch v := data.(type) {
+		case string:
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return time.Duration(n * float64(time.Second)), nil
+			}
+			return time.ParseDuration(v)
+		case int:
+			return time.Duration(v) * time.Second, nil
+		case int64:
+			return time.Duration(v) * time.Second, nil
+		case float64:
+			return time.Duration(v * float64(time.Second)), nil
+		}
+	case from.Kind() == reflect.String && to.Kind() == reflect.Slice && to.Elem().Kind() == reflect.String:
+		if data.(string) == "" {
+			return []string{}, nil
+		}
+		return strings.Split(data.(string), ","), nil
+	}
+	return data, nil
+}
+
+func loadRoutes(path string) (RouteTable, error) {
+	table := make(RouteTable)
+	if path == "" {
+		return table, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read routes file %s: %w", path, err)
+	}
+
+	var routes []RouteConfig
+	if err := v.UnmarshalKey("routes", &routes, viper.DecodeHook(routeDecodeHook)); err != nil {
+		return nil, fmt.Errorf("failed to parse routes file %s: %w", path, err)
+	}
+
+	for _, route := range routes {
+		if route.Method == "" || route.Path == "" {
+			return nil, fmt.Errorf("route entry in %s is missing method or path", path)
+		}
+		for _, v := range route.Variants {
+			if v.Name == "" || v.Service == "" || v.Address == "" || v.Value == "" {
+				return nil, fmt.Errorf("variant on %s %s needs name, value, service and address", route.Method, route.Path)
+			}
+			if (v.Header == "") == (v.Cookie == "") {
+				return nil, fmt.Errorf("variant %q on %s %s must set exactly one of header or cookie", v.Name, route.Method, route.Path)
+			}
+		}
+		if sh := route.Shadow; sh != nil {
+			if !strings.EqualFold(route.Method, "GET") {
+				return nil, fmt.Errorf("shadow on %s %s: only GET routes can be shadowed", route.Method, route.Path)
+			}
+			if sh.Service == "" || sh.Address == "" {
+				return nil, fmt.Errorf("shadow on %s %s needs service and address", route.Method, route.Path)
+			}
+			if sh.SampleRate <= 0 || sh.SampleRate > 1 {
+				return nil, fmt.Errorf("shadow on %s %s: sample_rate must be in (0, 1]", route.Method, route.Path)
+			}
+			if sh.DiffSampleRate < 0 || sh.DiffSampleRate > 1 {
+				return nil, fmt.Errorf("shadow on %s %s: diff_sample_rate must be in [0, 1]", route.Method, route.Path)
+			}
+		}
+		switch strings.ToLower(route.Priority) {
+		case "", "critical", "normal", "low":
+		default:
+			return nil, fmt.Errorf("priority on %s %s must be critical, normal or low", route.Method, route.Path)
+		}
+		if r := route.Resource; r != nil && r.Type == "" {
+			return nil, fmt.Errorf("resource on %s %s needs a type", route.Method, route.Path)
+		}
+		if route.Timeout < 0 || (route.Shadow != nil && route.Shadow.Timeout < 0) {
+			return nil, fmt.Errorf("timeouts on %s %s must not be negative", route.Method, route.Path)
+		}
+		if r := route.Retry; r != nil && (r.Attempts < 1 || r.Backoff < 0) {
+			return nil, fmt.Errorf("retry on %s %s needs attempts of at least 1 and a non-negative backoff", route.Method, route.Path)
+		}
+		for _, t := range route.ContentTypes {
+			if _, _, err := mime.ParseMediaType(t); err != nil || !strings.Contains(t, "/") {
+				return nil, fmt.Errorf("content_types on %s %s: %q is not a media type", route.Method, route.Path, t)
+			}
+		}
+		if route.Cost < 0 {
+			return nil, fmt.Errorf("cost on %s %s must not be negative", route.Method, route.Path)
+		}
+		if route.APIKey && len(route.Scopes) > 0 {
+			return nil, fmt.Errorf("api_key on %s %s: API keys carry no scopes, so the route can't require any", route.Method, route.Path)
+		}
+		if r := route.ValidateResponseRate; r != nil && (*r < 0 || *r > 1) {
+			return nil, fmt.Errorf("validate_response_rate on %s %s must be in [0, 1]", route.Method, route.Path)
+		}
+		if q := route.Query; q != nil {
+			switch q.Unknown {
+			case "":
+				q.Unknown = QueryUnknownAllow
+			case QueryUnknownAllow, QueryUnknownStrip, QueryUnknownReject:
+			default:
+				return nil, fmt.Errorf("query on %s %s: unknown must be allow, strip or reject", route.Method, route.Path)
+			}
+			for i := range q.Params {
+				p := &q.Params[i]
+				if p.Type == "" {
+					p.Type = "string"
+				}
+				switch p.Type {
+				case "string", "integer", "number", "boolean":
+				default:
+					return nil, fmt.Errorf("query parameter %q on %s %s: type must be string, integer, number or boolean", p.Name, route.Method, route.Path)
+				}
+				if p.Name == "" {
+					return nil, fmt.Errorf("query parameter on %s %s needs a name", route.Method, route.Path)
+				}
+			}
+		}
+		for _, f := range route.Faults {
+			if f.Probability <= 0 || f.Probability > 1 {
+				return nil, fmt.Errorf("fault on %s %s needs a probability in (0, 1]", route.Method, route.Path)
+			}
+			switch f.Kind {
+			case FaultLatency:
+				if f.Latency <= 0 {
+					return nil, fmt.Errorf("latency fault on %s %s needs a positive latency", route.Method, route.Path)
+				}
+			case FaultError:
+				if code, err := f.GRPCCode(); err != nil || code == codes.OK {
+					return nil, fmt.Errorf("error fault on %s %s needs a gRPC status code other than OK, e.g. UNAVAILABLE", route.Method, route.Path)
+				}
+			case FaultDrop:
+			default:
+				return nil, fmt.Errorf("fault on %s %s: kind must be latency, error or drop", route.Method, route.Path)
+			}
+		}
+		seenVersions := make(map[string]bool)
+		for i := range route.Versions {
+			v := &route.Versions[i]
+			v.Version = strings.TrimPrefix(v.Version, "v")
+			if v.Version == "" || seenVersions[v.Version] {
+				return nil, fmt.Errorf("versions on %s %s need a distinct version each", route.Method, route.Path)
+			}
+			seenVersions[v.Version] = true
+			for _, r := range v.Rename {
+				if r.From == "" || r.To == "" || strings.Contains(r.To, ".") {
+					return nil, fmt.Errorf("rename in version %s on %s %s needs from and a plain field name to", v.Version, route.Method, route.Path)
+				}
+			}
+		}
+		if err := route.expandHeaders(); err != nil {
+			return nil, err
+		}
+		if err := route.addDeprecationHeaders(); err != nil {
+			return nil, err
+		}
+		key := RouteKey(route.Method, route.Path)
+		if _, exists := table[key]; exists {
+			return nil, fmt.Errorf("duplicate route entry %q in %s", key, path)
+		}
+		table[key] = route
+	}
+	return table, nil
+}
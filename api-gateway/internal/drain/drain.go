@@ -0,0 +1,148 @@
+// Package drain staggers shutdowns across gateway replicas, so a rolling
+// deploy or a scale-down never drains every replica at once.
+package drain
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const replicasKey = "drain:replicas"
+
+// leaveScript removes ARGV[1] from the live replicas in KEYS[1] if at
+// least ARGV[3] others would remain, first forgetting replicas whose last
+// heartbeat is older than ARGV[2] (unix milliseconds). Replicas that are
+// themselves waiting to drain still serve traffic, so they count as
+// healthy. It returns {left, healthy others}.
+var leaveScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[2])
+local others = redis.call("ZCARD", KEYS[1])
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	others = others - 1
+end
+if others < tonumber(ARGV[3]) then
+	return {0, others}
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+return {1, others}
+`)
+
+// Coordinator keeps this replica's heartbeat in Redis and, at shutdown,
+// holds it until enough other replicas are healthy to take its traffic.
+type Coordinator struct {
+	client     *redis.Client
+	id         string
+	minHealthy int
+	maxWait    time.Duration
+	heartbeat  time.Duration
+	logger     *zap.Logger
+
+	// mu orders heartbeats with leaving, so a heartbeat already under way
+	// can't add the replica back once it has left.
+	mu      sync.Mutex
+	leaving bool
+}
+
+func NewCoordinator(client *redis.Client, cfg config.DrainConfig, logger *zap.Logger) *Coordinator {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gateway"
+	}
+	return &Coordinator{
+		client:     client,
+		id:         fmt.Sprintf("%s-%d", host, os.Getpid()),
+		minHealthy: cfg.MinHealthy,
+		maxWait:    cfg.MaxWait,
+		heartbeat:  cfg.Heartbeat,
+		logger:     logger,
+	}
+}
+
+// Run heartbeats until ctx is done or the replica has been let go.
+func (d *Coordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.heartbeat)
+	defer ticker.Stop()
+	for {
+		d.mu.Lock()
+		if !d.leaving {
+			err := d.client.ZAdd(ctx, replicasKey, &redis.Z{
+				Score:  float64(time.Now().UnixMilli()),
+				Member: d.id,
+			}).Err()
+			if err != nil && ctx.Err() == nil {
+				d.logger.Warn("Failed to record drain heartbeat", zap.Error(err))
+			}
+		}
+		d.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain blocks until this replica may shut down: when at least the
+// configured minimum of other replicas are healthy, or after the maximum
+// wait. Redis errors let it go at once; a missing coordinator must not wedge
+// a shutdown.
+func (d *Coordinator) Drain(ctx context.Context) {
+	start := time.Now()
+	outcome := "approved"
+	defer func() {
+		metrics.DrainWait.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, d.maxWait)
+	defer cancel()
+	poll := time.NewTicker(d.heartbeat)
+	defer poll.Stop()
+	for {
+		staleBefore := time.Now().Add(-3 * d.heartbeat).UnixMilli()
+		d.mu.Lock()
+		res, err := leaveScript.Run(ctx, d.client, []string{replicasKey},
+			d.id, staleBefore, d.minHealthy).Int64Slice()
+		// Stop heartbeating before the listener stops: on approval the
+		// script has already removed the replica, and on an error nobody
+		// should count on it any more
+		d.leaving = (err == nil && res[0] == 1) || (err != nil && ctx.Err() == nil)
+		d.mu.Unlock()
+		switch {
+		case err != nil && ctx.Err() == nil:
+			outcome = "error"
+			d.logger.Warn("Drain coordination unavailable; shutting down now", zap.Error(err))
+			return
+		case err == nil && res[0] == 1:
+			d.logger.Info("Drain approved",
+				zap.Int64("healthy_replicas", res[1]),
+				zap.Duration("waited", time.Since(start)))
+			return
+		case err == nil:
+			d.logger.Info("Waiting for healthy replicas before draining",
+				zap.Int64("healthy_replicas", res[1]),
+				zap.Int("min_healthy", d.minHealthy))
+		}
+
+		select {
+		case <-ctx.Done():
+			outcome = "timeout"
+			d.mu.Lock()
+			d.leaving = true
+			d.client.ZRem(context.Background(), replicasKey, d.id)
+			d.mu.Unlock()
+			d.logger.Warn("Gave up waiting for healthy replicas; draining anyway",
+				zap.Duration("waited", time.Since(start)))
+			return
+		case <-poll.C:
+		}
+	}
+}
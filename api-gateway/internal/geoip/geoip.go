@@ -0,0 +1,88 @@
+// Package geoip resolves client IPs to the country and autonomous system
+// they belong to, for risk scoring downstream.
+package geoip
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// gRPC metadata keys backends read the client's location from.
+const (
+	MetadataCountry = "x-client-country"
+	MetadataASN     = "x-client-asn"
+	MetadataASOrg   = "x-client-as-org"
+)
+
+// Location is what is known about where a client IP is. Fields the
+// databases have no answer for are left empty.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country the IP is
+	// registered in.
+	Country string
+	// ASN is the number of the autonomous system announcing the IP.
+	ASN uint
+	// ASOrg is the organization that operates the autonomous system.
+	ASOrg string
+}
+
+// Empty reports whether nothing is known about the location.
+func (l Location) Empty() bool {
+	return l.Country == "" && l.ASN == 0 && l.ASOrg == ""
+}
+
+// Metadata renders l as gRPC metadata. Empty fields are omitted.
+func (l Location) Metadata() metadata.MD {
+	md := metadata.MD{}
+	if l.Country != "" {
+		md.Set(MetadataCountry, l.Country)
+	}
+	if l.ASN != 0 {
+		md.Set(MetadataASN, strconv.FormatUint(uint64(l.ASN), 10))
+	}
+	if l.ASOrg != "" {
+		md.Set(MetadataASOrg, l.ASOrg)
+	}
+	return md
+}
+
+// Provider looks up the location of an IP. Lookups must be fast, as they
+// run on every request, and safe for concurrent use.
+type Provider interface {
+	Lookup(ip net.IP) (Location, error)
+}
+
+type contextKey struct{}
+
+// WithLocation returns a copy of ctx carrying loc.
+func WithLocation(ctx context.Context, loc Location) context.Context {
+	return context.WithValue(ctx, contextKey{}, loc)
+}
+
+// FromContext returns the Location carried by ctx, if any.
+func FromContext(ctx context.Context) (Location, bool) {
+	loc, ok := ctx.Value(contextKey{}).(Location)
+	return loc, ok
+}
+
+// OutgoingContext returns ctx with its Location, if any, set as outgoing
+// gRPC metadata. The location keys replace any existing values, empty
+// fields included, so forwarded client headers can never fake a location.
+func OutgoingContext(ctx context.Context) context.Context {
+	loc, ok := FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	existing, _ := metadata.FromOutgoingContext(ctx)
+	md := existing.Copy()
+	for _, key := range []string{MetadataCountry, MetadataASN, MetadataASOrg} {
+		delete(md, key)
+	}
+	for key, values := range loc.Metadata() {
+		md[key] = values
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
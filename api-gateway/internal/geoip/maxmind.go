@@ -0,0 +1,182 @@
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// MaxMindProvider looks IPs up in MaxMind databases memory-mapped from
+// disk: a GeoIP2 or GeoLite2 Country (or City) database for the country
+// and an ASN database for the autonomous system. Either may be left out.
+type MaxMindProvider struct {
+	countryPath string
+	asnPath     string
+	logger      *zap.Logger
+
+	mu       sync.RWMutex
+	country  *maxminddb.Reader
+	asn      *maxminddb.Reader
+	modTimes [2]time.Time
+}
+
+// NewMaxMindProvider opens the databases at countryPath and asnPath, either
+// of which may be empty. The returned provider is usable even when the
+// initial load fails; it finds nothing until a reload succeeds.
+func NewMaxMindProvider(countryPath, asnPath string, logger *zap.Logger) (*MaxMindProvider, error) {
+	p := &MaxMindProvider{countryPath: countryPath, asnPath: asnPath, logger: logger}
+	return p, p.Reload()
+}
+
+// Lookup returns what the databases know about ip.
+func (p *MaxMindProvider) Lookup(ip net.IP) (Location, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var loc Location
+	if p.country != nil {
+		var record countryRecord
+		if err := p.country.Lookup(ip, &record); err != nil {
+			return Location{}, err
+		}
+		loc.Country = record.Country.ISOCode
+	}
+	if p.asn != nil {
+		var record asnRecord
+		if err := p.asn.Lookup(ip, &record); err != nil {
+			return Location{}, err
+		}
+		loc.ASN, loc.ASOrg = record.Number, record.Organization
+	}
+	return loc, nil
+}
+
+// Reload reopens the databases. On failure the previously loaded ones are
+// kept and the failure is counted.
+func (p *MaxMindProvider) Reload() error {
+	country, countryMod, err := openDatabase(p.countryPath)
+	if err != nil {
+		metrics.GeoIPLoadFailures.Inc()
+		return err
+	}
+	asn, asnMod, err := openDatabase(p.asnPath)
+	if err != nil {
+		if country != nil {
+			country.Close()
+		}
+		metrics.GeoIPLoadFailures.Inc()
+		return err
+	}
+
+	p.mu.Lock()
+	oldCountry, oldASN := p.country, p.asn
+	p.country, p.asn = country, asn
+	p.modTimes = [2]time.Time{countryMod, asnMod}
+	p.mu.Unlock()
+
+	// Lookups hold the read lock, so none is still using the old readers.
+	if oldCountry != nil {
+		oldCountry.Close()
+	}
+	if oldASN != nil {
+		oldASN.Close()
+	}
+	return nil
+}
+
+// Watch polls the database files and reloads them whenever one changes,
+// until ctx is cancelled, so updated databases are picked up without a
+// restart.
+func (p *MaxMindProvider) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			countryMod, ok := modTime(p.countryPath)
+			if !ok {
+				continue
+			}
+			asnMod, ok := modTime(p.asnPath)
+			if !ok {
+				continue
+			}
+			p.mu.RLock()
+			changed := !countryMod.Equal(p.modTimes[0]) || !asnMod.Equal(p.modTimes[1])
+			p.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := p.Reload(); err != nil {
+				p.logger.Error("Failed to reload GeoIP databases", zap.Error(err))
+				continue
+			}
+			p.logger.Info("Reloaded GeoIP databases",
+				zap.String("country_db", p.countryPath), zap.String("asn_db", p.asnPath))
+		}
+	}
+}
+
+// Close releases the databases.
+func (p *MaxMindProvider) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, db := range []*maxminddb.Reader{p.country, p.asn} {
+		if db != nil {
+			db.Close()
+		}
+	}
+	p.country, p.asn = nil, nil
+}
+
+// openDatabase opens the database at path, returning a nil reader if path
+// is empty.
+func openDatabase(path string) (*maxminddb.Reader, time.Time, error) {
+	if path == "" {
+		return nil, time.Time{}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat GeoIP database %s: %w", path, err)
+	}
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+	return db, info.ModTime(), nil
+}
+
+// modTime returns the modification time of the file at path, the zero
+// time if path is empty, and false if the file can't be stat'd.
+func modTime(path string) (time.Time, bool) {
+	if path == "" {
+		return time.Time{}, true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
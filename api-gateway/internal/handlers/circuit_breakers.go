@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCircuitBreakers returns this replica's circuit breaker states by
+// backend and, when breaker trips are shared, the states every live replica
+// last published, by replica.
+func ListCircuitBreakers(service *proxy.Service, fleet *proxy.BreakerFleet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := gin.H{"local": service.BreakerStates()}
+		if fleet != nil {
+			states, err := fleet.States(c.Request.Context())
+			if err != nil {
+				apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read fleet circuit breaker states")
+				return
+			}
+			body["replica"] = fleet.Replica()
+			body["fleet"] = states
+		}
+		c.JSON(http.StatusOK, body)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/aggregate"
+	"dharmaguard/api-gateway/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dashboard returns the dashboard's sections in one response, or those the
+// client names in the comma-separated sections query parameter. Each
+// section carries its own status and either its data or its error, so one
+// failing backend doesn't blank out the rest; the response is 207
+// Multi-Status when any section failed. Requested sections over the
+// caller's complexity budget are refused with a 400 before any runs, so a
+// plan with a small budget asks for fewer sections.
+func Dashboard(fetcher *aggregate.Fetcher, sections []aggregate.Section) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		selected, err := selectSections(c.Query("sections"), sections)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+		results, complete, err := fetcher.Fetch(c, selected)
+		var tooComplex *aggregate.ComplexityError
+		if errors.As(err, &tooComplex) {
+			apierror.AbortWithDetails(c, http.StatusBadRequest, "COMPLEXITY_LIMIT_EXCEEDED",
+				"Request is more complex than your plan allows",
+				map[string]interface{}{"cost": tooComplex.Cost, "budget": tooComplex.Budget})
+			return
+		}
+		status := http.StatusOK
+		if !complete {
+			status = http.StatusMultiStatus
+		}
+		c.JSON(status, gin.H{"sections": results, "partial": !complete})
+	}
+}
+
+// selectSections returns the sections named in the comma-separated list, in
+// the dashboard's order, or all of them for an empty list.
+func selectSections(list string, sections []aggregate.Section) ([]aggregate.Section, error) {
+	if strings.TrimSpace(list) == "" {
+		return sections, nil
+	}
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		wanted[strings.TrimSpace(name)] = true
+	}
+	var selected []aggregate.Section
+	for _, section := range sections {
+		if wanted[section.Name] {
+			selected = append(selected, section)
+			delete(wanted, section.Name)
+		}
+	}
+	for name := range wanted {
+		if name != "" {
+			return nil, fmt.Errorf("unknown dashboard section %q", name)
+		}
+	}
+	return selected, nil
+}
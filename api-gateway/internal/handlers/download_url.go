@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type downloadURLResponse struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Proxied is true when the URL is the gateway's own download route,
+	// used for files that aren't in object storage.
+	Proxied bool `json:"proxied"`
+}
+
+// GetDownloadURL returns a short-lived signed URL for downloading /:id
+// straight from object storage, once the file service has confirmed the
+// caller may read the file. Without object storage (presigner nil) or for
+// files outside it, the URL points at the proxied download route instead.
+// When the file service signs its own URL for the file, the client gets a
+// gateway link to it, valid for ttl at most, and never the backend URL.
+func GetDownloadURL(files *storage.FileClient, presigner *storage.Presigner, links *storage.LinkStore, ttl time.Duration, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := files.Lookup(c.Request.Context(), c.Param("id"))
+		switch {
+		case errors.Is(err, storage.ErrFileNotFound), errors.Is(err, storage.ErrFileDenied):
+			// Denials look like missing files so IDs can't be probed.
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "File not found")
+			return
+		case err != nil:
+			logger.Error("File lookup failed", zap.String("file_id", c.Param("id")), zap.Error(err))
+			apierror.Abort(c, http.StatusBadGateway, "UPSTREAM_ERROR", "Failed to look up file")
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		if file.DownloadURL != "" {
+			link := storage.Link{URL: file.DownloadURL, Filename: file.Filename, ContentType: file.ContentType}
+			token, expiresAt, err := links.Issue(c.Request.Context(), link, ttl, time.Now())
+			if err != nil {
+				logger.Error("Failed to issue download link", zap.String("file_id", file.ID), zap.Error(err))
+				apierror.Abort(c, http.StatusBadGateway, "UPSTREAM_ERROR", "Failed to create download URL")
+				return
+			}
+			expiresAt = expiresAt.UTC()
+			c.JSON(http.StatusOK, downloadURLResponse{
+				URL:       path.Dir(strings.TrimSuffix(c.Request.URL.Path, "/download-url")) + "/links/" + token,
+				ExpiresAt: &expiresAt,
+				Proxied:   true,
+			})
+			return
+		}
+		if presigner == nil || file.ObjectKey == "" {
+			c.JSON(http.StatusOK, downloadURLResponse{
+				URL:     strings.TrimSuffix(c.Request.URL.Path, "/download-url") + "/download",
+				Proxied: true,
+			})
+			return
+		}
+
+		now := time.Now()
+		signed, err := presigner.PresignGet(file.ObjectKey, file.Filename, ttl, now)
+		if err != nil {
+			logger.Error("Failed to presign download URL", zap.String("file_id", file.ID), zap.Error(err))
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create download URL")
+			return
+		}
+		expiresAt := now.Add(ttl).UTC()
+		c.JSON(http.StatusOK, downloadURLResponse{URL: signed, ExpiresAt: &expiresAt})
+	}
+}
+
+// FetchFileLink serves a download link issued by GetDownloadURL, fetching
+// the backend URL behind it. The token is the credential, as a presigned
+// URL's signature is, so the route sits outside authentication; it stops
+// working when the link expires.
+func FetchFileLink(links *storage.LinkStore, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, err := links.Resolve(c.Request.Context(), c.Param("token"))
+		if errors.Is(err, storage.ErrLinkNotFound) {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "Download link not found or expired")
+			return
+		}
+		if err != nil {
+			logger.Error("Download link lookup failed", zap.Error(err))
+			apierror.Abort(c, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Failed to look up download link")
+			return
+		}
+
+		resp, err := links.Open(c.Request.Context(), link, c.Request.Header)
+		if err != nil {
+			logger.Error("Backend download failed", zap.Error(err))
+			apierror.Abort(c, http.StatusBadGateway, "UPSTREAM_ERROR", "Failed to fetch file")
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			logger.Warn("Backend refused download", zap.Int("status", resp.StatusCode))
+			apierror.Abort(c, http.StatusBadGateway, "UPSTREAM_ERROR", "Failed to fetch file")
+			return
+		}
+
+		for _, h := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+			if v := resp.Header.Get(h); v != "" {
+				c.Header(h, v)
+			}
+		}
+		if link.ContentType != "" && resp.Header.Get("Content-Type") == "" {
+			c.Header("Content-Type", link.ContentType)
+		}
+		if link.Filename != "" {
+			c.Header("Content-Disposition", storage.ContentDisposition(link.Filename))
+		}
+		c.Header("Cache-Control", "no-store")
+		// The server's write timeout would cut a large file off.
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			logger.Debug("Could not clear write deadline for download", zap.Error(err))
+		}
+		c.Status(resp.StatusCode)
+		io.Copy(c.Writer, resp.Body)
+	}
+}
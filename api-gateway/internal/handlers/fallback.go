@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotFound is the router's NoRoute handler.
+func NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metrics.UnmatchedRoutes.WithLabelValues("not_found").Inc()
+		apierror.Abort(c, http.StatusNotFound, "NOT_FOUND",
+			"No route matches "+c.Request.Method+" "+c.Request.URL.Path)
+	}
+}
+
+// MethodNotAllowed is the router's NoMethod handler. It answers with the
+// methods the path does support in the Allow header.
+func MethodNotAllowed(router *gin.Engine) gin.HandlerFunc {
+	var (
+		once   sync.Once
+		routes []gin.RouteInfo
+	)
+
+	return func(c *gin.Context) {
+		// Routes are all registered before the server starts serving.
+		once.Do(func() { routes = router.Routes() })
+
+		metrics.UnmatchedRoutes.WithLabelValues("method_not_allowed").Inc()
+		if allowed := allowedMethods(routes, c.Request.URL.Path); len(allowed) > 0 {
+			c.Header("Allow", strings.Join(allowed, ", "))
+		}
+		apierror.Abort(c, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED",
+			"Method "+c.Request.Method+" is not allowed on "+c.Request.URL.Path)
+	}
+}
+
+func allowedMethods(routes []gin.RouteInfo, path string) []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, r := range routes {
+		if !seen[r.Method] && matchTemplate(r.Path, path) {
+			seen[r.Method] = true
+			methods = append(methods, r.Method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchTemplate reports whether path matches a gin route template, where
+// ":name" matches one segment and "*name" matches the remainder.
+func matchTemplate(template, path string) bool {
+	tSegs := strings.Split(strings.Trim(template, "/"), "/")
+	pSegs := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range tSegs {
+		if strings.HasPrefix(seg, "*") {
+			return true
+		}
+		if i >= len(pSegs) {
+			return false
+		}
+		if !strings.HasPrefix(seg, ":") && seg != pSegs[i] {
+			return false
+		}
+	}
+	return len(tSegs) == len(pSegs)
+}
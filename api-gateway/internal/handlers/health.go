@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"dharmaguard/api-gateway/internal/buildinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheck reports that the gateway is up. Load balancers poll the plain
+// form; ?verbose=true adds the build and runtime details, to confirm which
+// build is running where.
+func HealthCheck(c *gin.Context) {
+	if c.Query("verbose") != "true" {
+		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+		return
+	}
+
+	uptime := buildinfo.Uptime()
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "healthy",
+		"build":          buildinfo.Get(),
+		"uptime":         uptime.Truncate(time.Second).String(),
+		"uptime_seconds": int64(uptime.Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+	})
+}
+
+// Version returns the running build's version details.
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalJWKS publishes the public keys backends use to verify gateway
+// internal tokens. It is served on the internal metrics port only.
+func InternalJWKS(signer *auth.InternalTokenSigner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		c.JSON(http.StatusOK, gin.H{"keys": signer.JWKS()})
+	}
+}
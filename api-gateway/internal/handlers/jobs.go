@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/jobs"
+	"dharmaguard/api-gateway/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobPollSeconds is the Retry-After suggested while a job is running.
+const jobPollSeconds = "5"
+
+// GetJob reports the status of an async job and, once it has finished, its
+// result or error. Jobs are visible only to the user and tenant that
+// submitted them; anyone else gets a 404.
+func GetJob(store *jobs.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, err := store.Get(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, jobs.ErrJobNotFound) ||
+			(err == nil && (job.UserID != c.GetString(middleware.ContextKeyUserID) ||
+				job.TenantID != c.GetString(middleware.ContextKeyTenantID))) {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "Job not found")
+			return
+		}
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read job")
+			return
+		}
+
+		if job.Status == jobs.StatusRunning {
+			c.Header("Retry-After", jobPollSeconds)
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
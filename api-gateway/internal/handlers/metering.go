@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metering"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportUsage returns every API key's usage for ?period=, defaulting to the
+// current period, for import into billing.
+func ExportUsage(meter *metering.Meter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period := c.DefaultQuery("period", meter.CurrentPeriod(time.Now()))
+		usage, err := meter.Export(c.Request.Context(), period)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read usage")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"period": period, "usage": usage})
+	}
+}
+
+// GetKeyUsage returns the usage of the API key fingerprint /:key_id for
+// ?period=, defaulting to the current period.
+func GetKeyUsage(meter *metering.Meter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period := c.DefaultQuery("period", meter.CurrentPeriod(time.Now()))
+		usage, err := meter.Get(c.Request.Context(), period, c.Param("key_id"))
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read usage")
+			return
+		}
+		c.JSON(http.StatusOK, usage)
+	}
+}
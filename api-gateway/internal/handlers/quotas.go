@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/audit"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setQuotaRequest struct {
+	Limit  int64  `json:"limit" binding:"required,min=1"`
+	Period string `json:"period" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// GetQuota returns the quota for /:scope/:id with its usage this period, or
+// 404.
+func GetQuota(store *quota.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, id, ok := quotaTarget(c)
+		if !ok {
+			return
+		}
+
+		status, err := store.Status(c.Request.Context(), scope, id)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read quota")
+			return
+		}
+		if status == nil {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "No quota set")
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// SetQuota creates or replaces the quota for /:scope/:id. Usage already
+// counted in the current period still applies to the new limit.
+func SetQuota(store *quota.Store, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, id, ok := quotaTarget(c)
+		if !ok {
+			return
+		}
+
+		var req setQuotaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+		if !quota.ValidPeriod(req.Period) {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Period must be daily or monthly")
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, scope, id)
+
+		q := quota.Quota{
+			Limit:  req.Limit,
+			Period: req.Period,
+			Reason: req.Reason,
+			SetBy:  c.GetString(middleware.ContextKeyUserID),
+			SetAt:  time.Now().UTC(),
+		}
+		if err := store.Set(ctx, scope, id, q); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to store quota")
+			return
+		}
+
+		auditQuotaChange(c, auditClient, "QUOTA_SET", scope, id, previous, &q)
+		c.JSON(http.StatusOK, q)
+	}
+}
+
+// DeleteQuota removes the quota for /:scope/:id, leaving it unlimited.
+func DeleteQuota(store *quota.Store, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, id, ok := quotaTarget(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, scope, id)
+		if err := store.Delete(ctx, scope, id); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete quota")
+			return
+		}
+
+		auditQuotaChange(c, auditClient, "QUOTA_DELETED", scope, id, previous, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func quotaTarget(c *gin.Context) (scope, id string, ok bool) {
+	scope, id = c.Param("scope"), c.Param("id")
+	if !quota.ValidScope(scope) {
+		apierror.Abort(c, http.StatusBadRequest, "INVALID_SCOPE", "Scope must be tenant or api_key")
+		return "", "", false
+	}
+	return scope, id, true
+}
+
+func auditQuotaChange(c *gin.Context, auditClient *audit.Client, action, scope, id string, previous *quota.Quota, current *quota.Quota) {
+	event := audit.Event{
+		TenantID:     c.GetString(middleware.ContextKeyTenantID),
+		UserID:       c.GetString(middleware.ContextKeyUserID),
+		Action:       action,
+		ResourceType: "quota",
+		ResourceID:   id,
+		Metadata: map[string]interface{}{
+			"scope":      scope,
+			"request_id": c.GetString(apierror.RequestIDKey),
+			"client_ip":  c.ClientIP(),
+		},
+	}
+	if previous != nil {
+		event.OldValues = previous
+	}
+	if current != nil {
+		event.NewValues = current
+	}
+	auditClient.Emit(event)
+}
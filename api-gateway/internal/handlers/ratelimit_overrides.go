@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/audit"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setRateLimitOverrideRequest struct {
+	RequestsPerMinute int    `json:"requests_per_minute" binding:"required,min=1"`
+	Burst             int    `json:"burst" binding:"required,min=1"`
+	TTLSeconds        int    `json:"ttl_seconds" binding:"min=0"`
+	Reason            string `json:"reason" binding:"required"`
+}
+
+// GetRateLimitOverride returns the override for /:scope/:id, or 404.
+func GetRateLimitOverride(store *ratelimit.OverrideStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, id, ok := overrideTarget(c)
+		if !ok {
+			return
+		}
+
+		override, err := store.Get(c.Request.Context(), scope, id)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read rate limit override")
+			return
+		}
+		if override == nil {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "No rate limit override set")
+			return
+		}
+		c.JSON(http.StatusOK, override)
+	}
+}
+
+// SetRateLimitOverride creates or replaces the override for /:scope/:id. A
+// ttl_seconds of zero makes the override permanent.
+func SetRateLimitOverride(store *ratelimit.OverrideStore, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, id, ok := overrideTarget(c)
+		if !ok {
+			return
+		}
+
+		var req setRateLimitOverrideRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, scope, id)
+
+		override := ratelimit.Override{
+			RequestsPerMinute: req.RequestsPerMinute,
+			Burst:             req.Burst,
+			Reason:            req.Reason,
+			SetBy:             c.GetString(middleware.ContextKeyUserID),
+			SetAt:             time.Now().UTC(),
+		}
+		if err := store.Set(ctx, scope, id, override, time.Duration(req.TTLSeconds)*time.Second); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to store rate limit override")
+			return
+		}
+
+		auditOverrideChange(c, auditClient, "RATE_LIMIT_OVERRIDE_SET", scope, id, previous, &override)
+		c.JSON(http.StatusOK, override)
+	}
+}
+
+// DeleteRateLimitOverride removes the override for /:scope/:id, reverting to
+// the static limit.
+func DeleteRateLimitOverride(store *ratelimit.OverrideStore, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, id, ok := overrideTarget(c)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, scope, id)
+		if err := store.Delete(ctx, scope, id); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete rate limit override")
+			return
+		}
+
+		auditOverrideChange(c, auditClient, "RATE_LIMIT_OVERRIDE_DELETED", scope, id, previous, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func overrideTarget(c *gin.Context) (scope, id string, ok bool) {
+	scope, id = c.Param("scope"), c.Param("id")
+	if !ratelimit.ValidScope(scope) {
+		apierror.Abort(c, http.StatusBadRequest, "INVALID_SCOPE", "Scope must be tenant or user")
+		return "", "", false
+	}
+	return scope, id, true
+}
+
+func auditOverrideChange(c *gin.Context, auditClient *audit.Client, action, scope, id string, previous *ratelimit.Override, current *ratelimit.Override) {
+	event := audit.Event{
+		TenantID:     c.GetString(middleware.ContextKeyTenantID),
+		UserID:       c.GetString(middleware.ContextKeyUserID),
+		Action:       action,
+		ResourceType: "rate_limit_override",
+		ResourceID:   id,
+		Metadata: map[string]interface{}{
+			"scope":      scope,
+			"request_id": c.GetString(apierror.RequestIDKey),
+			"client_ip":  c.ClientIP(),
+		},
+	}
+	if previous != nil {
+		event.OldValues = previous
+	}
+	if current != nil {
+		event.NewValues = current
+	}
+	auditClient.Emit(event)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// realtimeStream is a backend server-streaming method behind one of the
+// real-time feeds. Requests and events are generic structs: the gateway
+// passes the client's filters through and relays events as JSON.
+type realtimeStream struct {
+	service string
+	method  string
+}
+
+var (
+	alertsStream        = realtimeStream{"surveillance-engine", "/dharmaguard.surveillance.v1.SurveillanceService/StreamAlerts"}
+	tradesStream        = realtimeStream{"surveillance-engine", "/dharmaguard.surveillance.v1.SurveillanceService/StreamTrades"}
+	notificationsStream = realtimeStream{"notification-service", "/dharmaguard.notification.v1.NotificationService/StreamNotifications"}
+	surveillanceStream  = realtimeStream{"surveillance-engine", "/dharmaguard.surveillance.v1.SurveillanceService/StreamEvents"}
+)
+
+// streamFilterParams are query parameters the gateway consumes itself and
+// doesn't pass on as filters.
+var streamFilterParams = map[string]bool{"timeout": true}
+
+// streamRequest builds the subscription request from the query string: the
+// resume cursor and any filters, such as severity, as string fields.
+func streamRequest(c *gin.Context) (proto.Message, error) {
+	fields := make(map[string]interface{})
+	for name, values := range c.Request.URL.Query() {
+		if !streamFilterParams[name] && len(values) > 0 {
+			fields[name] = values[0]
+		}
+	}
+	return structpb.NewStruct(fields)
+}
+
+func newStreamEvent() proto.Message {
+	return &structpb.Struct{}
+}
+
+// streamCursor returns an event's resume cursor, its "cursor" field.
+func streamCursor(msg proto.Message) string {
+	event, ok := msg.(*structpb.Struct)
+	if !ok {
+		return ""
+	}
+	return event.GetFields()[proxy.CursorQueryParam].GetStringValue()
+}
+
+func (s realtimeStream) webSocket(proxyService *proxy.Service) gin.HandlerFunc {
+	return proxyService.WebSocketHandler(s.service, s.method, streamRequest, newStreamEvent, tokenExpiryNotice)
+}
+
+// tokenExpiryNotice sends the connection's token_expiring warning when it
+// is due, if the client opted in to it.
+func tokenExpiryNotice(ctx context.Context, c *gin.Context, send func(v interface{}) error) {
+	select {
+	case warning := <-middleware.TokenExpiryWarnings(c):
+		send(warning)
+	case <-ctx.Done():
+	}
+}
+
+func (s realtimeStream) longPoll(proxyService *proxy.Service) gin.HandlerFunc {
+	return proxyService.LongPollHandler(s.service, s.method, streamRequest, newStreamEvent, streamCursor)
+}
+
+// AlertsLongPoll serves the alert feed as long polling, the fallback for
+// clients that can't hold a WebSocket or SSE stream open.
+func AlertsLongPoll(proxyService *proxy.Service) gin.HandlerFunc {
+	return alertsStream.longPoll(proxyService)
+}
+
+// TradesLongPoll serves the trade feed as long polling.
+func TradesLongPoll(proxyService *proxy.Service) gin.HandlerFunc {
+	return tradesStream.longPoll(proxyService)
+}
+
+// NotificationsLongPoll serves the caller's notifications as long polling.
+func NotificationsLongPoll(proxyService *proxy.Service) gin.HandlerFunc {
+	return notificationsStream.longPoll(proxyService)
+}
+
+// SurveillanceLongPoll serves the surveillance event feed as long polling.
+func SurveillanceLongPoll(proxyService *proxy.Service) gin.HandlerFunc {
+	return surveillanceStream.longPoll(proxyService)
+}
+
+// AlertsWebSocket streams alerts to a WebSocket as they are raised.
+func AlertsWebSocket(proxyService *proxy.Service) gin.HandlerFunc {
+	return alertsStream.webSocket(proxyService)
+}
+
+// TradesWebSocket streams trades to a WebSocket as they are monitored.
+func TradesWebSocket(proxyService *proxy.Service) gin.HandlerFunc {
+	return tradesStream.webSocket(proxyService)
+}
+
+// NotificationsWebSocket streams the caller's notifications to a WebSocket.
+func NotificationsWebSocket(proxyService *proxy.Service) gin.HandlerFunc {
+	return notificationsStream.webSocket(proxyService)
+}
+
+// SurveillanceWebSocket streams surveillance events to a WebSocket.
+func SurveillanceWebSocket(proxyService *proxy.Service) gin.HandlerFunc {
+	return surveillanceStream.webSocket(proxyService)
+}
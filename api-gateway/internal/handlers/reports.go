@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"io"
+
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const reportingService = "reporting-service"
+
+// reportRequest reads the report parameters from the JSON body. The
+// reporting service validates them, so any JSON object is passed through.
+func reportRequest(c *gin.Context) (proto.Message, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	req := &structpb.Struct{}
+	if len(body) == 0 {
+		return req, nil
+	}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// reportProgress reads a GenerateReportStream update: "percent", "stage",
+// and on the last update "done" and the "file_id" of the report.
+func reportProgress(msg proto.Message) proxy.Progress {
+	update, ok := msg.(*structpb.Struct)
+	if !ok {
+		return proxy.Progress{}
+	}
+	fields := update.GetFields()
+	return proxy.Progress{
+		Percent: int(fields["percent"].GetNumberValue()),
+		Stage:   fields["stage"].GetStringValue(),
+		Done:    fields["done"].GetBoolValue(),
+		FileID:  fields["file_id"].GetStringValue(),
+	}
+}
+
+// GenerateReportWithProgress generates a report while streaming its
+// progress, ending with a link to download it.
+func GenerateReportWithProgress(proxyService *proxy.Service) gin.HandlerFunc {
+	return proxyService.ProgressHandler(reportingService, "/dharmaguard.reporting.v1.ReportingService/GenerateReportStream",
+		reportRequest, newStreamEvent, reportProgress)
+}
+
+// SubmitReportJob generates a report as an async job, for clients that would
+// rather poll or take a webhook than hold a stream open.
+func SubmitReportJob(proxyService *proxy.Service) gin.HandlerFunc {
+	return proxyService.AsyncHandler(reportingService, "/dharmaguard.reporting.v1.ReportingService/GenerateReport",
+		"report", reportRequest, newStreamEvent)
+}
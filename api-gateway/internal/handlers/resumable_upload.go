@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/upload"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Resumable uploads implement the core and creation parts of tus 1.0
+// (https://tus.io/protocols/resumable-upload): POST creates an upload, HEAD
+// reports how much has arrived, PATCH appends from that offset and DELETE
+// abandons it. Once the last byte arrives, the assembled file is submitted
+// to the regular upload route so it gets the same validation, scanning and
+// forwarding as a single-request upload.
+const (
+	tusVersion        = "1.0.0"
+	tusChunkMediaType = "application/offset+octet-stream"
+)
+
+// TusOptions advertises the supported protocol version and extensions.
+func TusOptions(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		c.Header("Tus-Version", tusVersion)
+		c.Header("Tus-Extension", "creation,termination,expiration")
+		c.Header("Tus-Max-Size", strconv.FormatInt(maxBytes, 10))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// CreateUpload starts a resumable upload of Upload-Length bytes. The
+// filename and filetype keys of Upload-Metadata name the file.
+func CreateUpload(store *upload.ResumableStore, maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+
+		length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+		if err != nil || length <= 0 {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Upload-Length must be a positive integer")
+			return
+		}
+		if length > maxBytes {
+			apierror.AbortWithDetails(c, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "Upload exceeds size limit",
+				map[string]interface{}{"max_bytes": maxBytes})
+			return
+		}
+
+		meta := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+		if meta["filename"] == "" {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Upload-Metadata must include filename")
+			return
+		}
+
+		session, err := store.Create(c.Request.Context(), upload.Session{
+			Length:      length,
+			Filename:    meta["filename"],
+			ContentType: meta["filetype"],
+			UserID:      c.GetString(middleware.ContextKeyUserID),
+			TenantID:    c.GetString(middleware.ContextKeyTenantID),
+		})
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create upload")
+			return
+		}
+
+		c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+session.ID)
+		setUploadHeaders(c, session)
+		c.Status(http.StatusCreated)
+	}
+}
+
+// UploadOffset answers HEAD /:id with the number of bytes received so far.
+func UploadOffset(store *upload.ResumableStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		session, ok := ownedSession(c, store)
+		if !ok {
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		setUploadHeaders(c, session)
+		c.Status(http.StatusOK)
+	}
+}
+
+// AppendUpload writes a chunk at Upload-Offset. When the upload completes,
+// the file is handed to submit as a multipart POST to submitPath, on the
+// same request context, and submit's response is returned. submit is the
+// regular upload route's handler with its checks, from CheckUpload.
+func AppendUpload(store *upload.ResumableStore, submit gin.HandlerFunc, submitPath string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		if c.ContentType() != tusChunkMediaType {
+			apierror.Abort(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Chunks must be "+tusChunkMediaType)
+			return
+		}
+		offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Upload-Offset must be a non-negative integer")
+			return
+		}
+		if _, ok := ownedSession(c, store); !ok {
+			return
+		}
+
+		session, err := store.Append(c.Request.Context(), c.Param("id"), offset, c.Request.Body)
+		switch {
+		case errors.Is(err, upload.ErrOffsetMismatch):
+			setUploadHeaders(c, session)
+			apierror.Abort(c, http.StatusConflict, "UPLOAD_OFFSET_MISMATCH", "Upload-Offset does not match the current offset")
+			return
+		case errors.Is(err, upload.ErrSessionBusy):
+			apierror.Abort(c, http.StatusConflict, "UPLOAD_IN_PROGRESS", "Another chunk is being written to this upload")
+			return
+		case errors.Is(err, upload.ErrChunkTooLarge):
+			apierror.Abort(c, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "Chunk exceeds the declared Upload-Length")
+			return
+		case err != nil && session == nil:
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to store chunk")
+			return
+		case err != nil:
+			// The client went away mid-chunk; what arrived is kept and the
+			// client resumes from the new offset.
+			logger.Info("Upload chunk interrupted", zap.String("upload_id", session.ID), zap.Int64("offset", session.Offset))
+			return
+		}
+
+		setUploadHeaders(c, session)
+		if !session.Complete() {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		submitUpload(c, store, session, submit, submitPath, logger)
+	}
+}
+
+// DeleteUpload abandons an upload and discards what was received.
+func DeleteUpload(store *upload.ResumableStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		session, ok := ownedSession(c, store)
+		if !ok {
+			return
+		}
+		if err := store.Delete(c.Request.Context(), session.ID); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete upload")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// submitUpload replays the completed file as a multipart upload, streaming
+// it from the staging file. The session survives a 5xx from that route so
+// the client can retry with an empty PATCH at the final offset.
+func submitUpload(c *gin.Context, store *upload.ResumableStore, session *upload.Session, submit gin.HandlerFunc, submitPath string, logger *zap.Logger) {
+	file, err := store.Open(session.ID)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read upload")
+		return
+	}
+	defer file.Close()
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", multipartFileDisposition(session.Filename))
+		contentType := session.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+		part, err := form.CreatePart(header)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, submitPath, body)
+	if err != nil {
+		body.Close()
+		apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to submit upload")
+		return
+	}
+	req.Header = c.Request.Header.Clone()
+	for _, h := range []string{"Content-Length", "Upload-Offset", "Upload-Length", "Tus-Resumable"} {
+		req.Header.Del(h)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.RemoteAddr = c.Request.RemoteAddr
+	req.ContentLength = -1
+
+	c.Request = req
+	submit(c)
+	body.Close()
+
+	if status := c.Writer.Status(); status < http.StatusInternalServerError {
+		if err := store.Delete(req.Context(), session.ID); err != nil {
+			logger.Warn("Failed to delete completed upload", zap.String("upload_id", session.ID), zap.Error(err))
+		}
+	}
+}
+
+// ownedSession loads /:id, answering 404 if it doesn't exist or belongs to
+// another user.
+func ownedSession(c *gin.Context, store *upload.ResumableStore) (*upload.Session, bool) {
+	session, err := store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, upload.ErrSessionNotFound) ||
+		(err == nil && session.UserID != c.GetString(middleware.ContextKeyUserID)) {
+		apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "Upload not found")
+		return nil, false
+	}
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read upload")
+		return nil, false
+	}
+	return session, true
+}
+
+func setUploadHeaders(c *gin.Context, session *upload.Session) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.Format(http.TimeFormat))
+}
+
+// parseUploadMetadata decodes "key base64value,key base64value".
+func parseUploadMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		meta[key] = string(decoded)
+	}
+	return meta
+}
+
+func multipartFileDisposition(filename string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "").Replace(filename)
+	return `form-data; name="file"; filename="` + escaped + `"`
+}
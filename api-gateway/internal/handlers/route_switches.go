@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/audit"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/routeswitch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// disabledRoutesPath is where the route switches themselves are served.
+const disabledRoutesPath = "/api/v1/admin/routes/disabled"
+
+// undisableableRoutes can't be disabled: the route switches, or nobody
+// could enable anything again, and the health and readiness checks, or the
+// orchestrator would restart or drain a replica that is working.
+var undisableableRoutes = map[string]bool{
+	disabledRoutesPath:            true,
+	"/health":                     true,
+	"/ready":                      true,
+	"/api/v1/admin/system/health": true,
+}
+
+type disableRouteRequest struct {
+	Method string `json:"method" binding:"required"`
+	Path   string `json:"path" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ListDisabledRoutes returns the routes currently disabled.
+func ListDisabledRoutes(store *routeswitch.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := store.Refresh(c.Request.Context()); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read disabled routes")
+			return
+		}
+		routes := store.List()
+		c.JSON(http.StatusOK, gin.H{"routes": routes, "total": len(routes)})
+	}
+}
+
+// DisableRoute disables the route named by method and gin route template
+// (e.g. "/api/v1/compliance/reports/:id") on every replica, until it is
+// enabled again.
+func DisableRoute(store *routeswitch.Store, router *gin.Engine, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req disableRouteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+		method := strings.ToUpper(req.Method)
+		if undisableableRoutes[req.Path] {
+			apierror.AbortWithDetails(c, http.StatusBadRequest, "INVALID_REQUEST", "This route can't be disabled",
+				map[string]interface{}{"method": method, "path": req.Path})
+			return
+		}
+		if !routeRegistered(router, method, req.Path) {
+			apierror.AbortWithDetails(c, http.StatusNotFound, "UNKNOWN_ROUTE", "No such route",
+				map[string]interface{}{"method": method, "path": req.Path})
+			return
+		}
+
+		sw := routeswitch.Switch{
+			Method:     method,
+			Path:       req.Path,
+			Reason:     req.Reason,
+			DisabledBy: c.GetString(middleware.ContextKeyUserID),
+			DisabledAt: time.Now().UTC(),
+		}
+		ctx := c.Request.Context()
+		previous, wasDisabled := store.Disabled(method, req.Path)
+		if err := store.Disable(ctx, sw); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to disable route")
+			return
+		}
+
+		var old *routeswitch.Switch
+		if wasDisabled {
+			old = &previous
+		}
+		auditRouteSwitch(c, auditClient, "ROUTE_DISABLED", sw.Method, sw.Path, old, &sw)
+		c.JSON(http.StatusOK, sw)
+	}
+}
+
+// EnableRoute re-enables the route named by the method and path query
+// parameters, or answers 404 if it isn't disabled.
+func EnableRoute(store *routeswitch.Store, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method, path := strings.ToUpper(c.Query("method")), c.Query("path")
+		if method == "" || path == "" {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "method and path are required")
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Disabled(method, path)
+		enabled, err := store.Enable(ctx, method, path)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to enable route")
+			return
+		}
+		if !enabled {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "Route is not disabled")
+			return
+		}
+
+		auditRouteSwitch(c, auditClient, "ROUTE_ENABLED", method, path, &previous, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func routeRegistered(router *gin.Engine, method, path string) bool {
+	for _, route := range router.Routes() {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func auditRouteSwitch(c *gin.Context, auditClient *audit.Client, action, method, path string, previous, current *routeswitch.Switch) {
+	event := audit.Event{
+		TenantID:     c.GetString(middleware.ContextKeyTenantID),
+		UserID:       c.GetString(middleware.ContextKeyUserID),
+		Action:       action,
+		ResourceType: "route",
+		ResourceID:   routeswitch.Key(method, path),
+		Metadata: map[string]interface{}{
+			"request_id": c.GetString(apierror.RequestIDKey),
+			"client_ip":  c.ClientIP(),
+		},
+	}
+	if previous != nil {
+		event.OldValues = previous
+	}
+	if current != nil {
+		event.NewValues = current
+	}
+	auditClient.Emit(event)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/inventory"
+	"dharmaguard/api-gateway/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRoutes returns the gateway's route inventory: every registered route
+// with its auth, role, scope, rate limit and timeout policy and whether the
+// OpenAPI spec documents it. ?auth=false lists only routes reachable without
+// authentication, to spot endpoints that should be protected.
+func ListRoutes(registry *inventory.Registry, router *gin.Engine, spec *schema.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		routes := registry.Routes(router, spec)
+		if filter := c.Query("auth"); filter != "" {
+			wantAuth := filter == "true"
+			filtered := routes[:0]
+			for _, r := range routes {
+				if r.Auth == wantAuth {
+					filtered = append(filtered, r)
+				}
+			}
+			routes = filtered
+		}
+
+		unclassified := 0
+		for _, r := range routes {
+			if r.Unclassified {
+				unclassified++
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"routes":       routes,
+			"total":        len(routes),
+			"unclassified": unclassified,
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionAdminRoles may list the sessions of other users in their tenant.
+var sessionAdminRoles = []string{"SUPER_ADMIN", "TENANT_ADMIN"}
+
+// GetActiveSessions lists the active sessions of user /:id in the caller's
+// tenant, oldest first. Users may list their own; admins anyone's.
+func GetActiveSessions(store *auth.SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if userID != c.GetString(middleware.ContextKeyUserID) && !hasAnyRole(c, sessionAdminRoles) {
+			apierror.Abort(c, http.StatusForbidden, "FORBIDDEN", "Cannot view another user's sessions")
+			return
+		}
+
+		sessions, err := store.List(c.Request.Context(), c.GetString(middleware.ContextKeyTenantID), userID)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list sessions")
+			return
+		}
+		if sessions == nil {
+			sessions = []auth.Session{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"sessions":     sessions,
+			"max_sessions": store.Limit(),
+		})
+	}
+}
+
+func hasAnyRole(c *gin.Context, roles []string) bool {
+	for _, h := range c.GetStringSlice(middleware.ContextKeyRoles) {
+		for _, r := range roles {
+			if h == r {
+				return true
+			}
+		}
+	}
+	return false
+}
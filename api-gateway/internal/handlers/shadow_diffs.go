@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListShadowDiffs returns the most recent sampled primary/shadow response
+// diffs, newest first. ?limit= defaults to 50.
+func ListShadowDiffs(store *proxy.DiffStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+		if err != nil || limit < 1 || limit > 500 {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "limit must be between 1 and 500")
+			return
+		}
+		diffs, err := store.Recent(c.Request.Context(), limit)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read shadow diffs")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"diffs": diffs})
+	}
+}
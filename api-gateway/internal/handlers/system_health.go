@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SystemHealth checks every backend at once, each within timeout, and
+// reports their status, latency and version together. A backend that fails
+// its check is reported alongside the rest; the response is 207
+// Multi-Status when any is unhealthy.
+func SystemHealth(service *proxy.Service, backends []string, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		results := service.CheckHealth(c.Request.Context(), backends, timeout)
+		overall, code := "healthy", http.StatusOK
+		for _, r := range results {
+			if !r.Healthy() {
+				overall, code = "degraded", http.StatusMultiStatus
+				break
+			}
+		}
+		c.JSON(code, gin.H{"status": overall, "services": results})
+	}
+}
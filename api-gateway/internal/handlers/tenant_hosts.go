@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/audit"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setTenantHostRequest struct {
+	TenantID string `json:"tenant_id" binding:"required"`
+	Reason   string `json:"reason" binding:"required"`
+}
+
+// GetTenantHost returns the tenant mapping for the hostname or wildcard
+// /:host, or 404.
+func GetTenantHost(store *tenant.HostStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host, ok := tenantHostParam(c)
+		if !ok {
+			return
+		}
+		mapping, err := store.Get(c.Request.Context(), host)
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read tenant host mapping")
+			return
+		}
+		if mapping == nil {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "Host is not mapped to a tenant")
+			return
+		}
+		c.JSON(http.StatusOK, mapping)
+	}
+}
+
+// SetTenantHost maps the hostname or wildcard /:host to a tenant.
+func SetTenantHost(store *tenant.HostStore, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host, ok := tenantHostParam(c)
+		if !ok {
+			return
+		}
+		var req setTenantHostRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, host)
+
+		mapping := tenant.HostMapping{
+			TenantID: req.TenantID,
+			Reason:   req.Reason,
+			SetBy:    c.GetString(middleware.ContextKeyUserID),
+			SetAt:    time.Now().UTC(),
+		}
+		if err := store.Set(ctx, host, mapping); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to store tenant host mapping")
+			return
+		}
+
+		auditTenantHostChange(c, auditClient, "TENANT_HOST_SET", host, previous, &mapping)
+		c.JSON(http.StatusOK, mapping)
+	}
+}
+
+// DeleteTenantHost removes the tenant mapping for /:host.
+func DeleteTenantHost(store *tenant.HostStore, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host, ok := tenantHostParam(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, host)
+		if err := store.Delete(ctx, host); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete tenant host mapping")
+			return
+		}
+
+		auditTenantHostChange(c, auditClient, "TENANT_HOST_DELETED", host, previous, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// tenantHostParam returns the normalized /:host, which must be a hostname
+// or a wildcard over a domain of at least two labels.
+func tenantHostParam(c *gin.Context) (string, bool) {
+	host := tenant.NormalizeHost(c.Param("host"))
+	name := strings.TrimPrefix(host, "*.")
+	if name == "" || strings.ContainsAny(name, "*/:") || (name != host && !strings.Contains(name, ".")) {
+		apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "host must be a hostname or a *.domain wildcard")
+		return "", false
+	}
+	return host, true
+}
+
+func auditTenantHostChange(c *gin.Context, auditClient *audit.Client, action, host string, previous *tenant.HostMapping, current *tenant.HostMapping) {
+	event := audit.Event{
+		TenantID:     c.GetString(middleware.ContextKeyTenantID),
+		UserID:       c.GetString(middleware.ContextKeyUserID),
+		Action:       action,
+		ResourceType: "tenant_host_mapping",
+		ResourceID:   host,
+		Metadata: map[string]interface{}{
+			"request_id": c.GetString(apierror.RequestIDKey),
+			"client_ip":  c.ClientIP(),
+		},
+	}
+	if previous != nil {
+		event.OldValues = previous
+	}
+	if current != nil {
+		event.NewValues = current
+	}
+	auditClient.Emit(event)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/audit"
+	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setTenantKeyRequest struct {
+	TenantID string `json:"tenant_id" binding:"required"`
+	Reason   string `json:"reason" binding:"required"`
+}
+
+// GetTenantKey returns the tenant mapping for the API key fingerprint
+// /:key_id, or 404.
+func GetTenantKey(store *tenant.KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mapping, err := store.Get(c.Request.Context(), c.Param("key_id"))
+		if err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read tenant key mapping")
+			return
+		}
+		if mapping == nil {
+			apierror.Abort(c, http.StatusNotFound, "NOT_FOUND", "API key is not mapped to a tenant")
+			return
+		}
+		c.JSON(http.StatusOK, mapping)
+	}
+}
+
+// SetTenantKey maps the API key fingerprint /:key_id to a tenant.
+func SetTenantKey(store *tenant.KeyStore, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.Param("key_id")
+		var req setTenantKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, keyID)
+
+		mapping := tenant.KeyMapping{
+			TenantID: req.TenantID,
+			Reason:   req.Reason,
+			SetBy:    c.GetString(middleware.ContextKeyUserID),
+			SetAt:    time.Now().UTC(),
+		}
+		if err := store.Set(ctx, keyID, mapping); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to store tenant key mapping")
+			return
+		}
+
+		auditTenantKeyChange(c, auditClient, "TENANT_KEY_SET", keyID, previous, &mapping)
+		c.JSON(http.StatusOK, mapping)
+	}
+}
+
+// DeleteTenantKey removes the tenant mapping for /:key_id.
+func DeleteTenantKey(store *tenant.KeyStore, auditClient *audit.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.Param("key_id")
+		ctx := c.Request.Context()
+		previous, _ := store.Get(ctx, keyID)
+		if err := store.Delete(ctx, keyID); err != nil {
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete tenant key mapping")
+			return
+		}
+
+		auditTenantKeyChange(c, auditClient, "TENANT_KEY_DELETED", keyID, previous, nil)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func auditTenantKeyChange(c *gin.Context, auditClient *audit.Client, action, keyID string, previous *tenant.KeyMapping, current *tenant.KeyMapping) {
+	event := audit.Event{
+		TenantID:     c.GetString(middleware.ContextKeyTenantID),
+		UserID:       c.GetString(middleware.ContextKeyUserID),
+		Action:       action,
+		ResourceType: "tenant_key_mapping",
+		ResourceID:   keyID,
+		Metadata: map[string]interface{}{
+			"request_id": c.GetString(apierror.RequestIDKey),
+			"client_ip":  c.ClientIP(),
+		},
+	}
+	if previous != nil {
+		event.OldValues = previous
+	}
+	if current != nil {
+		event.NewValues = current
+	}
+	auditClient.Emit(event)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/warmup"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RunWarmup warms backend connections now, e.g. after a backend restart,
+// and reports the outcome. The response is 207 Multi-Status when a backend
+// didn't connect.
+func RunWarmup(warmer *warmup.Warmer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := warmer.Run(c.Request.Context())
+		code := http.StatusOK
+		if !report.Complete {
+			code = http.StatusMultiStatus
+		}
+		c.JSON(code, report)
+	}
+}
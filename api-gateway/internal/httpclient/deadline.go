@@ -0,0 +1,25 @@
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineHeader carries the remaining request budget, in milliseconds, to
+// HTTP backends; gRPC backends receive it as the standard grpc-timeout.
+const DeadlineHeader = "X-Deadline-Remaining-Ms"
+
+// SetDeadlineHeader stamps an outgoing HTTP backend request with the
+// remaining budget of its context, if it has a deadline.
+func SetDeadlineHeader(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	req.Header.Set(DeadlineHeader, strconv.FormatInt(remaining, 10))
+}
@@ -0,0 +1,192 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+)
+
+// ErrForbiddenDestination is returned for URLs that resolve to an address
+// the gateway must not call.
+var ErrForbiddenDestination = errors.New("destination not allowed")
+
+// Ranges the IP helpers (IsPrivate, IsLoopback, ...) don't cover.
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, which can map to any IPv4 address
+)
+
+// Cloud instance metadata endpoints are refused even when an allowlist
+// would admit them, since they hand out credentials.
+var metadataIPs = []net.IP{
+	net.ParseIP("169.254.169.254"),
+	net.ParseIP("fd00:ec2::254"),
+	net.ParseIP("100.100.100.200"),
+}
+
+// Guard stops outbound requests from reaching loopback, private,
+// link-local and metadata addresses, except for allowlisted hosts and
+// networks. It checks addresses as the connection is dialed and dials the
+// address it checked, so a DNS answer that changes after validation
+// (rebinding) can't redirect the request.
+type Guard struct {
+	hosts    map[string]bool
+	nets     []*net.IPNet
+	resolver *net.Resolver
+	dialer   *net.Dialer
+}
+
+// NewGuard allows the given hostnames, whatever they resolve to, and the
+// given CIDR ranges.
+func NewGuard(allowedHosts, allowedCIDRs []string) (*Guard, error) {
+	g := &Guard{
+		hosts:    make(map[string]bool, len(allowedHosts)),
+		resolver: net.DefaultResolver,
+		dialer:   &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	for _, host := range allowedHosts {
+		if host = normalizeHost(host); host != "" {
+			g.hosts[host] = true
+		}
+	}
+	for _, cidr := range allowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed CIDR %q: %w", cidr, err)
+		}
+		g.nets = append(g.nets, ipNet)
+	}
+	return g, nil
+}
+
+// HostOf returns the hostname of rawURL, or "" if it has none, for
+// allowlisting configured backend URLs.
+func HostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// ValidateURL checks a caller-supplied URL before it is stored or used:
+// it must be absolute http(s) without credentials, and its host must
+// resolve only to allowed addresses. Guarded clients check again when
+// they connect.
+func (g *Guard) ValidateURL(ctx context.Context, rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrForbiddenDestination, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be http or https", ErrForbiddenDestination)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: URL has no host", ErrForbiddenDestination)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: URL must not contain credentials", ErrForbiddenDestination)
+	}
+	if _, err := g.resolve(ctx, u.Hostname()); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// DialContext is a net.Dialer.DialContext replacement for http.Transport.
+func (g *Guard) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := g.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := g.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// resolve returns host's addresses, failing if any of them is forbidden.
+func (g *Guard) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := g.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	trusted := g.hosts[normalizeHost(host)]
+	for _, ip := range ips {
+		if !g.allowed(ip, trusted) {
+			metrics.OutboundBlocked.Inc()
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrForbiddenDestination, host, ip)
+		}
+	}
+	return ips, nil
+}
+
+func (g *Guard) allowed(ip net.IP, trustedHost bool) bool {
+	for _, m := range metadataIPs {
+		if ip.Equal(m) {
+			return false
+		}
+	}
+	if trustedHost {
+		return true
+	}
+	for _, n := range g.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
@@ -0,0 +1,96 @@
+// Package httpclient builds the clients the gateway uses for outbound HTTP
+// (audit, file and token introspection services), with a redirect policy
+// that keeps calls on the host they were addressed to and a Guard against
+// requests to internal addresses. Every feature that fetches a URL should
+// get its client here.
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRedirectLimit   = errors.New("too many redirects")
+	ErrRedirectLoop    = errors.New("redirect loop")
+	ErrRedirectOffHost = errors.New("redirect to another host")
+)
+
+// Policy controls outbound requests. With MaxRedirects zero a redirect is
+// not followed and the 3xx response is returned to the caller as is. A nil
+// Guard leaves destinations unchecked.
+type Policy struct {
+	MaxRedirects int
+	Guard        *Guard
+}
+
+var (
+	mu            sync.RWMutex
+	defaultPolicy = Policy{MaxRedirects: 3}
+)
+
+// Configure sets the policy for clients created afterwards. Call it at
+// startup, before constructing backend clients.
+func Configure(policy Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultPolicy = policy
+}
+
+// New returns a client with the given timeout and the configured policy.
+func New(timeout time.Duration) *http.Client {
+	mu.RLock()
+	policy := defaultPolicy
+	mu.RUnlock()
+	return policy.client(timeout)
+}
+
+// NewGuarded is New with guard in place of the configured one, for
+// destinations chosen by callers rather than by configuration.
+func NewGuarded(timeout time.Duration, guard *Guard) *http.Client {
+	mu.RLock()
+	policy := defaultPolicy
+	mu.RUnlock()
+	policy.Guard = guard
+	return policy.client(timeout)
+}
+
+func (policy Policy) client(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout, CheckRedirect: policy.check}
+	if policy.Guard != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = policy.Guard.DialContext
+		// An environment proxy would dial on our behalf, out of the
+		// guard's sight.
+		transport.Proxy = nil
+		client.Transport = transport
+	}
+	return client
+}
+
+// check follows a redirect only within the original scheme and host, so a
+// backend (or anything impersonating one) can't steer the gateway, and the
+// identity headers it attaches, at internal endpoints such as cloud
+// metadata services. Revisiting a URL is a loop.
+func (p Policy) check(req *http.Request, via []*http.Request) error {
+	if p.MaxRedirects <= 0 {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > p.MaxRedirects {
+		return fmt.Errorf("%w: stopped after %d", ErrRedirectLimit, p.MaxRedirects)
+	}
+	origin := via[0].URL
+	if req.URL.Scheme != origin.Scheme || req.URL.Host != origin.Host {
+		return fmt.Errorf("%w: %s redirected to %s://%s", ErrRedirectOffHost, origin.Host, req.URL.Scheme, req.URL.Host)
+	}
+	target := req.URL.String()
+	for _, prev := range via {
+		if prev.URL.String() == target {
+			return fmt.Errorf("%w: %s", ErrRedirectLoop, req.URL.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+)
+
+// ErrPinMismatch is returned for connections to a pinned backend whose
+// certificate chain carries none of the pinned keys.
+var ErrPinMismatch = errors.New("backend certificate matches no pinned key")
+
+// TLSConfig builds the client TLS settings for calls to service. The usual
+// chain verification runs first; pins are checked on top of it, against
+// every certificate of the verified chain, so an intermediate's key can be
+// pinned as well as the backend's own.
+func TLSConfig(service string, c config.UpstreamTLSConfig) (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.ServerName}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file for %s: %w", service, err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s for %s", c.CAFile, service)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate for %s: %w", service, err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if len(c.Pins) > 0 {
+		pins := make(map[string]bool, len(c.Pins))
+		for _, pin := range c.Pins {
+			pins[pin] = true
+		}
+		conf.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, chain := range state.VerifiedChains {
+				for _, cert := range chain {
+					if pins[Pin(cert)] {
+						return nil
+					}
+				}
+			}
+			metrics.UpstreamPinFailures.WithLabelValues(service).Inc()
+			return fmt.Errorf("%s: %w", service, ErrPinMismatch)
+		}
+	}
+	return conf, nil
+}
+
+// Pin returns cert's pin: "sha256/" and the base64 SHA-256 hash of its
+// public key info.
+func Pin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// NewTLS is New for a backend called with tlsConfig; a nil tlsConfig
+// gives New's client.
+func NewTLS(timeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	client := New(timeout)
+	if tlsConfig == nil {
+		return client
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = tlsConfig
+	client.Transport = transport
+	return client
+}
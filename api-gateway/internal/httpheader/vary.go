@@ -0,0 +1,33 @@
+// Package httpheader holds helpers for response headers several layers of
+// the gateway contribute to.
+package httpheader
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AddVary adds names to h's Vary header, keeping the names already listed
+// and listing each once, case-insensitively. A Vary of "*" absorbs the rest.
+func AddVary(h http.Header, names ...string) {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, value := range append(h.Values("Vary"), names...) {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				h.Set("Vary", "*")
+				return
+			}
+			if key := strings.ToLower(name); name != "" && !seen[key] {
+				seen[key] = true
+				merged = append(merged, name)
+			}
+		}
+	}
+	if len(merged) == 0 {
+		h.Del("Vary")
+		return
+	}
+	h.Set("Vary", strings.Join(merged, ", "))
+}
@@ -0,0 +1,190 @@
+package inventory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/ratelimit"
+	"dharmaguard/api-gateway/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy describes the access controls applied to a group of routes. It is
+// declared where the group is mounted (see Registry.Group), since a
+// compiled gin handler chain can't be inspected.
+type Policy struct {
+	Auth      bool
+	Audiences []string
+	Roles     []string
+	RateLimit *ratelimit.Limit
+}
+
+// Route is one entry in the inventory.
+type Route struct {
+	Method    string           `json:"method"`
+	Path      string           `json:"path"`
+	Auth      bool             `json:"auth"`
+	Audiences []string         `json:"audiences,omitempty"`
+	Roles     []string         `json:"roles,omitempty"`
+	Scopes    []string         `json:"scopes,omitempty"`
+	RateLimit *ratelimit.Limit `json:"rate_limit,omitempty"`
+	// TimeoutSeconds is the request deadline; zero for long-lived routes
+	// (WebSocket, long polling, downloads) that run without one.
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	Cached         bool    `json:"cached"`
+	Documented     bool    `json:"documented"`
+	// Unclassified is set when no policy covers the route, usually an
+	// endpoint registered outside the known groups.
+	Unclassified bool `json:"unclassified,omitempty"`
+}
+
+// Registry maps path prefixes to the policy of the route group mounted
+// there, and combines it with per-route policy from the routes config. The
+// route list itself is read from the router on each call, so routes
+// registered later still appear.
+type Registry struct {
+	routes         config.RouteTable
+	defaultTimeout time.Duration
+	longLived      []string
+
+	mu       sync.RWMutex
+	policies map[string]Policy
+}
+
+// NewRegistry takes the per-route config, the server's default request
+// timeout and the path prefixes of routes that run without one.
+func NewRegistry(routes config.RouteTable, defaultTimeout time.Duration, longLived ...string) *Registry {
+	return &Registry{routes: routes, defaultTimeout: defaultTimeout, longLived: longLived, policies: make(map[string]Policy)}
+}
+
+func (r *Registry) isLongLived(path string) bool {
+	for _, prefix := range r.longLived {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Declare records the policy for routes under prefix. The longest matching
+// prefix applies, so nested groups declare their full policy.
+func (r *Registry) Declare(prefix string, p Policy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies[strings.TrimSuffix(prefix, "/")] = p
+}
+
+// Group mounts a route group at relativePath under parent and declares p
+// for it, so the policy is stated where the group's middleware is.
+func (r *Registry) Group(parent *gin.RouterGroup, relativePath string, p Policy) *gin.RouterGroup {
+	g := parent.Group(relativePath)
+	r.Declare(g.BasePath(), p)
+	return g
+}
+
+// Unclassified returns "METHOD /path" for registered routes that no
+// declared policy covers.
+func (r *Registry) Unclassified(engine *gin.Engine) []string {
+	var missing []string
+	for _, info := range engine.Routes() {
+		if _, ok := r.policyFor(info.Path); !ok {
+			missing = append(missing, info.Method+" "+info.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func (r *Registry) policyFor(path string) (Policy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	best, found := -1, Policy{}
+	for prefix, p := range r.policies {
+		if (path == prefix || strings.HasPrefix(path, prefix+"/")) && len(prefix) > best {
+			best, found = len(prefix), p
+		}
+	}
+	return found, best >= 0
+}
+
+// Routes lists every route registered on engine with its policy, and
+// whether spec documents it.
+func (r *Registry) Routes(engine *gin.Engine, spec *schema.Registry) []Route {
+	infos := engine.Routes()
+	routes := make([]Route, 0, len(infos))
+	for _, info := range infos {
+		route := Route{Method: info.Method, Path: info.Path}
+		if p, ok := r.policyFor(info.Path); ok {
+			route.Auth, route.Audiences, route.Roles, route.RateLimit = p.Auth, p.Audiences, p.Roles, p.RateLimit
+		} else {
+			route.Unclassified = true
+		}
+		rc, _ := r.routes.Lookup(info.Method, info.Path)
+		route.Scopes = rc.Scopes
+		route.Cached = rc.Cache != nil && rc.Cache.TTL > 0
+		switch {
+		case r.isLongLived(info.Path):
+		case rc.Timeout > 0:
+			route.TimeoutSeconds = rc.Timeout.Seconds()
+		default:
+			route.TimeoutSeconds = r.defaultTimeout.Seconds()
+		}
+		if spec != nil {
+			_, route.Documented = spec.Lookup(info.Method, info.Path)
+		}
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Undocumented returns "METHOD /path" for registered routes under any of
+// prefixes that the spec doesn't describe.
+func Undocumented(engine *gin.Engine, spec *schema.Registry, prefixes ...string) []string {
+	var missing []string
+	for _, info := range engine.Routes() {
+		if !hasAnyPrefix(info.Path, prefixes) {
+			continue
+		}
+		if _, ok := spec.Lookup(info.Method, info.Path); !ok {
+			missing = append(missing, info.Method+" "+info.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Unrouted returns "METHOD /path" for operations in the spec that no
+// registered route serves.
+func Unrouted(engine *gin.Engine, spec *schema.Registry) []string {
+	routed := make(map[string]bool)
+	for _, info := range engine.Routes() {
+		routed[schema.OperationKey(info.Method, info.Path)] = true
+	}
+	var stale []string
+	for _, op := range spec.Operations() {
+		if !routed[schema.OperationKey(op.Method, op.Path)] {
+			stale = append(stale, op.Method+" "+op.Path)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
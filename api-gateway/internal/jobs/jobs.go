@@ -0,0 +1,289 @@
+// Package jobs runs long backend operations outside the request that starts
+// them. The request gets a job ID back at once; the operation runs in the
+// background with its own deadline, its state is kept in Redis so any
+// replica can answer status queries, and a webhook can be notified when it
+// finishes.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const jobKeyPrefix = "jobs:"
+
+// StatusPath is where clients poll a job's status; the ID is appended.
+const StatusPath = "/api/v1/jobs/"
+
+// Job statuses.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// lostAfter is how long past its deadline a job may stay running before it
+// is reported failed: the replica running it must have died.
+const lostAfter = time.Minute
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Error is a job failure as reported to clients. Work functions return one
+// to control the code and message; any other error is reported generically.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Job is an async operation and, once it has finished, its outcome.
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Result is the backend's response, as JSON, once the job succeeded.
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+
+	UserID     string    `json:"-"`
+	TenantID   string    `json:"-"`
+	WebhookURL string    `json:"-"`
+	Deadline   time.Time `json:"-"`
+}
+
+// Store keeps job state in Redis. Jobs are deleted retention after they
+// finished, or after they were created if they never do.
+type Store struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+func NewStore(client *redis.Client, retention time.Duration) *Store {
+	return &Store{client: client, retention: retention}
+}
+
+func jobKey(id string) string {
+	return jobKeyPrefix + id
+}
+
+// create records job as running until deadline.
+func (s *Store) create(ctx context.Context, job Job, deadline time.Time) (*Job, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	job.ID = hex.EncodeToString(b)
+	job.Status = StatusRunning
+	job.CreatedAt = time.Now().UTC()
+	job.Deadline = deadline.UTC()
+
+	key := jobKey(job.ID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"kind":        job.Kind,
+		"status":      job.Status,
+		"user_id":     job.UserID,
+		"tenant_id":   job.TenantID,
+		"webhook_url": job.WebhookURL,
+		"created_at":  job.CreatedAt.UnixMilli(),
+		"deadline":    job.Deadline.UnixMilli(),
+	})
+	pipe.ExpireAt(ctx, key, job.CreatedAt.Add(s.retention))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// finish records the outcome of job and restarts its retention. The whole
+// record is written, since a job can finish after its key expired (retention
+// may be as short as the job timeout).
+func (s *Store) finish(ctx context.Context, job *Job) error {
+	values := map[string]interface{}{
+		"kind":        job.Kind,
+		"status":      job.Status,
+		"user_id":     job.UserID,
+		"tenant_id":   job.TenantID,
+		"webhook_url": job.WebhookURL,
+		"created_at":  job.CreatedAt.UnixMilli(),
+		"deadline":    job.Deadline.UnixMilli(),
+		"finished_at": job.FinishedAt.UnixMilli(),
+	}
+	if job.Result != nil {
+		values["result"] = string(job.Result)
+	}
+	if job.Error != nil {
+		values["error_code"] = job.Error.Code
+		values["error_message"] = job.Error.Message
+	}
+	key := jobKey(job.ID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, values)
+	pipe.ExpireAt(ctx, key, job.FinishedAt.Add(s.retention))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Get returns the job with the given ID. A job still running well past its
+// deadline is reported as failed.
+func (s *Store) Get(ctx context.Context, id string) (*Job, error) {
+	values, err := s.client.HGetAll(ctx, jobKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrJobNotFound
+	}
+	job := &Job{
+		ID:         id,
+		Kind:       values["kind"],
+		Status:     values["status"],
+		CreatedAt:  unixMilli(values["created_at"]),
+		UserID:     values["user_id"],
+		TenantID:   values["tenant_id"],
+		WebhookURL: values["webhook_url"],
+		Deadline:   unixMilli(values["deadline"]),
+	}
+	if v, ok := values["finished_at"]; ok {
+		finished := unixMilli(v)
+		job.FinishedAt = &finished
+	}
+	if v, ok := values["result"]; ok {
+		job.Result = json.RawMessage(v)
+	}
+	if code, ok := values["error_code"]; ok {
+		job.Error = &Error{Code: code, Message: values["error_message"]}
+	}
+	if job.Status == StatusRunning && time.Since(job.Deadline) > lostAfter {
+		job.Status = StatusFailed
+		job.Error = &Error{Code: "JOB_LOST", Message: "The job was interrupted before it finished"}
+	}
+	return job, nil
+}
+
+func unixMilli(v string) time.Time {
+	ms, _ := strconv.ParseInt(v, 10, 64)
+	return time.UnixMilli(ms).UTC()
+}
+
+// Work is the operation behind a job. It returns the job's JSON result.
+type Work func(ctx context.Context) (json.RawMessage, error)
+
+// Runner runs jobs in the background, each bounded by timeout.
+type Runner struct {
+	store    *Store
+	timeout  time.Duration
+	webhooks *Webhooks
+	logger   *zap.Logger
+
+	running sync.WaitGroup
+}
+
+// NewRunner runs jobs recorded in store. webhooks may be nil, in which case
+// completion webhooks are not sent.
+func NewRunner(store *Store, timeout time.Duration, webhooks *Webhooks, logger *zap.Logger) *Runner {
+	return &Runner{store: store, timeout: timeout, webhooks: webhooks, logger: logger}
+}
+
+// Store returns the store the runner records jobs in.
+func (r *Runner) Store() *Store {
+	return r.store
+}
+
+// ValidateWebhook checks a client's completion webhook URL before it is
+// submitted with a job.
+func (r *Runner) ValidateWebhook(ctx context.Context, raw string) error {
+	if r.webhooks == nil {
+		return errors.New("job webhooks are not configured")
+	}
+	return r.webhooks.Validate(ctx, raw)
+}
+
+// Submit records job and starts work in the background. work runs with
+// ctx's values, such as the caller's identity, but not its cancellation or
+// deadline: the job outlives the request that submitted it.
+func (r *Runner) Submit(ctx context.Context, job Job, work Work) (*Job, error) {
+	if job.WebhookURL != "" && r.webhooks == nil {
+		return nil, errors.New("job webhooks are not configured")
+	}
+	created, err := r.store.create(ctx, job, time.Now().Add(r.timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		r.run(context.WithoutCancel(ctx), *created, work)
+	}()
+	return created, nil
+}
+
+func (r *Runner) run(ctx context.Context, job Job, work Work) {
+	ctx, cancel := context.WithDeadline(ctx, job.Deadline)
+	defer cancel()
+
+	result, err := work(ctx)
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	if err == nil {
+		job.Status = StatusSucceeded
+		job.Result = result
+	} else {
+		job.Status = StatusFailed
+		var jobErr *Error
+		switch {
+		case errors.As(err, &jobErr):
+			job.Error = jobErr
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			job.Error = &Error{Code: "JOB_TIMEOUT", Message: "The job did not finish in time"}
+		default:
+			job.Error = &Error{Code: "UPSTREAM_ERROR", Message: "The backend service failed to handle the request"}
+		}
+		r.logger.Warn("Async job failed", zap.String("job_id", job.ID), zap.String("kind", job.Kind), zap.Error(err))
+	}
+	metrics.AsyncJobs.WithLabelValues(job.Kind, job.Status).Inc()
+
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+	if err := r.store.finish(saveCtx, &job); err != nil {
+		r.logger.Error("Failed to record job outcome", zap.String("job_id", job.ID), zap.Error(err))
+	}
+
+	if job.WebhookURL != "" {
+		r.webhooks.deliver(&job)
+	}
+}
+
+// Wait blocks until running jobs have finished or ctx is done. Jobs still
+// running when the process exits are reported failed once their deadline
+// has passed.
+func (r *Runner) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"dharmaguard/api-gateway/internal/httpclient"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// WebhookHeader carries the URL to notify when a submitted job finishes.
+const WebhookHeader = "X-Webhook-URL"
+
+// Webhook request headers. The signature is "sha256=" and the hex HMAC of
+// the timestamp, a ".", and the body, keyed with the webhook secret.
+const (
+	webhookTimestampHeader = "X-DharmaGuard-Timestamp"
+	webhookSignatureHeader = "X-DharmaGuard-Signature"
+)
+
+// webhookAttempts is how many times a webhook is tried, doubling the pause
+// from one second between tries.
+const webhookAttempts = 3
+
+// Webhooks posts finished jobs to the URL given when they were submitted.
+type Webhooks struct {
+	httpClient *http.Client
+	guard      *httpclient.Guard
+	secret     []byte
+	logger     *zap.Logger
+}
+
+// NewWebhooks signs webhooks with secret when it is set. Deliveries, and
+// the URLs checked by Validate, go only where guard allows; the guard
+// should trust none of the gateway's backends, since clients choose the
+// URL. Create it after httpclient.Configure.
+func NewWebhooks(secret string, timeout time.Duration, guard *httpclient.Guard, logger *zap.Logger) *Webhooks {
+	return &Webhooks{httpClient: httpclient.NewGuarded(timeout, guard), guard: guard, secret: []byte(secret), logger: logger}
+}
+
+// Validate checks that raw is an absolute https URL without credentials,
+// whose host resolves only to addresses the guard allows.
+func (w *Webhooks) Validate(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("must be an absolute https URL")
+	}
+	if u.User != nil {
+		return fmt.Errorf("must not contain credentials")
+	}
+	if _, err := w.guard.ValidateURL(ctx, raw); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *Webhooks) deliver(job *Job) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		w.logger.Error("Failed to encode job webhook", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+
+	pause := time.Second
+	for attempt := 1; ; attempt++ {
+		err = w.post(job.WebhookURL, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			break
+		}
+		time.Sleep(pause)
+		pause *= 2
+	}
+	metrics.JobWebhookFailures.WithLabelValues(job.Kind).Inc()
+	w.logger.Warn("Failed to deliver job webhook",
+		zap.String("job_id", job.ID),
+		zap.String("host", httpclient.HostOf(job.WebhookURL)),
+		zap.Error(err))
+}
+
+func (w *Webhooks) post(rawURL string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Package loadshed rejects a growing share of requests as the gateway's
+// own runtime comes under pressure, before the OOM killer or a scheduler
+// stall takes it down with every request in flight.
+package loadshed
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"runtime/metrics"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	gwmetrics "dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route priorities, as set by RouteConfig.Priority. Routes without one are
+// PriorityNormal.
+const (
+	PriorityCritical = "critical"
+	PriorityNormal   = "normal"
+	PriorityLow      = "low"
+)
+
+// shedStart is the pressure at which each priority starts being shed.
+// Every priority is shed entirely at full pressure.
+var shedStart = map[string]float64{
+	PriorityLow:      0,
+	PriorityNormal:   0.5,
+	PriorityCritical: 0.75,
+}
+
+const (
+	sampleGoroutines = "/sched/goroutines:goroutines"
+	sampleHeap       = "/memory/classes/heap/objects:bytes"
+	sampleGCCPU      = "/cpu/classes/gc/total:cpu-seconds"
+	sampleTotalCPU   = "/cpu/classes/total:cpu-seconds"
+)
+
+// Shedder samples goroutine count, live heap and the share of CPU spent in
+// GC, and turns whichever is furthest between its start and full
+// thresholds into a pressure from 0 to 1.
+type Shedder struct {
+	cfg    config.LoadShedConfig
+	routes config.RouteTable
+
+	// pressure holds a float64 as bits.
+	pressure atomic.Uint64
+
+	samples           []metrics.Sample
+	lastGC, lastTotal float64
+}
+
+func NewShedder(cfg config.LoadShedConfig, routes config.RouteTable) *Shedder {
+	return &Shedder{
+		cfg:    cfg,
+		routes: routes,
+		samples: []metrics.Sample{
+			{Name: sampleGoroutines},
+			{Name: sampleHeap},
+			{Name: sampleGCCPU},
+			{Name: sampleTotalCPU},
+		},
+	}
+}
+
+// Run samples the runtime every interval until ctx is cancelled.
+func (s *Shedder) Run(ctx context.Context, interval time.Duration) {
+	if !s.cfg.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *Shedder) sample() {
+	metrics.Read(s.samples)
+	goroutines := float64(s.samples[0].Value.Uint64())
+	heapMB := float64(s.samples[1].Value.Uint64()) / (1 << 20)
+	gc, total := s.samples[2].Value.Float64(), s.samples[3].Value.Float64()
+
+	var gcPercent float64
+	if total > s.lastTotal && s.lastTotal > 0 {
+		gcPercent = 100 * (gc - s.lastGC) / (total - s.lastTotal)
+	}
+	s.lastGC, s.lastTotal = gc, total
+
+	pressure := math.Max(
+		scale(goroutines, s.cfg.GoroutinesStart, s.cfg.GoroutinesFull),
+		math.Max(
+			scale(heapMB, s.cfg.HeapStartMB, s.cfg.HeapFullMB),
+			scale(gcPercent, s.cfg.GCPercentStart, s.cfg.GCPercentFull)))
+	s.pressure.Store(math.Float64bits(pressure))
+
+	gwmetrics.LoadShedPressure.Set(pressure)
+	for priority := range shedStart {
+		gwmetrics.LoadShedRate.WithLabelValues(priority).Set(shedFraction(pressure, priority))
+	}
+}
+
+// scale maps value onto 0 at start and 1 at full. A zero start disables
+// the signal.
+func scale(value float64, start, full int) float64 {
+	if start <= 0 || value <= float64(start) {
+		return 0
+	}
+	return math.Min(1, (value-float64(start))/float64(full-start))
+}
+
+// shedFraction is the share of priority's requests rejected at pressure:
+// none until the priority's start, rising linearly to all at full
+// pressure.
+func shedFraction(pressure float64, priority string) float64 {
+	start := shedStart[priority]
+	if pressure <= start {
+		return 0
+	}
+	return (pressure - start) / (1 - start)
+}
+
+func (s *Shedder) exempt(path string) bool {
+	for _, prefix := range s.cfg.ExemptPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests with 503 at the current shed fraction for
+// their route's priority. Requests on the exempt paths always pass.
+func (s *Shedder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pressure := math.Float64frombits(s.pressure.Load())
+		if pressure == 0 || s.exempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		priority := PriorityNormal
+		if route, ok := s.routes.Lookup(c.Request.Method, c.FullPath()); ok && route.Priority != "" {
+			priority = strings.ToLower(route.Priority)
+		}
+		if rand.Float64() >= shedFraction(pressure, priority) {
+			c.Next()
+			return
+		}
+
+		gwmetrics.LoadShedRejected.WithLabelValues(priority).Inc()
+		apierror.AbortOverloaded(c, apierror.OverloadShed, 0, "OVERLOADED",
+			"The gateway is overloaded; retry shortly")
+	}
+}
@@ -0,0 +1,151 @@
+package metering
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const keyPrefix = "metering:"
+
+// Metering periods. Counters roll over when the period changes: each period
+// has its own Redis keys, so a new period starts from zero.
+const (
+	PeriodHourly  = "hourly"
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// Usage is one API key's consumption within a period.
+type Usage struct {
+	KeyID    string `json:"key_id"`
+	Period   string `json:"period"`
+	Requests int64  `json:"requests"`
+	BytesIn  int64  `json:"bytes_in"`
+	BytesOut int64  `json:"bytes_out"`
+}
+
+// Meter counts requests and bytes per API key in Redis, so counts survive
+// restarts and every replica adds to the same totals. Past periods are kept
+// for retention after they end, leaving billing time to export them.
+type Meter struct {
+	client    *redis.Client
+	period    string
+	retention time.Duration
+}
+
+func NewMeter(client *redis.Client, period string, retention time.Duration) *Meter {
+	return &Meter{client: client, period: period, retention: retention}
+}
+
+// KeyID fingerprints an API key so raw keys never reach Redis, logs or
+// metrics.
+func KeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// CurrentPeriod returns the label of the period containing t, e.g.
+// "2026-10" for monthly metering.
+func (m *Meter) CurrentPeriod(t time.Time) string {
+	label, _ := PeriodBounds(m.period, t)
+	return label
+}
+
+// PeriodBounds returns the label and end of the period containing t, in UTC.
+// Unknown periods are treated as monthly.
+func PeriodBounds(period string, t time.Time) (label string, end time.Time) {
+	t = t.UTC()
+	switch period {
+	case PeriodHourly:
+		start := t.Truncate(time.Hour)
+		return start.Format("2006-01-02T15"), start.Add(time.Hour)
+	case PeriodDaily:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	}
+}
+
+func usageKey(period, keyID string) string {
+	return keyPrefix + period + ":" + keyID
+}
+
+func indexKey(period string) string {
+	return keyPrefix + period + ":keys"
+}
+
+// Record adds one request and its byte counts to keyID's current period.
+func (m *Meter) Record(ctx context.Context, keyID string, bytesIn, bytesOut int64) error {
+	period, end := PeriodBounds(m.period, time.Now())
+	expireAt := end.Add(m.retention)
+	key := usageKey(period, keyID)
+
+	pipe := m.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "requests", 1)
+	pipe.HIncrBy(ctx, key, "bytes_in", bytesIn)
+	pipe.HIncrBy(ctx, key, "bytes_out", bytesOut)
+	pipe.ExpireAt(ctx, key, expireAt)
+	pipe.SAdd(ctx, indexKey(period), keyID)
+	pipe.ExpireAt(ctx, indexKey(period), expireAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Get returns keyID's usage for period, zero-valued if nothing was recorded.
+func (m *Meter) Get(ctx context.Context, period, keyID string) (Usage, error) {
+	values, err := m.client.HGetAll(ctx, usageKey(period, keyID)).Result()
+	if err != nil {
+		return Usage{}, err
+	}
+	return decodeUsage(period, keyID, values)
+}
+
+// Export returns the usage of every key metered in period.
+func (m *Meter) Export(ctx context.Context, period string) ([]Usage, error) {
+	keyIDs, err := m.client.SMembers(ctx, indexKey(period)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := m.client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(keyIDs))
+	for i, keyID := range keyIDs {
+		cmds[i] = pipe.HGetAll(ctx, usageKey(period, keyID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	usage := make([]Usage, 0, len(keyIDs))
+	for i, keyID := range keyIDs {
+		u, err := decodeUsage(period, keyID, cmds[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+func decodeUsage(period, keyID string, values map[string]string) (Usage, error) {
+	u := Usage{KeyID: keyID, Period: period}
+	for field, dst := range map[string]*int64{
+		"requests":  &u.Requests,
+		"bytes_in":  &u.BytesIn,
+		"bytes_out": &u.BytesOut,
+	} {
+		if v, ok := values[field]; ok {
+			if _, err := fmt.Sscan(v, dst); err != nil {
+				return Usage{}, fmt.Errorf("decode %s for key %s: %w", field, keyID, err)
+			}
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,535 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"dharmaguard/api-gateway/internal/buildinfo"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// BuildInfo is always 1; its labels identify the running build so
+	// dashboards can mark deploys.
+	BuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "build_info",
+		Help:      "Build of the running gateway; always 1.",
+	}, []string{"version", "commit", "build_date", "go_version"})
+
+	SchemaLoadFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "schema_load_failures_total",
+		Help:      "Number of failed attempts to load or reload the OpenAPI spec.",
+	})
+
+	CacheResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "response_cache_results_total",
+		Help:      "Response cache lookups by result (HIT, MISS, STALE).",
+	}, []string{"result"})
+
+	RateLimitErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_errors_total",
+		Help:      "Rate limit checks that failed and were allowed through.",
+	})
+
+	RateLimitQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_queue_depth",
+		Help:      "Requests currently waiting for a rate limit token.",
+	})
+
+	RateLimitQueueWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_queue_wait_seconds",
+		Help:      "Time over-limit requests spent waiting for a token.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	})
+
+	GRPCPayloadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "grpc_payload_bytes_total",
+		Help:      "gRPC payload bytes exchanged with backends, uncompressed and as sent on the wire.",
+	}, []string{"service", "direction", "size"})
+
+	UnmatchedRoutes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "unmatched_route_requests_total",
+		Help:      "Requests that matched no route (not_found) or no method on a route (method_not_allowed).",
+	}, []string{"reason"})
+
+	APIKeyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "api_key_requests_total",
+		Help:      "Requests metered per API key fingerprint.",
+	}, []string{"key_id"})
+
+	APIKeyBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "api_key_bytes_total",
+		Help:      "Request (in) and response (out) body bytes metered per API key fingerprint.",
+	}, []string{"key_id", "direction"})
+
+	MeteringErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "metering_errors_total",
+		Help:      "Usage records that could not be written to Redis.",
+	})
+
+	QuotaExceeded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "quota_exceeded_total",
+		Help:      "Requests refused because a tenant or API key quota was exhausted.",
+	})
+
+	QuotaErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "quota_errors_total",
+		Help:      "Quota checks that failed and were allowed through.",
+	})
+
+	CircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "circuit_breaker_state",
+		Help:      "Backend circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, []string{"service"})
+
+	ExperimentRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "experiment_requests_total",
+		Help:      "Requests routed to an experiment variant backend, by route and variant.",
+	}, []string{"route", "variant"})
+
+	ShadowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "shadow_requests_total",
+		Help:      "Mirrored calls to shadow backends by route and comparison result.",
+	}, []string{"route", "result"})
+
+	ShadowLatencyDelta = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "shadow_latency_delta_seconds",
+		Help:      "Shadow call latency minus primary call latency; negative when the shadow was faster.",
+		Buckets:   []float64{-1, -.25, -.1, -.025, 0, .025, .1, .25, 1, 2.5},
+	}, []string{"route"})
+
+	ShadowDiffFields = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "shadow_diff_fields_total",
+		Help:      "Fields that differed between primary and shadow responses, by route and kind (added, removed, changed).",
+	}, []string{"route", "kind"})
+
+	OutboundBlocked = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "outbound_blocked_total",
+		Help:      "Outbound HTTP requests refused because the destination resolved to a forbidden address.",
+	})
+
+	AggregateSectionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "aggregate_section_errors_total",
+		Help:      "Failed sections of aggregate responses, by endpoint, section and reason (timeout, client_error, server_error, invalid).",
+	}, []string{"endpoint", "section", "reason"})
+
+	AggregateRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "aggregate_complexity_rejected_total",
+		Help:      "Aggregate requests refused because their sections cost more than the caller's complexity budget.",
+	}, []string{"endpoint"})
+
+	TenantResolutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "tenant_resolutions_total",
+		Help:      "Requests by the resolver that supplied their tenant (jwt, api_key, header, host, none).",
+	}, []string{"source"})
+
+	TenantResolutionErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "tenant_resolution_errors_total",
+		Help:      "API key and host tenant lookups that failed and resolved nothing.",
+	})
+
+	LongPollConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "long_poll_connections",
+		Help:      "Long-poll requests currently being held open.",
+	})
+
+	ResponseValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "response_validation_failures_total",
+		Help:      "Backend responses replaced with a 502 for failing OpenAPI validation, by route and reason (malformed, schema).",
+	}, []string{"route", "reason"})
+
+	HeaderLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "header_limit_rejections_total",
+		Help:      "Requests rejected with 431 for too many header lines (count) or too many header bytes (size).",
+	}, []string{"reason"})
+
+	BackendRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "backend_retries_total",
+		Help:      "Backend call retries by service and outcome (attempted, or suppressed by the retry budget).",
+	}, []string{"service", "outcome"})
+
+	RetryBudgetUtilization = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "retry_budget_utilization",
+		Help:      "Retries in the budget window as a fraction of those allowed; 1 means retries are being suppressed.",
+	})
+
+	LoadShedPressure = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "load_shed_pressure",
+		Help:      "Runtime pressure from 0 (none) to 1 (shed everything), from goroutines, heap and GC CPU.",
+	})
+
+	LoadShedRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "load_shed_rate",
+		Help:      "Fraction of requests currently being shed, by route priority.",
+	}, []string{"priority"})
+
+	LoadShedRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "load_shed_rejected_total",
+		Help:      "Requests rejected with 503 by the load shedder, by route priority.",
+	}, []string{"priority"})
+
+	AuthzDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "authz_decisions_total",
+		Help:      "Policy engine decisions by outcome (allow, deny, error) and whether they came from the decision cache.",
+	}, []string{"decision", "cached"})
+
+	ResourceLookupErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "authz_resource_lookup_errors_total",
+		Help:      "Failed resource attribute lookups for authorization, by resource type; each refused its request.",
+	}, []string{"type"})
+
+	WatchdogFired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "watchdog_fired_total",
+		Help:      "Requests cancelled with a 504 for running past the hard timeout, by route.",
+	}, []string{"route"})
+
+	DrainWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "drain_wait_seconds",
+		Help:      "Time a shutting-down replica waited for healthy peers, by outcome (approved, timeout, error).",
+		Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 120},
+	}, []string{"outcome"})
+
+	DeprecatedCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "deprecated_route_requests_total",
+		Help:      "Calls to deprecated routes by route, known API key fingerprint (empty without one) and tenant label.",
+	}, []string{"route", "key_id", "tenant"})
+
+	RateLimitFallbackActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_fallback_active",
+		Help:      "1 while rate limits are enforced from per-replica memory because the shared limiter is unavailable.",
+	})
+
+	RateLimitExemptions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_exemptions_total",
+		Help:      "Requests let past rate limiting unchecked, by reason (path, cidr, internal_caller).",
+	}, []string{"reason"})
+
+	RateLimitFallbackChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_fallback_checks_total",
+		Help:      "Rate limit checks answered by the in-memory fallback, by result (allowed, denied).",
+	}, []string{"result"})
+
+	RateLimitMode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_mode",
+		Help:      "Always 1; the label is the active cross-region rate limiting mode (local, global, reconciled).",
+	}, []string{"mode"})
+
+	RateLimitDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_region_drift",
+		Help:      "Requests admitted in this region not yet charged to a peer region's buckets, at the last reconcile.",
+	}, []string{"region"})
+
+	AsyncJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "async_jobs_total",
+		Help:      "Finished async jobs by kind and status (succeeded, failed).",
+	}, []string{"kind", "status"})
+
+	BreakerPeerTrips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "circuit_breaker_peer_trips_total",
+		Help:      "Circuit breaker trips reported by other replicas, by backend service.",
+	}, []string{"service"})
+
+	BackendInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "backend_in_flight_calls",
+		Help:      "Unary calls in flight to each backend connection under its in-flight cap.",
+	}, []string{"service"})
+
+	BackendQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "backend_queue_depth",
+		Help:      "Calls waiting for an in-flight slot on each backend connection.",
+	}, []string{"service"})
+
+	BulkheadRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "backend_bulkhead_rejected_total",
+		Help:      "Calls failed fast because a backend had its maximum calls in flight.",
+	}, []string{"service"})
+
+	WebSocketBytesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "websocket_sent_bytes_total",
+		Help:      "Message bytes written to WebSocket connections, by encoding (json, msgpack).",
+	}, []string{"encoding"})
+
+	WebSocketWireBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "websocket_wire_bytes_total",
+		Help:      "Bytes written to WebSocket sockets after compression, framing included, by encoding; compare websocket_sent_bytes_total.",
+	}, []string{"encoding"})
+
+	WebSocketMessagesRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "websocket_messages_rejected_total",
+		Help:      "Inbound WebSocket messages that closed their connection, by reason (oversized, rate_limited).",
+	}, []string{"reason"})
+
+	StreamInstanceSelections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "stream_instance_selections_total",
+		Help:      "Sticky streaming calls by service, chosen instance and outcome (preferred, failover).",
+	}, []string{"service", "instance", "outcome"})
+
+	UpstreamPinFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "upstream_tls_pin_failures_total",
+		Help:      "Backend TLS connections refused because the certificate chain matched no pinned key.",
+	}, []string{"service"})
+
+	BackendOutstandingCost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "backend_outstanding_cost",
+		Help:      "Summed route cost of unary calls in flight to each backend instance.",
+	}, []string{"service", "instance"})
+
+	RateLimitAlerts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "ratelimit_alerts_total",
+		Help:      "Alerts raised for rate-limit keys that reached the rejection threshold in a window.",
+	})
+
+	UpstreamTargetPins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "upstream_target_pins_total",
+		Help:      "Requests carrying X-Upstream-Target by caller, instance and result (pinned, untrusted, not_allowed).",
+	}, []string{"caller", "instance", "result"})
+
+	TenantRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "tenant_requests_total",
+		Help:      "API requests by tenant (capped; see METRICS_TENANT_LABELS_MAX) and status class.",
+	}, []string{"tenant", "status_class"})
+
+	TenantLabelsTracked = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "tenant_labels_tracked",
+		Help:      "Tenants outside the configured list that hold their own tenant label.",
+	})
+
+	GeoIPLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "geoip_lookups_total",
+		Help:      "Client IP geo/ASN lookups, by result (found, not_found, error).",
+	}, []string{"result"})
+
+	GeoIPLoadFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "geoip_load_failures_total",
+		Help:      "Number of failed attempts to load or reload the GeoIP databases.",
+	})
+
+	DisabledRouteHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "disabled_route_hits_total",
+		Help:      "Requests refused because their route was disabled at runtime, by method and route.",
+	}, []string{"method", "route"})
+
+	FaultsInjected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "faults_injected_total",
+		Help:      "Faults injected into backend calls for chaos testing, by route and kind.",
+	}, []string{"route", "kind"})
+
+	JobWebhookFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dharmaguard",
+		Subsystem: "gateway",
+		Name:      "job_webhook_failures_total",
+		Help:      "Job completion webhooks that could not be delivered after retries, by job kind.",
+	}, []string{"kind"})
+)
+
+var initOnce sync.Once
+
+// Registry holds every metric the gateway exposes. It is the gateway's own
+// rather than the default registry, so the runtime and process collectors
+// are registered here explicitly, once, alongside the request metrics.
+var Registry = prometheus.NewRegistry()
+
+// InitMetrics registers the gateway's collectors with Registry: its own
+// metrics, and the Go runtime (goroutines, heap, GC pauses) and process
+// (CPU, memory, open file descriptors) collectors.
+func InitMetrics() {
+	initOnce.Do(func() {
+		Registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			BuildInfo,
+			SchemaLoadFailures,
+			CacheResults,
+			RateLimitErrors,
+			RateLimitQueueDepth,
+			RateLimitQueueWait,
+			GRPCPayloadBytes,
+			UnmatchedRoutes,
+			APIKeyRequests,
+			APIKeyBytes,
+			MeteringErrors,
+			QuotaExceeded,
+			QuotaErrors,
+			CircuitBreakerState,
+			ExperimentRequests,
+			ShadowRequests,
+			ShadowLatencyDelta,
+			ShadowDiffFields,
+			OutboundBlocked,
+			AggregateSectionErrors,
+			TenantResolutions,
+			TenantResolutionErrors,
+			LongPollConnections,
+			ResponseValidationFailures,
+			HeaderLimitRejections,
+			BackendRetries,
+			RetryBudgetUtilization,
+			LoadShedPressure,
+			LoadShedRate,
+			LoadShedRejected,
+			AuthzDecisions,
+			ResourceLookupErrors,
+			WatchdogFired,
+			DrainWait,
+			DeprecatedCalls,
+			RateLimitMode,
+			RateLimitDrift,
+			AsyncJobs,
+			JobWebhookFailures,
+			BreakerPeerTrips,
+			FaultsInjected,
+			BackendInFlight,
+			BackendQueueDepth,
+			BulkheadRejected,
+			WebSocketBytesSent,
+			WebSocketWireBytes,
+			WebSocketMessagesRejected,
+			StreamInstanceSelections,
+			UpstreamPinFailures,
+			BackendOutstandingCost,
+			RateLimitAlerts,
+			AggregateRejected,
+			RateLimitFallbackActive,
+			RateLimitFallbackChecks,
+			RateLimitExemptions,
+			UpstreamTargetPins,
+			TenantRequests,
+			TenantLabelsTracked,
+			GeoIPLookups,
+			GeoIPLoadFailures,
+			DisabledRouteHits,
+		)
+
+		info := buildinfo.Get()
+		BuildInfo.WithLabelValues(info.Version, info.Commit, info.BuildDate, info.GoVersion).Set(1)
+	})
+}
+
+// Handler serves Registry in the Prometheus exposition format, counting
+// its own scrapes as the default handler does.
+func Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(Registry, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
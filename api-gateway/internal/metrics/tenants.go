@@ -0,0 +1,59 @@
+package metrics
+
+import "sync"
+
+// Tenant label values for requests outside the tracked tenants.
+const (
+	TenantOther = "other"
+	TenantNone  = "none"
+)
+
+// TenantLabeler assigns the tenant label of per-tenant metrics, holding
+// the number of distinct labels to a cap (see config.TenantLabelsConfig).
+type TenantLabeler struct {
+	max     int
+	allowed map[string]bool
+
+	mu      sync.Mutex
+	tracked map[string]bool
+}
+
+// NewTenantLabeler always labels the tenants in allow, and up to max
+// others as they are first seen.
+func NewTenantLabeler(max int, allow []string) *TenantLabeler {
+	l := &TenantLabeler{
+		max:     max,
+		allowed: make(map[string]bool, len(allow)),
+		tracked: make(map[string]bool, max),
+	}
+	for _, tenant := range allow {
+		l.allowed[tenant] = true
+	}
+	return l
+}
+
+// Label returns the label to record tenantID under: the tenant itself if
+// it is tracked or there is room to track it, else TenantOther.
+func (l *TenantLabeler) Label(tenantID string) string {
+	switch {
+	case tenantID == "":
+		return TenantNone
+	case tenantID == TenantOther || tenantID == TenantNone:
+		// A tenant can't take over a bucket's label.
+		return TenantOther
+	case l.allowed[tenantID]:
+		return tenantID
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tracked[tenantID] {
+		return tenantID
+	}
+	if len(l.tracked) >= l.max {
+		return TenantOther
+	}
+	l.tracked[tenantID] = true
+	TenantLabelsTracked.Set(float64(len(l.tracked)))
+	return tenantID
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+
+	"dharmaguard/api-gateway/internal/metering"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader carries the key integrations are billed by.
+const APIKeyHeader = "X-API-Key"
+
+// Context keys AuthenticateAPIKey populates for a key it knows.
+const (
+	// ContextKeyAPIKeyID holds the fingerprint of the request's API key.
+	ContextKeyAPIKeyID = "api_key_id"
+	// ContextKeyAPIKeyTenant holds the tenant the key is mapped to.
+	ContextKeyAPIKeyTenant = "api_key_tenant"
+)
+
+// AuthenticateAPIKey looks the request's X-API-Key up in the key store and,
+// for a key it knows, records the key's fingerprint and tenant. Unknown keys
+// and failed lookups leave the request without one, so a made-up key is
+// never metered, counted against a quota or used as a metric label. It
+// must run before AuthRequired.
+func AuthenticateAPIKey(keys *tenant.KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(APIKeyHeader)
+		if apiKey == "" || keys == nil {
+			c.Next()
+			return
+		}
+
+		keyID := metering.KeyID(apiKey)
+		mapping, err := keys.Get(c.Request.Context(), keyID)
+		if err != nil {
+			metrics.TenantResolutionErrors.Inc()
+		}
+		if mapping != nil {
+			c.Set(ContextKeyAPIKeyID, keyID)
+			c.Set(ContextKeyAPIKeyTenant, mapping.TenantID)
+		}
+		c.Next()
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
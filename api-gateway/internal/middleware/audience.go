@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAudience rejects tokens whose aud claim contains none of the allowed
+// audiences, so tokens minted for one surface can't be used on another. It
+// must run after AuthRequired. With no allowed audiences configured it is a
+// no-op, and requests authenticated by API key, which carry no token, pass.
+func RequireAudience(allowed ...string) gin.HandlerFunc {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, aud := range allowed {
+		allowedSet[aud] = true
+	}
+
+	return func(c *gin.Context) {
+		if len(allowedSet) == 0 || authMethod(c) == AuthMethodAPIKey {
+			c.Next()
+			return
+		}
+
+		claims, ok := GetClaims(c)
+		if !ok {
+			apierror.Abort(c, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+			return
+		}
+
+		for _, aud := range claims.Audience {
+			if allowedSet[aud] {
+				c.Next()
+				return
+			}
+		}
+
+		apierror.AbortWithDetails(c, http.StatusForbidden, "INVALID_AUDIENCE",
+			"Token was not issued for this API surface",
+			map[string]interface{}{"accepted_audiences": allowed})
+	}
+}
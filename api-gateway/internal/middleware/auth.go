@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/reqctx"
+	"dharmaguard/api-gateway/internal/servertiming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys AuthRequired populates for downstream middleware and handlers.
+const (
+	ContextKeyClaims   = "claims"
+	ContextKeyUserID   = "user_id"
+	ContextKeyTenantID = "tenant_id"
+	ContextKeyRoles    = "roles"
+)
+
+// AuthRequired validates the bearer token with the auth service and stores the
+// resulting claims in the request context. Without an Authorization header it
+// falls back to the session cookie; such requests need CSRF to follow it.
+//
+// With neither, a request whose X-API-Key AuthenticateAPIKey validated is let
+// through without claims on routes that set api_key in routes: it acts for
+// the key's tenant, as ResolveTenant settles it, with no user, roles or
+// scopes. On any other route it needs a token like everyone else.
+func AuthRequired(authService *auth.Service, sessions config.SessionConfig, routes config.RouteTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := AuthMethodBearer
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" && c.GetHeader("Authorization") == "" {
+			if token = sessionToken(c, sessions); token != "" {
+				method = AuthMethodCookie
+			} else if c.GetString(ContextKeyAPIKeyID) != "" && apiKeyRoute(c, routes) {
+				reqctx.Attach(c, &reqctx.RequestContext{
+					RequestID: c.GetString(apierror.RequestIDKey),
+					ClientIP:  c.ClientIP(),
+				})
+				c.Set(contextKeyAuthMethod, AuthMethodAPIKey)
+				c.Next()
+				return
+			}
+		}
+		if token == "" {
+			apierror.Abort(c, http.StatusUnauthorized, "UNAUTHORIZED", "Missing bearer token")
+			return
+		}
+
+		stop := servertiming.Start(c.Request.Context(), "auth")
+		claims, err := authService.ValidateToken(c.Request.Context(), token)
+		stop()
+		if err != nil {
+			apierror.Abort(c, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid or expired token")
+			return
+		}
+
+		setClaims(c, claims)
+		c.Set(contextKeyAuthMethod, method)
+		c.Next()
+	}
+}
+
+func apiKeyRoute(c *gin.Context, routes config.RouteTable) bool {
+	route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+	return ok && route.APIKey
+}
+
+// GetClaims returns the claims stored by AuthRequired, if any.
+func GetClaims(c *gin.Context) (*auth.Claims, bool) {
+	value, ok := c.Get(ContextKeyClaims)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*auth.Claims)
+	return claims, ok
+}
+
+func setClaims(c *gin.Context, claims *auth.Claims) {
+	c.Set(ContextKeyClaims, claims)
+	c.Set(ContextKeyUserID, claims.UserID)
+	c.Set(ContextKeyTenantID, claims.TenantID)
+	c.Set(ContextKeyRoles, claims.Roles)
+
+	reqctx.Attach(c, &reqctx.RequestContext{
+		TenantID:  claims.TenantID,
+		UserID:    claims.UserID,
+		Roles:     claims.Roles,
+		RequestID: c.GetString(apierror.RequestIDKey),
+		ClientIP:  c.ClientIP(),
+	})
+}
+
+func bearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/authz"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/servertiming"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Authorize lets the policy engine decide each request, from its method,
+// path, route parameters, tenant, the caller's claims and, after
+// EnrichResource, the resource the route acts on. Denied requests
+// get a 403. It fails closed: when no decision can be made the request is
+// refused with a 503, never let through. It must run after AuthRequired
+// and, where there is one, ResolveTenant. A nil client allows everything.
+func Authorize(client *authz.Client, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if client == nil {
+			c.Next()
+			return
+		}
+
+		input := authz.Input{
+			Method: c.Request.Method,
+			Path:   c.Request.URL.Path,
+			Route:  c.FullPath(),
+			Tenant: c.GetString(ContextKeyTenantID),
+		}
+		if len(c.Params) > 0 {
+			input.Params = make(map[string]string, len(c.Params))
+			for _, p := range c.Params {
+				input.Params[p.Key] = p.Value
+			}
+		}
+		if claims, ok := GetClaims(c); ok {
+			input.Subject = authz.Subject{
+				UserID:   claims.UserID,
+				TenantID: claims.TenantID,
+				Roles:    claims.Roles,
+				Scopes:   claims.Scope,
+				Org:      claims.Org,
+				Plan:     claims.Plan,
+				Issuer:   claims.Issuer,
+			}
+		}
+
+		if resource, ok := GetResource(c); ok {
+			input.Resource = resource
+		}
+
+		stop := servertiming.Start(c.Request.Context(), "authz")
+		allow, cached, err := client.Allow(c.Request.Context(), input)
+		stop()
+		switch {
+		case err != nil:
+			metrics.AuthzDecisions.WithLabelValues("error", "false").Inc()
+			logger.Error("Policy decision failed", zap.String("route", c.FullPath()), zap.Error(err))
+			apierror.Abort(c, http.StatusServiceUnavailable, "AUTHORIZATION_UNAVAILABLE",
+				"The request could not be authorized; retry shortly")
+		case !allow:
+			metrics.AuthzDecisions.WithLabelValues("deny", strconv.FormatBool(cached)).Inc()
+			apierror.Abort(c, http.StatusForbidden, "POLICY_DENIED", "The request is not permitted by policy")
+		default:
+			metrics.AuthzDecisions.WithLabelValues("allow", strconv.FormatBool(cached)).Inc()
+			c.Next()
+		}
+	}
+}
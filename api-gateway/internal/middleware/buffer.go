@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseBuffer holds a handler's response so middleware can inspect or
+// rewrite it after c.Next() returns. Nothing reaches the client until flush.
+type responseBuffer struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// header is the response header as it was before the handler ran.
+	header http.Header
+}
+
+func bufferResponse(c *gin.Context) *responseBuffer {
+	buf := &responseBuffer{ResponseWriter: c.Writer, status: http.StatusOK, header: c.Writer.Header().Clone()}
+	c.Writer = buf
+	return buf
+}
+
+func (w *responseBuffer) WriteHeader(code int)              { w.status = code }
+func (w *responseBuffer) WriteHeaderNow()                   {}
+func (w *responseBuffer) Write(data []byte) (int, error)    { return w.body.Write(data) }
+func (w *responseBuffer) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+func (w *responseBuffer) Status() int                       { return w.status }
+func (w *responseBuffer) Size() int                         { return w.body.Len() }
+func (w *responseBuffer) Written() bool                     { return w.body.Len() > 0 }
+
+// flush restores the original writer on c and sends the buffered response.
+func (w *responseBuffer) flush(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// discard restores the original writer on c and drops the buffered
+// response, headers included, so another can be written in its place.
+func (w *responseBuffer) discard(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+	h := w.ResponseWriter.Header()
+	for name := range h {
+		delete(h, name)
+	}
+	for name, values := range w.header {
+		h[name] = values
+	}
+}
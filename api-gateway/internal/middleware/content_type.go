@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireContentType rejects requests that carry a body whose
+// Content-Type isn't one the route accepts, with 415, before a backend
+// sees them. Routes accept their content_types, or defaults if they list
+// none; an entry may be a "type/*" wildcard, and "*/*" accepts anything.
+// Parameters such as charset are ignored. Requests without a body, and
+// routes with nothing to enforce or that don't exist, pass.
+func RequireContentType(routes config.RouteTable, defaults []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accepted := defaults
+		if route, ok := routes.Lookup(c.Request.Method, c.FullPath()); ok && len(route.ContentTypes) > 0 {
+			accepted = route.ContentTypes
+		}
+		if len(accepted) == 0 || c.FullPath() == "" || !hasBody(c.Request) {
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(sent)
+		if err == nil && (mediaTypeAccepted(mediaType, accepted) || patchTypeAccepted(c.Request.Method, mediaType, accepted)) {
+			c.Next()
+			return
+		}
+		apierror.AbortWithDetails(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE",
+			"Content-Type must be "+strings.Join(accepted, " or "), map[string]interface{}{
+				"expected": accepted,
+				"received": sent,
+			})
+	}
+}
+
+// hasBody reports whether the request sends a body: a positive
+// Content-Length, or a chunked one of unknown length.
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// patchTypeAccepted lets patch documents through wherever JSON is
+// accepted, since PatchDocuments turns them into JSON.
+func patchTypeAccepted(method, mediaType string, accepted []string) bool {
+	_, ok := patchFormats[mediaType]
+	return method == http.MethodPatch && ok && mediaTypeAccepted("application/json", accepted)
+}
+
+func mediaTypeAccepted(mediaType string, accepted []string) bool {
+	for _, a := range accepted {
+		a = strings.ToLower(a)
+		switch {
+		case a == "*/*", a == mediaType:
+			return true
+		case strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRF implements the double-submit-cookie pattern for cookie-authenticated
+// requests: it issues a token cookie readable by the page, and unsafe methods
+// must echo that token in the CSRF header. Bearer-authenticated requests are
+// skipped since browsers never attach the header on their own. It must run
+// after AuthRequired.
+func CSRF(csrf config.CSRFConfig, sessions config.SessionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authMethod(c) != AuthMethodCookie {
+			c.Next()
+			return
+		}
+
+		cookie, _ := c.Cookie(csrf.CookieName)
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			if cookie == "" {
+				if err := issueCSRFToken(c, sessions, csrf); err != nil {
+					apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to issue CSRF token")
+					return
+				}
+			}
+			c.Next()
+			return
+		}
+
+		if !validCSRFToken(c, csrf) {
+			apierror.Abort(c, http.StatusForbidden, "CSRF_TOKEN_INVALID", "Missing or invalid CSRF token")
+			return
+		}
+		c.Next()
+	}
+}
+
+// validCSRFToken reports whether the request echoes its CSRF cookie in the
+// CSRF header.
+func validCSRFToken(c *gin.Context, csrf config.CSRFConfig) bool {
+	cookie, _ := c.Cookie(csrf.CookieName)
+	header := c.GetHeader(csrf.HeaderName)
+	return cookie != "" && header != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// issueCSRFToken sets a new CSRF cookie. It is deliberately not HttpOnly: the
+// page's JavaScript reads it and echoes it in the CSRF header.
+func issueCSRFToken(c *gin.Context, sessions config.SessionConfig, csrf config.CSRFConfig) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	setCookie(c, sessions, csrf.CookieName, base64.RawURLEncoding.EncodeToString(b), "/", sessions.MaxAge, false)
+	return nil
+}
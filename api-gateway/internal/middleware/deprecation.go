@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountDeprecated counts calls to routes with a deprecation policy by API
+// key and tenant, so the clients still using a route can be chased down
+// before it is removed. The deprecation headers themselves come with the
+// route's response headers. Only keys AuthenticateAPIKey found in the key
+// store are labelled, and tenants go through labels, which caps the
+// distinct values, so clients can't mint labels. It must run after the
+// tenant is resolved.
+func CountDeprecated(routes config.RouteTable, labels *metrics.TenantLabeler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+		if ok && route.Deprecation != nil {
+			metrics.DeprecatedCalls.WithLabelValues(c.FullPath(),
+				c.GetString(ContextKeyAPIKeyID), labels.Label(c.GetString(ContextKeyTenantID))).Inc()
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net"
+
+	"dharmaguard/api-gateway/internal/geoip"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EnrichGeo looks up the client IP's country and autonomous system with
+// provider and records them on the request context, where the proxy
+// forwards them to backends as metadata, and on the trace span. Lookups
+// fail open: a request whose IP can't be resolved carries an empty
+// location, which still stops clients supplying their own. It must run
+// inside the tracing middleware.
+func EnrichGeo(provider geoip.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var loc geoip.Location
+		if ip := net.ParseIP(c.ClientIP()); ip != nil {
+			var err error
+			loc, err = provider.Lookup(ip)
+			switch {
+			case err != nil:
+				loc = geoip.Location{}
+				metrics.GeoIPLookups.WithLabelValues("error").Inc()
+			case loc.Empty():
+				metrics.GeoIPLookups.WithLabelValues("not_found").Inc()
+			default:
+				metrics.GeoIPLookups.WithLabelValues("found").Inc()
+			}
+		}
+
+		if !loc.Empty() {
+			span := trace.SpanFromContext(c.Request.Context())
+			if loc.Country != "" {
+				span.SetAttributes(attribute.String("client.geo.country_iso_code", loc.Country))
+			}
+			if loc.ASN != 0 {
+				span.SetAttributes(
+					attribute.Int64("client.as.number", int64(loc.ASN)),
+					attribute.String("client.as.organization.name", loc.ASOrg),
+				)
+			}
+		}
+		c.Request = c.Request.WithContext(geoip.WithLocation(c.Request.Context(), loc))
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitHeaders rejects requests with more than maxCount header lines, or
+// more than maxBytes of header data, with 431. A limit of zero is not
+// enforced.
+//
+// The server's MaxHeaderBytes stops far larger header blocks while they
+// are still being read; net/http answers those with its own 431 before any
+// middleware runs, so they are not counted here.
+func LimitHeaders(maxCount, maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, size := 0, 0
+		for name, values := range c.Request.Header {
+			count += len(values)
+			for _, v := range values {
+				// name + ": " + value + CRLF, as sent on the wire
+				size += len(name) + len(v) + 4
+			}
+		}
+
+		var reason string
+		switch {
+		case maxCount > 0 && count > maxCount:
+			reason = "count"
+		case maxBytes > 0 && size > maxBytes:
+			reason = "size"
+		default:
+			c.Next()
+			return
+		}
+		metrics.HeaderLimitRejections.WithLabelValues(reason).Inc()
+		apierror.AbortWithDetails(c, http.StatusRequestHeaderFieldsTooLarge, "HEADERS_TOO_LARGE",
+			"Request headers exceed the allowed size", map[string]interface{}{
+				"max_count": maxCount,
+				"max_bytes": maxBytes,
+			})
+	}
+}
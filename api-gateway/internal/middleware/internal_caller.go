@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKeyInternalCaller holds the name of the trusted backend service
+// that sent the request, if one did.
+const ContextKeyInternalCaller = "internal_caller"
+
+// IdentifyInternalCaller verifies the internal token a trusted backend
+// service sends in X-Internal-Token and records the caller's name under
+// ContextKeyInternalCaller. Requests without a valid token are left
+// anonymous. It must run before the proxy's Pinning, which removes the
+// header.
+func IdentifyInternalCaller(verifier *auth.InternalCallerVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.GetHeader(proxy.InternalTokenHeader); token != "" {
+			if caller, err := verifier.Verify(token); err == nil {
+				c.Set(ContextKeyInternalCaller, caller)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metering"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const meteringWriteTimeout = 2 * time.Second
+
+// Metering records each request made with an API key AuthenticateAPIKey
+// knows, with the request body bytes read and response body bytes written,
+// against the key's usage for the current period. Keys the store doesn't
+// know are never metered, so nobody can add to another key's bill or grow
+// the usage records with made-up keys. Recording happens after the response
+// and a Redis failure never fails the request.
+func Metering(meter *metering.Meter, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.GetString(ContextKeyAPIKeyID)
+		if keyID == "" {
+			c.Next()
+			return
+		}
+
+		body := &countingReader{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+		c.Next()
+
+		bytesIn, bytesOut := body.n, int64(0)
+		if size := c.Writer.Size(); size > 0 {
+			bytesOut = int64(size)
+		}
+
+		metrics.APIKeyRequests.WithLabelValues(keyID).Inc()
+		metrics.APIKeyBytes.WithLabelValues(keyID, "in").Add(float64(bytesIn))
+		metrics.APIKeyBytes.WithLabelValues(keyID, "out").Add(float64(bytesOut))
+
+		// The request context may already be cancelled or past its deadline.
+		ctx, cancel := context.WithTimeout(context.Background(), meteringWriteTimeout)
+		defer cancel()
+		if err := meter.Record(ctx, keyID, bytesIn, bytesOut); err != nil {
+			metrics.MeteringErrors.Inc()
+			logger.Warn("Failed to record API key usage", zap.String("key_id", keyID), zap.Error(err))
+		}
+	}
+}
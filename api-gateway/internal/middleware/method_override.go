@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// MethodOverrideHeader names the method a POST stands in for.
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// OverrideMethod serves a POST carrying MethodOverrideHeader as a request
+// with the method it names, so clients behind proxies that block PATCH and
+// DELETE can still reach those routes. Only allowlisted methods are
+// honored; any other value is refused with a 400 rather than served as the
+// POST. GET requests are overridden only with cfg.OnGet, since that makes a
+// safe request unsafe. Every override is logged.
+//
+// Like NormalizePaths it wraps the handler, because gin has matched the
+// route by the time middleware runs. Everything behind it, CSRF checks
+// included, sees the effective method.
+func OverrideMethod(cfg config.MethodOverrideConfig, next http.Handler, logger *zap.Logger) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+	allowed := make(map[string]bool, len(cfg.Allowed))
+	for _, method := range cfg.Allowed {
+		allowed[method] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		override := strings.ToUpper(strings.TrimSpace(r.Header.Get(MethodOverrideHeader)))
+		if override == "" || override == r.Method || !(r.Method == http.MethodPost || (cfg.OnGet && r.Method == http.MethodGet)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed[override] {
+			logger.Warn("Refused HTTP method override",
+				zap.String("method", r.Method),
+				zap.String("override", override),
+				zap.String("path", r.URL.Path))
+			body, _ := json.Marshal(apierror.APIError{
+				Code:    "METHOD_OVERRIDE_NOT_ALLOWED",
+				Message: "Method override to " + override + " is not allowed",
+			})
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(body)
+			return
+		}
+
+		logger.Info("Applied HTTP method override",
+			zap.String("method", r.Method),
+			zap.String("override", override),
+			zap.String("path", r.URL.Path),
+			zap.String("remote_addr", r.RemoteAddr))
+		r.Method = override
+		r.Header.Del(MethodOverrideHeader)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Patch document media types.
+const (
+	MergePatchType = "application/merge-patch+json" // RFC 7386
+	JSONPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+// maxPatchBytes caps the size of a patch document.
+const maxPatchBytes = 1 << 20
+
+// patchFormats maps the accepted PATCH media types to the format reported
+// to backends. Plain JSON bodies keep their existing merge semantics.
+var patchFormats = map[string]string{
+	"application/json": "merge",
+	MergePatchType:     "merge",
+	JSONPatchType:      "json-patch",
+}
+
+var acceptedPatchTypes = []string{MergePatchType, JSONPatchType, "application/json"}
+
+// PatchDocuments validates the body of a PATCH request as a JSON merge
+// patch or a JSON Patch and hands handlers a single shape: the body becomes
+// the merge document, sent on as application/json, and the fields it
+// touches travel to the backend as an update mask (see proxy.PatchContext).
+// JSON Patch operations that need the current resource to apply (move,
+// copy, test, and anything addressing an array element) are refused with
+// 422, since the gateway never sees the resource, as are those a merge
+// document can't express: add or replace with a null value, which merging
+// reads as a removal, or with an object value, which it merges into the
+// current member instead of replacing it. Other media types are refused
+// with 415, and every response advertises Accept-Patch.
+func PatchDocuments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPatch {
+			c.Next()
+			return
+		}
+		c.Header("Accept-Patch", strings.Join(acceptedPatchTypes[:2], ", "))
+		if !hasBody(c.Request) {
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(sent)
+		format, ok := patchFormats[mediaType]
+		if err != nil || !ok {
+			apierror.AbortWithDetails(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE",
+				"Content-Type must be "+strings.Join(acceptedPatchTypes, " or "), map[string]interface{}{
+					"expected": acceptedPatchTypes,
+					"received": sent,
+				})
+			return
+		}
+
+		raw, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPatchBytes+1))
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_PATCH", "Failed to read patch document")
+			return
+		}
+		if len(raw) > maxPatchBytes {
+			apierror.AbortWithDetails(c, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE",
+				"Patch document exceeds size limit", map[string]interface{}{"max_bytes": maxPatchBytes})
+			return
+		}
+
+		var doc map[string]interface{}
+		var mask []string
+		if format == "merge" {
+			if err := decodeJSON(raw, &doc); err != nil || doc == nil {
+				apierror.Abort(c, http.StatusBadRequest, "INVALID_PATCH", "Merge patch must be a JSON object")
+				return
+			}
+			mask = mergeMask(doc, "", nil)
+		} else {
+			var ops []patchOp
+			if err := decodeJSON(raw, &ops); err != nil {
+				apierror.Abort(c, http.StatusBadRequest, "INVALID_PATCH", "JSON Patch must be an array of operations")
+				return
+			}
+			var status int
+			doc, mask, status, err = translatePatch(ops)
+			if err != nil {
+				code := "INVALID_PATCH"
+				if status == http.StatusUnprocessableEntity {
+					code = "UNSUPPORTED_PATCH_OPERATION"
+				}
+				apierror.Abort(c, status, code, err.Error())
+				return
+			}
+			if raw, err = json.Marshal(doc); err != nil {
+				apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to encode patch")
+				return
+			}
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+		c.Request.ContentLength = int64(len(raw))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+		c.Request = c.Request.WithContext(proxy.PatchContext(c.Request.Context(), format, mask))
+		c.Next()
+	}
+}
+
+// patchOp is one RFC 6902 operation. Value is nil when the member is
+// absent and "null" when it is JSON null.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// translatePatch validates ops and folds them into a merge document and
+// the update mask they imply, in order. It returns 400 for malformed
+// operations and 422 for ones the gateway can't apply without the
+// resource.
+func translatePatch(ops []patchOp) (map[string]interface{}, []string, int, error) {
+	doc := make(map[string]interface{})
+	var mask []string
+	seen := make(map[string]bool)
+
+	for i, op := range ops {
+		invalid := func(format string, args ...interface{}) (map[string]interface{}, []string, int, error) {
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("operation %d: "+format, append([]interface{}{i}, args...)...)
+		}
+		unsupported := func(format string, args ...interface{}) (map[string]interface{}, []string, int, error) {
+			return nil, nil, http.StatusUnprocessableEntity, fmt.Errorf("operation %d: "+format, append([]interface{}{i}, args...)...)
+		}
+
+		if op.Path == nil {
+			return invalid("missing path")
+		}
+		segments, err := parsePointer(*op.Path)
+		if err != nil {
+			return invalid("path: %v", err)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return invalid("%s requires a value", op.Op)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return invalid("%s requires from", op.Op)
+			}
+			if _, err := parsePointer(*op.From); err != nil {
+				return invalid("from: %v", err)
+			}
+		case "remove":
+		default:
+			return invalid("unknown op %q", op.Op)
+		}
+
+		switch {
+		case op.Op == "move" || op.Op == "copy" || op.Op == "test":
+			return unsupported("%s needs the current resource and is not supported", op.Op)
+		case len(segments) == 0:
+			return unsupported("operations on the whole document are not supported")
+		}
+		for _, seg := range segments {
+			if seg == "-" || isIndex(seg) {
+				return unsupported("array element paths are not supported")
+			}
+		}
+
+		var value interface{}
+		if op.Op != "remove" {
+			if err := decodeJSON(op.Value, &value); err != nil {
+				return invalid("value: %v", err)
+			}
+			switch value.(type) {
+			case nil:
+				return unsupported("%s with a null value is not supported; use remove", op.Op)
+			case map[string]interface{}:
+				return unsupported("%s with an object value is not supported; set its members one by one or send a merge patch", op.Op)
+			}
+		}
+		if !setPath(doc, segments, value) {
+			return unsupported("path %s conflicts with an earlier operation", *op.Path)
+		}
+		if path := strings.Join(segments, "."); !seen[path] {
+			seen[path] = true
+			mask = append(mask, path)
+		}
+	}
+	return doc, mask, 0, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference
+// tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%q must start with /", pointer)
+	}
+	segments := strings.Split(pointer[1:], "/")
+	for i, seg := range segments {
+		for j := 0; j < len(seg); j++ {
+			if seg[j] == '~' && (j+1 == len(seg) || (seg[j+1] != '0' && seg[j+1] != '1')) {
+				return nil, fmt.Errorf("%q has an invalid ~ escape", pointer)
+			}
+		}
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+	}
+	return segments, nil
+}
+
+func isIndex(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	for _, r := range seg {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// setPath sets the member at segments in doc, creating intermediate
+// objects. It reports false if an earlier operation left a non-object
+// value on the way.
+func setPath(doc map[string]interface{}, segments []string, value interface{}) bool {
+	for _, seg := range segments[:len(segments)-1] {
+		next, exists := doc[seg]
+		if !exists {
+			child := make(map[string]interface{})
+			doc[seg] = child
+			doc = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		doc = child
+	}
+	doc[segments[len(segments)-1]] = value
+	return true
+}
+
+// mergeMask lists, sorted, the dotted paths of the leaves a merge patch
+// sets or removes. Nested objects merge, so only their members are listed,
+// and an empty object changes nothing.
+func mergeMask(doc map[string]interface{}, prefix string, mask []string) []string {
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := prefix + key
+		if child, ok := doc[key].(map[string]interface{}); ok {
+			mask = mergeMask(child, path+".", mask)
+			continue
+		}
+		mask = append(mask, path)
+	}
+	return mask
+}
+
+// decodeJSON decodes exactly one JSON value, keeping numbers exact.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
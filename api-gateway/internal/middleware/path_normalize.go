@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Path normalization modes. PathNormalizeOff only applies to trailing
+// slashes.
+const (
+	PathNormalizeRedirect = "redirect"
+	PathNormalizeRewrite  = "rewrite"
+	PathNormalizeOff      = "off"
+)
+
+// NormalizePaths maps request paths onto the routes registered on engine
+// before gin matches them: runs of slashes are collapsed, a trailing slash
+// is added or dropped to match the route, and static segments are folded to
+// the route's case. Path parameter values keep the case the client sent.
+// Paths that match no route, and paths with escaped characters, are left
+// for gin to answer as usual.
+//
+// In redirect mode a request for a non-canonical path gets a 301 (308 for
+// methods other than GET and HEAD, so the body is resent) to the canonical
+// one; in rewrite mode it is served as if it had asked for it, method and
+// body untouched. A path that differs from its route only by a trailing
+// slash is handled in the trailing slash mode, even with the rest of
+// normalization disabled; unless that is off, gin's own trailing slash
+// redirects are turned off, so such paths get one consistent answer.
+//
+// It wraps the engine rather than running as gin middleware because gin
+// has already matched the route by the time middleware runs. Routes must be
+// registered before it is called.
+func NormalizePaths(cfg config.PathNormalizationConfig, engine *gin.Engine) http.Handler {
+	if cfg.TrailingSlash != PathNormalizeOff {
+		engine.RedirectTrailingSlash = false
+	} else if !cfg.Enabled {
+		return engine
+	}
+	if !cfg.Enabled {
+		// Trailing slashes only
+		cfg.CaseFold, cfg.CollapseSlashes = false, false
+	}
+	n := &pathNormalizer{cfg: cfg, bySegments: make(map[int][][]string)}
+	seen := make(map[string]bool)
+	for _, info := range engine.Routes() {
+		if seen[info.Path] {
+			continue
+		}
+		seen[info.Path] = true
+		segments := strings.Split(info.Path, "/")
+		if strings.HasPrefix(segments[len(segments)-1], "*") {
+			n.catchAll = append(n.catchAll, segments)
+		} else {
+			n.bySegments[len(segments)] = append(n.bySegments[len(segments)], segments)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawPath != "" {
+			engine.ServeHTTP(w, r)
+			return
+		}
+		canonical, slashOnly := n.canonical(r.URL.Path)
+		if canonical == r.URL.Path {
+			engine.ServeHTTP(w, r)
+			return
+		}
+
+		mode := cfg.Mode
+		if slashOnly {
+			mode = cfg.TrailingSlash
+		}
+		if mode == PathNormalizeRewrite {
+			r.URL.Path = canonical
+			engine.ServeHTTP(w, r)
+			return
+		}
+		location := canonical
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, location, status)
+	})
+}
+
+type pathNormalizer struct {
+	cfg config.PathNormalizationConfig
+	// bySegments holds the registered route templates split on "/", keyed
+	// by segment count; catchAll holds those ending in a *param.
+	bySegments map[int][][]string
+	catchAll   [][]string
+}
+
+// canonical returns the normalized form of path, or path itself when no
+// normalized form matches a route. slashOnly reports that the two differ
+// only by a trailing slash.
+func (n *pathNormalizer) canonical(path string) (canonical string, slashOnly bool) {
+	p := path
+	if n.cfg.CollapseSlashes {
+		for strings.Contains(p, "//") {
+			p = strings.ReplaceAll(p, "//", "/")
+		}
+	}
+	if match, ok := n.match(p); ok {
+		return match, false
+	}
+	if n.cfg.TrailingSlash != PathNormalizeOff && p != "/" {
+		alt := p + "/"
+		if strings.HasSuffix(p, "/") {
+			alt = strings.TrimSuffix(p, "/")
+		}
+		if match, ok := n.match(alt); ok {
+			return match, p == path && match == alt
+		}
+	}
+	return path, false
+}
+
+// match returns p with its static segments in the case of the best
+// matching route. A route whose static segments match exactly wins; among
+// case-insensitive matches the one with the most static segments wins, as
+// gin prefers static segments over parameters.
+func (n *pathNormalizer) match(p string) (string, bool) {
+	segments := strings.Split(p, "/")
+
+	var best []string
+	bestStatic := -1
+	for _, templates := range [][][]string{n.bySegments[len(segments)], n.catchAll} {
+		for _, tmpl := range templates {
+			static, exact, ok := matchTemplate(segments, tmpl, n.cfg.CaseFold)
+			if !ok {
+				continue
+			}
+			if exact {
+				return p, true
+			}
+			if static > bestStatic {
+				best, bestStatic = tmpl, static
+			}
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+
+	folded := make([]string, len(segments))
+	copy(folded, segments)
+	for i, seg := range best {
+		if strings.HasPrefix(seg, "*") {
+			break
+		}
+		if !strings.HasPrefix(seg, ":") {
+			folded[i] = seg
+		}
+	}
+	return strings.Join(folded, "/"), true
+}
+
+// matchTemplate reports whether segments fit the route template tmpl,
+// comparing static segments case-insensitively when fold is set. static is
+// the number of static segments compared and exact whether they all
+// matched case-sensitively.
+func matchTemplate(segments, tmpl []string, fold bool) (static int, exact, ok bool) {
+	exact = true
+	for i, seg := range tmpl {
+		if strings.HasPrefix(seg, "*") {
+			return static, exact, len(segments) > i
+		}
+		if i >= len(segments) {
+			return 0, false, false
+		}
+		if strings.HasPrefix(seg, ":") {
+			if segments[i] == "" {
+				return 0, false, false
+			}
+			continue
+		}
+		static++
+		switch {
+		case segments[i] == seg:
+		case fold && strings.EqualFold(segments[i], seg):
+			exact = false
+		default:
+			return 0, false, false
+		}
+	}
+	return static, exact, len(segments) == len(tmpl)
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/schema"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ValidateQuery enforces the query policy of routes that declare one: values
+// must parse as their parameter's type (and, for parameters the OpenAPI spec
+// declares, match its schema), and unknown or repeated parameters are
+// passed, stripped or rejected as the policy says. Stripped parameters are
+// removed from the request URL before anything reads it, so backends never
+// see them. Routes without a query policy are not checked.
+func ValidateQuery(routes config.RouteTable, spec *schema.Registry, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+		if !ok || route.Query == nil {
+			c.Next()
+			return
+		}
+		policy := route.Query
+
+		allowed := make(map[string]*schema.Schema)
+		repeated := make(map[string]bool)
+		if op, ok := spec.Lookup(c.Request.Method, c.FullPath()); ok {
+			for name, s := range op.Query {
+				allowed[name] = s
+				repeated[name] = s != nil && s.Type == "array"
+			}
+		}
+		// A listed type replaces the spec's schema; the default string type
+		// leaves it in place.
+		for _, p := range policy.Params {
+			if _, ok := allowed[p.Name]; !ok || p.Type != "string" {
+				allowed[p.Name] = &schema.Schema{Type: p.Type}
+			}
+			repeated[p.Name] = repeated[p.Name] || p.Repeated
+		}
+
+		query := c.Request.URL.Query()
+		var stripped []string
+		for name, values := range query {
+			s, known := allowed[name]
+			if !known || (len(values) > 1 && !repeated[name]) {
+				reason := "is not allowed"
+				if known {
+					reason = "must not be repeated"
+				}
+				switch policy.Unknown {
+				case config.QueryUnknownReject:
+					apierror.AbortWithDetails(c, http.StatusBadRequest, "INVALID_QUERY", "Query parameter "+name+" "+reason,
+						map[string]interface{}{"parameter": name})
+					return
+				case config.QueryUnknownStrip:
+					if known {
+						query[name] = values[:1]
+					} else {
+						delete(query, name)
+					}
+					stripped = append(stripped, name)
+				}
+				if !known {
+					continue
+				}
+			}
+			for _, value := range query[name] {
+				if err := validateQueryValue(s, value); err != nil {
+					apierror.AbortWithDetails(c, http.StatusBadRequest, "INVALID_QUERY", "Query parameter "+name+" "+err.Error(),
+						map[string]interface{}{"parameter": name})
+					return
+				}
+			}
+		}
+
+		if len(stripped) > 0 {
+			c.Request.URL.RawQuery = query.Encode()
+			logger.Debug("Stripped query parameters",
+				zap.String("route", c.FullPath()),
+				zap.Strings("parameters", stripped),
+				zap.String("request_id", c.GetString(apierror.RequestIDKey)))
+		}
+		c.Next()
+	}
+}
+
+// validateQueryValue parses value as s's type, or for arrays its items' type,
+// and checks the result against s.
+func validateQueryValue(s *schema.Schema, value string) error {
+	if s == nil {
+		return nil
+	}
+	check := s
+	if s.Type == "array" && s.Items != nil {
+		check = s.Items
+	}
+	var parsed interface{} = value
+	switch check.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("must be of type integer")
+		}
+		parsed = float64(n)
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return errors.New("must be of type number")
+		}
+		parsed = n
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be of type boolean")
+		}
+		parsed = b
+	}
+	var vErr *schema.ValidationError
+	if err := check.Validate(parsed); errors.As(err, &vErr) {
+		return errors.New(vErr.Reason)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quota counts each request against the tenant's and API key's period
+// quotas and refuses it with 429 QUOTA_EXCEEDED once either is used up.
+// Only keys AuthenticateAPIKey knows count, so changing the header to a
+// made-up key can't start a fresh quota.
+// Unlike RateLimit there is no refill: the request is refused until the
+// period rolls over. Redis failures allow the request through.
+func Quota(store *quota.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		targets := []quota.Target{{Scope: quota.ScopeTenant, ID: c.GetString(ContextKeyTenantID)}}
+		if keyID := c.GetString(ContextKeyAPIKeyID); keyID != "" {
+			targets = append(targets, quota.Target{Scope: quota.ScopeAPIKey, ID: keyID})
+		}
+
+		result, err := store.Consume(c.Request.Context(), targets)
+		if err != nil {
+			metrics.QuotaErrors.Inc()
+			c.Next()
+			return
+		}
+		if !result.Limited {
+			c.Next()
+			return
+		}
+
+		c.Header("X-Quota-Limit", strconv.FormatInt(result.Limit, 10))
+		c.Header("X-Quota-Remaining", strconv.FormatInt(result.Remaining, 10))
+		c.Header("X-Quota-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+		if !result.Allowed {
+			metrics.QuotaExceeded.Inc()
+			apierror.AbortOverloaded(c, apierror.OverloadRateLimited, time.Until(result.ResetAt),
+				"QUOTA_EXCEEDED", "Request quota for this period is exhausted")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/ratelimit"
+	"dharmaguard/api-gateway/internal/servertiming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit applies the configured token-bucket limit per user, or per client
+// IP for unauthenticated requests. With keys set, the bucket is derived from
+// the token's claims instead, falling back to the user or IP when a claim
+// the template needs is missing. Limiter errors fail open, unless limiter
+// is a ratelimit.FallbackLimiter, which doesn't return any. When overrides is
+// non-nil, a runtime user or tenant override replaces the static limit.
+//
+// With QueueMaxWait set, an over-limit request waits for a token instead of
+// being rejected immediately, for at most QueueMaxWait and never past the
+// request's own deadline. At most QueueMaxDepth requests wait at once.
+//
+// When alerts is non-nil, rejections are counted towards its alert
+// threshold.
+//
+// Requests on the exempt paths, from the exempt CIDRs or, when so
+// configured, from internal callers identified by IdentifyInternalCaller
+// are never limited, so probes and scrapers keep working during an
+// incident.
+func RateLimit(limiter ratelimit.Limiter, cfg config.RateLimitConfig, overrides *ratelimit.OverrideStore, keys *ratelimit.KeyTemplate, alerts *ratelimit.Alerter) gin.HandlerFunc {
+	staticLimit := ratelimit.Limit{
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		Burst:             cfg.BurstSize,
+	}
+	maxWait := cfg.QueueMaxWait
+	var queued int64
+	var exemptNets []*net.IPNet
+	for _, cidr := range cfg.Exempt.CIDRs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			exemptNets = append(exemptNets, n)
+		}
+	}
+
+	return func(c *gin.Context) {
+		if reason := rateLimitExemption(c, cfg.Exempt, exemptNets); reason != "" {
+			metrics.RateLimitExemptions.WithLabelValues(reason).Inc()
+			c.Next()
+			return
+		}
+
+		key := rateLimitKey(c, keys)
+		ctx := c.Request.Context()
+
+		limit := staticLimit
+		if overrides != nil {
+			override, err := overrides.Resolve(ctx, c.GetString(ContextKeyTenantID), c.GetString(ContextKeyUserID))
+			if err != nil {
+				metrics.RateLimitErrors.Inc()
+			} else if override != nil {
+				limit = override.Limit()
+			}
+		}
+
+		stop := servertiming.Start(ctx, "ratelimit")
+		result, err := limiter.Allow(ctx, key, limit)
+		stop()
+		if err != nil {
+			metrics.RateLimitErrors.Inc()
+			c.Next()
+			return
+		}
+
+		if !result.Allowed && maxWait > 0 {
+			if atomic.AddInt64(&queued, 1) <= int64(cfg.QueueMaxDepth) {
+				metrics.RateLimitQueueDepth.Inc()
+				start := time.Now()
+				result, err = waitForToken(ctx, limiter, key, limit, result, start.Add(maxWait))
+				metrics.RateLimitQueueWait.Observe(time.Since(start).Seconds())
+				metrics.RateLimitQueueDepth.Dec()
+			}
+			atomic.AddInt64(&queued, -1)
+			if err != nil {
+				metrics.RateLimitErrors.Inc()
+				c.Next()
+				return
+			}
+		}
+
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
+			apierror.AbortOverloaded(c, apierror.OverloadRateLimited, result.RetryAfter, "RATE_LIMITED", "Rate limit exceeded")
+			if alerts != nil {
+				alerts.Record(key, c.GetString(ContextKeyTenantID), c.FullPath())
+			}
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitExemption returns why the request is exempt from rate limiting
+// ("path", "cidr" or "internal_caller"), or "" if it isn't.
+func rateLimitExemption(c *gin.Context, exempt config.RateLimitExemptConfig, nets []*net.IPNet) string {
+	switch {
+	case hasAnyPrefix(c.Request.URL.Path, exempt.Paths):
+		return "path"
+	case len(nets) > 0 && fromTrustedNetwork(c, nets):
+		return "cidr"
+	case exempt.InternalCallers && c.GetString(ContextKeyInternalCaller) != "":
+		return "internal_caller"
+	}
+	return ""
+}
+
+// waitForToken retries until a token is granted, the wait budget would be
+// exceeded, or ctx is done. It returns the last denied result in the latter
+// two cases.
+func waitForToken(ctx context.Context, limiter ratelimit.Limiter, key string, limit ratelimit.Limit, result ratelimit.Result, deadline time.Time) (ratelimit.Result, error) {
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	for !result.Allowed {
+		wait := result.RetryAfter
+		if wait <= 0 {
+			wait = 10 * time.Millisecond
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return result, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, nil
+		case <-timer.C:
+		}
+
+		next, err := limiter.Allow(ctx, key, limit)
+		if err != nil {
+			return result, err
+		}
+		result = next
+	}
+	return result, nil
+}
+
+func rateLimitKey(c *gin.Context, keys *ratelimit.KeyTemplate) string {
+	if claims, ok := GetClaims(c); ok && keys != nil {
+		if key, ok := keys.Key(claims.Value); ok {
+			return key
+		}
+	}
+	if userID := c.GetString(ContextKeyUserID); userID != "" {
+		return "user:" + userID
+	}
+	// A request authenticated by API key alone has no user: it counts
+	// against its tenant, or its key while no tenant is resolved, wherever
+	// it comes from.
+	if keyID := c.GetString(ContextKeyAPIKeyID); keyID != "" {
+		if tenantID := c.GetString(ContextKeyTenantID); tenantID != "" {
+			return "tenant:" + tenantID
+		}
+		return "api_key:" + keyID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func setRateLimitHeaders(c *gin.Context, result ratelimit.Result) {
+	if result.Limit == 0 {
+		return
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+}
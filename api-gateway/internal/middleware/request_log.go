@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redacted = "[REDACTED]"
+
+// defaultRedactFields are masked in logged JSON bodies and query strings
+// whatever the configuration adds.
+var defaultRedactFields = []string{
+	"password", "new_password", "current_password", "secret", "client_secret",
+	"token", "access_token", "refresh_token", "id_token", "api_key", "otp", "mfa_code",
+}
+
+// RequestLog logs a request in detail, with headers and bodies, when it
+// fails with a 5xx, is slower than the configured threshold, or is picked
+// by the sample rate; other requests are not logged here. Credentials are
+// masked: the proxy's stripped headers, and the redacted fields in JSON
+// bodies and query strings. Bodies that aren't JSON are logged by size
+// only, since they can't be redacted. The request body is captured as the
+// handler reads it, so a request rejected before that logs none.
+func RequestLog(cfg config.RequestLogConfig, logger *zap.Logger) gin.HandlerFunc {
+	slow := cfg.SlowThreshold
+	fields := make(map[string]bool)
+	for _, f := range append(defaultRedactFields, cfg.RedactFields...) {
+		fields[strings.ToLower(f)] = true
+	}
+	headers := make(map[string]bool)
+	for _, h := range proxy.DefaultForwardDeny {
+		headers[http.CanonicalHeaderKey(h)] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		reqBody := captureRequestBody(c, cfg.MaxBodyBytes)
+		capture := &captureWriter{ResponseWriter: c.Writer, limit: cfg.MaxBodyBytes}
+		c.Writer = capture
+
+		c.Next()
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		level, reason := zapcore.InfoLevel, "sampled"
+		switch {
+		case status >= http.StatusInternalServerError:
+			level, reason = zapcore.ErrorLevel, "error"
+		case slow > 0 && latency >= slow:
+			level, reason = zapcore.WarnLevel, "slow"
+		case cfg.SampleRate <= 0 || rand.Float64() >= cfg.SampleRate:
+			return
+		}
+
+		logger.Check(level, "Request detail").Write(
+			zap.String("reason", reason),
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("query", redactQuery(c.Request.URL.Query(), fields)),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.String("request_id", c.GetString(apierror.RequestIDKey)),
+			zap.String("user_id", c.GetString(ContextKeyUserID)),
+			zap.String("tenant_id", c.GetString(ContextKeyTenantID)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Any("request_headers", redactHeaders(c.Request.Header, headers)),
+			zap.Any("request_body", redactBody(c.Request.Header.Get("Content-Type"), reqBody.body.Bytes(), fields)),
+			zap.Any("response_headers", redactHeaders(c.Writer.Header(), headers)),
+			zap.Any("response_body", redactBody(c.Writer.Header().Get("Content-Type"), capture.body.Bytes(), fields)),
+		)
+	}
+}
+
+// captureRequestBody keeps the first limit bytes (plus one, to tell a
+// truncated body) of the request body as the handler reads it. It reads
+// nothing itself: the server sends 100 Continue to a client that expects
+// it only once the body is read, so a request rejected before its handler
+// reads the body (by auth, say) gets its final status without the client
+// uploading it.
+func captureRequestBody(c *gin.Context, limit int) *captureReader {
+	r := &captureReader{limit: limit}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody && limit > 0 {
+		r.ReadCloser = c.Request.Body
+		c.Request.Body = r
+	}
+	return r
+}
+
+type captureReader struct {
+	io.ReadCloser
+	limit int
+	body  bytes.Buffer
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	keepPrefix(&r.body, r.limit, p[:n])
+	return n, err
+}
+
+// captureWriter passes the response through, keeping the first limit
+// bytes (plus one, to tell a truncated body) of the body.
+type captureWriter struct {
+	gin.ResponseWriter
+	limit int
+	body  bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) keep(data []byte) {
+	keepPrefix(&w.body, w.limit, data)
+}
+
+// keepPrefix appends data to buf until buf holds limit+1 bytes.
+func keepPrefix(buf *bytes.Buffer, limit int, data []byte) {
+	if room := limit + 1 - buf.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		buf.Write(data)
+	}
+}
+
+func redactHeaders(h http.Header, sensitive map[string]bool) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitive[http.CanonicalHeaderKey(name)] {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+func redactQuery(q url.Values, fields map[string]bool) string {
+	for name := range q {
+		if fields[strings.ToLower(name)] {
+			q.Set(name, redacted)
+		}
+	}
+	return q.Encode()
+}
+
+// redactBody returns the JSON body with sensitive fields masked, or a
+// placeholder for bodies that aren't JSON or were truncated.
+func redactBody(contentType string, body []byte, fields map[string]bool) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if !strings.Contains(contentType, "json") {
+		return map[string]interface{}{"omitted": "non-JSON body", "bytes": len(body)}
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return map[string]interface{}{"omitted": "truncated or invalid JSON", "bytes": len(body)}
+	}
+	return redactValue(doc, fields)
+}
+
+func redactValue(v interface{}, fields map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if fields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(child, fields)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(child, fields)
+		}
+	}
+	return v
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/authz"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ContextKeyResource holds the *authz.Resource the request targets.
+const ContextKeyResource = "authz_resource"
+
+// EnrichResource looks up the resource that routes with a resource policy
+// act on, such as the tenant and owner of the alert being resolved, and
+// stores it for Authorize and for handlers making their own ownership
+// checks. Authorization depends on the answer, so a failed lookup refuses
+// the request with a 503; a resource that doesn't exist is passed on with
+// no attributes. A nil resolver does nothing.
+func EnrichResource(routes config.RouteTable, resolver *authz.ResourceResolver, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+		if !ok || route.Resource == nil || resolver == nil {
+			c.Next()
+			return
+		}
+		policy := route.Resource
+		param := policy.Param
+		if param == "" {
+			param = "id"
+		}
+
+		resource, err := resolver.Lookup(c.Request.Context(), policy.Type, c.Param(param))
+		if err != nil {
+			metrics.ResourceLookupErrors.WithLabelValues(policy.Type).Inc()
+			logger.Error("Resource lookup for authorization failed",
+				zap.String("route", c.FullPath()), zap.String("resource_type", policy.Type), zap.Error(err))
+			apierror.Abort(c, http.StatusServiceUnavailable, "AUTHORIZATION_UNAVAILABLE",
+				"The request could not be authorized; retry shortly")
+			return
+		}
+		c.Set(ContextKeyResource, resource)
+		c.Next()
+	}
+}
+
+// GetResource returns the resource EnrichResource found for the request.
+func GetResource(c *gin.Context) (*authz.Resource, bool) {
+	value, ok := c.Get(ContextKeyResource)
+	if !ok {
+		return nil, false
+	}
+	resource, ok := value.(*authz.Resource)
+	return resource, ok
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/httpheader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseHeaders adds each route's configured response headers, replacing
+// global defaults set by earlier middleware such as SecurityHeaders. They
+// are set before the handler runs, so a handler that sets the same header
+// replaces the configured value. A configured Vary is merged with the one
+// already set, and later layers add to it.
+func ResponseHeaders(routes config.RouteTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+		if ok {
+			for name, value := range route.Headers {
+				if http.CanonicalHeaderKey(name) == "Vary" {
+					httpheader.AddVary(c.Writer.Header(), value)
+					continue
+				}
+				c.Writer.Header().Set(name, value)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/schema"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ValidateResponses checks a sample of responses against the schema the
+// OpenAPI spec documents for their route and status. A response that is not
+// valid JSON, or does not match the schema, is logged and replaced with a
+// 502, so a backend regression reaches clients as a clean error instead of
+// a body they can't parse. Responses without a documented JSON schema pass
+// through unchecked.
+//
+// sampleRate is the fraction of requests checked; a route's
+// validate_response_rate overrides it. Installed inside the response cache,
+// the 502 is never stored, and routes with stale_if_error serve their last
+// good copy instead.
+func ValidateResponses(spec *schema.Registry, routes config.RouteTable, sampleRate float64, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rate := sampleRate
+		if route, ok := routes.Lookup(c.Request.Method, c.FullPath()); ok && route.ValidateResponseRate != nil {
+			rate = *route.ValidateResponseRate
+		}
+		if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+			c.Next()
+			return
+		}
+		op, ok := spec.Lookup(c.Request.Method, c.FullPath())
+		if !ok || len(op.Responses) == 0 {
+			c.Next()
+			return
+		}
+
+		buf := bufferResponse(c)
+		c.Next()
+
+		s := op.Responses[strconv.Itoa(buf.status)]
+		if s == nil {
+			s = op.Responses["default"]
+		}
+		mediaType, _, _ := mime.ParseMediaType(buf.Header().Get("Content-Type"))
+		if s == nil || mediaType != "application/json" || buf.body.Len() == 0 {
+			buf.flush(c)
+			return
+		}
+
+		var value interface{}
+		reason := ""
+		err := json.Unmarshal(buf.body.Bytes(), &value)
+		if err != nil {
+			reason = "malformed"
+		} else if err = s.Validate(value); err != nil {
+			reason = "schema"
+		}
+		if reason == "" {
+			buf.flush(c)
+			return
+		}
+
+		metrics.ResponseValidationFailures.WithLabelValues(c.FullPath(), reason).Inc()
+		logger.Error("Backend response failed validation",
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", buf.status),
+			zap.String("reason", reason),
+			zap.Error(err),
+			zap.Int("body_bytes", buf.body.Len()),
+			zap.String("request_id", c.GetString(apierror.RequestIDKey)))
+
+		buf.body.Reset()
+		buf.Header().Del("Content-Length")
+		apierror.Abort(c, http.StatusBadGateway, "INVALID_UPSTREAM_RESPONSE",
+			"The backend service returned an invalid response")
+		buf.flush(c)
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/routeswitch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DisabledRoutes answers requests for routes disabled in store with 503,
+// leaving every other route running. Each refused request is counted by
+// route.
+func DisabledRoutes(store *routeswitch.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		if _, disabled := store.Disabled(c.Request.Method, route); !disabled {
+			c.Next()
+			return
+		}
+		metrics.DisabledRouteHits.WithLabelValues(c.Request.Method, route).Inc()
+		apierror.Abort(c, http.StatusServiceUnavailable, "ROUTE_DISABLED",
+			"This endpoint is temporarily disabled; try again later")
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope enforces the OAuth scopes each route declares in the routes
+// config. It must run after AuthRequired; routes without declared scopes pass.
+func RequireScope(routes config.RouteTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+		if !ok || len(route.Scopes) == 0 {
+			c.Next()
+			return
+		}
+
+		claims, ok := GetClaims(c)
+		if !ok {
+			apierror.Abort(c, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication required")
+			return
+		}
+
+		for _, scope := range route.Scopes {
+			if !claims.Scope.Has(scope) {
+				apierror.AbortWithDetails(c, http.StatusForbidden, "INSUFFICIENT_SCOPE",
+					fmt.Sprintf("Token is missing required scope %q", scope),
+					map[string]interface{}{"required_scope": scope})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"strings"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/servertiming"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServerTiming adds a Server-Timing header breaking the response time down
+// into gateway overhead, upstream latency and the steps timed along the
+// way (auth, rate limiting, authorization, cache lookups), so it shows in
+// browser devtools and RUM tools. The header is stamped when the response
+// starts, so it covers the time until then. Paths under an excluded prefix
+// get no header: timing login and token endpoints would help probe for
+// valid accounts.
+func ServerTiming(cfg config.ServerTimingConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Enabled || hasAnyPrefix(c.Request.URL.Path, cfg.ExcludePrefixes) {
+			c.Next()
+			return
+		}
+
+		ctx, timings := servertiming.New(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+		w := &timingWriter{ResponseWriter: c.Writer, timings: timings}
+		c.Writer = w
+
+		c.Next()
+		w.stamp()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// timingWriter sets the Server-Timing header just before the response
+// header is sent.
+type timingWriter struct {
+	gin.ResponseWriter
+	timings *servertiming.Timings
+	stamped bool
+}
+
+func (w *timingWriter) stamp() {
+	if w.stamped || w.ResponseWriter.Written() {
+		return
+	}
+	w.stamped = true
+	w.ResponseWriter.Header().Set("Server-Timing", w.timings.Header())
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.stamp()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timingWriter) Flush() {
+	w.stamp()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientTypeHeader is how a client declares its type at login, selecting
+// cookie or header token delivery.
+const ClientTypeHeader = "X-Client-Type"
+
+const contextKeyAuthMethod = "auth_method"
+
+// Authentication methods AuthRequired records on the context.
+const (
+	AuthMethodBearer = "bearer"
+	AuthMethodCookie = "cookie"
+	AuthMethodAPIKey = "api_key"
+)
+
+// SessionCookies moves tokens from successful login and refresh responses
+// into HttpOnly cookies for client types configured for cookie sessions, so
+// browser clients never expose the tokens to JavaScript. It also issues the
+// double-submit CSRF cookie, and clears all session cookies on logout.
+func SessionCookies(cfg config.SessionConfig, csrf config.CSRFConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.UsesCookies(c.GetHeader(ClientTypeHeader)) {
+			c.Next()
+			return
+		}
+
+		buf := bufferResponse(c)
+		c.Next()
+
+		if buf.status == http.StatusOK && c.FullPath() == cfg.LogoutPath {
+			clearSessionCookies(c, cfg, csrf)
+		} else if buf.status == http.StatusOK {
+			if body, ok := moveTokensToCookies(c, cfg, csrf, buf.body.Bytes()); ok {
+				buf.body.Reset()
+				buf.body.Write(body)
+				c.Header("Content-Length", strconv.Itoa(len(body)))
+			}
+		}
+		buf.flush(c)
+	}
+}
+
+func moveTokensToCookies(c *gin.Context, cfg config.SessionConfig, csrf config.CSRFConfig, body []byte) ([]byte, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, false
+	}
+	accessToken, _ := payload["access_token"].(string)
+	if accessToken == "" {
+		return nil, false
+	}
+
+	maxAge := cfg.MaxAge
+	if expiresIn, ok := payload["expires_in"].(float64); ok && expiresIn > 0 {
+		maxAge = int(expiresIn)
+	}
+	setCookie(c, cfg, cfg.CookieName, accessToken, "/", maxAge, true)
+	delete(payload, "access_token")
+
+	if refreshToken, ok := payload["refresh_token"].(string); ok && refreshToken != "" {
+		setCookie(c, cfg, cfg.RefreshCookieName, refreshToken, cfg.RefreshPath, cfg.RefreshMaxAge, true)
+		delete(payload, "refresh_token")
+	}
+
+	// A fresh CSRF token per login so a token planted before authentication
+	// can't be reused.
+	if err := issueCSRFToken(c, cfg, csrf); err != nil {
+		return nil, false
+	}
+
+	rewritten, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false
+	}
+	return rewritten, true
+}
+
+// maxRefreshBody bounds the refresh request body RefreshFromCookie reads.
+const maxRefreshBody = 64 << 10
+
+// RefreshFromCookie lets cookie-session clients refresh: when the request
+// body carries no refresh_token and the refresh cookie is present, the
+// cookie's token is put in the body for the refresh handler. As the cookie
+// is sent by the browser on its own, the request must pass the CSRF check.
+func RefreshFromCookie(cfg config.SessionConfig, csrf config.CSRFConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		refreshToken, err := c.Cookie(cfg.RefreshCookieName)
+		if err != nil || refreshToken == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRefreshBody+1))
+		if err != nil || len(body) > maxRefreshBody {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Failed to read request body")
+			return
+		}
+		payload := map[string]interface{}{}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &payload); err != nil {
+				apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Request body must be a JSON object")
+				return
+			}
+		}
+		if token, _ := payload["refresh_token"].(string); token == "" {
+			if !validCSRFToken(c, csrf) {
+				apierror.Abort(c, http.StatusForbidden, "CSRF_TOKEN_INVALID", "Missing or invalid CSRF token")
+				return
+			}
+			payload["refresh_token"] = refreshToken
+			if body, err = json.Marshal(payload); err != nil {
+				apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to prepare refresh request")
+				return
+			}
+			c.Request.Header.Set("Content-Type", "application/json")
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Next()
+	}
+}
+
+func clearSessionCookies(c *gin.Context, cfg config.SessionConfig, csrf config.CSRFConfig) {
+	setCookie(c, cfg, cfg.CookieName, "", "/", -1, true)
+	setCookie(c, cfg, cfg.RefreshCookieName, "", cfg.RefreshPath, -1, true)
+	setCookie(c, cfg, csrf.CookieName, "", "/", -1, false)
+}
+
+func setCookie(c *gin.Context, cfg config.SessionConfig, name, value, path string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: sameSite(cfg.SameSite),
+	})
+}
+
+func sameSite(mode string) http.SameSite {
+	switch mode {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+// sessionToken returns the access token from the session cookie, if any.
+func sessionToken(c *gin.Context, cfg config.SessionConfig) string {
+	if cfg.CookieName == "" {
+		return ""
+	}
+	token, err := c.Cookie(cfg.CookieName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+func authMethod(c *gin.Context) string {
+	method, _ := c.Get(contextKeyAuthMethod)
+	m, _ := method.(string)
+	return m
+}
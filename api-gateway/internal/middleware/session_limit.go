@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LimitSessions records the session of every successful login in store,
+// holding users to its session limit. A login beyond the limit is either
+// refused with 409, its freshly issued tokens revoked before they leave
+// the gateway, or admitted by evicting the user's oldest sessions, each of
+// which is reported to onEvict. It must run inside SessionCookies, which
+// needs the tokens it reads. Redis failures let the login through.
+func LimitSessions(authService *auth.Service, store *auth.SessionStore, onEvict func(c *gin.Context, claims *auth.Claims, evicted auth.Session), logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buf := bufferResponse(c)
+		c.Next()
+
+		claims := loginClaims(c, authService, buf)
+		if claims == nil {
+			buf.flush(c)
+			return
+		}
+
+		ctx := c.Request.Context()
+		evicted, err := store.Admit(ctx, claims.TenantID, claims.UserID, auth.Session{
+			ID:        claims.Session(),
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		switch {
+		case errors.Is(err, auth.ErrSessionLimit):
+			if err := store.Revoke(ctx, claims.Session()); err != nil {
+				logger.Warn("Failed to revoke refused session", zap.String("user_id", claims.UserID), zap.Error(err))
+			}
+			c.Writer = buf.ResponseWriter
+			c.Writer.Header().Del("Content-Length")
+			apierror.AbortWithDetails(c, http.StatusConflict, "SESSION_LIMIT_REACHED",
+				"Too many active sessions; sign out of another session first",
+				map[string]interface{}{"max_sessions": store.Limit()})
+			return
+		case err != nil:
+			logger.Warn("Failed to record session", zap.String("user_id", claims.UserID), zap.Error(err))
+		}
+		for _, session := range evicted {
+			onEvict(c, claims, session)
+		}
+		buf.flush(c)
+	}
+}
+
+// loginClaims returns the claims of the access token a successful login
+// response carries, or nil if it has none with a session ID.
+func loginClaims(c *gin.Context, authService *auth.Service, buf *responseBuffer) *auth.Claims {
+	if buf.status != http.StatusOK {
+		return nil
+	}
+	var payload struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(buf.body.Bytes(), &payload); err != nil || payload.AccessToken == "" {
+		return nil
+	}
+	claims, err := authService.ValidateToken(c.Request.Context(), payload.AccessToken)
+	if err != nil || claims.Session() == "" {
+		return nil
+	}
+	return claims
+}
+
+// EndSession removes the caller's session from store after a successful
+// logout, revoking its tokens. The caller is identified as AuthRequired
+// would, by bearer token or session cookie.
+func EndSession(authService *auth.Service, store *auth.SessionStore, sessions config.SessionConfig, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			token = sessionToken(c, sessions)
+		}
+		var claims *auth.Claims
+		if token != "" {
+			claims, _ = authService.ValidateToken(c.Request.Context(), token)
+		}
+
+		c.Next()
+
+		if claims == nil || claims.Session() == "" || c.Writer.Status() != http.StatusOK {
+			return
+		}
+		if err := store.Remove(c.Request.Context(), claims.TenantID, claims.UserID, claims.Session()); err != nil {
+			logger.Warn("Failed to end session", zap.String("user_id", claims.UserID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/reqctx"
+	"dharmaguard/api-gateway/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant resolvers, as named in TenantConfig.Resolvers.
+const (
+	TenantFromJWT    = "jwt"
+	TenantFromAPIKey = "api_key"
+	TenantFromHeader = "header"
+	TenantFromHost   = "host"
+)
+
+// ContextKeyTenantSource records which resolver supplied the tenant.
+const ContextKeyTenantSource = "tenant_source"
+
+// ResolveTenant settles the request's canonical tenant by trying the
+// configured resolvers in order, and stores it where rate limiting, quotas,
+// audit and backend calls read it. The tenant header is rewritten to the
+// canonical tenant, or removed, so a client-supplied value never reaches
+// backends unless a resolver vouched for it. Requests on tenant-required
+// routes that no resolver places get a 400. It must run after AuthRequired.
+//
+// When the host resolver is reached, a request for a host the gateway
+// doesn't serve gets a 421, and one whose token names a different tenant
+// than its host a 403.
+//
+// The api_key resolver reads the tenant AuthenticateAPIKey found for the
+// request's key, so it needs that middleware earlier in the chain.
+func ResolveTenant(cfg config.TenantConfig, routes config.RouteTable, hosts *tenant.HostResolver) gin.HandlerFunc {
+	var trusted []*net.IPNet
+	for _, cidr := range cfg.TrustedHeaderCIDRs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			trusted = append(trusted, n)
+		}
+	}
+
+	return func(c *gin.Context) {
+		headerValue := c.GetHeader(cfg.Header)
+		c.Request.Header.Del(cfg.Header)
+
+		tenantID, source := "", "none"
+		for _, resolver := range cfg.Resolvers {
+			switch resolver {
+			case TenantFromJWT:
+				if claims, ok := GetClaims(c); ok {
+					tenantID = claims.TenantID
+				}
+			case TenantFromAPIKey:
+				// A validated key places only requests whose token
+				// names no tenant of its own
+				if claims, ok := GetClaims(c); !ok || claims.TenantID == "" {
+					tenantID = c.GetString(ContextKeyAPIKeyTenant)
+				}
+			case TenantFromHeader:
+				if headerValue != "" && fromTrustedNetwork(c, trusted) {
+					tenantID = headerValue
+				}
+			case TenantFromHost:
+				var ok bool
+				if tenantID, ok = hostTenant(c, hosts); !ok {
+					return
+				}
+			}
+			if tenantID != "" {
+				source = resolver
+				break
+			}
+		}
+		metrics.TenantResolutions.WithLabelValues(source).Inc()
+
+		// Replace whatever AuthRequired took from the token, which may not
+		// be a configured resolver.
+		c.Set(ContextKeyTenantID, tenantID)
+		c.Set(ContextKeyTenantSource, source)
+		if rc, ok := reqctx.FromGin(c); ok {
+			rc.TenantID = tenantID
+		}
+		if tenantID != "" {
+			c.Request.Header.Set(cfg.Header, tenantID)
+		}
+
+		if tenantID == "" {
+			required := cfg.Required
+			if route, ok := routes.Lookup(c.Request.Method, c.FullPath()); ok && route.RequireTenant != nil {
+				required = *route.RequireTenant
+			}
+			if required {
+				apierror.Abort(c, http.StatusBadRequest, "TENANT_REQUIRED",
+					"No tenant could be determined for this request")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// hostTenant returns the tenant the request's Host is mapped to. It aborts
+// the request and returns false for unknown hosts and for tokens issued to
+// another tenant. A failed lookup resolves nothing.
+func hostTenant(c *gin.Context, hosts *tenant.HostResolver) (string, bool) {
+	if hosts == nil {
+		return "", true
+	}
+	tenantID, known, err := hosts.Resolve(c.Request.Context(), c.Request.Host)
+	if err != nil {
+		metrics.TenantResolutionErrors.Inc()
+		return "", true
+	}
+	if !known {
+		apierror.Abort(c, http.StatusMisdirectedRequest, "UNKNOWN_HOST",
+			"This host is not served by the API")
+		return "", false
+	}
+	if claims, ok := GetClaims(c); ok && tenantID != "" && claims.TenantID != "" && claims.TenantID != tenantID {
+		apierror.Abort(c, http.StatusForbidden, "TENANT_MISMATCH",
+			"The token was not issued for this host's tenant")
+		return "", false
+	}
+	return tenantID, true
+}
+
+func fromTrustedNetwork(c *gin.Context, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
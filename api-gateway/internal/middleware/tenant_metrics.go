@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantMetrics counts each request under its tenant's label and status
+// class ("2xx", "4xx", ...), for per-tenant request and error rates. It
+// must run after the tenant is resolved.
+func TenantMetrics(labels *metrics.TenantLabeler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		tenant := labels.Label(c.GetString(ContextKeyTenantID))
+		class := strconv.Itoa(c.Writer.Status()/100) + "xx"
+		metrics.TenantRequests.WithLabelValues(tenant, class).Inc()
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeoutHeader is how a client asks for a shorter deadline, as
+// milliseconds ("2000") or a duration ("2s", "1500ms").
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// Timeout sets the request context's deadline from the route's configured
+// timeout, or defaultTimeout. Backend calls made with the request context
+// inherit it, and the proxy propagates what remains to the backend.
+// WebSocket upgrades, event streams and requests under the longLived path
+// prefixes (long polling, which bounds its own hold, and downloads) are
+// left alone.
+//
+// With client enabled, X-Request-Timeout shortens the deadline: the value,
+// held within client's bounds, replaces the route's timeout when it is
+// shorter, and the context is marked with proxy.WithCallerDeadline. A
+// malformed value gets a 400.
+func Timeout(routes config.RouteTable, defaultTimeout time.Duration, client config.ClientTimeoutConfig, longLived ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isLongLived(c) || hasAnyPrefix(c.Request.URL.Path, longLived) {
+			c.Next()
+			return
+		}
+
+		timeout := defaultTimeout
+		callerChosen := false
+		if route, ok := routes.Lookup(c.Request.Method, c.FullPath()); ok && route.Timeout > 0 {
+			timeout = route.Timeout
+		}
+		if header := c.GetHeader(RequestTimeoutHeader); header != "" && client.Enabled {
+			requested, ok := parseRequestTimeout(header)
+			if !ok {
+				apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST_TIMEOUT",
+					"X-Request-Timeout must be a positive number of milliseconds or a duration such as 2s")
+				return
+			}
+			requested = max(client.Min, min(requested, client.Max))
+			if timeout <= 0 || requested < timeout {
+				timeout = requested
+				callerChosen = true
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		if callerChosen {
+			ctx = proxy.WithCallerDeadline(ctx)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func parseRequestTimeout(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms > math.MaxInt64/int64(time.Millisecond) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(ms) * time.Millisecond, ms > 0
+	}
+	d, err := time.ParseDuration(value)
+	return d, err == nil && d > 0
+}
+
+func isLongLived(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	return c.IsWebsocket() || strings.Contains(accept, "text/event-stream") ||
+		strings.Contains(accept, "application/x-ndjson")
+}
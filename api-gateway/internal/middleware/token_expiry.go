@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExpiryWarningsParam is the query parameter a WebSocket client sets to
+// opt in to token_expiring messages; browsers can't set headers on the
+// handshake.
+const ExpiryWarningsParam = "expiry_warnings"
+
+const contextKeyExpiryWarnings = "token_expiry_warnings"
+
+// TokenExpiring is the message pushed over a WebSocket shortly before the
+// connection's token expires, so the client can refresh it and reconnect
+// before being cut off.
+type TokenExpiring struct {
+	Type      string    `json:"type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// ExpiresIn is the seconds left when the message was sent.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// WarnTokenExpiry arranges a TokenExpiring message for connections that opt
+// in with ExpiryWarningsParam, due threshold before their token expires (at
+// once if less than that is left). WebSocket handlers receive it from
+// TokenExpiryWarnings and write it to the socket. A threshold of zero
+// disables warnings. It must run after authentication.
+func WarnTokenExpiry(threshold time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := GetClaims(c)
+		optIn, _ := strconv.ParseBool(c.Query(ExpiryWarningsParam))
+		if threshold <= 0 || !optIn || !ok || claims.ExpiresAt == nil {
+			c.Next()
+			return
+		}
+
+		expiresAt := claims.ExpiresAt.Time
+		warnings := make(chan TokenExpiring, 1)
+		timer := time.AfterFunc(time.Until(expiresAt)-threshold, func() {
+			warnings <- TokenExpiring{
+				Type:      "token_expiring",
+				ExpiresAt: expiresAt.UTC(),
+				ExpiresIn: int(time.Until(expiresAt).Seconds()),
+			}
+		})
+		defer timer.Stop()
+
+		c.Set(contextKeyExpiryWarnings, (<-chan TokenExpiring)(warnings))
+		c.Next()
+	}
+}
+
+// TokenExpiryWarnings returns the channel WarnTokenExpiry delivers the
+// connection's warning on. It is nil, and so never ready in a select, for
+// connections that did not opt in.
+func TokenExpiryWarnings(c *gin.Context) <-chan TokenExpiring {
+	value, ok := c.Get(contextKeyExpiryWarnings)
+	if !ok {
+		return nil
+	}
+	warnings, _ := value.(<-chan TokenExpiring)
+	return warnings
+}
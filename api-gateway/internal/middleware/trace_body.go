@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// authPrefix is never captured in traces: its bodies are credentials.
+const authPrefix = "/api/v1/auth"
+
+// TraceBodies records the request and response bodies of a sample of
+// traced requests on their span, as the http.request.body and
+// http.response.body attributes, for debugging failures from the trace
+// alone. Bodies are capped at the configured size and redacted as the
+// request log redacts them, with redactFields added to the defaults; bodies
+// that aren't JSON are recorded by size only. It must run inside the
+// tracing middleware.
+func TraceBodies(cfg config.TraceBodyConfig, redactFields []string) gin.HandlerFunc {
+	fields := make(map[string]bool)
+	for _, f := range append(defaultRedactFields, redactFields...) {
+		fields[strings.ToLower(f)] = true
+	}
+	excluded := append([]string{authPrefix}, cfg.ExcludePrefixes...)
+
+	return func(c *gin.Context) {
+		span := trace.SpanFromContext(c.Request.Context())
+		if cfg.SampleRate <= 0 || cfg.MaxBodyBytes <= 0 || !span.SpanContext().IsSampled() ||
+			hasAnyPrefix(c.Request.URL.Path, excluded) || rand.Float64() >= cfg.SampleRate {
+			c.Next()
+			return
+		}
+
+		reqBody := captureRequestBody(c, cfg.MaxBodyBytes)
+		capture := &captureWriter{ResponseWriter: c.Writer, limit: cfg.MaxBodyBytes}
+		c.Writer = capture
+
+		c.Next()
+
+		if body := spanBody(c.Request.Header.Get("Content-Type"), reqBody.body.Bytes(), fields); body != "" {
+			span.SetAttributes(attribute.String("http.request.body", body))
+		}
+		if body := spanBody(c.Writer.Header().Get("Content-Type"), capture.body.Bytes(), fields); body != "" {
+			span.SetAttributes(attribute.String("http.response.body", body))
+		}
+	}
+}
+
+// spanBody renders a redacted body as a span attribute value.
+func spanBody(contentType string, body []byte, fields map[string]bool) string {
+	doc := redactBody(contentType, body, fields)
+	if doc == nil {
+		return ""
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
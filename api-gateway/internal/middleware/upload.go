@@ -0,0 +1,282 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/upload"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// sniffLen is how much of a file http.DetectContentType looks at.
+const sniffLen = 512
+
+var errUploadTooLarge = errors.New("upload exceeds size limit")
+
+// rejection is a validation failure reported to the client.
+type rejection struct {
+	status  int
+	code    string
+	message string
+	details map[string]interface{}
+}
+
+func (r *rejection) Error() string { return r.message }
+
+// errBodyAbandoned ends the checks when the handler is done without having
+// read the body to its end.
+var errBodyAbandoned = errors.New("upload body abandoned by handler")
+
+// ValidateUpload checks multipart uploads while the handler forwards them.
+// It enforces a size cap, checks each file's declared content type against
+// the type sniffed from its first bytes and the route's allowed types, and
+// passes each file to scanner (if set). The body streams through to the
+// handler as it is checked, never held in memory or on disk, and the
+// handler's last read waits for the checks, so a rejected upload never
+// reaches the backend whole. The handler's response is held back until the
+// checks finish and replaced by the rejection if there is one.
+func ValidateUpload(cfg config.UploadConfig, routes config.RouteTable, scanner upload.Scanner, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := routes.UploadPolicy(c.Request.Method, c.FullPath(), cfg)
+		checkUpload(c, policy, scanner, logger, (*gin.Context).Next)
+	}
+}
+
+// CheckUpload returns handler wrapped in ValidateUpload's checks under
+// policy, for code that hands an upload to the upload handler directly
+// rather than through the router.
+func CheckUpload(policy config.UploadPolicy, scanner upload.Scanner, logger *zap.Logger, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkUpload(c, policy, scanner, logger, handler)
+	}
+}
+
+// checkUpload runs next with c's body checked under policy.
+func checkUpload(c *gin.Context, policy config.UploadPolicy, scanner upload.Scanner, logger *zap.Logger, next func(*gin.Context)) {
+	maxBytes, allowed := policy.MaxBytes, policy.AllowedTypes
+
+	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		apierror.Abort(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Uploads must be multipart/form-data")
+		return
+	}
+	if c.Request.ContentLength > maxBytes {
+		abortTooLarge(c, maxBytes)
+		return
+	}
+
+	body := newCheckedBody(&cappedReader{r: c.Request.Body, remaining: maxBytes})
+	ctx := c.Request.Context()
+	go body.check(func(r io.Reader) error {
+		return checkParts(ctx, multipart.NewReader(r, params["boundary"]), allowed, scanner)
+	})
+
+	buf := bufferResponse(c)
+	c.Request.Body = body
+	next(c)
+	err = body.finish()
+
+	var rej *rejection
+	switch {
+	case errors.Is(err, errUploadTooLarge):
+		buf.discard(c)
+		abortTooLarge(c, maxBytes)
+	case errors.As(err, &rej):
+		logger.Warn("Rejected upload",
+			zap.String("code", rej.code),
+			zap.Any("details", rej.details),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		buf.discard(c)
+		apierror.AbortWithDetails(c, rej.status, rej.code, rej.message, rej.details)
+	case err != nil:
+		buf.discard(c)
+		apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", "Malformed multipart body")
+	default:
+		buf.flush(c)
+	}
+}
+
+// checkedBody passes an upload through to the handler while copying it to
+// the checks running alongside. Each read waits for the checks to take the
+// bytes, and once they fail every read fails with their error.
+type checkedBody struct {
+	r    io.Reader
+	pw   *io.PipeWriter
+	pr   *io.PipeReader
+	done chan struct{}
+	// err is the checks' result, valid once done is closed.
+	err error
+	// ended is set once the handler has been given the end of the body or
+	// an error, final, which later reads return again.
+	ended   bool
+	final   error
+	readErr error
+}
+
+func newCheckedBody(r io.Reader) *checkedBody {
+	pr, pw := io.Pipe()
+	return &checkedBody{r: r, pw: pw, pr: pr, done: make(chan struct{})}
+}
+
+// check runs checks over the body as it is read.
+func (b *checkedBody) check(checks func(io.Reader) error) {
+	defer close(b.done)
+	err := checks(b.pr)
+	if err == nil {
+		// Let through whatever follows the final boundary.
+		_, err = io.Copy(io.Discard, b.pr)
+	}
+	b.err = err
+	b.pr.CloseWithError(err)
+}
+
+func (b *checkedBody) Read(p []byte) (int, error) {
+	if b.ended {
+		return 0, b.final
+	}
+	n, err := b.r.Read(p)
+	if n > 0 {
+		if _, werr := b.pw.Write(p[:n]); werr != nil {
+			<-b.done
+			b.ended, b.final = true, b.err
+			return 0, b.final
+		}
+	}
+	switch {
+	case err == io.EOF:
+		// Hold the last bytes back until every file has passed.
+		b.pw.Close()
+		<-b.done
+		b.ended, b.final = true, io.EOF
+		if b.err != nil {
+			b.final = b.err
+			return 0, b.final
+		}
+	case err != nil:
+		b.pw.CloseWithError(err)
+		<-b.done
+		b.ended, b.final, b.readErr = true, err, err
+	}
+	return n, err
+}
+
+func (b *checkedBody) Close() error { return nil }
+
+// finish ends the checks and returns their error. A handler that stopped
+// reading early, without being given an error, forwarded nothing whole, so
+// whatever it answered stands unless the checks had already failed.
+func (b *checkedBody) finish() error {
+	b.pw.CloseWithError(errBodyAbandoned)
+	<-b.done
+	if errors.Is(b.readErr, errUploadTooLarge) {
+		return b.readErr
+	}
+	var rej *rejection
+	if !b.ended && !errors.As(b.err, &rej) {
+		return nil
+	}
+	return b.err
+}
+
+func checkParts(ctx context.Context, reader *multipart.Reader, allowed []string, scanner upload.Scanner) error {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		if err := checkFile(ctx, part, allowed, scanner); err != nil {
+			return err
+		}
+	}
+}
+
+func checkFile(ctx context.Context, part *multipart.Part, allowed []string, scanner upload.Scanner) error {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+
+	declared := part.Header.Get("Content-Type")
+	if declared == "" {
+		declared = "application/octet-stream"
+	}
+	sniffed := http.DetectContentType(head)
+	details := map[string]interface{}{
+		"filename":      part.FileName(),
+		"declared_type": upload.BaseType(declared),
+		"detected_type": upload.BaseType(sniffed),
+	}
+	if !upload.Consistent(declared, sniffed) {
+		return &rejection{http.StatusUnprocessableEntity, "CONTENT_TYPE_MISMATCH",
+			"File content does not match its declared type", details}
+	}
+	if !upload.Allowed(declared, allowed) {
+		return &rejection{http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE",
+			"File type is not allowed", details}
+	}
+
+	content := io.MultiReader(bytes.NewReader(head), part)
+	if scanner == nil {
+		_, err := io.Copy(io.Discard, content)
+		return err
+	}
+	verdict, err := scanner.Scan(ctx, part.FileName(), content)
+	if errors.Is(err, errUploadTooLarge) {
+		return err
+	}
+	if err != nil {
+		return &rejection{http.StatusServiceUnavailable, "SCAN_UNAVAILABLE",
+			"Upload could not be scanned", details}
+	}
+	if !verdict.Clean {
+		details["threat"] = verdict.Threat
+		return &rejection{http.StatusUnprocessableEntity, "MALICIOUS_CONTENT",
+			"File was rejected by the content scanner", details}
+	}
+	return nil
+}
+
+func abortTooLarge(c *gin.Context, maxBytes int64) {
+	apierror.AbortWithDetails(c, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "Upload exceeds size limit",
+		map[string]interface{}{"max_bytes": maxBytes})
+}
+
+// cappedReader fails with errUploadTooLarge once more than remaining bytes
+// have been read.
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (r *cappedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, errUploadTooLarge
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/httpheader"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AcceptVersionHeader selects the API version of a response.
+	AcceptVersionHeader = "Accept-Version"
+	// APIVersionHeader reports the version a response was shaped for.
+	APIVersionHeader = "API-Version"
+)
+
+var (
+	pathVersionPattern  = regexp.MustCompile(`^/api/v([0-9]+)/`)
+	vendorAcceptPattern = regexp.MustCompile(`application/vnd\.dharmaguard\.v([0-9]+)\+json`)
+)
+
+// NegotiateVersion reshapes JSON responses for the API version the client
+// asks for, per the route's versions config. The version comes from the
+// Accept-Version header, then from a vendor media type in Accept
+// (application/vnd.dharmaguard.v2+json), and defaults to the one in the
+// path. Asking for a version the route doesn't serve is a 406. Only
+// successful JSON responses are transformed.
+//
+// It is installed outside the response cache, so cached entries hold the
+// backend's shape and each hit is transformed for its own client.
+func NegotiateVersion(routes config.RouteTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := routes.Lookup(c.Request.Method, c.FullPath())
+		if !ok || len(route.Versions) == 0 {
+			c.Next()
+			return
+		}
+		httpheader.AddVary(c.Writer.Header(), AcceptVersionHeader)
+
+		pathVersion := ""
+		if m := pathVersionPattern.FindStringSubmatch(c.FullPath()); m != nil {
+			pathVersion = m[1]
+		}
+		version := requestedVersion(c.Request)
+		if version == "" {
+			version = pathVersion
+		}
+		transform, ok := route.Version(version)
+		if !ok && version != pathVersion {
+			supported := []string{pathVersion}
+			for _, v := range route.Versions {
+				if v.Version != pathVersion {
+					supported = append(supported, v.Version)
+				}
+			}
+			sort.Strings(supported)
+			apierror.AbortWithDetails(c, http.StatusNotAcceptable, "UNSUPPORTED_VERSION",
+				"The requested API version is not available for this endpoint",
+				map[string]interface{}{"requested": version, "supported": supported})
+			return
+		}
+		c.Writer.Header().Set(APIVersionHeader, version)
+		if len(transform.Remove) == 0 && len(transform.Rename) == 0 {
+			c.Next()
+			return
+		}
+
+		buf := bufferResponse(c)
+		c.Next()
+
+		mediaType, _, _ := mime.ParseMediaType(buf.Header().Get("Content-Type"))
+		if buf.status < 200 || buf.status >= 300 || mediaType != "application/json" {
+			buf.flush(c)
+			return
+		}
+		// UseNumber keeps large integer IDs exact through the round trip.
+		var body interface{}
+		dec := json.NewDecoder(bytes.NewReader(buf.body.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil {
+			buf.flush(c)
+			return
+		}
+		for _, field := range transform.Remove {
+			removeField(body, strings.Split(field, "."))
+		}
+		for _, r := range transform.Rename {
+			renameField(body, strings.Split(r.From, "."), r.To)
+		}
+		out, err := json.Marshal(body)
+		if err != nil {
+			buf.flush(c)
+			return
+		}
+		buf.body.Reset()
+		buf.body.Write(out)
+		buf.Header().Del("Content-Length")
+		buf.flush(c)
+	}
+}
+
+// requestedVersion returns the version the client asked for, without a
+// leading "v", or "" if it didn't ask.
+func requestedVersion(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(AcceptVersionHeader)); v != "" {
+		return strings.TrimPrefix(strings.ToLower(v), "v")
+	}
+	if m := vendorAcceptPattern.FindStringSubmatch(r.Header.Get("Accept")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// eachObject calls fn with every object the path prefix leads to, and the
+// path's last segment.
+func eachObject(node interface{}, path []string, fn func(obj map[string]interface{}, field string)) {
+	switch n := node.(type) {
+	case []interface{}:
+		for _, elem := range n {
+			eachObject(elem, path, fn)
+		}
+	case map[string]interface{}:
+		if len(path) == 1 {
+			fn(n, path[0])
+			return
+		}
+		if child, ok := n[path[0]]; ok {
+			eachObject(child, path[1:], fn)
+		}
+	}
+}
+
+func removeField(body interface{}, path []string) {
+	eachObject(body, path, func(obj map[string]interface{}, field string) {
+		delete(obj, field)
+	})
+}
+
+func renameField(body interface{}, path []string, to string) {
+	eachObject(body, path, func(obj map[string]interface{}, field string) {
+		if value, ok := obj[field]; ok {
+			delete(obj, field)
+			obj[to] = value
+		}
+	})
+}
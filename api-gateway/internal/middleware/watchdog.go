@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/proxy"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ErrWatchdog is the cancellation cause of requests the watchdog stopped.
+var ErrWatchdog = errors.New("request exceeded the watchdog ceiling")
+
+// Watchdog is the safety net behind Timeout: a request still running after
+// ceiling has its context cancelled, which tears down its backend calls,
+// and the client gets a 504 straight away, even if the handler itself is
+// stuck and never returns. Whatever the handler writes afterwards is
+// discarded. Each firing is logged with the backend calls the request was
+// waiting on. Set the ceiling well above every route's timeout, so it only
+// fires when deadline propagation has failed. WebSocket upgrades, event
+// streams and requests under the longLived path prefixes are left alone.
+func Watchdog(ceiling time.Duration, logger *zap.Logger, longLived ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ceiling <= 0 || isLongLived(c) || hasAnyPrefix(c.Request.URL.Path, longLived) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithCancelCause(c.Request.Context())
+		defer cancel(nil)
+		ctx, inFlight := proxy.TrackCalls(ctx)
+		c.Request = c.Request.WithContext(ctx)
+		w := &watchdogWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		route, method, requestID := c.FullPath(), c.Request.Method, c.GetString(apierror.RequestIDKey)
+		start := time.Now()
+		timer := time.AfterFunc(ceiling, func() {
+			calls := inFlight()
+			cancel(ErrWatchdog)
+			metrics.WatchdogFired.WithLabelValues(route).Inc()
+			logger.Error("Watchdog cancelled stuck request",
+				zap.String("method", method),
+				zap.String("route", route),
+				zap.Duration("elapsed", time.Since(start)),
+				zap.Strings("backend_calls", calls),
+				zap.String("request_id", requestID))
+			body, _ := json.Marshal(apierror.APIError{
+				Code:      "GATEWAY_TIMEOUT",
+				Message:   "The request did not complete in time",
+				RequestID: requestID,
+			})
+			w.fire(body)
+		})
+		defer timer.Stop()
+
+		c.Next()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// watchdogWriter passes writes through until the watchdog fires. Then it
+// sends the 504 if nothing has been sent yet, and drops everything the
+// handler writes from then on.
+type watchdogWriter struct {
+	gin.ResponseWriter
+
+	mu      sync.Mutex
+	fired   bool
+	discard http.Header
+}
+
+func (w *watchdogWriter) fire(body []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fired = true
+	w.discard = make(http.Header)
+	if w.ResponseWriter.Written() {
+		return
+	}
+	h := w.ResponseWriter.Header()
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	// The handler may never return, so the response must be complete
+	// without the server finishing it.
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
+
+func (w *watchdogWriter) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fired {
+		return w.discard
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *watchdogWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.fired {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *watchdogWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.fired {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *watchdogWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fired {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *watchdogWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fired {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *watchdogWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.fired {
+		w.ResponseWriter.Flush()
+	}
+}
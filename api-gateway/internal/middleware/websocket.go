@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// WebSocketOrigin rejects WebSocket upgrades whose Origin is not in allowed,
+// before any upgrade happens. Browsers attach cookies to cross-site socket
+// handshakes and never enforce CORS on them, so without this check any page
+// could open a socket with the user's session. Entries are origins such as
+// "https://app.dharmaguard.com"; "https://*.dharmaguard.com" matches any
+// subdomain. With no entries only same-origin upgrades are accepted.
+// Requests without an Origin header come from non-browser clients and pass.
+func WebSocketOrigin(allowed []string, logger *zap.Logger) gin.HandlerFunc {
+	patterns := make([]string, 0, len(allowed))
+	for _, origin := range allowed {
+		patterns = append(patterns, strings.ToLower(strings.TrimSuffix(origin, "/")))
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || originAllowed(origin, c.Request.Host, patterns) {
+			c.Next()
+			return
+		}
+
+		logger.Warn("Rejected WebSocket upgrade from disallowed origin",
+			zap.String("origin", origin),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		apierror.Abort(c, http.StatusForbidden, "ORIGIN_NOT_ALLOWED", "WebSocket origin not allowed")
+	}
+}
+
+func originAllowed(origin, host string, patterns []string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if len(patterns) == 0 {
+		return strings.EqualFold(u.Host, host)
+	}
+
+	normalized := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, p := range patterns {
+		if p == normalized {
+			return true
+		}
+		// "scheme://*.example.com" matches subdomains, not the apex.
+		if scheme, rest, ok := strings.Cut(p, "://*."); ok {
+			if u.Scheme == scheme && strings.HasSuffix(strings.ToLower(u.Host), "."+rest) {
+				return true
+			}
+		}
+	}
+	return false
+}
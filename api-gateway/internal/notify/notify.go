@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/httpclient"
+
+	"go.uber.org/zap"
+)
+
+// Event is an operational alert the notification service routes to the
+// on-call channels for its type.
+type Event struct {
+	Type       string                 `json:"type"`
+	Severity   string                 `json:"severity"`
+	TenantID   string                 `json:"tenant_id,omitempty"`
+	Summary    string                 `json:"summary"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// Client delivers gateway-originated operational events to the
+// notification service.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewClient posts events to the notification service; tlsConfig, if set,
+// secures the connection.
+func NewClient(notificationServiceURL string, tlsConfig *tls.Config, logger *zap.Logger) *Client {
+	return &Client{
+		endpoint:   strings.TrimRight(notificationServiceURL, "/") + "/notifications/events",
+		httpClient: httpclient.NewTLS(5*time.Second, tlsConfig),
+		logger:     logger,
+	}
+}
+
+// Emit sends the event in the background. Delivery failures are logged
+// with the full event.
+func (c *Client) Emit(event Event) {
+	go func() {
+		if err := c.send(context.Background(), event); err != nil {
+			c.logger.Error("Failed to deliver notification event",
+				zap.String("type", event.Type),
+				zap.Any("event", event),
+				zap.Error(err))
+		}
+	}()
+}
+
+func (c *Client) send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification service returned %d", resp.StatusCode)
+	}
+	return nil
+}
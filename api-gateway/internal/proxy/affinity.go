@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/reqctx"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Where a client sends the session ID streaming calls are hashed on when
+// the sticky key is "session". Browsers can't set headers on WebSocket
+// handshakes, hence the query parameter.
+const (
+	SessionIDHeader = "X-Session-ID"
+	SessionIDParam  = "session_id"
+)
+
+// Outcomes reported in the stream_instance_selections_total metric.
+const (
+	InstancePreferred = "preferred"
+	InstanceFailover  = "failover"
+)
+
+// ringReplicas is how many points each instance gets on its service's
+// hash ring, so callers spread evenly and an instance joining or leaving
+// moves only its share of them.
+const ringReplicas = 100
+
+type sessionKey struct{}
+
+// WithStickyInstances sends each service's streaming calls to one of its
+// instances (connected as config.InstanceConnName) by consistent hash of
+// the caller, keyed on the user or, with keyBy "session", on the session
+// ID Affinity picked up.
+func WithStickyInstances(instances map[string][]string, keyBy string) Option {
+	return func(s *Service) {
+		s.stickyKey = keyBy
+		s.rings = make(map[string]*hashRing, len(instances))
+		for service, addresses := range instances {
+			s.rings[service] = newHashRing(service, addresses)
+		}
+	}
+}
+
+// Affinity records the client's session ID for sticky routing when
+// streaming calls are hashed on sessions.
+func (s *Service) Affinity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.stickyKey != "session" || len(s.rings) == 0 {
+			c.Next()
+			return
+		}
+		id := c.GetHeader(SessionIDHeader)
+		if id == "" {
+			id = c.Query(SessionIDParam)
+		}
+		if id != "" {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), sessionKey{}, id))
+		}
+		c.Next()
+	}
+}
+
+// streamTarget returns the connection a streaming call to service should
+// use: the instance the request is pinned to, else the caller's instance
+// on the service's hash ring, or the next healthy one clockwise while it
+// is down. Services without instances, and anonymous callers, use the
+// service connection.
+func (s *Service) streamTarget(ctx context.Context, service string) string {
+	if pinned, ok := pinnedTarget(ctx, service); ok {
+		return pinned
+	}
+	ring := s.rings[service]
+	if ring == nil {
+		return service
+	}
+	key, _ := ctx.Value(sessionKey{}).(string)
+	if key == "" {
+		if rc, ok := reqctx.FromContext(ctx); ok && rc.UserID != "" {
+			key = rc.TenantID + "/" + rc.UserID
+		}
+	}
+	if key == "" {
+		return service
+	}
+
+	candidates := ring.lookup(key)
+	chosen, outcome := candidates[0], InstancePreferred
+	for i, c := range candidates {
+		if s.instanceHealthy(c.conn) {
+			chosen = c
+			if i > 0 {
+				outcome = InstanceFailover
+			}
+			break
+		}
+	}
+	metrics.StreamInstanceSelections.WithLabelValues(service, chosen.address, outcome).Inc()
+	return chosen.conn
+}
+
+func (s *Service) instanceHealthy(name string) bool {
+	conn, ok := s.conns[name]
+	if !ok {
+		return false
+	}
+	state := conn.GetState()
+	return state != connectivity.TransientFailure && state != connectivity.Shutdown
+}
+
+type ringInstance struct {
+	address string
+	conn    string
+}
+
+// hashRing places a service's instances on a ring of 32-bit hashes.
+type hashRing struct {
+	points    []uint32
+	owners    []int
+	instances []ringInstance
+}
+
+func newHashRing(service string, addresses []string) *hashRing {
+	r := &hashRing{}
+	type point struct {
+		hash  uint32
+		owner int
+	}
+	var points []point
+	for i, addr := range addresses {
+		r.instances = append(r.instances, ringInstance{address: addr, conn: config.InstanceConnName(service, addr)})
+		for v := 0; v < ringReplicas; v++ {
+			points = append(points, point{hash: ringHash(addr + "#" + strconv.Itoa(v)), owner: i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	for _, p := range points {
+		r.points = append(r.points, p.hash)
+		r.owners = append(r.owners, p.owner)
+	}
+	return r
+}
+
+// lookup returns every instance in the order key meets them walking the
+// ring clockwise from its hash; the first is key's own instance.
+func (r *hashRing) lookup(key string) []ringInstance {
+	h := ringHash(key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	seen := make([]bool, len(r.instances))
+	order := make([]ringInstance, 0, len(r.instances))
+	for i := 0; i < len(r.points) && len(order) < len(r.instances); i++ {
+		owner := r.owners[(start+i)%len(r.points)]
+		if !seen[owner] {
+			seen[owner] = true
+			order = append(order, r.instances[owner])
+		}
+	}
+	return order
+}
+
+func ringHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
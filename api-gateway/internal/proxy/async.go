@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/jobs"
+	"dharmaguard/api-gateway/internal/reqctx"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithJobs runs the operations behind AsyncHandler routes.
+func WithJobs(runner *jobs.Runner) Option {
+	return func(s *Service) { s.jobs = runner }
+}
+
+// AsyncHandler calls a unary method as a job of the given kind, for
+// operations that take longer than a request should stay open. The client
+// gets a 202 with the job and a Location to poll at once, independently of
+// the request timeout; the call runs in the background under the job
+// timeout, and the job records the response, rendered as JSON under the
+// route's transcoding options, or the failure. A client that sends
+// jobs.WebhookHeader is also notified at that URL when the job finishes.
+func (s *Service) AsyncHandler(service, method, kind string, newRequest StreamRequestFunc, newResponse func() proto.Message) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.jobs == nil {
+			apierror.Abort(c, http.StatusNotImplemented, "JOBS_UNAVAILABLE", "Async jobs are not enabled")
+			return
+		}
+		webhook := c.GetHeader(jobs.WebhookHeader)
+		if webhook != "" {
+			if err := s.jobs.ValidateWebhook(c.Request.Context(), webhook); err != nil {
+				apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", jobs.WebhookHeader+" "+err.Error())
+				return
+			}
+		}
+		req, err := newRequest(c)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		job := jobs.Job{Kind: kind, WebhookURL: webhook}
+		if rc, ok := reqctx.FromGin(c); ok {
+			job.UserID, job.TenantID = rc.UserID, rc.TenantID
+		}
+		marshal := s.jsonOptions(s.routeFor(c))
+		created, err := s.jobs.Submit(c.Request.Context(), job, func(ctx context.Context) (json.RawMessage, error) {
+			resp := newResponse()
+			if err := s.Invoke(ctx, service, method, req, resp); err != nil {
+				return nil, err
+			}
+			return marshal.Marshal(resp)
+		})
+		if err != nil {
+			s.logger.Error("Failed to submit job", zap.String("kind", kind), zap.Error(err))
+			apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to submit job")
+			return
+		}
+
+		c.Header("Location", jobs.StatusPath+created.ID)
+		c.JSON(http.StatusAccepted, created)
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+type costKey struct{}
+
+// WithInstanceBalancing spreads each service's unary calls over its
+// instances (connected as config.InstanceConnName), skipping unhealthy
+// ones. Calls go round-robin unless a route in routes declares a cost; then
+// each call goes to the instance with the least cost outstanding, a call
+// counting its route's cost (1 if unset) until it returns.
+func WithInstanceBalancing(instances map[string][]string, routes config.RouteTable) Option {
+	return func(s *Service) {
+		leastCost := routes.HasCosts()
+		s.balancers = make(map[string]*balancer, len(instances))
+		for service, addresses := range instances {
+			b := &balancer{service: service, leastCost: leastCost, outstanding: make([]float64, len(addresses))}
+			for _, addr := range addresses {
+				b.instances = append(b.instances, ringInstance{address: addr, conn: config.InstanceConnName(service, addr)})
+			}
+			s.balancers[service] = b
+		}
+	}
+}
+
+// Costs records the route's cost for the load balancer on routes that
+// declare one.
+func (s *Service) Costs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cost := s.routeFor(c).Cost; cost > 0 && len(s.balancers) > 0 {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), costKey{}, cost))
+		}
+		c.Next()
+	}
+}
+
+// balancer tracks the cost outstanding on each of a service's instances.
+type balancer struct {
+	service   string
+	leastCost bool
+
+	mu          sync.Mutex
+	instances   []ringInstance
+	outstanding []float64
+	// next is where the round-robin, and the tie-break between equally
+	// loaded instances, resumes.
+	next int
+}
+
+// unaryTarget returns the connection a unary call to service should use
+// and a func to call when the call returns. Services without instances,
+// or with none healthy, use the service connection.
+func (s *Service) unaryTarget(ctx context.Context, service string) (string, func()) {
+	b := s.balancers[service]
+	if b == nil {
+		return service, func() {}
+	}
+	cost, ok := ctx.Value(costKey{}).(float64)
+	if !ok {
+		cost = 1
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	chosen := -1
+	for i := range b.instances {
+		idx := (b.next + i) % len(b.instances)
+		if !s.instanceHealthy(b.instances[idx].conn) {
+			continue
+		}
+		if chosen < 0 || b.outstanding[idx] < b.outstanding[chosen] {
+			chosen = idx
+		}
+		if !b.leastCost {
+			break
+		}
+	}
+	if chosen < 0 {
+		return service, func() {}
+	}
+	b.next = (chosen + 1) % len(b.instances)
+	b.add(chosen, cost)
+	return b.instances[chosen].conn, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.add(chosen, -cost)
+	}
+}
+
+// add changes instance i's outstanding cost by delta; b.mu must be held.
+func (b *balancer) add(i int, delta float64) {
+	b.outstanding[i] += delta
+	metrics.BackendOutstandingCost.WithLabelValues(b.service, b.instances[i].address).Set(b.outstanding[i])
+}
@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned by Invoke, without calling the backend, while
+// the backend's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// circuitOpenError is the ErrCircuitOpen Invoke returns, with how long until
+// the breaker lets a trial call through; zero if it can't tell.
+type circuitOpenError struct {
+	target  string
+	retryIn time.Duration
+}
+
+func (e circuitOpenError) Error() string {
+	return e.target + ": " + ErrCircuitOpen.Error()
+}
+
+func (e circuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type breakerState int
+
+// Values of the circuit_breaker_state metric.
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+// breaker stops calls to a backend after threshold consecutive failures.
+// After cooldown one trial call is let through: success closes the breaker,
+// failure re-opens it for another cooldown. While cautious, after another
+// replica's breaker for the backend tripped, it opens at half the
+// threshold and lets a trial through after half the cooldown.
+type breaker struct {
+	service   string
+	threshold int
+	cooldown  time.Duration
+	// onTrip, if set, is called (on its own goroutine) whenever the
+	// breaker opens.
+	onTrip func(service string)
+
+	mu            sync.Mutex
+	state         breakerState
+	changedAt     time.Time
+	failures      int
+	openedAt      time.Time
+	trial         bool
+	cautiousUntil time.Time
+}
+
+func newBreaker(service string, threshold int, cooldown time.Duration, onTrip func(string)) *breaker {
+	b := &breaker{service: service, threshold: threshold, cooldown: cooldown, onTrip: onTrip}
+	b.setState(breakerClosed)
+	return b
+}
+
+// allow reports whether a call may proceed.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.effectiveCooldown() {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.trial = true
+		return true
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// retryIn returns how long the open breaker has left before its trial
+// call, or zero while half open, when the trial is already under way.
+func (b *breaker) retryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerOpen {
+		return 0
+	}
+	return max(b.effectiveCooldown()-time.Since(b.openedAt), 0)
+}
+
+// record reports the outcome of a call that allow let through.
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.trial = false
+		if failed {
+			b.open()
+		} else {
+			b.failures = 0
+			b.setState(breakerClosed)
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerClosed && b.failures >= b.effectiveThreshold() {
+		b.open()
+	}
+}
+
+func (b *breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(breakerOpen)
+	if b.onTrip != nil {
+		go b.onTrip(b.service)
+	}
+}
+
+// caution makes the breaker quicker to open and to retry until until.
+func (b *breaker) caution(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until.After(b.cautiousUntil) {
+		b.cautiousUntil = until
+	}
+}
+
+func (b *breaker) cautious() bool {
+	return time.Now().Before(b.cautiousUntil)
+}
+
+func (b *breaker) effectiveThreshold() int {
+	if b.cautious() && b.threshold > 1 {
+		return b.threshold / 2
+	}
+	return b.threshold
+}
+
+func (b *breaker) effectiveCooldown() time.Duration {
+	if b.cautious() {
+		return b.cooldown / 2
+	}
+	return b.cooldown
+}
+
+// status reports the breaker's state for the admin API.
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{State: b.state.String(), Since: b.changedAt.UTC()}
+	if b.cautious() {
+		until := b.cautiousUntil.UTC()
+		st.CautiousUntil = &until
+	}
+	return st
+}
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half_open"
+	case breakerOpen:
+		return "open"
+	}
+	return "closed"
+}
+
+func (b *breaker) setState(state breakerState) {
+	if state != b.state || b.changedAt.IsZero() {
+		b.changedAt = time.Now()
+	}
+	b.state = state
+	metrics.CircuitBreakerState.WithLabelValues(b.service).Set(float64(state))
+}
+
+// breakerFor returns the service's breaker, or nil if breaking is disabled.
+func (s *Service) breakerFor(service string) *breaker {
+	if s.breakerThreshold <= 0 {
+		return nil
+	}
+	if b, ok := s.breakers.Load(service); ok {
+		return b.(*breaker)
+	}
+	var onTrip func(string)
+	if s.fleet != nil {
+		onTrip = s.fleet.publishTrip
+	}
+	b, _ := s.breakers.LoadOrStore(service, newBreaker(service, s.breakerThreshold, s.breakerCooldown, onTrip))
+	return b.(*breaker)
+}
+
+// BreakerStatus is the state of one backend's circuit breaker on one
+// replica.
+type BreakerStatus struct {
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+	// CautiousUntil is set while a trip on another replica has made the
+	// breaker quicker to open.
+	CautiousUntil *time.Time `json:"cautious_until,omitempty"`
+}
+
+// BreakerStates returns this replica's breakers by backend. Backends that
+// haven't been called yet have none.
+func (s *Service) BreakerStates() map[string]BreakerStatus {
+	states := make(map[string]BreakerStatus)
+	s.breakers.Range(func(key, value interface{}) bool {
+		states[key.(string)] = value.(*breaker).status()
+		return true
+	})
+	return states
+}
+
+// isBreakerFailure reports whether err says the backend is unhealthy, as
+// opposed to rejecting this particular request. A deadline only counts when
+// the backend had the call's time to answer in: one spent before the call
+// was made, one the caller shortened, or the request's own deadline running
+// out (ctx is done) says nothing about the backend.
+func isBreakerFailure(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, errBudgetExhausted) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded:
+		return !hasCallerDeadline(ctx) && ctx.Err() == nil
+	case codes.Unavailable, codes.Internal, codes.Unknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	breakerTripsChannel = "breaker:trips"
+	breakerReplicasKey  = "breaker:replicas"
+	breakerStatePrefix  = "breaker:state:"
+)
+
+type breakerTrip struct {
+	Replica string `json:"replica"`
+	Service string `json:"service"`
+}
+
+// BreakerFleet shares circuit breaker trips between replicas through Redis
+// pub/sub. A trip elsewhere only makes this replica's breaker for the
+// backend cautious for a while (see breaker); it still opens on its own
+// failures alone, so one replica with a bad network path can't open the
+// circuit for the whole fleet. Each replica also publishes its breaker
+// states, for a fleet-wide view in the admin API.
+type BreakerFleet struct {
+	client    *redis.Client
+	id        string
+	caution   time.Duration
+	heartbeat time.Duration
+	logger    *zap.Logger
+
+	service atomic.Pointer[Service]
+}
+
+// NewBreakerFleet makes breakers cautious for caution after a trip on
+// another replica, and publishes this replica's states every heartbeat.
+func NewBreakerFleet(client *redis.Client, caution, heartbeat time.Duration, logger *zap.Logger) *BreakerFleet {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gateway"
+	}
+	return &BreakerFleet{
+		client:    client,
+		id:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		caution:   caution,
+		heartbeat: heartbeat,
+		logger:    logger,
+	}
+}
+
+// WithBreakerFleet shares the service's breaker trips through fleet.
+func WithBreakerFleet(fleet *BreakerFleet) Option {
+	return func(s *Service) {
+		s.fleet = fleet
+		if fleet != nil {
+			fleet.service.Store(s)
+		}
+	}
+}
+
+// Replica is the ID this replica publishes under.
+func (f *BreakerFleet) Replica() string {
+	return f.id
+}
+
+// Run follows other replicas' trips and publishes this replica's breaker
+// states until ctx is done.
+func (f *BreakerFleet) Run(ctx context.Context) {
+	sub := f.client.Subscribe(ctx, breakerTripsChannel)
+	defer sub.Close()
+	trips := sub.Channel()
+
+	ticker := time.NewTicker(f.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-trips:
+			if !ok {
+				return
+			}
+			var trip breakerTrip
+			if err := json.Unmarshal([]byte(msg.Payload), &trip); err != nil || trip.Replica == f.id {
+				continue
+			}
+			f.peerTripped(trip)
+		case <-ticker.C:
+			f.publishStates(ctx)
+		}
+	}
+}
+
+func (f *BreakerFleet) peerTripped(trip breakerTrip) {
+	s := f.service.Load()
+	if s == nil {
+		return
+	}
+	b := s.breakerFor(trip.Service)
+	if b == nil {
+		return
+	}
+	b.caution(time.Now().Add(f.caution))
+	metrics.BreakerPeerTrips.WithLabelValues(trip.Service).Inc()
+	f.logger.Info("Circuit breaker tripped on another replica; lowering threshold",
+		zap.String("service", trip.Service),
+		zap.String("replica", trip.Replica),
+		zap.Duration("for", f.caution))
+}
+
+func (f *BreakerFleet) publishTrip(service string) {
+	payload, _ := json.Marshal(breakerTrip{Replica: f.id, Service: service})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := f.client.Publish(ctx, breakerTripsChannel, payload).Err(); err != nil {
+		f.logger.Warn("Failed to share circuit breaker trip", zap.String("service", service), zap.Error(err))
+	}
+}
+
+func (f *BreakerFleet) publishStates(ctx context.Context) {
+	s := f.service.Load()
+	if s == nil {
+		return
+	}
+	payload, err := json.Marshal(s.BreakerStates())
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	pipe := f.client.TxPipeline()
+	pipe.Set(ctx, breakerStatePrefix+f.id, payload, 3*f.heartbeat)
+	pipe.ZAdd(ctx, breakerReplicasKey, &redis.Z{Score: float64(now.UnixMilli()), Member: f.id})
+	pipe.ZRemRangeByScore(ctx, breakerReplicasKey, "-inf", fmt.Sprintf("(%d", now.Add(-3*f.heartbeat).UnixMilli()))
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() == nil {
+		f.logger.Warn("Failed to publish circuit breaker states", zap.Error(err))
+	}
+}
+
+// States returns the breaker states every live replica last published, by
+// replica and backend.
+func (f *BreakerFleet) States(ctx context.Context) (map[string]map[string]BreakerStatus, error) {
+	replicas, err := f.client.ZRangeByScore(ctx, breakerReplicasKey, &redis.ZRangeBy{
+		Min: fmt.Sprint(time.Now().Add(-3 * f.heartbeat).UnixMilli()),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]map[string]BreakerStatus, len(replicas))
+	if len(replicas) == 0 {
+		return states, nil
+	}
+	keys := make([]string, len(replicas))
+	for i, id := range replicas {
+		keys[i] = breakerStatePrefix + id
+	}
+	values, err := f.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		payload, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var replica map[string]BreakerStatus
+		if json.Unmarshal([]byte(payload), &replica) == nil {
+			states[replicas[i]] = replica
+		}
+	}
+	return states, nil
+}
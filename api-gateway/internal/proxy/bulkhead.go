@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"google.golang.org/grpc/status"
+)
+
+// ErrBulkheadFull is returned by Invoke, without calling the backend, when
+// the backend already has its maximum calls in flight and no slot freed up
+// within the queue wait.
+var ErrBulkheadFull = errors.New("backend at its in-flight call limit")
+
+// bulkhead caps the calls in flight to one backend connection, so a slow
+// backend holds at most its own share of the gateway's workers.
+type bulkhead struct {
+	target   string
+	slots    chan struct{}
+	maxWait  time.Duration
+	maxDepth int
+
+	mu     sync.Mutex
+	queued int
+}
+
+// acquire takes a slot, waiting for one as configured, and returns the
+// function that gives it back.
+func (b *bulkhead) acquire(ctx context.Context) (func(), error) {
+	select {
+	case b.slots <- struct{}{}:
+		return b.taken(), nil
+	default:
+	}
+	if b.maxWait <= 0 || !b.enqueue() {
+		metrics.BulkheadRejected.WithLabelValues(b.target).Inc()
+		return nil, ErrBulkheadFull
+	}
+	defer b.dequeue()
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return b.taken(), nil
+	case <-timer.C:
+		metrics.BulkheadRejected.WithLabelValues(b.target).Inc()
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (b *bulkhead) taken() func() {
+	inFlight := metrics.BackendInFlight.WithLabelValues(b.target)
+	inFlight.Inc()
+	return func() {
+		inFlight.Dec()
+		<-b.slots
+	}
+}
+
+func (b *bulkhead) enqueue() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.queued >= b.maxDepth {
+		return false
+	}
+	b.queued++
+	metrics.BackendQueueDepth.WithLabelValues(b.target).Set(float64(b.queued))
+	return true
+}
+
+func (b *bulkhead) dequeue() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queued--
+	metrics.BackendQueueDepth.WithLabelValues(b.target).Set(float64(b.queued))
+}
+
+// bulkheadFor returns target's bulkhead, sized by service's gRPC client
+// settings, or nil if its in-flight calls aren't capped.
+func (s *Service) bulkheadFor(service, target string) *bulkhead {
+	client := s.grpcClients[service]
+	if client.MaxInFlight <= 0 {
+		return nil
+	}
+	if b, ok := s.bulkheads.Load(target); ok {
+		return b.(*bulkhead)
+	}
+	b, _ := s.bulkheads.LoadOrStore(target, &bulkhead{
+		target:   target,
+		slots:    make(chan struct{}, client.MaxInFlight),
+		maxWait:  client.QueueMaxWait,
+		maxDepth: client.QueueMaxDepth,
+	})
+	return b.(*bulkhead)
+}
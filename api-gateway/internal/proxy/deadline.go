@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errBudgetExhausted fails a call the request has no time left for, without
+// calling the backend.
+var errBudgetExhausted = status.Error(codes.DeadlineExceeded, "request deadline exhausted before calling backend")
+
+type callerDeadlineKey struct{}
+
+// WithCallerDeadline marks ctx's deadline as one the caller chose, such as
+// with X-Request-Timeout, rather than the route's. A backend that misses it
+// may only have been given too little time, so it isn't held against the
+// backend's circuit breaker.
+func WithCallerDeadline(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callerDeadlineKey{}, true)
+}
+
+func hasCallerDeadline(ctx context.Context) bool {
+	set, _ := ctx.Value(callerDeadlineKey{}).(bool)
+	return set
+}
+
+// withReturnBuffer shortens ctx's deadline by the configured buffer so the
+// backend gives up early enough for its response to still reach the client.
+// It fails fast when the remaining budget is already spent.
+func (s *Service) withReturnBuffer(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx, func() {}, nil
+	}
+
+	budget := time.Until(deadline) - s.deadlineBuffer
+	if budget <= 0 {
+		return ctx, func() {}, errBudgetExhausted
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	return ctx, cancel, nil
+}
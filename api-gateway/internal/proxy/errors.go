@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorMapping is how the gateway answers one gRPC status code.
+type errorMapping struct {
+	status    int
+	code      string
+	message   string
+	retryable bool
+}
+
+// defaultErrorMappings answers each gRPC status code a backend may return.
+// Client errors pass the backend's message through; server errors use the
+// generic message, as the backend's may leak internals.
+var defaultErrorMappings = map[codes.Code]errorMapping{
+	codes.Canceled:           {499, "REQUEST_CANCELLED", "The request was cancelled", false},
+	codes.Unknown:            {http.StatusBadGateway, "UPSTREAM_ERROR", "The backend service failed to handle the request", false},
+	codes.InvalidArgument:    {http.StatusBadRequest, "INVALID_ARGUMENT", "The request is invalid", false},
+	codes.DeadlineExceeded:   {http.StatusGatewayTimeout, "GATEWAY_TIMEOUT", "The request did not complete within its deadline", true},
+	codes.NotFound:           {http.StatusNotFound, "NOT_FOUND", "The requested resource was not found", false},
+	codes.AlreadyExists:      {http.StatusConflict, "ALREADY_EXISTS", "The resource already exists", false},
+	codes.PermissionDenied:   {http.StatusForbidden, "PERMISSION_DENIED", "The caller may not perform this operation", false},
+	codes.ResourceExhausted:  {http.StatusTooManyRequests, "RESOURCE_EXHAUSTED", "The backend service is out of capacity for this caller; retry later", true},
+	codes.FailedPrecondition: {http.StatusBadRequest, "FAILED_PRECONDITION", "The resource is not in a state that allows this operation", false},
+	codes.Aborted:            {http.StatusConflict, "ABORTED", "The operation conflicted with a concurrent change; retry it", true},
+	codes.OutOfRange:         {http.StatusBadRequest, "OUT_OF_RANGE", "The request is outside the valid range", false},
+	codes.Unimplemented:      {http.StatusNotImplemented, "NOT_IMPLEMENTED", "The backend service does not implement this operation", false},
+	codes.Internal:           {http.StatusBadGateway, "UPSTREAM_ERROR", "The backend service failed to handle the request", false},
+	codes.Unavailable:        {http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", "The backend service is unavailable; retry shortly", true},
+	codes.DataLoss:           {http.StatusBadGateway, "UPSTREAM_ERROR", "The backend service failed to handle the request", false},
+	codes.Unauthenticated:    {http.StatusUnauthorized, "UNAUTHENTICATED", "The backend service could not authenticate the request", false},
+}
+
+// WithErrorMap overrides the HTTP status, error code and retryability the
+// gateway answers gRPC status codes with. Codes left out keep their
+// defaults. Whether the backend's message is passed through stays with the
+// code, whatever status it is given (see forwardsMessage).
+func WithErrorMap(overrides map[codes.Code]config.UpstreamErrorMapping) Option {
+	return func(s *Service) {
+		for code, o := range overrides {
+			m := s.errorMap[code]
+			m.status, m.code, m.retryable = o.Status, o.Code, o.Retryable
+			if m.message == "" {
+				m.message = defaultErrorMappings[codes.Unknown].message
+			}
+			s.errorMap[code] = m
+		}
+	}
+}
+
+// RenderError translates a failed backend call into an APIError response,
+// its status and code picked from the error map by the call's gRPC status.
+func (s *Service) RenderError(c *gin.Context, err error) {
+	if errors.Is(err, ErrCircuitOpen) {
+		var open circuitOpenError
+		errors.As(err, &open)
+		s.renderFallback(c, open.retryIn)
+		return
+	}
+
+	if errors.Is(err, ErrBulkheadFull) {
+		apierror.AbortOverloaded(c, apierror.OverloadConcurrency, 0, "UPSTREAM_BUSY",
+			"The backend service is handling too many requests; retry shortly")
+		return
+	}
+
+	st, _ := status.FromError(err)
+
+	if isMessageSizeError(st) {
+		s.logger.Warn("Backend message exceeded gRPC size limit",
+			zap.String("route", c.FullPath()),
+			zap.String("grpc_message", st.Message()))
+		apierror.AbortRetryable(c, http.StatusBadGateway, "UPSTREAM_MESSAGE_TOO_LARGE",
+			"The response from the backend service exceeded the gateway's message size limit; "+
+				"narrow the request (e.g. a smaller date range or page size) or use the streaming endpoint", false)
+		return
+	}
+
+	code, m := s.mappingFor(c, st)
+	if forwardsMessage(code) {
+		s.logger.Debug("Backend rejected request", zap.String("route", c.FullPath()), zap.Error(err))
+	} else {
+		s.logger.Error("Backend call failed", zap.String("route", c.FullPath()), zap.Error(err))
+	}
+	apierror.AbortRetryable(c, m.status, m.code, clientMessage(code, m, st), m.retryable)
+}
+
+// mappingFor returns the gRPC code a failed backend call is answered as,
+// DEADLINE_EXCEEDED once the request's own deadline has passed, and the
+// error map's entry for it.
+func (s *Service) mappingFor(c *gin.Context, st *status.Status) (codes.Code, errorMapping) {
+	code := st.Code()
+	if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		code = codes.DeadlineExceeded
+	}
+	m, ok := s.errorMap[code]
+	if !ok {
+		m = s.errorMap[codes.Unknown]
+	}
+	return code, m
+}
+
+// clientMessage returns the message a failed call answered as code is
+// reported with: the backend's own where forwardsMessage allows it, else
+// the mapping's.
+func clientMessage(code codes.Code, m errorMapping, st *status.Status) string {
+	if forwardsMessage(code) && st.Message() != "" {
+		return st.Message()
+	}
+	return m.message
+}
+
+// forwardsMessage reports whether the backend's message for code reaches the
+// client: only for codes whose default mapping is a client error. It goes by
+// the default so an error map entry answering INTERNAL with a 400 doesn't
+// start passing server-side messages on.
+func forwardsMessage(code codes.Code) bool {
+	m, ok := defaultErrorMappings[code]
+	return ok && m.status < http.StatusInternalServerError
+}
+
+// isMessageSizeError reports whether a call failed because a message exceeded
+// MaxCallRecvMsgSize or MaxCallSendMsgSize. grpc-go reports both as
+// ResourceExhausted with a "larger than max" message.
+func isMessageSizeError(st *status.Status) bool {
+	return st.Code() == codes.ResourceExhausted && strings.Contains(st.Message(), "larger than max")
+}
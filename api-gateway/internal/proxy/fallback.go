@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKeyFallback is set on requests answered by a circuit-open
+// fallback. The response cache replaces such responses with its last known
+// good copy when the route's fallback allows it.
+const ContextKeyFallback = "proxy_fallback"
+
+// FallbackHeader marks responses that came from a fallback rather than the
+// backend.
+const FallbackHeader = "X-Fallback"
+
+// renderFallback answers a request whose backend circuit is open with the
+// route's declared fallback, or 503 if it has none, telling the client to
+// retry once retryIn has passed and the breaker lets a trial call through.
+func (s *Service) renderFallback(c *gin.Context, retryIn time.Duration) {
+	fallback := s.routeFor(c).Fallback
+	if fallback != nil && fallback.Cache {
+		c.Set(ContextKeyFallback, true)
+	}
+
+	if fallback == nil || fallback.Body == nil {
+		apierror.AbortOverloaded(c, apierror.OverloadCircuitOpen, retryIn, "CIRCUIT_OPEN",
+			"The backend service is temporarily unavailable")
+		return
+	}
+
+	status := fallback.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c.Header(FallbackHeader, "static")
+	c.AbortWithStatusJSON(status, fallback.Body)
+}
+
+// FallbackRequested reports whether the request's response is a fallback
+// that the response cache may replace with a cached copy.
+func FallbackRequested(c *gin.Context) bool {
+	return c.GetBool(ContextKeyFallback)
+}
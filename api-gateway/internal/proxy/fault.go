@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/reqctx"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+)
+
+type faultKey struct{}
+
+type faultSelection struct {
+	route  string
+	faults []config.FaultRule
+	// internal is set for requests from a verified internal caller.
+	internal bool
+}
+
+// WithFaultInjection lets Faults inject the faults routes declare, as cfg
+// allows, into calls made for internal callers verifier verifies or for
+// users with one of roles. Nobody else gets faults.
+func WithFaultInjection(cfg config.FaultInjectionConfig, verifier *auth.InternalCallerVerifier, roles []string) Option {
+	return func(s *Service) {
+		s.faultInjection, s.faultVerifier, s.faultRoles = cfg, verifier, roles
+	}
+}
+
+// Faults marks requests on routes with fault rules so their backend calls
+// get the faults. It does nothing unless fault injection is enabled, and
+// when a fault injection header is configured, only marks requests that
+// carry it. Marked requests still only get faults when they come from a
+// verified internal caller, checked here from InternalTokenHeader, or once
+// authenticated from a user with one of the fault roles. It must run
+// before Pinning, which removes the token.
+func (s *Service) Faults() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.faultInjection.Enabled {
+			c.Next()
+			return
+		}
+		if h := s.faultInjection.Header; h != "" && c.GetHeader(h) == "" {
+			c.Next()
+			return
+		}
+		if faults := s.routeFor(c).Faults; len(faults) > 0 {
+			sel := faultSelection{route: c.FullPath(), faults: faults}
+			if token := c.GetHeader(InternalTokenHeader); token != "" && s.faultVerifier != nil {
+				caller, err := s.faultVerifier.Verify(token)
+				sel.internal = err == nil && caller != ""
+			}
+			ctx := context.WithValue(c.Request.Context(), faultKey{}, sel)
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
+
+// faultsAllowed reports whether the call is made for a caller faults may
+// be injected for.
+func (s *Service) faultsAllowed(ctx context.Context, sel faultSelection) bool {
+	if sel.internal {
+		return true
+	}
+	rc, ok := reqctx.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, role := range rc.Roles {
+		for _, allowed := range s.faultRoles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// injectFault applies the request's fault rules to one call of method on
+// target. A non-nil error fails the call without reaching the backend, and
+// counts against the backend's circuit breaker like a real failure.
+func (s *Service) injectFault(ctx context.Context, target, method string) error {
+	sel, ok := ctx.Value(faultKey{}).(faultSelection)
+	if !ok || !s.faultsAllowed(ctx, sel) {
+		return nil
+	}
+	for _, f := range sel.faults {
+		if rand.Float64() >= f.Probability {
+			continue
+		}
+		metrics.FaultsInjected.WithLabelValues(sel.route, f.Kind).Inc()
+		fields := []zap.Field{
+			zap.String("route", sel.route),
+			zap.String("service", target),
+			zap.String("method", method),
+			zap.String("kind", f.Kind),
+		}
+		if rc, ok := reqctx.FromContext(ctx); ok {
+			fields = append(fields, zap.String("request_id", rc.RequestID))
+		}
+
+		switch f.Kind {
+		case config.FaultLatency:
+			s.logger.Warn("Injecting fault", append(fields, zap.Duration("latency", f.Latency))...)
+			timer := time.NewTimer(f.Latency)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		case config.FaultError:
+			code, _ := f.GRPCCode()
+			s.logger.Warn("Injecting fault", append(fields, zap.String("code", code.String()))...)
+			return status.Error(code, "injected fault")
+		case config.FaultDrop:
+			s.logger.Warn("Injecting fault", fields...)
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return nil
+}
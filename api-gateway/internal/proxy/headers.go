@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+// Default header forwarding policy. Only allowlisted headers reach backends,
+// and the denylist wins over the allowlist. Credentials and identity never
+// cross the gateway from the client: backends get identity, tenant included,
+// from the RequestContext metadata instead.
+var (
+	DefaultForwardAllow = []string{
+		"X-Request-ID",
+		"X-Correlation-ID",
+		"Accept-Language",
+		"User-Agent",
+	}
+	DefaultForwardDeny = []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Set-Cookie",
+		"X-API-Key",
+		"X-CSRF-Token",
+		"X-Cache-Bypass-Secret",
+		"X-Internal-Token",
+	}
+)
+
+// hopByHopHeaders (RFC 7230 section 6.1) describe the client connection and
+// are always stripped, whatever the configured lists say.
+var hopByHopHeaders = map[string]bool{
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-connection":    true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+}
+
+// HeaderPolicy decides which incoming headers become gRPC metadata. Entries
+// ending in "*" match by prefix, e.g. "X-Dharma-*".
+type HeaderPolicy struct {
+	allow []string
+	deny  []string
+}
+
+func NewHeaderPolicy(allow, deny []string) *HeaderPolicy {
+	return &HeaderPolicy{allow: lowerAll(allow), deny: lowerAll(deny)}
+}
+
+// Filter returns the forwardable headers as metadata with lowercase keys.
+func (p *HeaderPolicy) Filter(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range h {
+		key := strings.ToLower(name)
+		if hopByHopHeaders[key] || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		if matchAny(p.deny, key) || !matchAny(p.allow, key) {
+			continue
+		}
+		md[key] = append([]string(nil), values...)
+	}
+	return md
+}
+
+type forwardedKey struct{}
+
+// ForwardHeaders captures the request's forwardable headers for every
+// backend call made with the request context.
+func (s *Service) ForwardHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		md := s.headerPolicy.Filter(c.Request.Header)
+		if len(md) > 0 {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), forwardedKey{}, md))
+		}
+		c.Next()
+	}
+}
+
+func forwardedHeaders(ctx context.Context) metadata.MD {
+	md, _ := ctx.Value(forwardedKey{}).(metadata.MD)
+	return md
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == key {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// VersionMetadataKey is the response header a backend may send with its
+// health check to report the version it runs.
+const VersionMetadataKey = "x-service-version"
+
+// Backend health statuses: the gRPC health protocol's, plus UNREACHABLE
+// for a backend that didn't answer. A backend without the health service
+// is reported from its connection state, e.g. READY or TRANSIENT_FAILURE.
+const (
+	HealthServing     = "SERVING"
+	HealthUnreachable = "UNREACHABLE"
+)
+
+// BackendHealth is one backend's health check result.
+type BackendHealth struct {
+	Service   string  `json:"service"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Version   string  `json:"version,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Healthy reports whether the backend is serving.
+func (h BackendHealth) Healthy() bool {
+	return h.Status == HealthServing || h.Status == "READY"
+}
+
+// CheckHealth asks each of services for its health concurrently, over the
+// gRPC health protocol, giving each at most timeout. Results are sorted
+// by service.
+func (s *Service) CheckHealth(ctx context.Context, services []string, timeout time.Duration) []BackendHealth {
+	results := make([]BackendHealth, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service string) {
+			defer wg.Done()
+			results[i] = s.checkHealth(ctx, service, timeout)
+		}(i, service)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Service < results[j].Service })
+	return results
+}
+
+func (s *Service) checkHealth(ctx context.Context, service string, timeout time.Duration) BackendHealth {
+	result := BackendHealth{Service: service}
+	conn, ok := s.conns[service]
+	if !ok {
+		result.Status, result.Error = HealthUnreachable, "not connected"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx, err := s.outgoingContext(ctx, service)
+	if err != nil {
+		result.Status, result.Error = HealthUnreachable, err.Error()
+		return result
+	}
+
+	var header metadata.MD
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if v := header.Get(VersionMetadataKey); len(v) > 0 {
+		result.Version = v[0]
+	}
+
+	switch status.Code(err) {
+	case codes.OK:
+		result.Status = resp.GetStatus().String()
+	case codes.Unimplemented:
+		result.Status = conn.GetState().String()
+	default:
+		result.Status, result.Error = HealthUnreachable, status.Convert(err).Message()
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type inFlightKey struct{}
+
+// inFlight records the backend calls a request has outstanding.
+type inFlight struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// TrackCalls returns a context under which the proxy records its
+// outstanding backend calls, and a function listing them as
+// "backend /package.Service/Method". The watchdog uses it to report what a
+// stuck request is waiting on.
+func TrackCalls(ctx context.Context) (context.Context, func() []string) {
+	f := &inFlight{calls: make(map[string]int)}
+	return context.WithValue(ctx, inFlightKey{}, f), f.list
+}
+
+// beginCall records a call on ctx's tracker, if any, and returns the
+// function that marks it finished.
+func beginCall(ctx context.Context, target, method string) func() {
+	f, ok := ctx.Value(inFlightKey{}).(*inFlight)
+	if !ok {
+		return func() {}
+	}
+	name := target + " " + method
+	f.mu.Lock()
+	f.calls[name]++
+	f.mu.Unlock()
+	return func() {
+		f.mu.Lock()
+		if f.calls[name]--; f.calls[name] <= 0 {
+			delete(f.calls, name)
+		}
+		f.mu.Unlock()
+	}
+}
+
+func (f *inFlight) list() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := make([]string, 0, len(f.calls))
+	for name := range f.calls {
+		calls = append(calls, name)
+	}
+	sort.Strings(calls)
+	return calls
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultLongPollHold = 25 * time.Second
+	// After the first event arrives, events already on their way are
+	// collected for this long, up to longPollMaxEvents, before responding.
+	longPollBatchWindow = 100 * time.Millisecond
+	longPollMaxEvents   = 100
+	// longPollDeadlineMargin is kept from the request deadline so the
+	// response is written before the deadline passes.
+	longPollDeadlineMargin = time.Second
+)
+
+// CursorQueryParam is the long-poll query parameter carrying the cursor of
+// the last event the client received.
+const CursorQueryParam = "cursor"
+
+// CursorFunc returns the resume cursor of a stream message.
+type CursorFunc func(msg proto.Message) string
+
+type longPollResponse struct {
+	Events []json.RawMessage `json:"events"`
+	// Cursor is passed back on the next poll; it is the request's own
+	// cursor when no event arrived.
+	Cursor string `json:"cursor,omitempty"`
+	// Ended is set when the backend closed the stream.
+	Ended bool `json:"ended,omitempty"`
+}
+
+// WithLongPoll bounds long polling: requests are held at most maxHold, and
+// at most maxConcurrent are held at once.
+func WithLongPoll(maxHold time.Duration, maxConcurrent int) Option {
+	return func(s *Service) {
+		s.longPollHold = maxHold
+		if maxConcurrent > 0 {
+			s.longPollSlots = make(chan struct{}, maxConcurrent)
+		}
+	}
+}
+
+// LongPollHandler serves a gRPC server-streaming method as long polling,
+// for networks that block both WebSockets and SSE. It opens the same
+// backend subscription as StreamHandler, with newRequest reading the
+// client's cursor from CursorQueryParam, and holds the request until an
+// event arrives or the hold time (the "timeout" query parameter in seconds,
+// capped by WithLongPoll and the request deadline) runs out. The response
+// carries the events received and the cursor to reconnect with.
+func (s *Service) LongPollHandler(service, method string, newRequest StreamRequestFunc, newResponse func() proto.Message, cursorOf CursorFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.longPollSlots != nil {
+			select {
+			case s.longPollSlots <- struct{}{}:
+				defer func() { <-s.longPollSlots }()
+			default:
+				apierror.AbortOverloaded(c, apierror.OverloadConcurrency, 0, "LONG_POLL_CAPACITY", "Too many long-poll connections; retry shortly")
+				return
+			}
+		}
+		metrics.LongPollConnections.Inc()
+		defer metrics.LongPollConnections.Dec()
+
+		req, err := newRequest(c)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), s.longPollHoldFor(c))
+		defer cancel()
+
+		messages, recvErr, err := s.subscribe(ctx, service, method, req, newResponse)
+		if err != nil {
+			s.RenderError(c, err)
+			return
+		}
+		s.disableWriteDeadline(c)
+
+		resp := longPollResponse{Events: []json.RawMessage{}, Cursor: c.Query(CursorQueryParam)}
+		var batch <-chan time.Time
+		for len(resp.Events) < longPollMaxEvents {
+			select {
+			case <-ctx.Done():
+				s.renderLongPoll(c, resp)
+				return
+			case <-batch:
+				s.renderLongPoll(c, resp)
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					if err := <-recvErr; !errors.Is(err, io.EOF) && len(resp.Events) == 0 {
+						s.RenderError(c, err)
+						return
+					}
+					resp.Ended = true
+					s.renderLongPoll(c, resp)
+					return
+				}
+				data, err := s.jsonOptions(s.routeFor(c)).Marshal(msg)
+				if err != nil {
+					apierror.Abort(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to encode stream message")
+					return
+				}
+				resp.Events = append(resp.Events, data)
+				if cursor := cursorOf(msg); cursor != "" {
+					resp.Cursor = cursor
+				}
+				if batch == nil {
+					batch = time.After(longPollBatchWindow)
+				}
+			}
+		}
+		s.renderLongPoll(c, resp)
+	}
+}
+
+// longPollHoldFor is how long to hold c: the client's requested timeout,
+// within the configured maximum and the request's own deadline.
+func (s *Service) longPollHoldFor(c *gin.Context) time.Duration {
+	hold := s.longPollHold
+	if hold <= 0 {
+		hold = defaultLongPollHold
+	}
+	if secs, err := strconv.Atoi(c.Query("timeout")); err == nil && secs > 0 && time.Duration(secs)*time.Second < hold {
+		hold = time.Duration(secs) * time.Second
+	}
+	if deadline, ok := c.Request.Context().Deadline(); ok {
+		if left := time.Until(deadline) - longPollDeadlineMargin; left < hold {
+			hold = left
+		}
+	}
+	return hold
+}
+
+func (s *Service) renderLongPoll(c *gin.Context, resp longPollResponse) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
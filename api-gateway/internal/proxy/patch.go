@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+type patchKey struct{}
+
+// patchInfo describes the partial update a PATCH request carries.
+type patchInfo struct {
+	format string
+	mask   []string
+}
+
+// PatchContext returns a context whose backend calls carry the request's
+// patch semantics as metadata: x-patch-format names the document the
+// client sent and x-update-mask lists the dotted field paths it touches,
+// so backends apply a partial update rather than a replacement.
+func PatchContext(ctx context.Context, format string, mask []string) context.Context {
+	return context.WithValue(ctx, patchKey{}, patchInfo{format: format, mask: mask})
+}
+
+// patchMetadata returns the patch metadata recorded on ctx, if any.
+func patchMetadata(ctx context.Context) metadata.MD {
+	info, ok := ctx.Value(patchKey{}).(patchInfo)
+	if !ok {
+		return nil
+	}
+	return metadata.Pairs(
+		"x-patch-format", info.format,
+		"x-update-mask", strings.Join(info.mask, ","),
+	)
+}
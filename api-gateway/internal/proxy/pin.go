@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Headers a trusted internal caller sends to pin a request's backend calls
+// to one instance: the instance address, and the caller's own internal
+// token proving it may.
+const (
+	UpstreamTargetHeader = "X-Upstream-Target"
+	InternalTokenHeader  = "X-Internal-Token"
+)
+
+// Results reported in the upstream_target_pins_total metric.
+const (
+	PinPinned     = "pinned"
+	PinUntrusted  = "untrusted"
+	PinNotAllowed = "not_allowed"
+)
+
+type pinKey struct{}
+
+// WithUpstreamPinning lets callers verified by verifier pin a request to
+// one of the services' instances, bypassing the load balancer and sticky
+// routing for that request. allowed narrows the instances that may be
+// pinned; empty allows all of them.
+func WithUpstreamPinning(verifier *auth.InternalCallerVerifier, instances map[string][]string, allowed []string) Option {
+	return func(s *Service) {
+		permitted := make(map[string]bool, len(allowed))
+		for _, addr := range allowed {
+			permitted[addr] = true
+		}
+		s.pinVerifier = verifier
+		s.pinTargets = make(map[string]ringInstance)
+		for service, addresses := range instances {
+			for _, addr := range addresses {
+				if len(permitted) == 0 || permitted[addr] {
+					s.pinTargets[addr] = ringInstance{address: addr, conn: config.InstanceConnName(service, addr)}
+				}
+			}
+		}
+	}
+}
+
+// Pinning honours X-Upstream-Target from trusted callers: the request's
+// calls to the instance's service go to that instance. Targets that aren't
+// allowed are refused with 400. From anyone else the header is stripped
+// and ignored. Both headers are always removed from the request.
+func (s *Service) Pinning(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.GetHeader(UpstreamTargetHeader)
+		token := c.GetHeader(InternalTokenHeader)
+		c.Request.Header.Del(UpstreamTargetHeader)
+		c.Request.Header.Del(InternalTokenHeader)
+		if address == "" {
+			c.Next()
+			return
+		}
+
+		caller := ""
+		if s.pinVerifier != nil && token != "" {
+			caller, _ = s.pinVerifier.Verify(token)
+		}
+		if caller == "" {
+			metrics.UpstreamTargetPins.WithLabelValues("", "", PinUntrusted).Inc()
+			logger.Debug("Ignored upstream target from untrusted caller",
+				zap.String("path", c.Request.URL.Path), zap.String("client_ip", c.ClientIP()))
+			c.Next()
+			return
+		}
+
+		target, ok := s.pinTargets[address]
+		if !ok {
+			metrics.UpstreamTargetPins.WithLabelValues(caller, "", PinNotAllowed).Inc()
+			apierror.AbortWithDetails(c, http.StatusBadRequest, "UPSTREAM_TARGET_NOT_ALLOWED",
+				"Upstream target is not an allowed instance", map[string]interface{}{"target": address})
+			return
+		}
+
+		metrics.UpstreamTargetPins.WithLabelValues(caller, target.address, PinPinned).Inc()
+		logger.Info("Request pinned to upstream instance",
+			zap.String("caller", caller),
+			zap.String("instance", target.address),
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("request_id", c.GetString(apierror.RequestIDKey)))
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), pinKey{}, target))
+		c.Next()
+	}
+}
+
+// pinnedTarget returns the connection of the instance the request is
+// pinned to, if it is one of service's.
+func pinnedTarget(ctx context.Context, service string) (string, bool) {
+	target, ok := ctx.Value(pinKey{}).(ringInstance)
+	if !ok || target.conn != config.InstanceConnName(service, target.address) {
+		return "", false
+	}
+	return target.conn, true
+}
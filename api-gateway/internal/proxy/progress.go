@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// Progress is a client-facing update from a long-running backend operation
+// such as report generation.
+type Progress struct {
+	Percent int    `json:"percent"`
+	Stage   string `json:"stage,omitempty"`
+	// Done is set on the last update. FileID then names the result, and
+	// DownloadURL is where the client gets a link to it.
+	Done        bool   `json:"done,omitempty"`
+	FileID      string `json:"file_id,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// ProgressFunc reads a backend progress message.
+type ProgressFunc func(msg proto.Message) Progress
+
+// ProgressHandler starts an operation through the gRPC server-streaming
+// method, which reports its progress as it runs, and relays each update to
+// the client as a "progress" event, ending with a "complete" event that
+// links to the result through the file download routes (so the download is
+// authorized like any other). Updates are sent as SSE with Accept:
+// text/event-stream and as NDJSON otherwise.
+//
+// The client cancels the operation by closing the stream: that cancels the
+// backend call, and backends stop work on a cancelled stream.
+func (s *Service) ProgressHandler(service, method string, newRequest StreamRequestFunc, newResponse func() proto.Message, progressOf ProgressFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := newRequest(c)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		messages, recvErr, err := s.subscribe(ctx, service, method, req, newResponse)
+		if err != nil {
+			s.RenderError(c, err)
+			return
+		}
+
+		w := newStreamWriter(c, strings.Contains(c.GetHeader("Accept"), "text/event-stream"))
+		s.disableWriteDeadline(c)
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.Canceled) {
+					s.logger.Info("Client cancelled operation", zap.String("method", method))
+				}
+				return
+			case <-heartbeat.C:
+				w.heartbeat()
+			case msg, ok := <-messages:
+				if !ok {
+					err := <-recvErr
+					if errors.Is(err, io.EOF) {
+						// A stream that ends without a final update didn't
+						// produce a result.
+						w.error(streamErrorCode, "operation ended without a result", nil)
+					} else if ctx.Err() == nil {
+						s.logger.Warn("Upstream operation failed", zap.String("method", method), zap.Error(err))
+						s.failStream(c, w, err)
+					}
+					return
+				}
+
+				p := progressOf(msg)
+				event := "progress"
+				if p.Done {
+					event = "complete"
+					if p.FileID != "" {
+						p.DownloadURL = "/api/v1/files/" + p.FileID + "/download-url"
+					}
+				}
+				data, err := json.Marshal(p)
+				if err != nil {
+					w.error(streamErrorCode, "failed to encode progress", nil)
+					return
+				}
+				w.event(event, data)
+				if p.Done {
+					w.end()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/httpheader"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Response formats the gateway can render a backend message in.
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+)
+
+const (
+	contentTypeJSON     = "application/json; charset=utf-8"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// Render writes msg in the format negotiated from the Accept header. Protobuf
+// is only offered on routes that enable it in the routes config; every other
+// route always renders JSON.
+func (s *Service) Render(c *gin.Context, status int, msg proto.Message) {
+	route := s.routeFor(c)
+
+	format := FormatJSON
+	if route.Protobuf {
+		format = negotiateFormat(c.GetHeader("Accept"), s.defaultFormat)
+	}
+	httpheader.AddVary(c.Writer.Header(), "Accept")
+
+	switch format {
+	case FormatProtobuf:
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			s.renderEncodeError(c, err)
+			return
+		}
+		c.Data(status, contentTypeProtobuf, data)
+	default:
+		data, err := s.jsonOptions(route).Marshal(msg)
+		if err != nil {
+			s.renderEncodeError(c, err)
+			return
+		}
+		c.Data(status, contentTypeJSON, data)
+	}
+}
+
+// routeFor returns the request's route policy, or the zero RouteConfig.
+func (s *Service) routeFor(c *gin.Context) config.RouteConfig {
+	route, _ := s.routes.Lookup(c.Request.Method, c.FullPath())
+	return route
+}
+
+// jsonOptions resolves the protojson options for a route. The zero
+// RouteConfig (no declared policy) yields the global options.
+func (s *Service) jsonOptions(route config.RouteConfig) protojson.MarshalOptions {
+	t := route.JSON.Apply(s.transcoding)
+	return protojson.MarshalOptions{
+		UseProtoNames:   t.UseProtoNames,
+		EmitUnpopulated: t.EmitUnpopulated,
+		UseEnumNumbers:  t.UseEnumNumbers,
+	}
+}
+
+func (s *Service) renderEncodeError(c *gin.Context, err error) {
+	s.logger.Error("Failed to encode backend response", zap.Error(err))
+	apierror.Abort(c, http.StatusInternalServerError, "ENCODING_ERROR", "Failed to encode response")
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateFormat picks the most preferred supported format from an Accept
+// header, falling back to defaultFormat for missing or wildcard-only headers.
+func negotiateFormat(accept, defaultFormat string) string {
+	if accept == "" {
+		return defaultFormat
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		switch r.mediaType {
+		case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+			return FormatProtobuf
+		case "application/json":
+			return FormatJSON
+		case "*/*", "application/*":
+			return defaultFormat
+		}
+	}
+	return defaultFormat
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Retry outcomes reported in the backend_retries_total metric.
+const (
+	RetryAttempted  = "attempted"
+	RetrySuppressed = "suppressed"
+)
+
+type retryKey struct{}
+
+// Retries marks requests on routes with a retry policy so their backend
+// calls are retried.
+func (s *Service) Retries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy := s.routeFor(c).Retry; policy != nil {
+			ctx := context.WithValue(c.Request.Context(), retryKey{}, policy)
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
+
+// WithRetryBudget bounds retries to ratio of the backend calls made in the
+// last window, plus minPerSecond for each second of the window.
+func WithRetryBudget(ratio float64, minPerSecond int, window time.Duration) Option {
+	return func(s *Service) { s.retryBudget = newRetryBudget(ratio, minPerSecond, window) }
+}
+
+// shouldRetry reports whether the call that failed with err on its
+// attempt'th retry (0 for the first call) should be tried again, taking a
+// retry from the budget if so. Only Unavailable is retried: the backend
+// could not be reached, or refused the call before handling it.
+func (s *Service) shouldRetry(ctx context.Context, service string, attempt int, err error) bool {
+	policy, ok := ctx.Value(retryKey{}).(*config.RetryPolicy)
+	if !ok || attempt >= policy.Attempts || status.Code(err) != codes.Unavailable || ctx.Err() != nil {
+		return false
+	}
+	if !s.retryBudget.take() {
+		metrics.BackendRetries.WithLabelValues(service, RetrySuppressed).Inc()
+		return false
+	}
+	metrics.BackendRetries.WithLabelValues(service, RetryAttempted).Inc()
+
+	// Exponential backoff with jitter, so retries from many requests
+	// don't land on the backend together.
+	backoff := time.Duration(policy.Backoff) * time.Millisecond << attempt
+	if backoff <= 0 {
+		return true
+	}
+	timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryBudget counts calls and retries in one-second buckets over a
+// rolling window. It is kept per gateway replica; since every replica
+// holds its retries to the same ratio, so does the fleet.
+type retryBudget struct {
+	ratio      float64
+	minRetries float64
+
+	mu      sync.Mutex
+	buckets []retryBucket
+}
+
+type retryBucket struct {
+	second  int64
+	calls   int
+	retries int
+}
+
+func newRetryBudget(ratio float64, minPerSecond int, window time.Duration) *retryBudget {
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &retryBudget{
+		ratio:      ratio,
+		minRetries: float64(minPerSecond * seconds),
+		buckets:    make([]retryBucket, seconds),
+	}
+}
+
+// record counts a backend call against which retries are budgeted.
+func (b *retryBudget) record() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket(time.Now()).calls++
+}
+
+// take reports whether a retry is within budget, and counts it if so.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	calls, retries := b.totals(now)
+	allowed := b.ratio*float64(calls) + b.minRetries
+	if allowed > 0 {
+		metrics.RetryBudgetUtilization.Set(float64(retries) / allowed)
+	}
+	if float64(retries) >= allowed {
+		return false
+	}
+	b.bucket(now).retries++
+	metrics.RetryBudgetUtilization.Set(float64(retries+1) / allowed)
+	return true
+}
+
+// bucket returns the bucket for now's second, clearing it if it last held
+// an earlier second.
+func (b *retryBudget) bucket(now time.Time) *retryBucket {
+	second := now.Unix()
+	bucket := &b.buckets[second%int64(len(b.buckets))]
+	if bucket.second != second {
+		*bucket = retryBucket{second: second}
+	}
+	return bucket
+}
+
+func (b *retryBudget) totals(now time.Time) (calls, retries int) {
+	oldest := now.Unix() - int64(len(b.buckets))
+	for _, bucket := range b.buckets {
+		if bucket.second > oldest {
+			calls += bucket.calls
+			retries += bucket.retries
+		}
+	}
+	return calls, retries
+}
@@ -0,0 +1,295 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/geoip"
+	"dharmaguard/api-gateway/internal/jobs"
+	"dharmaguard/api-gateway/internal/reqctx"
+	"dharmaguard/api-gateway/internal/servertiming"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Service forwards requests to backend services over their shared gRPC
+// connections and renders the responses back to HTTP clients.
+type Service struct {
+	conns  map[string]*grpc.ClientConn
+	logger *zap.Logger
+
+	routes        config.RouteTable
+	defaultFormat string
+	transcoding   config.TranscodingConfig
+	grpcClients   map[string]config.GRPCClientConfig
+	headerPolicy  *HeaderPolicy
+	tokenSigner   *auth.InternalTokenSigner
+	// deadlineBuffer is reserved from each request's deadline for the
+	// response's return trip.
+	deadlineBuffer time.Duration
+
+	// compressionUnsupported records backends that rejected our compressor,
+	// so later calls skip it instead of failing and retrying every time.
+	compressionUnsupported sync.Map
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	breakers         sync.Map
+	fleet            *BreakerFleet
+	faultInjection   config.FaultInjectionConfig
+	// faultVerifier and faultRoles identify the callers faults apply to.
+	faultVerifier *auth.InternalCallerVerifier
+	faultRoles    []string
+	// bulkheads caps the calls in flight to each backend connection.
+	bulkheads sync.Map
+
+	// rings place the instances of services with per-connection state,
+	// for sticky streaming calls.
+	rings     map[string]*hashRing
+	stickyKey string
+	// balancers spread unary calls over the same instances.
+	balancers map[string]*balancer
+	// pinTargets are the instances trusted callers may pin requests to,
+	// by address; pinVerifier identifies those callers.
+	pinTargets  map[string]ringInstance
+	pinVerifier *auth.InternalCallerVerifier
+
+	retryBudget *retryBudget
+
+	// shadowSlots limits concurrent shadow calls.
+	shadowSlots chan struct{}
+	diffStore   *DiffStore
+
+	longPollHold  time.Duration
+	longPollSlots chan struct{}
+
+	jobs *jobs.Runner
+
+	// errorMap answers backend errors by gRPC status code.
+	errorMap map[codes.Code]errorMapping
+}
+
+// Option customizes a Service.
+type Option func(*Service)
+
+// WithRoutes supplies the per-route policy table.
+func WithRoutes(routes config.RouteTable) Option {
+	return func(s *Service) { s.routes = routes }
+}
+
+// WithDefaultFormat sets the response format used when the client expresses
+// no preference. Only FormatJSON and FormatProtobuf are meaningful.
+func WithDefaultFormat(format string) Option {
+	return func(s *Service) { s.defaultFormat = format }
+}
+
+// WithTranscoding sets the global JSON rendering options; routes may
+// override them individually.
+func WithTranscoding(t config.TranscodingConfig) Option {
+	return func(s *Service) { s.transcoding = t }
+}
+
+// WithGRPCClients supplies per-backend gRPC settings such as compression.
+func WithGRPCClients(clients map[string]config.GRPCClientConfig) Option {
+	return func(s *Service) { s.grpcClients = clients }
+}
+
+// WithDeadlineBuffer sets how much of the request deadline is kept back
+// from backends for the return trip.
+func WithDeadlineBuffer(buffer time.Duration) Option {
+	return func(s *Service) { s.deadlineBuffer = buffer }
+}
+
+// WithHeaderPolicy sets which incoming headers are forwarded to backends.
+func WithHeaderPolicy(policy *HeaderPolicy) Option {
+	return func(s *Service) { s.headerPolicy = policy }
+}
+
+// WithInternalTokens attaches a gateway-signed identity token to every
+// backend call.
+func WithInternalTokens(signer *auth.InternalTokenSigner) Option {
+	return func(s *Service) { s.tokenSigner = signer }
+}
+
+// WithCircuitBreaker opens a backend's circuit after threshold consecutive
+// failed calls, failing further calls fast for cooldown. A threshold of
+// zero disables circuit breaking.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(s *Service) {
+		s.breakerThreshold = threshold
+		s.breakerCooldown = cooldown
+	}
+}
+
+func NewService(conns map[string]*grpc.ClientConn, logger *zap.Logger, opts ...Option) *Service {
+	s := &Service{
+		conns:         conns,
+		logger:        logger,
+		routes:        make(config.RouteTable),
+		defaultFormat: FormatJSON,
+		headerPolicy:  NewHeaderPolicy(DefaultForwardAllow, DefaultForwardDeny),
+		shadowSlots:   make(chan struct{}, maxShadowInFlight),
+		retryBudget:   newRetryBudget(0.1, 10, 10*time.Second),
+		errorMap:      make(map[codes.Code]errorMapping, len(defaultErrorMappings)),
+	}
+	for code, m := range defaultErrorMappings {
+		s.errorMap[code] = m
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Invoke calls a unary method (e.g. "/dharmaguard.user.v1.UserService/GetUser")
+// on the named backend, or on the variant of it that Experiments selected
+// for the request; calls to a service with instances go to the instance
+// the request is pinned to, or else to one of them by round-robin or
+// least outstanding cost. It returns ErrCircuitOpen without calling the
+// backend while the backend's circuit breaker is open, and ErrBulkheadFull
+// while the backend has its maximum calls in flight. On routes with a
+// retry policy, unavailable backends are retried while the retry budget
+// allows; retries keep the call's in-flight slot.
+func (s *Service) Invoke(ctx context.Context, service, method string, req, resp proto.Message, opts ...grpc.CallOption) error {
+	target := s.target(ctx, service)
+	if pinned, ok := pinnedTarget(ctx, service); ok {
+		target = pinned
+	} else if target == service {
+		var done func()
+		target, done = s.unaryTarget(ctx, service)
+		defer done()
+	}
+	// The in-flight slot comes first: a call the bulkhead turns away must
+	// not take a half-open breaker's one trial with it.
+	if bh := s.bulkheadFor(service, target); bh != nil {
+		release, err := bh.acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", target, err)
+		}
+		defer release()
+	}
+	b := s.breakerFor(target)
+	if b != nil && !b.allow() {
+		return circuitOpenError{target: target, retryIn: b.retryIn()}
+	}
+	s.retryBudget.record()
+	start := time.Now()
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = s.invoke(ctx, service, target, method, req, resp, opts...)
+		if b != nil {
+			b.record(isBreakerFailure(ctx, err))
+		}
+		if !s.shouldRetry(ctx, service, attempt, err) || (b != nil && !b.allow()) {
+			break
+		}
+		proto.Reset(resp)
+	}
+	s.mirror(ctx, service, method, req, resp, err, time.Since(start))
+	return err
+}
+
+// invoke calls method on the connection named target, which is service
+// itself, one of its instances or one of its experiment variants.
+func (s *Service) invoke(ctx context.Context, service, target, method string, req, resp proto.Message, opts ...grpc.CallOption) error {
+	conn, ok := s.conns[target]
+	if !ok {
+		return fmt.Errorf("unknown backend service %q", target)
+	}
+	defer beginCall(ctx, target, method)()
+
+	ctx, cancel, err := s.withReturnBuffer(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if ctx, err = s.outgoingContext(ctx, service); err != nil {
+		return err
+	}
+
+	defer servertiming.Start(ctx, servertiming.Upstream)()
+	if err := s.injectFault(ctx, target, method); err != nil {
+		return err
+	}
+
+	compressor := s.compressorFor(service)
+	if compressor == "" {
+		return conn.Invoke(ctx, method, req, resp, opts...)
+	}
+
+	err = conn.Invoke(ctx, method, req, resp, append(opts, grpc.UseCompressor(compressor))...)
+	if !isCompressionUnsupported(err) {
+		return err
+	}
+
+	// The backend rejected the request before handling it, so retrying
+	// uncompressed is safe.
+	s.compressionUnsupported.Store(service, true)
+	s.logger.Warn("Backend does not support gRPC compression; falling back to uncompressed",
+		zap.String("service", service), zap.String("compressor", compressor))
+	return conn.Invoke(ctx, method, req, resp, append(opts, grpc.UseCompressor(encoding.Identity))...)
+}
+
+// InternalTokenMetadataKey carries the gateway-signed identity token.
+const InternalTokenMetadataKey = "x-gateway-token"
+
+// outgoingContext attaches forwarded client headers, patch semantics, the
+// caller's identity and location, and the internal token for service as
+// gRPC metadata.
+func (s *Service) outgoingContext(ctx context.Context, service string) (context.Context, error) {
+	if md := forwardedHeaders(ctx); len(md) > 0 {
+		existing, _ := metadata.FromOutgoingContext(ctx)
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Join(existing, md))
+	}
+	if md := patchMetadata(ctx); md != nil {
+		existing, _ := metadata.FromOutgoingContext(ctx)
+		ctx = metadata.NewOutgoingContext(ctx, metadata.Join(existing, md))
+	}
+	ctx = reqctx.OutgoingContext(ctx)
+	ctx = geoip.OutgoingContext(ctx)
+
+	if s.tokenSigner == nil {
+		return ctx, nil
+	}
+	rc, ok := reqctx.FromContext(ctx)
+	if !ok {
+		rc = &reqctx.RequestContext{}
+	}
+	token, err := s.tokenSigner.Mint(service, rc.UserID, rc.TenantID, rc.Roles, rc.RequestID)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to mint internal token: %w", err)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(InternalTokenMetadataKey, token)
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+func (s *Service) compressorFor(service string) string {
+	if s.grpcClients[service].Compression != gzip.Name {
+		return ""
+	}
+	if _, unsupported := s.compressionUnsupported.Load(service); unsupported {
+		return ""
+	}
+	return gzip.Name
+}
+
+// isCompressionUnsupported matches the Unimplemented status a grpc-go server
+// returns when it has no decompressor registered for the request encoding.
+func isCompressionUnsupported(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Unimplemented && strings.Contains(st.Message(), "grpc-encoding")
+}
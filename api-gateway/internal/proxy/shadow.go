@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"math/rand"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/reqctx"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Shadow comparison results reported in the shadow_requests_total metric.
+const (
+	ShadowMatch          = "match"
+	ShadowStatusMismatch = "status_mismatch"
+	ShadowBodyMismatch   = "body_mismatch"
+	ShadowError          = "error"
+	ShadowDropped        = "dropped"
+)
+
+// maxShadowInFlight bounds concurrent shadow calls; beyond it copies are
+// dropped rather than queued, so a slow shadow can't build up goroutines.
+const maxShadowInFlight = 100
+
+const defaultShadowTimeout = 5 * time.Second
+
+type shadowKey struct{}
+
+type shadowSelection struct {
+	route  string
+	policy *config.ShadowPolicy
+}
+
+// Shadow marks requests on routes with a shadow policy so their backend
+// calls are mirrored. Sampling is decided here, once per request.
+func (s *Service) Shadow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := s.routeFor(c).Shadow
+		if policy != nil && (policy.SampleRate >= 1 || rand.Float64() < policy.SampleRate) {
+			ctx := context.WithValue(c.Request.Context(), shadowKey{}, shadowSelection{route: c.FullPath(), policy: policy})
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
+
+// mirror replays a completed primary call against the shadow backend in the
+// background and records how the results compare. The shadow response is
+// discarded, and nothing about the shadow call can affect the primary
+// request.
+func (s *Service) mirror(ctx context.Context, service, method string, req, resp proto.Message, primaryErr error, primaryLatency time.Duration) {
+	sel, ok := ctx.Value(shadowKey{}).(shadowSelection)
+	if !ok || sel.policy.Service != service {
+		return
+	}
+	conn, ok := s.conns[config.ShadowConnName(service)]
+	if !ok {
+		return
+	}
+
+	select {
+	case s.shadowSlots <- struct{}{}:
+	default:
+		metrics.ShadowRequests.WithLabelValues(sel.route, ShadowDropped).Inc()
+		return
+	}
+
+	req = proto.Clone(req)
+	var primaryResp proto.Message
+	if primaryErr == nil && sel.policy.CompareBody {
+		primaryResp = proto.Clone(resp)
+	}
+	shadowResp := resp.ProtoReflect().New().Interface()
+
+	timeout := defaultShadowTimeout
+	if sel.policy.Timeout > 0 {
+		timeout = sel.policy.Timeout
+	}
+	// Keep the request's values (identity, forwarded headers) but not its
+	// cancellation: the primary response may already be on its way back.
+	shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+
+	go func() {
+		defer func() { <-s.shadowSlots }()
+		defer cancel()
+
+		outCtx, err := s.outgoingContext(shadowCtx, service)
+		if err != nil {
+			metrics.ShadowRequests.WithLabelValues(sel.route, ShadowError).Inc()
+			return
+		}
+		start := time.Now()
+		err = conn.Invoke(outCtx, method, req, shadowResp)
+		latency := time.Since(start)
+
+		result := ShadowMatch
+		switch {
+		case err != nil && shadowCtx.Err() != nil:
+			// The shadow timed out: that says nothing about correctness.
+			result = ShadowError
+		case status.Code(err) != status.Code(primaryErr):
+			result = ShadowStatusMismatch
+		case primaryResp != nil && !bytes.Equal(hashMessage(primaryResp), hashMessage(shadowResp)):
+			// Equal encodings mean equal messages; unequal ones may still
+			// be the same content, which the JSON comparison settles.
+			if s.compareBodies(shadowCtx, sel, method, primaryResp, shadowResp) {
+				result = ShadowBodyMismatch
+			}
+		}
+		metrics.ShadowRequests.WithLabelValues(sel.route, result).Inc()
+		if result != ShadowError {
+			metrics.ShadowLatencyDelta.WithLabelValues(sel.route).Observe((latency - primaryLatency).Seconds())
+		}
+		if result != ShadowMatch {
+			s.logger.Debug("Shadow response differed from primary",
+				zap.String("route", sel.route),
+				zap.String("method", method),
+				zap.String("result", result),
+				zap.NamedError("primary_error", primaryErr),
+				zap.NamedError("shadow_error", err))
+		}
+	}()
+}
+
+// compareBodies diffs the two responses, records the diff summary and, for
+// a sample of comparisons, keeps the full diff. It reports whether the
+// bodies differ.
+func (s *Service) compareBodies(ctx context.Context, sel shadowSelection, method string, primary, shadow proto.Message) bool {
+	fields, truncated, err := diffMessages(primary, shadow)
+	if err != nil {
+		s.logger.Debug("Failed to diff shadow response", zap.String("route", sel.route), zap.Error(err))
+		return true
+	}
+	if len(fields) == 0 {
+		return false
+	}
+	for _, f := range fields {
+		metrics.ShadowDiffFields.WithLabelValues(sel.route, f.Kind).Inc()
+	}
+
+	if s.diffStore == nil || rand.Float64() >= sel.policy.DiffSampleRate {
+		return true
+	}
+	diff := ShadowDiff{
+		Route:     sel.route,
+		Method:    method,
+		At:        time.Now().UTC(),
+		Truncated: truncated,
+		Fields:    fields,
+	}
+	if rc, ok := reqctx.FromContext(ctx); ok {
+		diff.RequestID = rc.RequestID
+	}
+	if err := s.diffStore.Add(ctx, diff); err != nil {
+		s.logger.Warn("Failed to store shadow diff", zap.String("route", sel.route), zap.Error(err))
+	}
+	return true
+}
+
+// hashMessage hashes a deterministic encoding so equal messages hash equal
+// regardless of map ordering.
+func hashMessage(m proto.Message) []byte {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
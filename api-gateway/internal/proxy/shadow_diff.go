@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Kinds of field difference between a primary and shadow response.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// maxDiffEntries caps how many differences one comparison records, so a
+// wholly different response doesn't produce a huge sample.
+const maxDiffEntries = 50
+
+// FieldDiff is one difference, at a JSON path such as "trades[3].price".
+type FieldDiff struct {
+	Path    string      `json:"path"`
+	Kind    string      `json:"kind"`
+	Primary interface{} `json:"primary,omitempty"`
+	Shadow  interface{} `json:"shadow,omitempty"`
+}
+
+// ShadowDiff is a sampled comparison kept for inspection.
+type ShadowDiff struct {
+	Route     string      `json:"route"`
+	Method    string      `json:"method"`
+	RequestID string      `json:"request_id,omitempty"`
+	At        time.Time   `json:"at"`
+	Truncated bool        `json:"truncated"`
+	Fields    []FieldDiff `json:"fields"`
+}
+
+// DiffStore keeps the most recent sampled diffs, shared by all replicas.
+// Diffs can contain response data, so the list is capped and only exposed
+// to admins.
+type DiffStore struct {
+	client *redis.Client
+	max    int64
+}
+
+const diffStoreKey = "shadow:diffs"
+
+func NewDiffStore(client *redis.Client, max int) *DiffStore {
+	return &DiffStore{client: client, max: int64(max)}
+}
+
+func (d *DiffStore) Add(ctx context.Context, diff ShadowDiff) error {
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	pipe := d.client.TxPipeline()
+	pipe.LPush(ctx, diffStoreKey, data)
+	pipe.LTrim(ctx, diffStoreKey, 0, d.max-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Recent returns up to limit diffs, newest first.
+func (d *DiffStore) Recent(ctx context.Context, limit int64) ([]ShadowDiff, error) {
+	values, err := d.client.LRange(ctx, diffStoreKey, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]ShadowDiff, 0, len(values))
+	for _, v := range values {
+		var diff ShadowDiff
+		if err := json.Unmarshal([]byte(v), &diff); err == nil {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// WithShadowDiffs keeps sampled shadow body diffs in store.
+func WithShadowDiffs(store *DiffStore) Option {
+	return func(s *Service) { s.diffStore = store }
+}
+
+// diffMessages compares two messages as JSON, so field order and encoding
+// details don't count as differences.
+func diffMessages(primary, shadow proto.Message) ([]FieldDiff, bool, error) {
+	a, err := normalizedJSON(primary)
+	if err != nil {
+		return nil, false, err
+	}
+	b, err := normalizedJSON(shadow)
+	if err != nil {
+		return nil, false, err
+	}
+	var diffs []FieldDiff
+	truncated := diffValues("", a, b, &diffs)
+	return diffs, truncated, nil
+}
+
+func normalizedJSON(m proto.Message) (interface{}, error) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(data, &v)
+	return v, err
+}
+
+// diffValues appends the differences between a and b to diffs and reports
+// whether it stopped at maxDiffEntries.
+func diffValues(path string, a, b interface{}, diffs *[]FieldDiff) bool {
+	if len(*diffs) >= maxDiffEntries {
+		return true
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]struct{}, len(av)+len(bv))
+		for k := range av {
+			keys[k] = struct{}{}
+		}
+		for k := range bv {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			x, inA := av[k]
+			y, inB := bv[k]
+			switch {
+			case !inA:
+				*diffs = append(*diffs, FieldDiff{Path: child, Kind: DiffAdded, Shadow: y})
+			case !inB:
+				*diffs = append(*diffs, FieldDiff{Path: child, Kind: DiffRemoved, Primary: x})
+			default:
+				if diffValues(child, x, y, diffs) {
+					return true
+				}
+			}
+			if len(*diffs) >= maxDiffEntries {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(av):
+				*diffs = append(*diffs, FieldDiff{Path: child, Kind: DiffAdded, Shadow: bv[i]})
+			case i >= len(bv):
+				*diffs = append(*diffs, FieldDiff{Path: child, Kind: DiffRemoved, Primary: av[i]})
+			default:
+				if diffValues(child, av[i], bv[i], diffs) {
+					return true
+				}
+			}
+			if len(*diffs) >= maxDiffEntries {
+				return true
+			}
+		}
+		return false
+	}
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, FieldDiff{Path: path, Kind: DiffChanged, Primary: a, Shadow: b})
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"google.golang.org/grpc/stats"
+)
+
+// payloadStats counts uncompressed and on-the-wire payload bytes per backend
+// so the effect of gRPC compression can be measured.
+type payloadStats struct {
+	service string
+}
+
+// NewPayloadStatsHandler returns a client stats.Handler for the named backend.
+func NewPayloadStatsHandler(service string) stats.Handler {
+	return &payloadStats{service: service}
+}
+
+func (h *payloadStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *payloadStats) HandleRPC(_ context.Context, s stats.RPCStats) {
+	switch p := s.(type) {
+	case *stats.OutPayload:
+		metrics.GRPCPayloadBytes.WithLabelValues(h.service, "sent", "uncompressed").Add(float64(p.Length))
+		metrics.GRPCPayloadBytes.WithLabelValues(h.service, "sent", "wire").Add(float64(p.WireLength))
+	case *stats.InPayload:
+		metrics.GRPCPayloadBytes.WithLabelValues(h.service, "received", "uncompressed").Add(float64(p.Length))
+		metrics.GRPCPayloadBytes.WithLabelValues(h.service, "received", "wire").Add(float64(p.WireLength))
+	}
+}
+
+func (h *payloadStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *payloadStats) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const streamHeartbeatInterval = 15 * time.Second
+
+// StreamRequestFunc builds the backend request message for a streaming call.
+type StreamRequestFunc func(c *gin.Context) (proto.Message, error)
+
+// StreamHandler proxies a gRPC server-streaming method to the client as
+// Server-Sent Events (Accept: text/event-stream) or, otherwise, as chunked
+// newline-delimited JSON. Each backend message becomes one event. The
+// upstream stream is cancelled as soon as the client disconnects.
+//
+// This is the preferred path for payloads too large for a single unary
+// response, since no message has to fit within MaxRecvMsgSize on its own.
+func (s *Service) StreamHandler(service, method string, newRequest StreamRequestFunc, newResponse func() proto.Message) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := newRequest(c)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		// The request context is cancelled when the client goes away, which
+		// tears down the upstream stream with it.
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		messages, recvErr, err := s.subscribe(ctx, service, method, req, newResponse)
+		if err != nil {
+			s.RenderError(c, err)
+			return
+		}
+
+		sse := strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+		w := newStreamWriter(c, sse)
+		s.disableWriteDeadline(c)
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				w.heartbeat()
+			case msg, ok := <-messages:
+				if !ok {
+					err := <-recvErr
+					if errors.Is(err, io.EOF) {
+						w.end()
+						return
+					}
+					if ctx.Err() == nil {
+						s.logger.Warn("Upstream stream failed", zap.String("method", method), zap.Error(err))
+						s.failStream(c, w, err)
+					}
+					return
+				}
+				data, err := s.jsonOptions(s.routeFor(c)).Marshal(msg)
+				if err != nil {
+					w.error(streamErrorCode, "failed to encode stream message", nil)
+					return
+				}
+				w.message(data)
+			}
+		}
+	}
+}
+
+// subscribe opens a server stream for method on service, on the caller's
+// sticky instance if the service has instances, and sends its messages on
+// the returned channel until the stream ends or ctx is done.
+// When the stream ends, the channel is closed and the error it ended with
+// (io.EOF for a clean end) is sent on the error channel.
+func (s *Service) subscribe(ctx context.Context, service, method string, req proto.Message, newResponse func() proto.Message) (<-chan proto.Message, <-chan error, error) {
+	target := s.streamTarget(ctx, service)
+	conn, ok := s.conns[target]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown backend service %q", service)
+	}
+
+	var opts []grpc.CallOption
+	if compressor := s.compressorFor(service); compressor != "" {
+		opts = append(opts, grpc.UseCompressor(compressor))
+	}
+
+	outCtx, err := s.outgoingContext(ctx, service)
+	if err != nil {
+		return nil, nil, err
+	}
+	desc := &grpc.StreamDesc{StreamName: method, ServerStreams: true}
+	stream, err := conn.NewStream(outCtx, desc, method, opts...)
+	if err == nil {
+		err = stream.SendMsg(req)
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages := make(chan proto.Message)
+	recvErr := make(chan error, 1)
+	done := beginCall(ctx, target, method)
+	go func() {
+		defer done()
+		defer close(messages)
+		for {
+			msg := newResponse()
+			if err := stream.RecvMsg(msg); err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, recvErr, nil
+}
+
+// disableWriteDeadline lifts the server's WriteTimeout for this response,
+// which would otherwise cut long-lived streams off.
+func (s *Service) disableWriteDeadline(c *gin.Context) {
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Debug("Could not clear write deadline for stream", zap.Error(err))
+	}
+}
+
+type streamWriter struct {
+	c   *gin.Context
+	sse bool
+}
+
+func newStreamWriter(c *gin.Context, sse bool) *streamWriter {
+	h := c.Writer.Header()
+	if sse {
+		h.Set("Content-Type", "text/event-stream")
+	} else {
+		h.Set("Content-Type", "application/x-ndjson")
+	}
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	return &streamWriter{c: c, sse: sse}
+}
+
+func (w *streamWriter) message(data []byte) {
+	w.event("message", data)
+}
+
+// event sends data as an SSE event of the given type; NDJSON has no event
+// types, so there it is just the next line.
+func (w *streamWriter) event(name string, data []byte) {
+	if w.sse {
+		fmt.Fprintf(w.c.Writer, "event: %s\ndata: %s\n\n", name, data)
+	} else {
+		w.c.Writer.Write(append(data, '\n'))
+	}
+	w.c.Writer.Flush()
+}
+
+// streamErrorCode reports a stream the gateway itself couldn't carry on.
+const streamErrorCode = "UPSTREAM_STREAM_ERROR"
+
+// error writes the stream's error frame, in the shape of APIError.
+func (w *streamWriter) error(code, message string, retryable *bool) {
+	payload, _ := json.Marshal(struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		Retryable *bool  `json:"retryable,omitempty"`
+	}{code, message, retryable})
+	if w.sse {
+		fmt.Fprintf(w.c.Writer, "event: error\ndata: %s\n\n", payload)
+	} else {
+		fmt.Fprintf(w.c.Writer, `{"error":%s}`+"\n", payload)
+	}
+	w.c.Writer.Flush()
+}
+
+// failStream writes the error frame for a failed backend stream, with the
+// code, message and retryability RenderError would answer the failure with.
+func (s *Service) failStream(c *gin.Context, w *streamWriter, err error) {
+	st := status.Convert(err)
+	code, m := s.mappingFor(c, st)
+	w.error(m.code, clientMessage(code, m, st), &m.retryable)
+}
+
+func (w *streamWriter) end() {
+	if w.sse {
+		fmt.Fprint(w.c.Writer, "event: end\ndata: {}\n\n")
+		w.c.Writer.Flush()
+	}
+}
+
+// heartbeat keeps intermediaries from closing an idle stream. NDJSON has no
+// comment syntax, so only SSE streams get one.
+func (w *streamWriter) heartbeat() {
+	if w.sse {
+		fmt.Fprint(w.c.Writer, ": heartbeat\n\n")
+		w.c.Writer.Flush()
+	}
+}
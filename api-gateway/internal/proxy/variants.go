@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"context"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VariantHeader names the experiment variant that served the response.
+const VariantHeader = "X-Variant"
+
+type variantKey struct{}
+
+type variantSelection struct {
+	service string
+	name    string
+}
+
+// Experiments evaluates the route's variant rules in order and routes the
+// request's calls to the first matching variant's backend. Unlike a
+// percentage canary, the choice is deterministic on the client's header or
+// cookie, so a client stays in its experiment arm.
+func (s *Service) Experiments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := s.routeFor(c)
+		for _, rule := range route.Variants {
+			if !variantMatches(c, rule) {
+				continue
+			}
+			ctx := context.WithValue(c.Request.Context(), variantKey{}, variantSelection{service: rule.Service, name: rule.Name})
+			c.Request = c.Request.WithContext(ctx)
+			c.Header(VariantHeader, rule.Name)
+			metrics.ExperimentRequests.WithLabelValues(c.FullPath(), rule.Name).Inc()
+			break
+		}
+		c.Next()
+	}
+}
+
+func variantMatches(c *gin.Context, rule config.VariantRule) bool {
+	if rule.Header != "" {
+		return c.GetHeader(rule.Header) == rule.Value
+	}
+	value, err := c.Cookie(rule.Cookie)
+	return err == nil && value == rule.Value
+}
+
+// target returns the connection to use for service: the selected variant's
+// when the request matched a variant rule for service, else service's own.
+func (s *Service) target(ctx context.Context, service string) string {
+	v, ok := ctx.Value(variantKey{}).(variantSelection)
+	if !ok || v.service != service {
+		return service
+	}
+	name := config.VariantConnName(service, v.name)
+	if _, ok := s.conns[name]; !ok {
+		return service
+	}
+	return name
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/wsconn"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// NoticeFunc delivers the gateway's own messages on a WebSocket, such as a
+// token expiry warning, by calling send until ctx is done. It runs
+// alongside the stream for the life of the connection.
+type NoticeFunc func(ctx context.Context, c *gin.Context, send func(v interface{}) error)
+
+// WebSocketHandler serves a gRPC server-streaming method over a WebSocket.
+// The subscription is opened before the upgrade, so a backend that can't
+// be reached gets an ordinary error response. Each backend message is then
+// written as one event in the encoding the client negotiated with
+// wsconn.Upgrade, and the connection is closed with 1000 when the backend
+// ends the stream or 1011 when it fails.
+//
+// Client messages are read and discarded, through wsconn.Conn so its size
+// and rate limits apply; a client that breaks one, closes the socket or
+// goes away ends the backend stream. The connection is closed with 1001 if
+// the request context ends first. notices, if not nil, adds the gateway's
+// messages to the stream's.
+func (s *Service) WebSocketHandler(service, method string, newRequest StreamRequestFunc, newResponse func() proto.Message, notices NoticeFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := newRequest(c)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+
+		messages, recvErr, err := s.subscribe(ctx, service, method, req, newResponse)
+		if err != nil {
+			s.RenderError(c, err)
+			return
+		}
+
+		conn, err := wsconn.Upgrade(c)
+		if err != nil {
+			// The upgrader has already answered with an HTTP error.
+			s.logger.Debug("WebSocket upgrade failed", zap.String("method", method), zap.Error(err))
+			return
+		}
+
+		readDone := make(chan struct{})
+		go func() {
+			defer close(readDone)
+			for {
+				_, r, err := conn.NextReader()
+				if err == nil {
+					_, err = io.Copy(io.Discard, r)
+				}
+				if err != nil {
+					if errors.Is(err, websocket.ErrReadLimit) || errors.Is(err, wsconn.ErrRateLimited) {
+						s.logger.Info("WebSocket client broke a message limit", zap.String("method", method), zap.Error(err))
+					}
+					return
+				}
+			}
+		}()
+		var notifying sync.WaitGroup
+		if notices != nil {
+			notifying.Add(1)
+			go func() {
+				defer notifying.Done()
+				notices(ctx, c, conn.WriteEvent)
+			}()
+		}
+		defer func() {
+			cancel()
+			notifying.Wait()
+			<-readDone
+			conn.Close()
+		}()
+
+		marshal := s.jsonOptions(s.routeFor(c))
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-readDone:
+				// The client closed the connection, went away or broke a
+				// limit, and has been sent the reason.
+				return
+			case <-ctx.Done():
+				conn.SendClose(websocket.CloseGoingAway, "going away")
+				return
+			case <-heartbeat.C:
+				if err := conn.Ping(); err != nil {
+					conn.SetReadDeadline(time.Now())
+					return
+				}
+			case msg, ok := <-messages:
+				if !ok {
+					err := <-recvErr
+					if errors.Is(err, io.EOF) {
+						conn.SendClose(websocket.CloseNormalClosure, "stream ended")
+						return
+					}
+					s.logger.Warn("Upstream stream failed", zap.String("method", method), zap.Error(err))
+					st := status.Convert(err)
+					code, m := s.mappingFor(c, st)
+					conn.SendClose(websocket.CloseInternalServerErr, closeReason(clientMessage(code, m, st)))
+					return
+				}
+				data, err := marshal.Marshal(msg)
+				if err != nil {
+					conn.SendClose(websocket.CloseInternalServerErr, "failed to encode stream message")
+					return
+				}
+				if err := conn.WriteEvent(json.RawMessage(data)); err != nil {
+					s.logger.Debug("WebSocket write failed", zap.String("method", method), zap.Error(err))
+					conn.SetReadDeadline(time.Now())
+					return
+				}
+			}
+		}
+	}
+}
+
+// maxCloseReason is the most a close frame's reason may hold: a control
+// frame payload is 125 bytes, two of them the code.
+const maxCloseReason = 123
+
+// closeReason shortens message to fit a close frame, on a rune boundary.
+func closeReason(message string) string {
+	if len(message) <= maxCloseReason {
+		return message
+	}
+	end := maxCloseReason
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end]
+}
@@ -0,0 +1,215 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metering"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	limitKeyPrefix = "quota:limit:"
+	usedKeyPrefix  = "quota:used:"
+)
+
+// Quota scopes.
+const (
+	ScopeTenant = "tenant"
+	ScopeAPIKey = "api_key"
+)
+
+// Quota caps the requests a tenant or API key may make per period. Unlike a
+// rate limit it doesn't refill gradually: once consumed, requests are refused
+// until the period rolls over.
+type Quota struct {
+	Limit int64 `json:"limit"`
+	// Period is metering.PeriodDaily or metering.PeriodMonthly.
+	Period string    `json:"period"`
+	Reason string    `json:"reason,omitempty"`
+	SetBy  string    `json:"set_by,omitempty"`
+	SetAt  time.Time `json:"set_at"`
+}
+
+// Target identifies whose quota a request counts against.
+type Target struct {
+	Scope string
+	ID    string
+}
+
+// Result is the outcome of Consume for the most constrained target.
+type Result struct {
+	Allowed bool
+	// Limited is false when no target had a quota.
+	Limited   bool
+	Limit     int64
+	Remaining int64
+	ResetAt   time.Time
+}
+
+// Status is a quota together with its consumption in the current period.
+type Status struct {
+	Quota
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// consumeScript counts one request against every quota in KEYS, but only if
+// none is exhausted, so a refused request doesn't use up another target's
+// quota. ARGV holds a limit and expiry (unix seconds) per key. It returns
+// {allowed, index of the most constrained key, its used count}.
+var consumeScript = redis.NewScript(`
+local tightest, tightestUsed, tightestLeft = 1, 0, nil
+for i, key in ipairs(KEYS) do
+	local used = tonumber(redis.call("GET", key)) or 0
+	local limit = tonumber(ARGV[i * 2 - 1])
+	if used >= limit then
+		return {0, i, used}
+	end
+	if tightestLeft == nil or limit - used < tightestLeft then
+		tightest, tightestUsed, tightestLeft = i, used, limit - used
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call("INCR", key)
+	redis.call("EXPIREAT", key, ARGV[i * 2])
+end
+return {1, tightest, tightestUsed + 1}
+`)
+
+// Store keeps quotas and their usage counters in Redis, shared by every
+// replica. Each period has its own counter key, expiring when the period
+// ends, so usage resets on rollover.
+type Store struct {
+	client *redis.Client
+}
+
+func NewStore(client *redis.Client) *Store {
+	return &Store{client: client}
+}
+
+func ValidScope(scope string) bool {
+	return scope == ScopeTenant || scope == ScopeAPIKey
+}
+
+func ValidPeriod(period string) bool {
+	return period == metering.PeriodDaily || period == metering.PeriodMonthly
+}
+
+func limitKey(scope, id string) string {
+	return limitKeyPrefix + scope + ":" + id
+}
+
+func usedKey(scope, id, period string) string {
+	return usedKeyPrefix + scope + ":" + id + ":" + period
+}
+
+func (s *Store) Get(ctx context.Context, scope, id string) (*Quota, error) {
+	data, err := s.client.Get(ctx, limitKey(scope, id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeQuota(data)
+}
+
+func (s *Store) Set(ctx context.Context, scope, id string, q Quota) error {
+	data, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, limitKey(scope, id), data, 0).Err()
+}
+
+// Delete removes the quota; usage already counted this period is kept until
+// rollover.
+func (s *Store) Delete(ctx context.Context, scope, id string) error {
+	return s.client.Del(ctx, limitKey(scope, id)).Err()
+}
+
+// Status returns the quota for scope/id with its current-period usage, or
+// nil if none is set.
+func (s *Store) Status(ctx context.Context, scope, id string) (*Status, error) {
+	q, err := s.Get(ctx, scope, id)
+	if err != nil || q == nil {
+		return nil, err
+	}
+	label, end := metering.PeriodBounds(q.Period, time.Now())
+	used, err := s.client.Get(ctx, usedKey(scope, id, label)).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	return &Status{Quota: *q, Used: used, Remaining: max(q.Limit-used, 0), ResetAt: end}, nil
+}
+
+// Consume counts one request against the quotas of targets. Targets without
+// a quota are ignored; if none has one the request is allowed unlimited.
+func (s *Store) Consume(ctx context.Context, targets []Target) (Result, error) {
+	limitKeys := make([]string, 0, len(targets))
+	active := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		if t.ID != "" {
+			limitKeys = append(limitKeys, limitKey(t.Scope, t.ID))
+			active = append(active, t)
+		}
+	}
+	if len(limitKeys) == 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	values, err := s.client.MGet(ctx, limitKeys...).Result()
+	if err != nil {
+		return Result{}, fmt.Errorf("quota lookup failed: %w", err)
+	}
+
+	now := time.Now()
+	var keys []string
+	var args []interface{}
+	var quotas []*Quota
+	var resets []time.Time
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		q, err := decodeQuota([]byte(str))
+		if err != nil {
+			return Result{}, err
+		}
+		label, end := metering.PeriodBounds(q.Period, now)
+		keys = append(keys, usedKey(active[i].Scope, active[i].ID, label))
+		args = append(args, q.Limit, end.Unix())
+		quotas = append(quotas, q)
+		resets = append(resets, end)
+	}
+	if len(keys) == 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	out, err := consumeScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("quota check failed: %w", err)
+	}
+	i := out[1] - 1
+	return Result{
+		Allowed:   out[0] == 1,
+		Limited:   true,
+		Limit:     quotas[i].Limit,
+		Remaining: max(quotas[i].Limit-out[2], 0),
+		ResetAt:   resets[i],
+	}, nil
+}
+
+func decodeQuota(data []byte) (*Quota, error) {
+	var q Quota
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, fmt.Errorf("corrupt quota: %w", err)
+	}
+	return &q, nil
+}
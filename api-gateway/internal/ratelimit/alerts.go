@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const alertKeyPrefix = keyPrefix + "alert:"
+
+// totalField holds a window's total rejections; route fields are paths,
+// so can't collide with it.
+const totalField = "*"
+
+// maxAlertsInFlight bounds the rejections being counted at once; beyond it
+// rejections are not counted, so a flood of 429s can't pile up goroutines.
+const maxAlertsInFlight = 64
+
+// Alert reports a rate-limit key that was rejected Rejected times in one
+// window, with the rejections per route.
+type Alert struct {
+	Key      string
+	TenantID string
+	Rejected int64
+	Window   time.Duration
+	Routes   map[string]int64
+}
+
+// Alerter counts rate-limit rejections per key in fixed windows, in Redis
+// so the count spans replicas, and calls notify when a key reaches the
+// threshold. A key alerts at most once per cooldown, across replicas.
+type Alerter struct {
+	client    *redis.Client
+	threshold int64
+	window    time.Duration
+	cooldown  time.Duration
+	notify    func(Alert)
+	slots     chan struct{}
+	logger    *zap.Logger
+}
+
+func NewAlerter(client *redis.Client, threshold int, window, cooldown time.Duration, notify func(Alert), logger *zap.Logger) *Alerter {
+	return &Alerter{
+		client:    client,
+		threshold: int64(threshold),
+		window:    window,
+		cooldown:  cooldown,
+		notify:    notify,
+		slots:     make(chan struct{}, maxAlertsInFlight),
+		logger:    logger,
+	}
+}
+
+// Record counts a rejection of key on route in the background.
+func (a *Alerter) Record(key, tenantID, route string) {
+	select {
+	case a.slots <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-a.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := a.record(ctx, key, tenantID, route); err != nil {
+			a.logger.Warn("Failed to count rate limit rejection", zap.String("key", key), zap.Error(err))
+		}
+	}()
+}
+
+func (a *Alerter) record(ctx context.Context, key, tenantID, route string) error {
+	window := time.Now().Truncate(a.window).Unix()
+	countKey := alertKeyPrefix + "count:" + key + ":" + strconv.FormatInt(window, 10)
+
+	pipe := a.client.TxPipeline()
+	pipe.HIncrBy(ctx, countKey, route, 1)
+	total := pipe.HIncrBy(ctx, countKey, totalField, 1)
+	pipe.Expire(ctx, countKey, a.window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	// Only the rejection that reaches the threshold alerts, so each window
+	// alerts once however far past it the key goes.
+	if total.Val() != a.threshold {
+		return nil
+	}
+
+	first, err := a.client.SetNX(ctx, alertKeyPrefix+"sent:"+key, window, a.cooldown).Result()
+	if err != nil || !first {
+		return err
+	}
+	counts, err := a.client.HGetAll(ctx, countKey).Result()
+	if err != nil {
+		return err
+	}
+	alert := Alert{Key: key, TenantID: tenantID, Rejected: total.Val(), Window: a.window, Routes: make(map[string]int64)}
+	for r, n := range counts {
+		if r == totalField {
+			continue
+		}
+		alert.Routes[r], _ = strconv.ParseInt(n, 10, 64)
+	}
+	metrics.RateLimitAlerts.Inc()
+	a.notify(alert)
+	return nil
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// fallbackProbeInterval is how long the fallback serves alone after the
+// shared limiter fails before it is tried again, so an outage that hangs
+// rather than refuses doesn't cost every request a timeout.
+const fallbackProbeInterval = time.Second
+
+// FallbackLimiter passes checks to a shared limiter and, while that
+// fails, answers them from token buckets in this replica's memory. The
+// local buckets see only this replica's traffic, so the limit they keep is
+// the configured one per replica: looser than the shared limit, but not
+// none. Scale shrinks it, e.g. to the reciprocal of the replica count.
+type FallbackLimiter struct {
+	primary Limiter
+	scale   float64
+	maxKeys int
+	logger  *zap.Logger
+
+	mu       sync.Mutex
+	buckets  map[string]*localBucket
+	degraded bool
+	probeAt  time.Time
+	// probing is set while one request, while degraded, tries the shared
+	// limiter again; the rest stay on the local buckets meanwhile.
+	probing bool
+}
+
+type localBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewFallbackLimiter falls back from primary to local buckets holding at
+// most maxKeys keys, with limits multiplied by scale.
+func NewFallbackLimiter(primary Limiter, scale float64, maxKeys int, logger *zap.Logger) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary: primary,
+		scale:   scale,
+		maxKeys: maxKeys,
+		logger:  logger,
+		buckets: make(map[string]*localBucket),
+	}
+}
+
+// Allow never fails: when the shared limiter does, the local bucket
+// answers. A check that fails because its own request was cancelled or
+// ran out of time says nothing about the shared limiter, so it doesn't
+// start or prolong the fallback.
+func (l *FallbackLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	l.mu.Lock()
+	try := !l.degraded || (!l.probing && !now.Before(l.probeAt))
+	probe := try && l.degraded
+	if probe {
+		l.probing = true
+	}
+	l.mu.Unlock()
+
+	if try {
+		result, err := l.primary.Allow(ctx, key, limit)
+		switch {
+		case err == nil:
+			l.recover()
+			return result, nil
+		case ctx.Err() != nil:
+			if probe {
+				l.endProbe()
+			}
+		default:
+			metrics.RateLimitErrors.Inc()
+			l.degrade(now, err)
+		}
+	}
+	return l.allowLocal(key, limit, now), nil
+}
+
+// endProbe lets the next request probe the shared limiter.
+func (l *FallbackLimiter) endProbe() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.probing = false
+}
+
+func (l *FallbackLimiter) degrade(now time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.probing = false
+	l.probeAt = now.Add(fallbackProbeInterval)
+	if l.degraded {
+		return
+	}
+	l.degraded = true
+	metrics.RateLimitFallbackActive.Set(1)
+	l.logger.Warn("Shared rate limiter unavailable; enforcing per-replica limits in memory", zap.Error(err))
+}
+
+func (l *FallbackLimiter) recover() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.probing = false
+	if !l.degraded {
+		return
+	}
+	l.degraded = false
+	l.buckets = make(map[string]*localBucket)
+	metrics.RateLimitFallbackActive.Set(0)
+	l.logger.Info("Shared rate limiter recovered; leaving in-memory fallback")
+}
+
+// allowLocal takes a token from key's local bucket, refilled as the Redis
+// script refills shared ones.
+func (l *FallbackLimiter) allowLocal(key string, limit Limit, now time.Time) Result {
+	rate := float64(limit.RequestsPerMinute) * l.scale / 60
+	burst := math.Max(1, float64(limit.Burst)*l.scale)
+	if rate <= 0 || limit.Burst <= 0 {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxKeys {
+			l.evict(now)
+		}
+		b = &localBucket{tokens: burst, ts: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.ts).Seconds()*rate)
+	b.ts = now
+
+	result := Result{Limit: int(burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate * float64(time.Second)))
+	}
+	result.Remaining = int(b.tokens)
+	metrics.RateLimitFallbackChecks.WithLabelValues(resultLabel(result.Allowed)).Inc()
+	return result
+}
+
+// evict drops buckets idle for a minute, which have refilled to about
+// where a new bucket starts, and everything if that frees nothing.
+func (l *FallbackLimiter) evict(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.ts) > time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) >= l.maxKeys {
+		l.buckets = make(map[string]*localBucket)
+	}
+}
+
+func resultLabel(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
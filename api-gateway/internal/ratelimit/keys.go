@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var keyRefPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// KeyTemplate derives the rate limit bucket from token claims instead of
+// the user ID. "{org}:{plan}", for instance, gives all users of an
+// organization on a plan one shared bucket.
+type KeyTemplate struct {
+	template string
+}
+
+// ParseKeyTemplate checks that tmpl references at least one claim and only
+// claims in known. An empty tmpl returns nil, which keeps the per-user key.
+func ParseKeyTemplate(tmpl string, known []string) (*KeyTemplate, error) {
+	if tmpl == "" {
+		return nil, nil
+	}
+	refs := keyRefPattern.FindAllStringSubmatch(tmpl, -1)
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("rate limit key template %q references no claims", tmpl)
+	}
+	for _, ref := range refs {
+		if !contains(known, ref[1]) {
+			return nil, fmt.Errorf("rate limit key template %q references unknown claim %q (known: %s)",
+				tmpl, ref[1], strings.Join(known, ", "))
+		}
+	}
+	return &KeyTemplate{template: tmpl}, nil
+}
+
+// Key expands the template with value, which returns a claim by name. It
+// reports false if any referenced claim is empty, so the caller can fall
+// back to its default key rather than pool unrelated callers together.
+func (t *KeyTemplate) Key(value func(claim string) string) (string, bool) {
+	complete := true
+	key := keyRefPattern.ReplaceAllStringFunc(t.template, func(ref string) string {
+		v := value(ref[1 : len(ref)-1])
+		if v == "" {
+			complete = false
+		}
+		return v
+	})
+	return "claims:" + key, complete
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
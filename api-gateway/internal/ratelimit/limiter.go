@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const keyPrefix = "ratelimit:"
+
+// Limit is a token-bucket limit: tokens refill at RequestsPerMinute and the
+// bucket holds at most Burst tokens.
+type Limit struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+// Result is the outcome of a single Allow call.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token is available when the
+	// request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key may proceed.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// tokenBucketScript refills and takes one token atomically. It returns
+// {allowed, remaining, retry_after_ms}. Any further KEYS are pending
+// charge hashes for peer regions, and an admitted request is recorded in
+// each under ARGV[4], the bucket's name.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+	for i = 2, #KEYS do
+		redis.call("HINCRBY", KEYS[i], ARGV[4], 1)
+	end
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// RedisRateLimiter is a token-bucket limiter shared by all gateway replicas.
+type RedisRateLimiter struct {
+	client *redis.Client
+	// pendingKeys are the peer regions' pending charge hashes, in
+	// ModeReconciled.
+	pendingKeys []string
+}
+
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.RequestsPerMinute <= 0 || limit.Burst <= 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	ratePerSecond := float64(limit.RequestsPerMinute) / 60
+	keys := append([]string{keyPrefix + key}, l.pendingKeys...)
+	values, err := tokenBucketScript.Run(ctx, l.client, keys,
+		ratePerSecond, limit.Burst, time.Now().UnixMilli(), key).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit check failed: %w", err)
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
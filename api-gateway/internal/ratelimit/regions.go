@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// Rate limiting modes for deployments spanning regions, each with its own
+// Redis.
+const (
+	// ModeLocal limits against the region's own Redis: a client spreading
+	// requests across N regions gets up to N times its limit.
+	ModeLocal = "local"
+	// ModeGlobal limits every region against one shared Redis: exact, but
+	// each check pays the cross-region round trip.
+	ModeGlobal = "global"
+	// ModeReconciled limits locally and periodically charges each region's
+	// admissions to the buckets in the others, so limits hold across
+	// regions to within one reconcile interval.
+	ModeReconciled = "reconciled"
+)
+
+const pendingKeyPrefix = "ratelimit:pending:"
+
+// takePendingScript returns the charges owed to a peer and clears them, so
+// only one replica in the region sends each batch.
+var takePendingScript = redis.NewScript(`
+local charges = redis.call("HGETALL", KEYS[1])
+redis.call("DEL", KEYS[1])
+return charges
+`)
+
+// chargeScript takes ARGV[1] tokens from an existing bucket, admitted
+// elsewhere, leaving at least none. A bucket the region hasn't seen is full
+// anyway and is left to be created by its first request.
+var chargeScript = redis.NewScript(`
+local tokens = tonumber(redis.call("HGET", KEYS[1], "tokens"))
+if tokens == nil then
+	return 0
+end
+redis.call("HSET", KEYS[1], "tokens", math.max(0, tokens - tonumber(ARGV[1])))
+return 1
+`)
+
+// Reconciler implements ModeReconciled. Its limiter records each admission
+// as a charge owed to every peer region, and Run delivers the charges.
+type Reconciler struct {
+	local  *redis.Client
+	peers  map[string]*redis.Client
+	logger *zap.Logger
+}
+
+// NewReconciler takes the region's own Redis and the peer regions' Redis
+// clients by region name.
+func NewReconciler(local *redis.Client, peers map[string]*redis.Client, logger *zap.Logger) *Reconciler {
+	return &Reconciler{local: local, peers: peers, logger: logger}
+}
+
+// Limiter returns the limiter for this region's requests.
+func (r *Reconciler) Limiter() *RedisRateLimiter {
+	l := NewRedisRateLimiter(r.local)
+	for region := range r.peers {
+		l.pendingKeys = append(l.pendingKeys, pendingKeyPrefix+region)
+	}
+	return l
+}
+
+// Run delivers pending charges to the peers every interval until ctx is
+// done.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for region, peer := range r.peers {
+				r.reconcile(ctx, region, peer)
+			}
+		}
+	}
+}
+
+// reconcile charges region's buckets with this region's admissions since
+// the last round. Charges that can't be delivered are put back for the
+// next round, and show up as drift until they are.
+func (r *Reconciler) reconcile(ctx context.Context, region string, peer *redis.Client) {
+	pendingKey := pendingKeyPrefix + region
+	charges, err := takePendingScript.Run(ctx, r.local, []string{pendingKey}).StringSlice()
+	if err != nil {
+		r.logger.Warn("Failed to read pending rate limit charges", zap.String("region", region), zap.Error(err))
+		return
+	}
+
+	var drift int64
+	// EvalSha can't fall back to Eval inside a pipeline, so send the
+	// script itself.
+	pipe := peer.Pipeline()
+	cmds := make([]*redis.Cmd, 0, len(charges)/2)
+	amounts := make([]int64, 0, len(charges)/2)
+	for i := 0; i+1 < len(charges); i += 2 {
+		n, _ := strconv.ParseInt(charges[i+1], 10, 64)
+		drift += n
+		cmds = append(cmds, chargeScript.Eval(ctx, pipe, []string{keyPrefix + charges[i]}, n))
+		amounts = append(amounts, n)
+	}
+	metrics.RateLimitDrift.WithLabelValues(region).Set(float64(drift))
+	if drift == 0 {
+		return
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		r.logger.Warn("Failed to deliver rate limit charges", zap.String("region", region), zap.Error(err))
+		// Put back only the charges that failed; the rest were applied
+		// and would be charged twice.
+		restore := r.local.Pipeline()
+		for i, cmd := range cmds {
+			if err := cmd.Err(); err != nil && err != redis.Nil {
+				restore.HIncrBy(ctx, pendingKey, charges[2*i], amounts[i])
+			}
+		}
+		restore.Exec(ctx)
+	}
+}
@@ -0,0 +1,97 @@
+package reqctx
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+// GinKey is the gin context key the RequestContext is stored under.
+const GinKey = "request_context"
+
+// gRPC metadata keys backends read the caller identity from.
+const (
+	MetadataTenantID  = "x-tenant-id"
+	MetadataUserID    = "x-user-id"
+	MetadataRoles     = "x-user-roles"
+	MetadataRequestID = "x-request-id"
+	MetadataClientIP  = "x-client-ip"
+)
+
+// RequestContext is the caller identity resolved once per request by the
+// auth middleware and carried to every downstream call.
+type RequestContext struct {
+	TenantID  string
+	UserID    string
+	Roles     []string
+	RequestID string
+	ClientIP  string
+}
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying rc.
+func WithContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, rc)
+}
+
+// FromContext returns the RequestContext carried by ctx, if any.
+func FromContext(ctx context.Context) (*RequestContext, bool) {
+	rc, ok := ctx.Value(contextKey{}).(*RequestContext)
+	return rc, ok
+}
+
+// Attach stores rc on both the gin context and the request's context.Context.
+func Attach(c *gin.Context, rc *RequestContext) {
+	c.Set(GinKey, rc)
+	c.Request = c.Request.WithContext(WithContext(c.Request.Context(), rc))
+}
+
+// FromGin returns the RequestContext stored by Attach, if any.
+func FromGin(c *gin.Context) (*RequestContext, bool) {
+	value, ok := c.Get(GinKey)
+	if !ok {
+		return nil, false
+	}
+	rc, ok := value.(*RequestContext)
+	return rc, ok
+}
+
+// Metadata renders rc as gRPC metadata. Empty fields are omitted.
+func (rc *RequestContext) Metadata() metadata.MD {
+	md := metadata.MD{}
+	set := func(key, value string) {
+		if value != "" {
+			md.Set(key, value)
+		}
+	}
+	set(MetadataTenantID, rc.TenantID)
+	set(MetadataUserID, rc.UserID)
+	set(MetadataRoles, strings.Join(rc.Roles, ","))
+	set(MetadataRequestID, rc.RequestID)
+	set(MetadataClientIP, rc.ClientIP)
+	return md
+}
+
+// identityKeys are the metadata keys only the gateway may set.
+var identityKeys = []string{MetadataTenantID, MetadataUserID, MetadataRoles, MetadataClientIP}
+
+// OutgoingContext returns ctx with its RequestContext, if any, set as
+// outgoing gRPC metadata. Identity keys are removed first, whether or not
+// ctx carries a RequestContext, so forwarded client headers can never
+// supply or override the identity the gateway resolved.
+func OutgoingContext(ctx context.Context) context.Context {
+	existing, _ := metadata.FromOutgoingContext(ctx)
+	md := existing.Copy()
+	for _, key := range identityKeys {
+		delete(md, key)
+	}
+	if rc, ok := FromContext(ctx); ok {
+		for key, values := range rc.Metadata() {
+			md[key] = values
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,133 @@
+// Package routeswitch disables individual routes at runtime, for taking
+// an expensive endpoint out of service during an incident without a
+// redeploy.
+package routeswitch
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// disabledKey is the Redis hash of disabled routes, keyed by Key.
+const disabledKey = "route:disabled"
+
+// Switch records why and by whom a route was disabled.
+type Switch struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Reason     string    `json:"reason"`
+	DisabledBy string    `json:"disabled_by,omitempty"`
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+// Key identifies a route by method and gin route template, e.g.
+// "POST /api/v1/compliance/reports".
+func Key(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// Store keeps the disabled routes in Redis so every replica sees them.
+// Requests are checked against a copy held in memory, which Run refreshes,
+// so checking costs no round trip; a replica learns of another's change
+// within the refresh interval.
+type Store struct {
+	client *redis.Client
+	logger *zap.Logger
+
+	disabled atomic.Pointer[map[string]Switch]
+}
+
+func NewStore(client *redis.Client, logger *zap.Logger) *Store {
+	s := &Store{client: client, logger: logger}
+	s.disabled.Store(&map[string]Switch{})
+	return s
+}
+
+// Disabled returns the switch that disabled the route, if it is disabled.
+func (s *Store) Disabled(method, path string) (Switch, bool) {
+	sw, ok := (*s.disabled.Load())[Key(method, path)]
+	return sw, ok
+}
+
+// List returns the disabled routes as last refreshed, ordered by route.
+func (s *Store) List() []Switch {
+	disabled := *s.disabled.Load()
+	switches := make([]Switch, 0, len(disabled))
+	for _, sw := range disabled {
+		switches = append(switches, sw)
+	}
+	sort.Slice(switches, func(i, j int) bool {
+		return Key(switches[i].Method, switches[i].Path) < Key(switches[j].Method, switches[j].Path)
+	})
+	return switches
+}
+
+// Disable disables sw's route on every replica.
+func (s *Store) Disable(ctx context.Context, sw Switch) error {
+	sw.Method = strings.ToUpper(sw.Method)
+	data, err := json.Marshal(sw)
+	if err != nil {
+		return err
+	}
+	if err := s.client.HSet(ctx, disabledKey, Key(sw.Method, sw.Path), data).Err(); err != nil {
+		return err
+	}
+	return s.Refresh(ctx)
+}
+
+// Enable re-enables the route, returning whether it was disabled.
+func (s *Store) Enable(ctx context.Context, method, path string) (bool, error) {
+	n, err := s.client.HDel(ctx, disabledKey, Key(method, path)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, s.Refresh(ctx)
+}
+
+// Refresh reloads the disabled routes from Redis. On failure the previous
+// copy is kept. An entry that can't be decoded is logged and skipped, so
+// one bad write doesn't freeze every other switch.
+func (s *Store) Refresh(ctx context.Context) error {
+	entries, err := s.client.HGetAll(ctx, disabledKey).Result()
+	if err != nil {
+		return err
+	}
+	disabled := make(map[string]Switch, len(entries))
+	for key, data := range entries {
+		var sw Switch
+		if err := json.Unmarshal([]byte(data), &sw); err != nil {
+			s.logger.Warn("Skipping corrupt route switch", zap.String("route", key), zap.Error(err))
+			continue
+		}
+		disabled[key] = sw
+	}
+	s.disabled.Store(&disabled)
+	return nil
+}
+
+// Run refreshes the disabled routes every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	if err := s.Refresh(ctx); err != nil {
+		s.logger.Warn("Failed to load disabled routes", zap.Error(err))
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.Warn("Failed to refresh disabled routes", zap.Error(err))
+			}
+		}
+	}
+}
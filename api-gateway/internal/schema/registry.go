@@ -0,0 +1,303 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Operation is the parsed contract for one method+path in the spec.
+type Operation struct {
+	Method      string
+	Path        string
+	RequestBody *Schema
+	// Query maps the operation's query parameters, including those declared
+	// on its path, to their schemas.
+	Query map[string]*Schema
+	// Responses maps a status code ("200", "default") to its JSON body schema.
+	Responses map[string]*Schema
+}
+
+// Registry is the single parsed copy of the OpenAPI spec shared by request
+// validation and response transformation. It is safe for concurrent use.
+type Registry struct {
+	specPath string
+	logger   *zap.Logger
+
+	mu         sync.RWMutex
+	operations map[string]*Operation
+	modTime    time.Time
+}
+
+// NewRegistry loads the spec at specPath. The returned registry is usable
+// even when the initial load fails; it is simply empty until a reload succeeds.
+func NewRegistry(specPath string, logger *zap.Logger) (*Registry, error) {
+	r := &Registry{
+		specPath:   specPath,
+		logger:     logger,
+		operations: make(map[string]*Operation),
+	}
+	return r, r.Reload()
+}
+
+// Lookup returns the operation for a request, keyed by method and gin route.
+func (r *Registry) Lookup(method, route string) (*Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	op, ok := r.operations[OperationKey(method, route)]
+	return op, ok
+}
+
+// Operations returns every operation in the spec.
+func (r *Registry) Operations() []*Operation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ops := make([]*Operation, 0, len(r.operations))
+	for _, op := range r.operations {
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// Reload re-parses the spec. On failure the previously loaded operations are
+// kept and the failure is counted.
+func (r *Registry) Reload() error {
+	info, err := os.Stat(r.specPath)
+	if err != nil {
+		metrics.SchemaLoadFailures.Inc()
+		return fmt.Errorf("failed to stat spec %s: %w", r.specPath, err)
+	}
+
+	operations, err := parseSpec(r.specPath)
+	if err != nil {
+		metrics.SchemaLoadFailures.Inc()
+		return err
+	}
+
+	r.mu.Lock()
+	r.operations = operations
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch polls the spec file and reloads it whenever it changes, until ctx is
+// cancelled. Polling rather than inotify keeps this working with Kubernetes
+// ConfigMap mounts, which swap symlinks instead of writing the file.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.specPath)
+			if err != nil {
+				continue
+			}
+			r.mu.RLock()
+			changed := !info.ModTime().Equal(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				r.logger.Error("Failed to reload OpenAPI spec", zap.String("path", r.specPath), zap.Error(err))
+				continue
+			}
+			r.logger.Info("Reloaded OpenAPI spec", zap.String("path", r.specPath))
+		}
+	}
+}
+
+// OperationKey identifies an operation independently of path parameter
+// names, so spec and gin templates for the same route compare equal.
+func OperationKey(method, path string) string {
+	return strings.ToUpper(method) + " " + normalizePath(path)
+}
+
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+func parseSpec(path string) (map[string]*Operation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec %s: %w", path, err)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse spec %s: %w", path, err)
+	}
+
+	p := &parser{root: doc, refs: make(map[string]*Schema)}
+	operations := make(map[string]*Operation)
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	for route, item := range paths {
+		pathItem, _ := p.resolve(item).(map[string]interface{})
+		for _, method := range specMethods {
+			opNode, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op := &Operation{
+				Method:      strings.ToUpper(method),
+				Path:        route,
+				RequestBody: p.contentSchema(opNode["requestBody"]),
+				Responses:   make(map[string]*Schema),
+				Query:       make(map[string]*Schema),
+			}
+			for _, params := range []interface{}{pathItem["parameters"], opNode["parameters"]} {
+				list, _ := params.([]interface{})
+				for _, param := range list {
+					paramNode, _ := p.resolve(param).(map[string]interface{})
+					name, _ := paramNode["name"].(string)
+					if in, _ := paramNode["in"].(string); in == "query" && name != "" {
+						op.Query[name] = p.schema(paramNode["schema"])
+					}
+				}
+			}
+			responses, _ := opNode["responses"].(map[string]interface{})
+			for status, resp := range responses {
+				if s := p.contentSchema(resp); s != nil {
+					op.Responses[status] = s
+				}
+			}
+			operations[OperationKey(method, route)] = op
+		}
+	}
+	return operations, nil
+}
+
+type parser struct {
+	root map[string]interface{}
+	// refs memoizes resolved component schemas so shared and recursive
+	// references map to a single *Schema.
+	refs map[string]*Schema
+}
+
+// resolve follows a local "$ref" JSON pointer, if node is a reference.
+func (p *parser) resolve(node interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return node
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return node
+		}
+		node = p.lookupRef(ref)
+	}
+	return nil
+}
+
+func (p *parser) lookupRef(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node interface{} = p.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = m[strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")]
+	}
+	return node
+}
+
+// contentSchema extracts the application/json schema from a request body or
+// response object.
+func (p *parser) contentSchema(node interface{}) *Schema {
+	obj, ok := p.resolve(node).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	content, _ := obj["content"].(map[string]interface{})
+	media, _ := content["application/json"].(map[string]interface{})
+	if media == nil {
+		return nil
+	}
+	return p.schema(media["schema"])
+}
+
+func (p *parser) schema(node interface{}) *Schema {
+	if m, ok := node.(map[string]interface{}); ok {
+		if ref, ok := m["$ref"].(string); ok {
+			if s, ok := p.refs[ref]; ok {
+				return s
+			}
+			s := &Schema{}
+			p.refs[ref] = s
+			p.fill(s, p.resolve(node))
+			return s
+		}
+	}
+	s := &Schema{}
+	p.fill(s, node)
+	return s
+}
+
+func (p *parser) fill(s *Schema, node interface{}) {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	s.Type, _ = m["type"].(string)
+	s.Format, _ = m["format"].(string)
+	s.Nullable, _ = m["nullable"].(bool)
+	if enum, ok := m["enum"].([]interface{}); ok {
+		s.Enum = enum
+	}
+	if required, ok := m["required"].([]interface{}); ok {
+		for _, name := range required {
+			if str, ok := name.(string); ok {
+				s.Required = append(s.Required, str)
+			}
+		}
+	}
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		s.Properties = make(map[string]*Schema, len(props))
+		for name, prop := range props {
+			s.Properties[name] = p.schema(prop)
+		}
+		if s.Type == "" {
+			s.Type = "object"
+		}
+	}
+	if additional, ok := m["additionalProperties"].(bool); ok {
+		s.AdditionalProperties = &additional
+	}
+	if items, ok := m["items"]; ok {
+		s.Items = p.schema(items)
+	}
+	// allOf is flattened into a single object schema, which covers how the
+	// spec composes shared envelopes with per-endpoint fields.
+	if allOf, ok := m["allOf"].([]interface{}); ok {
+		for _, part := range allOf {
+			sub := p.schema(part)
+			if sub.Type != "" {
+				s.Type = sub.Type
+			}
+			s.Required = append(s.Required, sub.Required...)
+			for name, prop := range sub.Properties {
+				if s.Properties == nil {
+					s.Properties = make(map[string]*Schema)
+				}
+				s.Properties[name] = prop
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema is the subset of OpenAPI 3 schema objects the gateway understands.
+// References are resolved at load time, so a Schema graph may be cyclic.
+type Schema struct {
+	Type                 string
+	Format               string
+	Nullable             bool
+	Required             []string
+	Properties           map[string]*Schema
+	AdditionalProperties *bool
+	Items                *Schema
+	Enum                 []interface{}
+}
+
+// ValidationError describes the first mismatch found by Validate.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// Validate checks a value decoded by encoding/json against the schema.
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate("", value)
+}
+
+func (s *Schema) validate(field string, value interface{}) error {
+	if s == nil {
+		return nil
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return &ValidationError{Field: field, Reason: "must not be null"}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		return &ValidationError{Field: field, Reason: "is not one of the allowed values"}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(field, "object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{Field: join(field, name), Reason: "is required"}
+			}
+		}
+		for name, v := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Field: join(field, name), Reason: "is not allowed"}
+				}
+				continue
+			}
+			if err := prop.validate(join(field, name), v); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return typeError(field, "array")
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return typeError(field, "string")
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return typeError(field, "integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return typeError(field, "number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(field, "boolean")
+		}
+	}
+	return nil
+}
+
+func typeError(field, expected string) error {
+	return &ValidationError{Field: field, Reason: "must be of type " + expected}
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePath maps both OpenAPI ("/users/{userId}") and gin ("/users/:id")
+// path templates to the same key, since parameter names differ between them.
+func normalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") ||
+			(strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")) {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,91 @@
+// Package secrets resolves secrets from mounted files, an external secret
+// store or, as a fallback, plain environment variables.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by a Provider that holds no secret by a name.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider is an external secret store, such as Vault or a cloud secrets
+// manager, that secrets are looked up in by their environment variable
+// name.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Loader resolves a secret named by its environment variable, e.g.
+// JWT_SECRET, from the first of:
+//
+//   - the file named by JWT_SECRET_FILE, as mounted by Docker or
+//     Kubernetes secrets;
+//   - the provider, if there is one;
+//   - the JWT_SECRET variable itself.
+type Loader struct {
+	provider Provider
+}
+
+// NewLoader returns a Loader that consults provider, which may be nil.
+func NewLoader(provider Provider) *Loader {
+	return &Loader{provider: provider}
+}
+
+// Lookup returns the secret name resolves to, and whether any source had
+// it. A _FILE variable naming an unreadable file, or a provider failure,
+// is an error rather than a fallback, so a broken mount can't slip by
+// unnoticed.
+func (l *Loader) Lookup(ctx context.Context, name string) (string, bool, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		// Editors and echo leave a trailing newline that is never part of
+		// the secret.
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	if l.provider != nil {
+		value, err := l.provider.Get(ctx, name)
+		if err == nil {
+			return value, true, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", false, fmt.Errorf("failed to look up %s: %w", name, err)
+		}
+	}
+	if value := os.Getenv(name); value != "" {
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+// Watch looks name up every interval until ctx is done, and calls onChange
+// with each new non-empty value. Failed lookups are passed to onError and
+// keep the current value.
+func (l *Loader) Watch(ctx context.Context, name, current string, interval time.Duration,
+	onChange func(value string), onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		value, ok, err := l.Lookup(ctx, name)
+		switch {
+		case err != nil:
+			onError(err)
+		case ok && value != "" && value != current:
+			current = value
+			onChange(value)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package servertiming records where a request's time goes, across the
+// middleware chain and the proxy, for the Server-Timing response header
+// (https://www.w3.org/TR/server-timing/).
+package servertiming
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upstream is the metric backend calls are recorded under. The gateway's
+// own overhead is reported as the total less upstream time.
+const Upstream = "upstream"
+
+type timingsKey struct{}
+
+// Timings accumulates named durations for one request. Spans recorded
+// under the same name add up, so concurrent backend calls can sum to more
+// than the request took.
+type Timings struct {
+	start time.Time
+
+	mu    sync.Mutex
+	names []string
+	durs  map[string]time.Duration
+}
+
+// New returns a context under which Start records into the returned
+// Timings, whose total runs from now.
+func New(ctx context.Context) (context.Context, *Timings) {
+	t := &Timings{start: time.Now(), durs: make(map[string]time.Duration)}
+	return context.WithValue(ctx, timingsKey{}, t), t
+}
+
+// Start begins timing name on ctx's Timings, if any, and returns the
+// function that records it.
+func Start(ctx context.Context, name string) func() {
+	t, ok := ctx.Value(timingsKey{}).(*Timings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.add(name, time.Since(start)) }
+}
+
+func (t *Timings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.durs[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.durs[name] += d
+}
+
+// Header renders the recorded metrics, in the order first recorded,
+// followed by gateway (the total less upstream time) and total, e.g.
+// "auth;dur=2.1, upstream;dur=45.3, gateway;dur=3.2, total;dur=48.5".
+func (t *Timings) Header() string {
+	total := time.Since(t.start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := make([]string, 0, len(t.names)+2)
+	for _, name := range t.names {
+		parts = append(parts, metric(name, t.durs[name]))
+	}
+	gateway := total - t.durs[Upstream]
+	if gateway < 0 {
+		gateway = 0
+	}
+	parts = append(parts, metric("gateway", gateway), metric("total", total))
+	return strings.Join(parts, ", ")
+}
+
+func metric(name string, d time.Duration) string {
+	return name + ";dur=" + strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 1, 64)
+}
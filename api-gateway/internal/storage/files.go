@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/httpclient"
+	"dharmaguard/api-gateway/internal/reqctx"
+)
+
+var (
+	ErrFileNotFound = errors.New("file not found")
+	ErrFileDenied   = errors.New("access to file denied")
+)
+
+// File is the file service's description of a stored file. ObjectKey is
+// empty for files that are not in object storage. DownloadURL is set when
+// the file service signs its own download URL for the file instead; the
+// gateway fetches it for the client rather than handing it over.
+type File struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	ObjectKey   string `json:"object_key"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// FileClient asks the service that owns files whether the caller may read
+// one, and where it is stored. The caller's identity is taken from the
+// RequestContext on ctx and sent as headers along with a gateway identity
+// token, so the service makes the authorization decision.
+type FileClient struct {
+	baseURL    string
+	audience   string
+	httpClient *http.Client
+	signer     *auth.InternalTokenSigner
+}
+
+func NewFileClient(baseURL, audience string, signer *auth.InternalTokenSigner) *FileClient {
+	return &FileClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		audience:   audience,
+		httpClient: httpclient.New(5 * time.Second),
+		signer:     signer,
+	}
+}
+
+// Lookup returns the file if the caller may read it.
+func (c *FileClient) Lookup(ctx context.Context, fileID string) (*File, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/"+url.PathEscape(fileID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	httpclient.SetDeadlineHeader(req)
+
+	rc, ok := reqctx.FromContext(ctx)
+	if !ok {
+		rc = &reqctx.RequestContext{}
+	}
+	for key, values := range rc.Metadata() {
+		req.Header.Set(key, strings.Join(values, ","))
+	}
+	if c.signer != nil {
+		token, err := c.signer.Mint(c.audience, rc.UserID, rc.TenantID, rc.Roles, rc.RequestID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mint internal token: %w", err)
+		}
+		req.Header.Set("X-Gateway-Token", token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrFileNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrFileDenied
+	default:
+		return nil, fmt.Errorf("file service returned %d", resp.StatusCode)
+	}
+
+	var file File
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("decode file metadata: %w", err)
+	}
+	return &file, nil
+}
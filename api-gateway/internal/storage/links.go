@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"dharmaguard/api-gateway/internal/httpclient"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const linkKeyPrefix = "storage:link:"
+
+var (
+	ErrLinkNotFound = errors.New("download link not found or expired")
+	// ErrLinkExpired means the file service handed over a signed URL that
+	// had already expired.
+	ErrLinkExpired = errors.New("backend download URL has expired")
+)
+
+// Link is a backend-issued download URL the gateway fetches on the
+// client's behalf. The URL never reaches the client.
+type Link struct {
+	URL         string `json:"url"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// LinkStore issues short-lived gateway download links for backend URLs.
+// A link is an unguessable token resolving, in Redis, to the backend URL
+// until it expires; fetching it needs no other credential, like a
+// presigned URL. Backend URLs are fetched through the outbound guard, so
+// a URL the file service returns can't point the gateway at internal
+// addresses outside the allowlist.
+type LinkStore struct {
+	client     *redis.Client
+	httpClient *http.Client
+}
+
+func NewLinkStore(client *redis.Client) *LinkStore {
+	// No overall timeout: downloads may be large. The download route is
+	// exempt from the request timeout and watchdog too, so a transfer ends
+	// with the client's request.
+	return &LinkStore{client: client, httpClient: httpclient.New(0)}
+}
+
+// Issue stores link for at most ttl, or until the backend URL's own
+// signature expires if that is sooner, and returns its token and expiry.
+func (s *LinkStore) Issue(ctx context.Context, link Link, ttl time.Duration, now time.Time) (string, time.Time, error) {
+	u, err := url.Parse(link.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", time.Time{}, fmt.Errorf("invalid backend download URL")
+	}
+	expiresAt := now.Add(ttl)
+	if backendExpiry, ok := signedURLExpiry(u.Query()); ok {
+		if !backendExpiry.After(now) {
+			return "", time.Time{}, ErrLinkExpired
+		}
+		if backendExpiry.Before(expiresAt) {
+			expiresAt = backendExpiry
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	data, err := json.Marshal(link)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.client.Set(ctx, linkKeyPrefix+token, data, expiresAt.Sub(now)).Err(); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Resolve returns the link for token while it is valid.
+func (s *LinkStore) Resolve(ctx context.Context, token string) (*Link, error) {
+	data, err := s.client.Get(ctx, linkKeyPrefix+token).Bytes()
+	if err == redis.Nil {
+		return nil, ErrLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var link Link
+	if err := json.Unmarshal(data, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// Open fetches the link's backend URL, passing on the client's Range and
+// conditional headers.
+func (s *LinkStore) Open(ctx context.Context, link *Link, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	return s.httpClient.Do(req)
+}
+
+// ContentDisposition is the attachment header for filename.
+func ContentDisposition(filename string) string {
+	return contentDisposition(filename)
+}
+
+// signedURLExpiry reads when a signed URL stops working from its query:
+// SigV4 (X-Amz-Date plus X-Amz-Expires), GCS V4 (X-Goog-Date plus
+// X-Goog-Expires), Azure SAS (se), or a Unix Expires as S3 SigV2 and
+// CloudFront use.
+func signedURLExpiry(q url.Values) (time.Time, bool) {
+	for _, p := range []string{"X-Amz", "X-Goog"} {
+		if date, expires := q.Get(p+"-Date"), q.Get(p+"-Expires"); date != "" && expires != "" {
+			t, err := time.Parse("20060102T150405Z", date)
+			secs, err2 := strconv.Atoi(expires)
+			if err == nil && err2 == nil {
+				return t.Add(time.Duration(secs) * time.Second), true
+			}
+		}
+	}
+	if se := q.Get("se"); se != "" {
+		if t, err := time.Parse(time.RFC3339, se); err == nil {
+			return t, true
+		}
+	}
+	if expires := q.Get("Expires"); expires != "" {
+		if secs, err := strconv.ParseInt(expires, 10, 64); err == nil {
+			return time.Unix(secs, 0), true
+		}
+	}
+	return time.Time{}, false
+}
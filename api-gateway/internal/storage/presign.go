@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxURLTTL is the longest lifetime SigV4 allows for a presigned URL.
+const MaxURLTTL = 7 * 24 * time.Hour
+
+// Presigner issues AWS Signature V4 presigned GET URLs for an S3-compatible
+// bucket. Each URL is valid for one object only and expires after its TTL;
+// nothing is stored server-side.
+type Presigner struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+}
+
+// NewPresigner uses path-style URLs (endpoint/bucket/key), which S3 and
+// MinIO both accept.
+func NewPresigner(endpoint, region, bucket, accessKey, secretKey string) (*Presigner, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint %q", endpoint)
+	}
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("object storage bucket and credentials are required")
+	}
+	return &Presigner{endpoint: u, region: region, bucket: bucket, accessKey: accessKey, secretKey: secretKey}, nil
+}
+
+// PresignGet returns a URL for downloading key until now+ttl. A non-empty
+// filename is served as the attachment name.
+func (p *Presigner) PresignGet(key, filename string, ttl time.Duration, now time.Time) (string, error) {
+	if ttl <= 0 || ttl > MaxURLTTL {
+		return "", fmt.Errorf("presigned URL TTL must be between 1s and %s", MaxURLTTL)
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	scope := day + "/" + p.region + "/s3/aws4_request"
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    p.accessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       fmt.Sprintf("%d", int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if filename != "" {
+		query["response-content-disposition"] = contentDisposition(filename)
+	}
+
+	path := strings.TrimRight(p.endpoint.Path, "/") + "/" + uriEncode(p.bucket, false) + "/" + uriEncode(key, true)
+	canonicalQuery := canonicalQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		path,
+		canonicalQuery,
+		"host:" + p.endpoint.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+p.secretKey), day)
+	signingKey = hmacSHA256(signingKey, p.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return p.endpoint.Scheme + "://" + p.endpoint.Host + path + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQueryString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = uriEncode(k, false) + "=" + uriEncode(params[k], false)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters,
+// as SigV4 requires, optionally leaving "/" intact for object keys.
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func contentDisposition(filename string) string {
+	return `attachment; filename*=UTF-8''` + uriEncode(filename, false)
+}
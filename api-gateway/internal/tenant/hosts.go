@@ -0,0 +1,135 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const hostPrefix = "tenant:host:"
+
+// HostMapping assigns a hostname, or a "*.example.com" wildcard, to a
+// tenant.
+type HostMapping struct {
+	TenantID string    `json:"tenant_id"`
+	Reason   string    `json:"reason,omitempty"`
+	SetBy    string    `json:"set_by,omitempty"`
+	SetAt    time.Time `json:"set_at"`
+}
+
+// HostStore keeps hostname to tenant mappings in Redis.
+type HostStore struct {
+	client *redis.Client
+}
+
+func NewHostStore(client *redis.Client) *HostStore {
+	return &HostStore{client: client}
+}
+
+// Get returns the mapping for host, or nil if the host isn't mapped.
+func (s *HostStore) Get(ctx context.Context, host string) (*HostMapping, error) {
+	raw, err := s.client.Get(ctx, hostPrefix+host).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m HostMapping
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("corrupt tenant host mapping: %w", err)
+	}
+	return &m, nil
+}
+
+// Set creates or replaces the mapping for host.
+func (s *HostStore) Set(ctx context.Context, host string, m HostMapping) error {
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, hostPrefix+host, raw, 0).Err()
+}
+
+// Delete removes the mapping for host.
+func (s *HostStore) Delete(ctx context.Context, host string) error {
+	return s.client.Del(ctx, hostPrefix+host).Err()
+}
+
+// NormalizeHost lowercases host and drops any port and trailing dot, so
+// "API.Acme.example.com.:443" and "api.acme.example.com" map alike.
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// HostResolver finds the tenant a request's Host belongs to, from the
+// static map in the config first and then the Redis store. Mappings are
+// by exact hostname or by wildcard: "*.acme.example.com" covers every
+// subdomain of acme.example.com at any depth, and the most specific
+// mapping wins. Shared hosts belong to no tenant and are left to the other
+// resolvers.
+type HostResolver struct {
+	static map[string]string
+	shared map[string]bool
+	store  *HostStore
+}
+
+func NewHostResolver(static map[string]string, shared []string, store *HostStore) *HostResolver {
+	r := &HostResolver{
+		static: make(map[string]string, len(static)),
+		shared: make(map[string]bool, len(shared)),
+		store:  store,
+	}
+	for host, tenantID := range static {
+		r.static[NormalizeHost(host)] = tenantID
+	}
+	for _, host := range shared {
+		r.shared[NormalizeHost(host)] = true
+	}
+	return r
+}
+
+// Resolve returns the tenant mapped to host. known is false when host is
+// neither mapped nor shared, i.e. not a host the gateway serves.
+func (r *HostResolver) Resolve(ctx context.Context, host string) (tenantID string, known bool, err error) {
+	host = NormalizeHost(host)
+	if r.shared[host] {
+		return "", true, nil
+	}
+	for _, candidate := range hostCandidates(host) {
+		if tenantID, ok := r.static[candidate]; ok {
+			return tenantID, true, nil
+		}
+		if r.store == nil {
+			continue
+		}
+		m, err := r.store.Get(ctx, candidate)
+		if err != nil {
+			return "", false, err
+		}
+		if m != nil {
+			return m.TenantID, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// hostCandidates lists the mappings that could cover host, most specific
+// first: the host itself, then a wildcard for each parent domain with at
+// least two labels.
+func hostCandidates(host string) []string {
+	candidates := []string{host}
+	labels := strings.Split(host, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		candidates = append(candidates, "*."+strings.Join(labels[i:], "."))
+	}
+	return candidates
+}
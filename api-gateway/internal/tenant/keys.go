@@ -0,0 +1,62 @@
+// Package tenant maps legacy API keys and custom hostnames to the tenant
+// they act for.
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const keyPrefix = "tenant:api_key:"
+
+// KeyMapping assigns an API key, identified by its metering.KeyID
+// fingerprint, to a tenant.
+type KeyMapping struct {
+	TenantID string    `json:"tenant_id"`
+	Reason   string    `json:"reason,omitempty"`
+	SetBy    string    `json:"set_by,omitempty"`
+	SetAt    time.Time `json:"set_at"`
+}
+
+// KeyStore keeps API key to tenant mappings in Redis.
+type KeyStore struct {
+	client *redis.Client
+}
+
+func NewKeyStore(client *redis.Client) *KeyStore {
+	return &KeyStore{client: client}
+}
+
+// Get returns the mapping for keyID, or nil if the key isn't mapped.
+func (s *KeyStore) Get(ctx context.Context, keyID string) (*KeyMapping, error) {
+	raw, err := s.client.Get(ctx, keyPrefix+keyID).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m KeyMapping
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("corrupt tenant key mapping: %w", err)
+	}
+	return &m, nil
+}
+
+// Set creates or replaces the mapping for keyID.
+func (s *KeyStore) Set(ctx context.Context, keyID string, m KeyMapping) error {
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, keyPrefix+keyID, raw, 0).Err()
+}
+
+// Delete removes the mapping for keyID.
+func (s *KeyStore) Delete(ctx context.Context, keyID string) error {
+	return s.client.Del(ctx, keyPrefix+keyID).Err()
+}
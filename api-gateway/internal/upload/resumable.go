@@ -0,0 +1,260 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const resumableKeyPrefix = "upload:resumable:"
+
+var (
+	ErrSessionNotFound = errors.New("upload session not found")
+	ErrOffsetMismatch  = errors.New("upload offset does not match")
+	ErrSessionBusy     = errors.New("upload session is being written")
+	ErrChunkTooLarge   = errors.New("chunk exceeds declared upload length")
+)
+
+// releaseLockScript deletes the lock KEYS[1] only if it still holds the
+// token ARGV[1].
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Session is a resumable upload in progress.
+type Session struct {
+	ID          string    `json:"id"`
+	Length      int64     `json:"length"`
+	Offset      int64     `json:"offset"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	UserID      string    `json:"user_id"`
+	TenantID    string    `json:"tenant_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Complete reports whether every byte of the upload has arrived.
+func (s *Session) Complete() bool {
+	return s.Offset == s.Length
+}
+
+// ResumableStore tracks resumable uploads: session state lives in Redis and
+// received bytes are staged in dir. Replicas share sessions through Redis,
+// so dir must be a volume every replica mounts. Sessions expire ttl after
+// creation; Sweep removes their staged data.
+type ResumableStore struct {
+	client *redis.Client
+	dir    string
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewResumableStore(client *redis.Client, dir string, ttl time.Duration, logger *zap.Logger) *ResumableStore {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "dharmaguard-uploads")
+	}
+	return &ResumableStore{client: client, dir: dir, ttl: ttl, logger: logger}
+}
+
+func sessionKey(id string) string {
+	return resumableKeyPrefix + id
+}
+
+func (s *ResumableStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// Create starts a session for an upload of length bytes.
+func (s *ResumableStore) Create(ctx context.Context, session Session) (*Session, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	session.ID = hex.EncodeToString(b)
+	session.Offset = 0
+	session.ExpiresAt = time.Now().Add(s.ttl).UTC()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path(session.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	key := sessionKey(session.ID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"length":       session.Length,
+		"offset":       0,
+		"filename":     session.Filename,
+		"content_type": session.ContentType,
+		"user_id":      session.UserID,
+		"tenant_id":    session.TenantID,
+		"expires_at":   session.ExpiresAt.Unix(),
+	})
+	pipe.ExpireAt(ctx, key, session.ExpiresAt)
+	if _, err := pipe.Exec(ctx); err != nil {
+		os.Remove(s.path(session.ID))
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *ResumableStore) Get(ctx context.Context, id string) (*Session, error) {
+	values, err := s.client.HGetAll(ctx, sessionKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	length, _ := strconv.ParseInt(values["length"], 10, 64)
+	offset, _ := strconv.ParseInt(values["offset"], 10, 64)
+	expiresAt, _ := strconv.ParseInt(values["expires_at"], 10, 64)
+	return &Session{
+		ID:          id,
+		Length:      length,
+		Offset:      offset,
+		Filename:    values["filename"],
+		ContentType: values["content_type"],
+		UserID:      values["user_id"],
+		TenantID:    values["tenant_id"],
+		ExpiresAt:   time.Unix(expiresAt, 0).UTC(),
+	}, nil
+}
+
+// Append writes a chunk starting at offset, which must equal the session's
+// current offset. Bytes received before the client disconnects are kept, so
+// the next chunk resumes where this one stopped. It returns the updated
+// session.
+func (s *ResumableStore) Append(ctx context.Context, id string, offset int64, chunk io.Reader) (*Session, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	lockKey, token := sessionKey(id)+":lock", hex.EncodeToString(b)
+	locked, err := s.client.SetNX(ctx, lockKey, token, time.Minute).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrSessionBusy
+	}
+	// A chunk slower than the lock's expiry must not release the lock of
+	// the writer that took it over.
+	defer releaseLockScript.Run(context.Background(), s.client, []string{lockKey}, token)
+
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return session, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.path(id), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Discard anything past the recorded offset, e.g. from a write that
+	// failed before its offset was saved.
+	if err := f.Truncate(offset); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	remaining := session.Length - offset
+	written, copyErr := io.Copy(f, io.LimitReader(chunk, remaining+1))
+	if written > remaining {
+		f.Truncate(offset)
+		return session, ErrChunkTooLarge
+	}
+	if syncErr := f.Sync(); syncErr != nil && copyErr == nil {
+		copyErr = syncErr
+	}
+
+	session.Offset += written
+	if err := s.client.HSet(context.Background(), sessionKey(id), "offset", session.Offset).Err(); err != nil {
+		return nil, err
+	}
+	return session, copyErr
+}
+
+// Open returns the staged data of a session for reading.
+func (s *ResumableStore) Open(id string) (*os.File, error) {
+	return os.Open(s.path(id))
+}
+
+// Delete ends a session and removes its staged data.
+func (s *ResumableStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, sessionKey(id)).Err(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Sweep periodically removes staged data whose session has expired, until
+// ctx is cancelled.
+func (s *ResumableStore) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sweep(ctx); err != nil {
+				s.logger.Error("Failed to sweep expired uploads", zap.String("dir", s.dir), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *ResumableStore) sweep(ctx context.Context) error {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		// Skip files too new to have expired: their session may still be
+		// being created.
+		if err != nil || time.Since(info.ModTime()) < s.ttl {
+			continue
+		}
+		exists, err := s.client.Exists(ctx, sessionKey(entry.Name())).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			if err := os.Remove(filepath.Join(s.dir, entry.Name())); err == nil {
+				s.logger.Info("Removed expired upload", zap.String("upload_id", entry.Name()))
+			}
+		}
+	}
+	return nil
+}
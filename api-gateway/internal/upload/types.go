@@ -0,0 +1,76 @@
+package upload
+
+import (
+	"mime"
+	"strings"
+)
+
+// BaseType returns the lowercased media type of a Content-Type value,
+// without parameters.
+func BaseType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+// signatureTypes are the media types http.DetectContentType recognises by
+// signature. Content declared as one of them that sniffs as nothing in
+// particular isn't what it claims to be.
+var signatureTypes = map[string]bool{
+	"application/pdf": true, "application/postscript": true, "application/ogg": true,
+	"application/x-gzip": true, "application/zip": true, "application/x-rar-compressed": true,
+	"application/wasm": true, "application/vnd.ms-fontobject": true,
+	"image/x-icon": true, "image/bmp": true, "image/gif": true, "image/webp": true,
+	"image/png": true, "image/jpeg": true,
+	"audio/basic": true, "audio/aiff": true, "audio/mpeg": true, "audio/midi": true, "audio/wave": true,
+	"video/avi": true, "video/mp4": true, "video/webm": true,
+	"font/ttf": true, "font/otf": true, "font/collection": true, "font/woff": true, "font/woff2": true,
+}
+
+// Consistent reports whether a client-declared content type is plausible for
+// the sniffed one. http.DetectContentType only recognises a fixed set of
+// signatures, so content it can't identify is trusted to be of the declared
+// type only when that is a binary type without a signature; the caller
+// still checks the declared type is allowed.
+func Consistent(declared, sniffed string) bool {
+	declared, sniffed = BaseType(declared), BaseType(sniffed)
+	if declared == sniffed || declared == "application/octet-stream" {
+		return true
+	}
+	switch sniffed {
+	case "application/octet-stream":
+		return !isText(declared) && !signatureTypes[declared]
+	case "text/plain":
+		// Textual formats (CSV, JSON, XML) all sniff as text/plain.
+		return isText(declared)
+	case "application/zip":
+		// Office Open XML documents are zip containers.
+		return strings.HasPrefix(declared, "application/vnd.openxmlformats-officedocument.")
+	}
+	return false
+}
+
+// isText reports whether contentType is a textual format, which sniffs as
+// text/plain.
+func isText(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") || contentType == "application/json" ||
+		strings.HasSuffix(contentType, "+json") || strings.HasSuffix(contentType, "xml")
+}
+
+// Allowed reports whether contentType matches one of the allowed types.
+// Entries like "image/*" match every subtype.
+func Allowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	base := BaseType(contentType)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == base || (strings.HasSuffix(a, "/*") && strings.HasPrefix(base, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
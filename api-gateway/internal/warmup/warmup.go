@@ -0,0 +1,121 @@
+// Package warmup readies a freshly started replica for traffic: it
+// connects every gRPC backend, so the first client requests don't pay for
+// name resolution, TCP and TLS handshakes. It doesn't fill the response
+// cache: cache keys carry the client's tenant, Accept and Accept-Encoding,
+// which a warmup request can't stand in for.
+package warmup
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Report is the outcome of a warmup run: each backend's connection state
+// when it finished.
+type Report struct {
+	Complete    bool              `json:"complete"`
+	DurationMS  int64             `json:"duration_ms"`
+	Connections map[string]string `json:"connections"`
+}
+
+// Warmer runs warmups, one at a time.
+type Warmer struct {
+	conns  map[string]*grpc.ClientConn
+	cfg    config.WarmupConfig
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	ready atomic.Bool
+}
+
+// NewWarmer warms conns. With cfg.OnStartup the replica reports not ready
+// until a warmup has finished.
+func NewWarmer(conns map[string]*grpc.ClientConn, cfg config.WarmupConfig, logger *zap.Logger) *Warmer {
+	w := &Warmer{conns: conns, cfg: cfg, logger: logger}
+	w.ready.Store(!cfg.OnStartup)
+	return w
+}
+
+// Ready reports whether the replica is warm enough to take traffic.
+func (w *Warmer) Ready() bool {
+	return w.ready.Load()
+}
+
+// Gate answers 503 NOT_READY in place of the readiness check until the
+// first warmup finishes.
+func (w *Warmer) Gate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !w.Ready() {
+			apierror.Abort(c, http.StatusServiceUnavailable, "NOT_READY", "Warmup in progress")
+			return
+		}
+		c.Next()
+	}
+}
+
+// Run connects every backend within the configured timeout. The replica is
+// marked ready when it returns, even if a backend didn't connect: that is
+// reported, and left to the readiness check and the circuit breakers.
+func (w *Warmer) Run(ctx context.Context) Report {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	defer w.ready.Store(true)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	report := Report{Complete: true, Connections: w.connect(ctx)}
+	for _, state := range report.Connections {
+		if state != connectivity.Ready.String() {
+			report.Complete = false
+		}
+	}
+	report.DurationMS = time.Since(start).Milliseconds()
+
+	log := w.logger.Info
+	if !report.Complete {
+		log = w.logger.Warn
+	}
+	log("Warmup finished", zap.Bool("complete", report.Complete),
+		zap.Int64("duration_ms", report.DurationMS), zap.Any("report", report))
+	return report
+}
+
+// connect asks every connection to connect and waits, concurrently, until
+// each is ready, has failed, or ctx is done.
+func (w *Warmer) connect(ctx context.Context) map[string]string {
+	states := make(map[string]string, len(w.conns))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, conn := range w.conns {
+		wg.Add(1)
+		go func(name string, conn *grpc.ClientConn) {
+			defer wg.Done()
+			conn.Connect()
+			state := conn.GetState()
+			for state != connectivity.Ready && state != connectivity.TransientFailure && state != connectivity.Shutdown {
+				if !conn.WaitForStateChange(ctx, state) {
+					break
+				}
+				state = conn.GetState()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			states[name] = state.String()
+		}(name, conn)
+	}
+	wg.Wait()
+	return states
+}
@@ -0,0 +1,105 @@
+package wsconn
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"dharmaguard/api-gateway/internal/config"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Subprotocols a client may offer to choose the message encoding.
+const (
+	SubprotocolJSON    = "dharmaguard.json"
+	SubprotocolMsgpack = "dharmaguard.msgpack"
+)
+
+// Encoding is how a connection's events are serialized.
+type Encoding struct {
+	name string
+	// subprotocol is echoed in the handshake response; empty when the
+	// client offered none.
+	subprotocol string
+	messageType int
+	marshal     func(v interface{}) ([]byte, error)
+}
+
+var (
+	jsonEncoding         = &Encoding{name: "json", messageType: websocket.TextMessage, marshal: marshalJSON}
+	jsonProtocolEncoding = &Encoding{name: "json", subprotocol: SubprotocolJSON, messageType: websocket.TextMessage, marshal: marshalJSON}
+	msgpackEncoding      = &Encoding{name: "msgpack", subprotocol: SubprotocolMsgpack, messageType: websocket.BinaryMessage, marshal: marshalMsgpack}
+)
+
+// negotiate picks the first subprotocol the client offered that the
+// gateway serves, or JSON.
+func negotiate(r *http.Request, opts config.WebSocketConfig) *Encoding {
+	for _, offered := range websocket.Subprotocols(r) {
+		switch offered {
+		case SubprotocolJSON:
+			return jsonProtocolEncoding
+		case SubprotocolMsgpack:
+			if opts.Msgpack {
+				return msgpackEncoding
+			}
+		}
+	}
+	return jsonEncoding
+}
+
+func marshalJSON(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case proto.Message:
+		return protojson.Marshal(v)
+	case json.RawMessage:
+		return v, nil
+	}
+	return json.Marshal(v)
+}
+
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// marshalMsgpack encodes v through its JSON form, so messages carry the
+// same field names and shapes (timestamps as RFC 3339 strings) in either
+// encoding.
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	data, err := marshalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	var out []byte
+	err = codec.NewEncoderBytes(&out, msgpackHandle).Encode(numbers(value))
+	return out, err
+}
+
+// numbers replaces the json.Numbers in a decoded JSON value with int64s
+// where they fit and float64s otherwise, so msgpack gets compact integers.
+func numbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,280 @@
+// Package wsconn upgrades requests to WebSocket connections that write
+// events in the encoding the client negotiated.
+package wsconn
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited is returned by reads once the client has sent messages
+// faster than it may and the connection has been closed.
+var ErrRateLimited = errors.New("websocket message rate exceeded")
+
+// closeTimeout bounds writing the close frame to a client being
+// disconnected, and how long the client then has to answer it.
+const closeTimeout = time.Second
+
+var (
+	mu  sync.RWMutex
+	cfg = config.WebSocketConfig{Msgpack: true}
+)
+
+// Configure sets the options for connections upgraded afterwards. Call it
+// at startup, before serving.
+func Configure(c config.WebSocketConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+}
+
+// Conn is an upgraded WebSocket connection. WriteEvent may be called from
+// several goroutines; other data writes must not run alongside it, though
+// control frames (WriteControl) may. Reads must come from one goroutine
+// and go through NextReader, ReadMessage or ReadJSON, which enforce the
+// message size and rate limits. End a connection with SendClose, if the
+// gateway is the one ending it, and then Close once reads have stopped.
+type Conn struct {
+	*websocket.Conn
+	encoding *Encoding
+
+	mu        sync.Mutex
+	compress  bool
+	threshold int
+
+	maxBytes int64
+	limiter  *rate.Limiter
+	readErr  error
+	// closing is set once a close frame has been sent, and closed once the
+	// client has sent one.
+	closing atomic.Bool
+	closed  bool
+}
+
+// Upgrade upgrades the request to a WebSocket connection. The client picks
+// the message encoding by offering subprotocols; without an offer it gets
+// JSON. Compression is used when enabled and the client offers
+// permessage-deflate. The Origin header must already have been checked, as
+// middleware.WebSocketOrigin does.
+func Upgrade(c *gin.Context) (*Conn, error) {
+	mu.RLock()
+	opts := cfg
+	mu.RUnlock()
+
+	encoding := negotiate(c.Request, opts)
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       func(*http.Request) bool { return true },
+		EnableCompression: opts.Compression,
+	}
+	var header http.Header
+	if encoding.subprotocol != "" {
+		header = http.Header{"Sec-Websocket-Protocol": {encoding.subprotocol}}
+	}
+	w := &countingWriter{ResponseWriter: c.Writer, wire: metrics.WebSocketWireBytes.WithLabelValues(encoding.name)}
+	ws, err := upgrader.Upgrade(w, c.Request, header)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(int64(opts.MaxMessageBytes))
+	conn := &Conn{Conn: ws, encoding: encoding, maxBytes: int64(opts.MaxMessageBytes)}
+	if opts.MessageRate > 0 {
+		conn.limiter = rate.NewLimiter(rate.Limit(opts.MessageRate), opts.MessageBurst)
+	}
+	if opts.Compression {
+		if err := ws.SetCompressionLevel(opts.CompressionLevel); err != nil {
+			ws.Close()
+			return nil, err
+		}
+		conn.compress, conn.threshold = true, opts.CompressionThreshold
+	}
+	return conn, nil
+}
+
+// Encoding names the connection's message encoding, "json" or "msgpack".
+func (c *Conn) Encoding() string {
+	return c.encoding.name
+}
+
+// WriteEvent writes v as one message in the connection's encoding. v may be
+// a proto message or JSON bytes (json.RawMessage) as well as a value
+// encoding/json can marshal; msgpack messages keep the JSON field names.
+func (c *Conn) WriteEvent(v interface{}) error {
+	data, err := c.encoding.marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compress {
+		// A no-op unless the client negotiated compression.
+		c.EnableWriteCompression(len(data) >= c.threshold)
+	}
+	if err := c.WriteMessage(c.encoding.messageType, data); err != nil {
+		return err
+	}
+	metrics.WebSocketBytesSent.WithLabelValues(c.encoding.name).Add(float64(len(data)))
+	return nil
+}
+
+// Ping sends a ping, which keeps intermediaries from dropping a connection
+// that has been quiet for a while.
+func (c *Conn) Ping() error {
+	return c.WriteControl(websocket.PingMessage, nil, time.Now().Add(closeTimeout))
+}
+
+// SendClose starts closing the connection: it sends a close frame with
+// code and reason and leaves the client closeTimeout to answer, after
+// which reads fail.
+func (c *Conn) SendClose(code int, reason string) error {
+	c.closing.Store(true)
+	err := c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(closeTimeout))
+	c.SetReadDeadline(time.Now().Add(closeTimeout))
+	return err
+}
+
+// Close closes the connection. If a close frame was sent and the client
+// hasn't answered it, what the client still sends is discarded for up to
+// closeTimeout first: closing a socket with unread data resets it, and the
+// client would lose the close frame and its code. Close must not run
+// alongside a read.
+func (c *Conn) Close() error {
+	if c.closing.Load() && !c.closed {
+		raw := c.UnderlyingConn()
+		raw.SetReadDeadline(time.Now().Add(closeTimeout))
+		io.Copy(io.Discard, raw)
+	}
+	return c.Conn.Close()
+}
+
+// NextReader returns the next message from the client, as
+// websocket.Conn's does. A message over the size limit, counted after
+// decompression, fails, now or while it is read, with
+// websocket.ErrReadLimit after the connection is closed with 1009; one over
+// the rate limit closes it with 1008 and fails with ErrRateLimited. Either
+// way every later read fails too.
+func (c *Conn) NextReader() (int, io.Reader, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, r, err := c.Conn.NextReader()
+	if err != nil {
+		c.readFailed(err)
+		return 0, nil, err
+	}
+	if c.limiter != nil && !c.limiter.Allow() {
+		c.readErr = ErrRateLimited
+		metrics.WebSocketMessagesRejected.WithLabelValues("rate_limited").Inc()
+		c.SendClose(websocket.ClosePolicyViolation, "message rate exceeded")
+		return 0, nil, ErrRateLimited
+	}
+	return messageType, &limitedReader{Reader: r, conn: c}, nil
+}
+
+// ReadMessage reads the next message from the client in full, subject to
+// the limits NextReader enforces.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType, r, err := c.NextReader()
+	if err != nil {
+		return messageType, nil, err
+	}
+	data, err := io.ReadAll(r)
+	return messageType, data, err
+}
+
+// ReadJSON decodes the next message from the client as JSON into v,
+// subject to the limits NextReader enforces.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, r, err := c.NextReader()
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(r).Decode(v)
+	if err == io.EOF {
+		// One value is expected in the message.
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readFailed notes why a read failed: the client answered or sent a close
+// frame, or its message was too big on the wire, in which case
+// websocket.Conn has already sent the 1009 close frame.
+func (c *Conn) readFailed(err error) {
+	var closeErr *websocket.CloseError
+	switch {
+	case errors.As(err, &closeErr):
+		c.closed = true
+	case errors.Is(err, websocket.ErrReadLimit) && c.readErr == nil:
+		c.readErr = err
+		c.closing.Store(true)
+		metrics.WebSocketMessagesRejected.WithLabelValues("oversized").Inc()
+	}
+}
+
+// limitedReader enforces the size limit on a message as it is read.
+// websocket.Conn's own limit counts frame bytes, which with compression
+// are fewer than the message's.
+type limitedReader struct {
+	io.Reader
+	conn *Conn
+	read int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.conn.readErr != nil {
+		return 0, r.conn.readErr
+	}
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.conn.readFailed(err)
+	}
+	r.read += int64(n)
+	if limit := r.conn.maxBytes; limit > 0 && r.read > limit {
+		r.conn.readErr = websocket.ErrReadLimit
+		metrics.WebSocketMessagesRejected.WithLabelValues("oversized").Inc()
+		r.conn.SendClose(websocket.CloseMessageTooBig, "message too big")
+		return 0, websocket.ErrReadLimit
+	}
+	return n, err
+}
+
+// countingWriter hands the upgrade a socket that counts the bytes written
+// to it, so compression savings show against the message bytes.
+type countingWriter struct {
+	gin.ResponseWriter
+	wire prometheus.Counter
+}
+
+func (w *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &countingConn{Conn: conn, wire: w.wire}, rw, nil
+}
+
+type countingConn struct {
+	net.Conn
+	wire prometheus.Counter
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.wire.Add(float64(n))
+	return n, err
+}
@@ -12,6 +12,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
@@ -19,17 +20,37 @@ import (
 	"syscall"
 	"time"
 
+	"dharmaguard/api-gateway/internal/aggregate"
+	"dharmaguard/api-gateway/internal/apierror"
+	"dharmaguard/api-gateway/internal/audit"
 	"dharmaguard/api-gateway/internal/auth"
+	"dharmaguard/api-gateway/internal/authz"
+	"dharmaguard/api-gateway/internal/cache"
 	"dharmaguard/api-gateway/internal/config"
+	"dharmaguard/api-gateway/internal/drain"
+	"dharmaguard/api-gateway/internal/geoip"
 	"dharmaguard/api-gateway/internal/handlers"
+	"dharmaguard/api-gateway/internal/httpclient"
+	"dharmaguard/api-gateway/internal/inventory"
+	"dharmaguard/api-gateway/internal/jobs"
+	"dharmaguard/api-gateway/internal/loadshed"
 	"dharmaguard/api-gateway/internal/middleware"
+	"dharmaguard/api-gateway/internal/metering"
 	"dharmaguard/api-gateway/internal/metrics"
+	"dharmaguard/api-gateway/internal/notify"
 	"dharmaguard/api-gateway/internal/proxy"
+	"dharmaguard/api-gateway/internal/quota"
 	"dharmaguard/api-gateway/internal/ratelimit"
+	"dharmaguard/api-gateway/internal/routeswitch"
+	"dharmaguard/api-gateway/internal/schema"
+	"dharmaguard/api-gateway/internal/storage"
+	"dharmaguard/api-gateway/internal/tenant"
+	"dharmaguard/api-gateway/internal/upload"
+	"dharmaguard/api-gateway/internal/warmup"
+	"dharmaguard/api-gateway/internal/wsconn"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/jaeger"
@@ -38,14 +59,34 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 )
 
+// fileLinksPrefix is where download links are served; the token follows.
+const fileLinksPrefix = "/api/v1/files/links/"
+
 var (
 	logger           *zap.Logger
 	cfg             *config.Config
 	redisClient     *redis.Client
 	grpcConnections map[string]*grpc.ClientConn
+	upstreamTLS     map[string]*tls.Config
+	schemaRegistry  *schema.Registry
+	geoProvider      *geoip.MaxMindProvider
+	tokenSigner     *auth.InternalTokenSigner
+	resumableUploads *upload.ResumableStore
+	loadShedder      *loadshed.Shedder
+	drainer          *drain.Coordinator
+	authService      *auth.Service
+	sessionStore     *auth.SessionStore
+	rateLimiter      ratelimit.Limiter
+	jobRunner        *jobs.Runner
+	breakerFleet     *proxy.BreakerFleet
+	warmer           *warmup.Warmer
+	routeSwitches    *routeswitch.Store
 )
 
 func main() {
@@ -63,6 +104,17 @@ func main() {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
 
+	// Localized error messages
+	if cfg.Server.ErrorCatalogDir != "" {
+		catalogs, err := apierror.LoadCatalogs(cfg.Server.ErrorCatalogDir)
+		if err != nil {
+			logger.Fatal("Failed to load error catalogs", zap.Error(err))
+		}
+		apierror.SetCatalogs(catalogs)
+		logger.Info("Loaded error catalogs", zap.Int("locales", len(catalogs)))
+	}
+	apierror.SetOverloadRetryAfter(cfg.Server.OverloadRetryAfter)
+
 	// Initialize observability
 	if err := initTracing(); err != nil {
 		logger.Fatal("Failed to initialize tracing", zap.Error(err))
@@ -82,6 +134,28 @@ func main() {
 		logger.Fatal("Failed to connect to Redis", zap.Error(err))
 	}
 
+	// Initialize the signer for gateway-to-backend identity tokens
+	tokenSigner, err = auth.NewInternalTokenSigner(cfg.InternalAuth.KeyFile,
+		cfg.InternalAuth.TokenTTL,
+		cfg.InternalAuth.KeyRetention)
+	if err != nil {
+		logger.Fatal("Failed to load internal token signing key", zap.Error(err))
+	}
+	if cfg.InternalAuth.KeyFile == "" {
+		logger.Warn("INTERNAL_TOKEN_KEY_FILE not set; using an ephemeral signing key")
+	}
+
+	// Validate the gateway's own tokens, picking up rotations of the
+	// signing secret. Sessions live as long as their refresh token, and
+	// revoked ones stop validating
+	sessionStore = auth.NewSessionStore(redisClient, cfg.Session.MaxActive, cfg.Session.LimitPolicy,
+		time.Duration(cfg.Session.RefreshMaxAge)*time.Second)
+	authService = auth.NewService(cfg.JWT.Secret, cfg.JWT.Issuer, redisClient,
+		auth.WithLeeway(cfg.JWT.ClockSkew),
+		auth.WithLogger(logger),
+		auth.WithSessions(sessionStore),
+	)
+
 	// Initialize gRPC connections
 	if err := initGRPCConnections(); err != nil {
 		logger.Fatal("Failed to initialize gRPC connections", zap.Error(err))
@@ -91,8 +165,64 @@ func main() {
 	// Initialize metrics
 	metrics.InitMetrics()
 
+	// Load the OpenAPI spec once; validation and transformation share it
+	schemaRegistry, err = schema.NewRegistry(cfg.OpenAPI.SpecPath, logger)
+	if err != nil {
+		logger.Error("Failed to load OpenAPI spec", zap.Error(err))
+	}
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go schemaRegistry.Watch(watchCtx, cfg.OpenAPI.ReloadInterval)
+	go watchInternalTokenKey(watchCtx, cfg.InternalAuth.ReloadInterval)
+	go watchJWTSecret(watchCtx, cfg.Secrets.ReloadInterval)
+
+	// Geo/ASN lookups are served from memory-mapped databases
+	if cfg.GeoIP.Enabled {
+		geoProvider, err = geoip.NewMaxMindProvider(cfg.GeoIP.CountryDB, cfg.GeoIP.ASNDB, logger)
+		if err != nil {
+			logger.Error("Failed to load GeoIP databases", zap.Error(err))
+		}
+		defer geoProvider.Close()
+		go geoProvider.Watch(watchCtx, cfg.GeoIP.ReloadInterval)
+	}
+
+	// Resumable uploads stage on disk; sweep out the ones that expired
+	resumableUploads = upload.NewResumableStore(redisClient, cfg.Upload.ResumableDir,
+		cfg.Upload.ResumableTTL, logger)
+	go resumableUploads.Sweep(watchCtx, time.Hour)
+
+	// Shed load as the runtime nears its limits
+	loadShedder = loadshed.NewShedder(cfg.LoadShed, cfg.Routes)
+	go loadShedder.Run(watchCtx, time.Second)
+
+	// Enforce rate limits across regions as RATE_LIMIT_MODE says
+	rateLimiter = initRateLimiter(watchCtx)
+
+	// Stagger shutdowns with the other replicas
+	if cfg.Drain.Enabled {
+		drainer = drain.NewCoordinator(redisClient, cfg.Drain, logger)
+		go drainer.Run(watchCtx)
+	}
+
+	// Tell the other replicas when a circuit breaker opens
+	if cb := cfg.Services.CircuitBreaker; cb.Share {
+		breakerFleet = proxy.NewBreakerFleet(redisClient, cb.Caution,
+			cb.Heartbeat, logger)
+		go breakerFleet.Run(watchCtx)
+	}
+
+	// Routes disabled at runtime, shared by every replica
+	routeSwitches = routeswitch.NewStore(redisClient, logger)
+	go routeSwitches.Run(watchCtx, cfg.Server.RouteSwitchRefresh)
+
 	// Setup Gin router
 	router := setupRouter()
+	checkRouteDocumentation(router)
+
+	// Connect backends before reporting ready
+	if cfg.Warmup.OnStartup {
+		go warmer.Run(watchCtx)
+	}
 
 	// Start metrics server
 	go startMetricsServer()
@@ -100,10 +230,13 @@ func main() {
 	// Start main server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:      router,
-		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
-		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+		Handler: middleware.OverrideMethod(cfg.Server.MethodOverride,
+			middleware.NormalizePaths(cfg.Server.PathNormalization, router), logger),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
 	}
 
 	// Start server in goroutine
@@ -121,6 +254,10 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	if drainer != nil {
+		drainer.Drain(context.Background())
+	}
+
 	logger.Info("Shutting down server gracefully...")
 
 	// Graceful shutdown
@@ -130,6 +267,9 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
+	if err := jobRunner.Wait(ctx); err != nil {
+		logger.Warn("Shutting down with async jobs still running", zap.Error(err))
+	}
 
 	logger.Info("Server shutdown complete")
 }
@@ -158,20 +298,93 @@ func initTracing() error {
 	return nil
 }
 
+// watchInternalTokenKey reloads the internal token key file so that
+// replacing it rotates the signing key without a restart.
+func watchInternalTokenKey(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rotated, err := tokenSigner.Reload()
+			if err != nil {
+				logger.Error("Failed to reload internal token key", zap.Error(err))
+			} else if rotated {
+				logger.Info("Rotated internal token signing key")
+			}
+		}
+	}
+}
+
+// watchJWTSecret re-reads JWT_SECRET from its file or secrets provider and
+// rotates to new values. Tokens signed with the old secret stay valid for
+// as long as the gateway issues them for.
+func watchJWTSecret(ctx context.Context, interval time.Duration) {
+	loader, err := cfg.Secrets.Loader()
+	if err != nil {
+		logger.Error("Failed to set up JWT secret reloading", zap.Error(err))
+		return
+	}
+	grace := time.Duration(cfg.JWT.ExpiryHours) * time.Hour
+	loader.Watch(ctx, "JWT_SECRET", cfg.JWT.Secret, interval,
+		func(secret string) {
+			authService.RotateSecret(secret, grace)
+			logger.Info("Rotated JWT secret")
+		},
+		func(err error) {
+			logger.Error("Failed to reload JWT secret", zap.Error(err))
+		})
+}
+
+// initRateLimiter builds the limiter for the configured cross-region mode
+// and, in reconciled mode, starts delivering admissions to the peers. With
+// the fallback enabled, limits are kept in memory while Redis is down.
+func initRateLimiter(ctx context.Context) ratelimit.Limiter {
+	rl := cfg.RateLimit
+	metrics.RateLimitMode.WithLabelValues(rl.Mode).Set(1)
+	logger.Info("Rate limiting across regions", zap.String("mode", rl.Mode), zap.String("region", rl.Region))
+
+	var limiter ratelimit.Limiter
+	switch rl.Mode {
+	case ratelimit.ModeGlobal:
+		limiter = ratelimit.NewRedisRateLimiter(redis.NewClient(&redis.Options{
+			Addr:     rl.GlobalRedisAddress,
+			Password: rl.RedisPassword,
+		}))
+	case ratelimit.ModeReconciled:
+		peers := make(map[string]*redis.Client, len(rl.Peers))
+		for region, address := range rl.Peers {
+			peers[region] = redis.NewClient(&redis.Options{Addr: address, Password: rl.RedisPassword})
+		}
+		reconciler := ratelimit.NewReconciler(redisClient, peers, logger)
+		go reconciler.Run(ctx, rl.ReconcileInterval)
+		limiter = reconciler.Limiter()
+	default:
+		limiter = ratelimit.NewRedisRateLimiter(redisClient)
+	}
+	if rl.Fallback.Enabled {
+		limiter = ratelimit.NewFallbackLimiter(limiter, rl.Fallback.Scale, rl.Fallback.MaxKeys, logger)
+	}
+	return limiter
+}
+
 func initGRPCConnections() error {
 	grpcConnections = make(map[string]*grpc.ClientConn)
-
-	services := map[string]string{
-		"surveillance-engine": cfg.Services.SurveillanceEngine,
-		"user-service":        cfg.Services.UserService,
-		"compliance-service":  cfg.Services.ComplianceService,
-		"reporting-service":   cfg.Services.ReportingService,
-		"audit-service":       cfg.Services.AuditService,
-		"notification-service": cfg.Services.NotificationService,
+	upstreamTLS = make(map[string]*tls.Config)
+	for name, tlsCfg := range cfg.Services.TLS {
+		conf, err := httpclient.TLSConfig(name, tlsCfg)
+		if err != nil {
+			return err
+		}
+		upstreamTLS[name] = conf
 	}
 
-	for name, address := range services {
-		conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	for name, address := range cfg.Services.Addresses() {
+		opts := append(grpcDialOptions(name), grpc.WithStatsHandler(proxy.NewPayloadStatsHandler(name)))
+		conn, err := grpc.Dial(address, opts...)
 		if err != nil {
 			return fmt.Errorf("failed to connect to %s at %s: %w", name, address, err)
 		}
@@ -179,9 +392,83 @@ func initGRPCConnections() error {
 		logger.Info("Connected to gRPC service", zap.String("service", name), zap.String("address", address))
 	}
 
+	// Experiment variants, shadow backends and sticky instances share their
+	// service's client settings
+	for name, instances := range cfg.Services.Instances {
+		for _, address := range instances {
+			if err := dialAlternate(config.InstanceConnName(name, address), name, address); err != nil {
+				return err
+			}
+		}
+	}
+	shadowAddresses := make(map[string]string)
+	for _, route := range cfg.Routes {
+		for _, v := range route.Variants {
+			if err := dialAlternate(config.VariantConnName(v.Service, v.Name), v.Service, v.Address); err != nil {
+				return err
+			}
+		}
+		if sh := route.Shadow; sh != nil {
+			if prev, ok := shadowAddresses[sh.Service]; ok && prev != sh.Address {
+				return fmt.Errorf("conflicting shadow addresses for %s: %s and %s", sh.Service, prev, sh.Address)
+			}
+			shadowAddresses[sh.Service] = sh.Address
+			if err := dialAlternate(config.ShadowConnName(sh.Service), sh.Service, sh.Address); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
+// dialAlternate connects an extra backend for service (an experiment variant
+// or shadow) under name, unless it is already connected.
+func dialAlternate(name, service, address string) error {
+	if _, ok := grpcConnections[name]; ok {
+		return nil
+	}
+	opts := append(grpcDialOptions(service), grpc.WithStatsHandler(proxy.NewPayloadStatsHandler(service)))
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s at %s: %w", name, address, err)
+	}
+	grpcConnections[name] = conn
+	logger.Info("Connected to gRPC backend", zap.String("backend", name), zap.String("address", address))
+	return nil
+}
+
+// grpcDialOptions applies service's client settings, and its TLS settings
+// if it has any.
+func grpcDialOptions(service string) []grpc.DialOption {
+	clientCfg := cfg.Services.GRPC[service]
+	creds := insecure.NewCredentials()
+	if conf, ok := upstreamTLS[service]; ok {
+		creds = credentials.NewTLS(conf)
+	}
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(clientCfg.MaxRecvMsgSize),
+			grpc.MaxCallSendMsgSize(clientCfg.MaxSendMsgSize),
+		),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                clientCfg.KeepaliveTime,
+			Timeout:             clientCfg.KeepaliveTimeout,
+			PermitWithoutStream: clientCfg.KeepalivePermitWithoutStream,
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  clientCfg.BackoffBaseDelay,
+				Multiplier: backoff.DefaultConfig.Multiplier,
+				Jitter:     backoff.DefaultConfig.Jitter,
+				MaxDelay:   clientCfg.BackoffMaxDelay,
+			},
+			MinConnectTimeout: clientCfg.MinConnectTimeout,
+		}),
+	}
+}
+
 func closeGRPCConnections() {
 	for name, conn := range grpcConnections {
 		if err := conn.Close(); err != nil {
@@ -190,6 +477,23 @@ func closeGRPCConnections() {
 	}
 }
 
+// checkRouteDocumentation compares the registered API routes with the
+// OpenAPI spec. Drift is logged; with OPENAPI_REQUIRE_DOCUMENTED set (as in
+// CI) undocumented routes stop startup.
+func checkRouteDocumentation(router *gin.Engine) {
+	if stale := inventory.Unrouted(router, schemaRegistry); len(stale) > 0 {
+		logger.Warn("OpenAPI spec documents routes the gateway does not serve", zap.Strings("operations", stale))
+	}
+	undocumented := inventory.Undocumented(router, schemaRegistry, "/api/")
+	if len(undocumented) == 0 {
+		return
+	}
+	if cfg.OpenAPI.RequireDocumented {
+		logger.Fatal("Routes missing from the OpenAPI spec", zap.Strings("routes", undocumented))
+	}
+	logger.Warn("Routes missing from the OpenAPI spec", zap.Strings("routes", undocumented))
+}
+
 func setupRouter() *gin.Engine {
 	// Set Gin mode
 	if cfg.Environment == "production" {
@@ -197,6 +501,21 @@ func setupRouter() *gin.Engine {
 	}
 
 	router := gin.New()
+	warmer = warmup.NewWarmer(grpcConnections, cfg.Warmup, logger)
+
+	// c.ClientIP() is what rate limits, tenant header trust and logs key
+	// on; only believe forwarding headers set by our own load balancers
+	router.RemoteIPHeaders = cfg.Server.ProxyHeaders
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+	if len(cfg.Server.TrustedProxies) == 0 {
+		logger.Info("No trusted proxies; client IPs are the connection peers")
+	} else {
+		logger.Info("Trusting forwarded client IPs from proxies",
+			zap.Strings("trusted_proxies", cfg.Server.TrustedProxies),
+			zap.Strings("headers", cfg.Server.ProxyHeaders))
+	}
 
 	// Global middlewares
 	router.Use(gin.Logger())
@@ -204,46 +523,332 @@ func setupRouter() *gin.Engine {
 	router.Use(otelgin.Middleware("dharmaguard-api-gateway"))
 	router.Use(middleware.CORS())
 	router.Use(middleware.RequestID())
+	router.Use(middleware.ServerTiming(cfg.Observability.ServerTiming))
+	router.Use(middleware.LimitHeaders(cfg.Server.MaxHeaderCount, cfg.Server.MaxHeaderBytes))
+	router.Use(loadShedder.Middleware())
+	router.Use(middleware.RequestLog(cfg.Observability.RequestLog, logger))
+	router.Use(middleware.TraceBodies(cfg.Observability.TraceBodies, cfg.Observability.RequestLog.RedactFields))
+	if geoProvider != nil {
+		router.Use(middleware.EnrichGeo(geoProvider))
+	}
 	router.Use(middleware.SecurityHeaders())
+	// Download links stream files of any size, for as long as the transfer
+	// takes; long polling bounds its own hold
+	router.Use(middleware.Watchdog(cfg.Server.HardTimeout, logger, fileLinksPrefix))
+	router.Use(middleware.Timeout(cfg.Routes, cfg.Server.RequestTimeout, cfg.Server.ClientTimeout, "/poll/", fileLinksPrefix))
+	router.Use(middleware.ResponseHeaders(cfg.Routes))
+	router.Use(middleware.RequireContentType(cfg.Routes, cfg.Server.ContentTypes))
+	router.Use(middleware.DisabledRoutes(routeSwitches))
 
 	// Initialize services
-	authService := auth.NewService(cfg.JWT.Secret, cfg.JWT.Issuer, redisClient)
-	rateLimiter := ratelimit.NewRedisRateLimiter(redisClient)
-	proxyService := proxy.NewService(grpcConnections, logger)
+	// Outbound HTTP may reach the configured backends and allowlisted
+	// destinations, but no other internal address.
+	backendHosts := append([]string{
+		httpclient.HostOf(cfg.Services.AuditService),
+		httpclient.HostOf(cfg.Services.NotificationService),
+		httpclient.HostOf(cfg.Storage.FileServiceURL),
+	}, cfg.Outbound.AllowedHosts...)
+	for _, p := range cfg.JWT.Introspection {
+		backendHosts = append(backendHosts, httpclient.HostOf(p.Endpoint))
+	}
+	if cfg.Authz.PolicyURL != "" {
+		backendHosts = append(backendHosts, httpclient.HostOf(cfg.Authz.PolicyURL))
+	}
+	for _, u := range cfg.Authz.ResourceURLs {
+		backendHosts = append(backendHosts, httpclient.HostOf(u))
+	}
+	outboundGuard, err := httpclient.NewGuard(backendHosts, cfg.Outbound.AllowedCIDRs)
+	if err != nil {
+		logger.Fatal("Invalid outbound allowlist", zap.Error(err))
+	}
+	httpclient.Configure(httpclient.Policy{MaxRedirects: cfg.Services.MaxRedirects, Guard: outboundGuard})
+	// Clients choose webhook URLs, so no backend host or allowed network is
+	// trusted for them
+	webhookGuard, err := httpclient.NewGuard(nil, nil)
+	if err != nil {
+		logger.Fatal("Invalid webhook allowlist", zap.Error(err))
+	}
+	jobRunner = jobs.NewRunner(jobs.NewStore(redisClient, cfg.Jobs.Retention), cfg.Jobs.Timeout,
+		jobs.NewWebhooks(cfg.Jobs.WebhookSecret, cfg.Jobs.WebhookTimeout, webhookGuard, logger), logger)
+	clockSkew := cfg.JWT.ClockSkew
+	for _, p := range cfg.JWT.Introspection {
+		introspector := auth.NewIntrospector(p.Endpoint, p.ClientID, p.ClientSecret,
+			p.CacheTTL, clockSkew, redisClient, logger)
+		authService.RegisterValidator(p.Issuer, introspector, p.Opaque)
+	}
+	rateLimitOverrides := ratelimit.NewOverrideStore(redisClient)
+	auditRedactor, err := audit.NewFieldRedactor(cfg.Audit.RedactFields, cfg.Audit.RedactPaths)
+	if err != nil {
+		logger.Fatal("Invalid AUDIT_REDACT_PATHS", zap.Error(err))
+	}
+	auditClient := audit.NewClient(cfg.Services.AuditService, auditRedactor, upstreamTLS["audit-service"], logger)
+	var rateLimitAlerts *ratelimit.Alerter
+	if a := cfg.RateLimit.Alert; a.Threshold > 0 {
+		notifier := notify.NewClient(cfg.Services.NotificationService, upstreamTLS["notification-service"], logger)
+		rateLimitAlerts = ratelimit.NewAlerter(redisClient, a.Threshold, a.Window, a.Cooldown, func(alert ratelimit.Alert) {
+			details := map[string]interface{}{
+				"key":            alert.Key,
+				"rejected":       alert.Rejected,
+				"window_seconds": alert.Window.Seconds(),
+				"routes":         alert.Routes,
+			}
+			notifier.Emit(notify.Event{
+				Type:     "rate_limit.threshold_exceeded",
+				Severity: "warning",
+				TenantID: alert.TenantID,
+				Summary: fmt.Sprintf("Rate limit key %s was rejected %d times in %s",
+					alert.Key, alert.Rejected, alert.Window),
+				Details:    details,
+				OccurredAt: time.Now(),
+			})
+			auditClient.Emit(audit.Event{
+				TenantID:     alert.TenantID,
+				Action:       "RATE_LIMIT_THRESHOLD_EXCEEDED",
+				ResourceType: "rate_limit_key",
+				ResourceID:   alert.Key,
+				Metadata:     details,
+			})
+		}, logger)
+	}
+	usageMeter := metering.NewMeter(redisClient, cfg.Metering.Period,
+		time.Duration(cfg.Metering.RetentionDays)*24*time.Hour)
+	quotaStore := quota.NewStore(redisClient)
+	tenantKeys := tenant.NewKeyStore(redisClient)
+	tenantHosts := tenant.NewHostStore(redisClient)
+	hostResolver := tenant.NewHostResolver(cfg.Tenant.Hosts, cfg.Tenant.SharedHosts, tenantHosts)
+	resolveTenant := middleware.ResolveTenant(cfg.Tenant, cfg.Routes, hostResolver)
+	var policyClient *authz.Client
+	if cfg.Authz.PolicyURL != "" {
+		policyClient = authz.NewClient(cfg.Authz.PolicyURL, cfg.Authz.Timeout,
+			cfg.Authz.CacheTTL)
+	}
+	var resourceResolver *authz.ResourceResolver
+	if len(cfg.Authz.ResourceURLs) > 0 {
+		resourceResolver = authz.NewResourceResolver(cfg.Authz.ResourceURLs,
+			cfg.Authz.ResourceCacheTTL, tokenSigner, redisClient)
+	}
+	enrichResource := middleware.EnrichResource(cfg.Routes, resourceResolver, logger)
+	authorize := middleware.Authorize(policyClient, logger)
+	// With the policy replacing them, the role checks below let everything
+	// through and the policy decides
+	requireRole := middleware.RequireRole
+	if cfg.Authz.Mode == "replace" {
+		requireRole = func(...string) gin.HandlerFunc { return func(c *gin.Context) { c.Next() } }
+	}
+	fileClient := storage.NewFileClient(cfg.Storage.FileServiceURL, "compliance-service", tokenSigner)
+	fileLinks := storage.NewLinkStore(redisClient)
+	var presigner *storage.Presigner
+	if cfg.Storage.Endpoint != "" {
+		var err error
+		presigner, err = storage.NewPresigner(cfg.Storage.Endpoint, cfg.Storage.Region, cfg.Storage.Bucket,
+			cfg.Storage.AccessKeyID, cfg.Storage.SecretAccessKey)
+		if err != nil {
+			logger.Fatal("Invalid object storage configuration", zap.Error(err))
+		}
+	}
+	var uploadScanner upload.Scanner
+	if cfg.Upload.ScannerAddress != "" {
+		uploadScanner = upload.NewClamdScanner(cfg.Upload.ScannerAddress,
+			cfg.Upload.ScanTimeout)
+	} else {
+		logger.Warn("UPLOAD_SCANNER_ADDRESS not set; uploads will not be virus scanned")
+	}
+	forwardAllow, forwardDeny := proxy.DefaultForwardAllow, proxy.DefaultForwardDeny
+	if len(cfg.Services.ForwardHeaders) > 0 {
+		forwardAllow = cfg.Services.ForwardHeaders
+	}
+	if len(cfg.Services.StripHeaders) > 0 {
+		forwardDeny = cfg.Services.StripHeaders
+	}
+	shadowDiffs := proxy.NewDiffStore(redisClient, 500)
+	callerVerifier, err := auth.NewInternalCallerVerifier(cfg.InternalAuth.TrustedCallers)
+	if err != nil {
+		logger.Fatal("Invalid INTERNAL_TRUSTED_CALLERS", zap.Error(err))
+	}
+	superAdmin := []string{"SUPER_ADMIN"}
+	backendNames := make([]string, 0, len(cfg.Services.Addresses()))
+	for name := range cfg.Services.Addresses() {
+		backendNames = append(backendNames, name)
+	}
+	proxyService := proxy.NewService(grpcConnections, logger,
+		proxy.WithHeaderPolicy(proxy.NewHeaderPolicy(forwardAllow, forwardDeny)),
+		proxy.WithRoutes(cfg.Routes),
+		proxy.WithDefaultFormat(cfg.Services.DefaultResponseFormat),
+		proxy.WithTranscoding(cfg.Transcoding),
+		proxy.WithGRPCClients(cfg.Services.GRPC),
+		proxy.WithInternalTokens(tokenSigner),
+		proxy.WithDeadlineBuffer(cfg.Services.DeadlineBuffer),
+		proxy.WithShadowDiffs(shadowDiffs),
+		proxy.WithCircuitBreaker(cfg.Services.CircuitBreaker.FailureThreshold,
+			cfg.Services.CircuitBreaker.Open),
+		proxy.WithJobs(jobRunner),
+		proxy.WithBreakerFleet(breakerFleet),
+		proxy.WithFaultInjection(cfg.FaultInjection, callerVerifier, superAdmin),
+		proxy.WithStickyInstances(cfg.Services.Instances, cfg.Services.StickyKey),
+		proxy.WithInstanceBalancing(cfg.Services.Instances, cfg.Routes),
+		proxy.WithUpstreamPinning(callerVerifier, cfg.Services.Instances, cfg.Services.PinTargets),
+		proxy.WithLongPoll(cfg.Server.LongPollMaxHold, cfg.Server.LongPollMaxConnections),
+		proxy.WithRetryBudget(cfg.Services.RetryBudget.Ratio, cfg.Services.RetryBudget.MinPerSecond,
+			cfg.Services.RetryBudget.Window),
+		proxy.WithErrorMap(cfg.Services.ErrorMap),
+	)
+
+	responseCache := cache.NewResponseCache(redisClient, cfg.Routes, cfg.CacheBypass, cfg.Tenant, logger)
+
+	// Pin requests from trusted callers first, so the pin headers are gone
+	// before anything forwards them. Their callers are identified before
+	// that, for rate-limit exemption and fault injection
+	router.Use(middleware.IdentifyInternalCaller(callerVerifier))
+	if cfg.FaultInjection.Enabled {
+		logger.Warn("Fault injection is enabled; routes with faults in the routes file will fail on purpose for internal callers and super admins",
+			zap.String("environment", cfg.Environment), zap.String("header", cfg.FaultInjection.Header))
+		router.Use(proxyService.Faults())
+	}
+	router.Use(proxyService.Pinning(logger))
+	router.Use(proxyService.ForwardHeaders())
+	router.Use(proxyService.Experiments())
+	router.Use(proxyService.Shadow())
+	router.Use(proxyService.Retries())
+	router.Use(proxyService.Affinity())
+	router.Use(proxyService.Costs())
+
+	// Rate limiting runs per route group, after authentication where there is
+	// one, so limits and overrides can key on the caller's identity
+	rateLimitKeys, err := ratelimit.ParseKeyTemplate(cfg.RateLimit.KeyTemplate, auth.ClaimNames)
+	if err != nil {
+		logger.Fatal("Invalid RATE_LIMIT_KEY_TEMPLATE", zap.Error(err))
+	}
+	rateLimit := middleware.RateLimit(rateLimiter, cfg.RateLimit, rateLimitOverrides, rateLimitKeys, rateLimitAlerts)
+
+	// Access policy per route group, for the route inventory. Groups
+	// declare theirs where they're mounted below; these cover the routes
+	// registered outside a group.
+	defaultLimit := &ratelimit.Limit{RequestsPerMinute: cfg.RateLimit.RequestsPerMinute, Burst: cfg.RateLimit.BurstSize}
+	adminRoles := []string{"SUPER_ADMIN", "TENANT_ADMIN"}
+	adminPolicy := inventory.Policy{Auth: true, Audiences: cfg.JWT.Audiences["admin"], Roles: adminRoles, RateLimit: defaultLimit}
+	superAdminPolicy := adminPolicy
+	superAdminPolicy.Roles = superAdmin
+	wsPolicy := inventory.Policy{Auth: true, Audiences: cfg.JWT.Audiences["ws"], RateLimit: defaultLimit}
+	routeInventory := inventory.NewRegistry(cfg.Routes, cfg.Server.RequestTimeout, "/ws/", "/poll/", fileLinksPrefix)
+	for prefix, policy := range map[string]inventory.Policy{
+		"/health":             {},
+		"/ready":              {},
+		"/version":            {},
+		"/docs":               {},
+		"/openapi.yaml":       {},
+		"/api/v1/files/links": {RateLimit: defaultLimit},
+	} {
+		routeInventory.Declare(prefix, policy)
+	}
 
-	// Rate limiting middleware
-	router.Use(middleware.RateLimit(rateLimiter))
+	// Aggregate endpoints are priced by their sections' route costs, and
+	// held to the budget of the caller's plan
+	aggregateScorer := aggregate.NewScorer(cfg.Routes)
+	aggregateBudget := func(c *gin.Context) float64 {
+		plan := ""
+		if claims, ok := middleware.GetClaims(c); ok {
+			plan = claims.Plan
+		}
+		return cfg.Aggregate.Budget(plan)
+	}
+
+	// Resource updates take merge patches or JSON Patch; the tus upload
+	// PATCH carries raw bytes and is left alone
+	patch := middleware.PatchDocuments()
 
 	// Health check (no auth required)
 	router.GET("/health", handlers.HealthCheck)
-	router.GET("/ready", handlers.ReadinessCheck(redisClient, grpcConnections))
+	router.GET("/ready", warmer.Gate(), handlers.ReadinessCheck(redisClient, grpcConnections))
+	router.GET("/version", handlers.Version)
+
+	// Download links are their own credential, like presigned URLs
+	router.GET(fileLinksPrefix+":token", rateLimit, handlers.FetchFileLink(fileLinks, logger))
+
+	// Logins past the session limit evict the oldest session, or are
+	// refused, per SESSION_LIMIT_POLICY
+	limitSessions := middleware.LimitSessions(authService, sessionStore,
+		func(c *gin.Context, claims *auth.Claims, evicted auth.Session) {
+			auditClient.Emit(audit.Event{
+				TenantID:     claims.TenantID,
+				UserID:       claims.UserID,
+				Action:       "SESSION_EVICTED",
+				ResourceType: "session",
+				ResourceID:   evicted.ID,
+				Metadata: map[string]interface{}{
+					"reason":       "session_limit",
+					"max_sessions": sessionStore.Limit(),
+					"evicted_by":   claims.Session(),
+					"created_at":   evicted.CreatedAt,
+					"client_ip":    evicted.ClientIP,
+				},
+			})
+		}, logger)
 
 	// Authentication endpoints (no auth required)
-	authGroup := router.Group("/api/v1/auth")
+	authGroup := routeInventory.Group(&router.RouterGroup, "/api/v1/auth", inventory.Policy{RateLimit: defaultLimit})
+	authGroup.Use(rateLimit)
+	authGroup.Use(middleware.SessionCookies(cfg.Session, cfg.CSRF))
 	{
-		authGroup.POST("/login", handlers.Login(authService, proxyService))
-		authGroup.POST("/refresh", handlers.RefreshToken(authService))
-		authGroup.POST("/logout", handlers.Logout(authService))
+		authGroup.POST("/login", limitSessions, handlers.Login(authService, proxyService))
+		authGroup.POST("/refresh", middleware.RefreshFromCookie(cfg.Session, cfg.CSRF), handlers.RefreshToken(authService))
+		authGroup.POST("/logout", middleware.EndSession(authService, sessionStore, cfg.Session, logger),
+			handlers.Logout(authService))
 		authGroup.POST("/register", handlers.Register(proxyService))
 		authGroup.POST("/forgot-password", handlers.ForgotPassword(proxyService))
 		authGroup.POST("/reset-password", handlers.ResetPassword(proxyService))
 	}
 
 	// Protected API routes
-	apiV1 := router.Group("/api/v1")
-	apiV1.Use(middleware.AuthRequired(authService))
+	apiV1 := routeInventory.Group(&router.RouterGroup, "/api/v1",
+		inventory.Policy{Auth: true, Audiences: cfg.JWT.Audiences["api"], RateLimit: defaultLimit})
+	apiV1.Use(middleware.AuthenticateAPIKey(tenantKeys))
+	apiV1.Use(middleware.AuthRequired(authService, cfg.Session, cfg.Routes))
+	apiV1.Use(middleware.CSRF(cfg.CSRF, cfg.Session))
+	apiV1.Use(middleware.RequireAudience(cfg.JWT.Audiences["api"]...))
+	apiV1.Use(resolveTenant)
+	tenantLabels := metrics.NewTenantLabeler(cfg.Metrics.TenantLabels.MaxTenants, cfg.Metrics.TenantLabels.Tenants)
+	if cfg.Metrics.TenantLabels.Enabled {
+		// Counted from here, so rate limit and quota rejections show in
+		// the tenant's error rate
+		apiV1.Use(middleware.TenantMetrics(tenantLabels))
+	}
+	apiV1.Use(rateLimit)
+	apiV1.Use(middleware.RequireScope(cfg.Routes))
+	apiV1.Use(middleware.ValidateQuery(cfg.Routes, schemaRegistry, logger))
+	apiV1.Use(enrichResource)
+	apiV1.Use(authorize)
+	apiV1.Use(middleware.Quota(quotaStore))
+	apiV1.Use(middleware.Metering(usageMeter, logger))
+	apiV1.Use(middleware.CountDeprecated(cfg.Routes, tenantLabels))
+	apiV1.Use(middleware.NegotiateVersion(cfg.Routes))
+	apiV1.Use(responseCache.Middleware())
+	apiV1.Use(middleware.ValidateResponses(schemaRegistry, cfg.Routes, cfg.OpenAPI.ResponseSampleRate, logger))
 	{
+		// Dashboard, assembled from the routes below
+		dashboard := aggregate.NewFetcher("dashboard", router,
+			cfg.Aggregate.SectionTimeout, aggregateScorer, aggregateBudget)
+		apiV1.GET("/dashboard", handlers.Dashboard(dashboard, []aggregate.Section{
+			{Name: "alerts", Path: "/api/v1/surveillance/alerts?limit=10"},
+			{Name: "statistics", Path: "/api/v1/surveillance/statistics"},
+			{Name: "trades", Path: "/api/v1/trading/trades?limit=10"},
+			{Name: "violations", Path: "/api/v1/compliance/violations?limit=10"},
+			{Name: "notifications", Path: "/api/v1/notifications?limit=10"},
+		}))
+
+		// Async job status, for routes that submit jobs
+		apiV1.GET("/jobs/:id", handlers.GetJob(jobRunner.Store()))
+
 		// User management
 		userGroup := apiV1.Group("/users")
 		{
 			userGroup.GET("", handlers.ListUsers(proxyService))
 			userGroup.POST("", handlers.CreateUser(proxyService))
 			userGroup.GET("/:id", handlers.GetUser(proxyService))
-			userGroup.PATCH("/:id", handlers.UpdateUser(proxyService))
+			userGroup.PATCH("/:id", patch, handlers.UpdateUser(proxyService))
 			userGroup.DELETE("/:id", handlers.DeleteUser(proxyService))
 			userGroup.POST("/:id/activate", handlers.ActivateUser(proxyService))
 			userGroup.POST("/:id/deactivate", handlers.DeactivateUser(proxyService))
-			userGroup.GET("/:id/sessions", handlers.GetUserSessions(proxyService))
+			userGroup.GET("/:id/sessions", handlers.GetActiveSessions(sessionStore))
 			userGroup.GET("/:id/permissions", handlers.GetUserPermissions(proxyService))
 		}
 
@@ -252,7 +857,7 @@ func setupRouter() *gin.Engine {
 		{
 			surveillanceGroup.GET("/alerts", handlers.GetAlerts(proxyService))
 			surveillanceGroup.GET("/alerts/:id", handlers.GetAlert(proxyService))
-			surveillanceGroup.PATCH("/alerts/:id", handlers.UpdateAlert(proxyService))
+			surveillanceGroup.PATCH("/alerts/:id", patch, handlers.UpdateAlert(proxyService))
 			surveillanceGroup.POST("/alerts/:id/resolve", handlers.ResolveAlert(proxyService))
 			surveillanceGroup.GET("/patterns", handlers.GetPatterns(proxyService))
 			surveillanceGroup.POST("/patterns", handlers.CreatePattern(proxyService))
@@ -267,7 +872,7 @@ func setupRouter() *gin.Engine {
 			tradingGroup.GET("/positions", handlers.GetPositions(proxyService))
 			tradingGroup.GET("/orders", handlers.GetOrders(proxyService))
 			tradingGroup.POST("/orders", handlers.CreateOrder(proxyService))
-			tradingGroup.PATCH("/orders/:id", handlers.UpdateOrder(proxyService))
+			tradingGroup.PATCH("/orders/:id", patch, handlers.UpdateOrder(proxyService))
 		}
 
 		// Compliance and reporting
@@ -275,6 +880,8 @@ func setupRouter() *gin.Engine {
 		{
 			complianceGroup.GET("/reports", handlers.GetReports(proxyService))
 			complianceGroup.POST("/reports", handlers.GenerateReport(proxyService))
+			complianceGroup.POST("/reports/generate", handlers.GenerateReportWithProgress(proxyService))
+			complianceGroup.POST("/reports/jobs", handlers.SubmitReportJob(proxyService))
 			complianceGroup.GET("/reports/:id", handlers.GetReport(proxyService))
 			complianceGroup.POST("/reports/:id/submit", handlers.SubmitReport(proxyService))
 			complianceGroup.GET("/violations", handlers.GetViolations(proxyService))
@@ -296,36 +903,120 @@ func setupRouter() *gin.Engine {
 			notificationGroup.POST("", handlers.SendNotification(proxyService))
 			notificationGroup.PATCH("/:id/read", handlers.MarkAsRead(proxyService))
 			notificationGroup.GET("/settings", handlers.GetNotificationSettings(proxyService))
-			notificationGroup.PATCH("/settings", handlers.UpdateNotificationSettings(proxyService))
+			notificationGroup.PATCH("/settings", patch, handlers.UpdateNotificationSettings(proxyService))
 		}
 
 		// File uploads and downloads
 		fileGroup := apiV1.Group("/files")
 		{
-			fileGroup.POST("/upload", handlers.UploadFile(proxyService))
+			uploadFile := handlers.UploadFile(proxyService)
+			fileGroup.POST("/upload",
+				middleware.ValidateUpload(cfg.Upload, cfg.Routes, uploadScanner, logger),
+				uploadFile)
 			fileGroup.GET("/:id/download", handlers.DownloadFile(proxyService))
+			fileGroup.GET("/:id/download-url", handlers.GetDownloadURL(fileClient, presigner, fileLinks,
+				cfg.Storage.URLTTL, logger))
+
+			// Resumable (tus) uploads, submitted to /upload's handler once
+			// complete
+			uploadPolicy := cfg.Routes.UploadPolicy(http.MethodPost, "/api/v1/files/upload", cfg.Upload)
+			submitUpload := middleware.CheckUpload(uploadPolicy, uploadScanner, logger, uploadFile)
+			fileGroup.OPTIONS("/uploads", handlers.TusOptions(uploadPolicy.MaxBytes))
+			fileGroup.POST("/uploads", handlers.CreateUpload(resumableUploads, uploadPolicy.MaxBytes))
+			fileGroup.HEAD("/uploads/:id", handlers.UploadOffset(resumableUploads))
+			fileGroup.PATCH("/uploads/:id", handlers.AppendUpload(resumableUploads, submitUpload, "/api/v1/files/upload", logger))
+			fileGroup.DELETE("/uploads/:id", handlers.DeleteUpload(resumableUploads))
 			fileGroup.DELETE("/:id", handlers.DeleteFile(proxyService))
 		}
 	}
 
 	// Admin routes (requires admin role)
-	adminGroup := router.Group("/api/v1/admin")
-	adminGroup.Use(middleware.AuthRequired(authService))
-	adminGroup.Use(middleware.RequireRole("SUPER_ADMIN", "TENANT_ADMIN"))
+	adminGroup := routeInventory.Group(&router.RouterGroup, "/api/v1/admin", adminPolicy)
+	adminGroup.Use(middleware.AuthRequired(authService, cfg.Session, cfg.Routes))
+	adminGroup.Use(middleware.CSRF(cfg.CSRF, cfg.Session))
+	adminGroup.Use(middleware.RequireAudience(cfg.JWT.Audiences["admin"]...))
+	adminGroup.Use(requireRole(adminRoles...))
+	adminGroup.Use(middleware.RequireScope(cfg.Routes))
+	adminGroup.Use(enrichResource)
+	adminGroup.Use(authorize)
+	adminGroup.Use(rateLimit)
+	// Groups restricted further to super admins, declared with their policy
+	superAdminGroup := func(relativePath string) *gin.RouterGroup {
+		g := routeInventory.Group(adminGroup, relativePath, superAdminPolicy)
+		g.Use(requireRole(superAdmin...))
+		return g
+	}
 	{
 		adminGroup.GET("/tenants", handlers.ListTenants(proxyService))
 		adminGroup.POST("/tenants", handlers.CreateTenant(proxyService))
 		adminGroup.GET("/tenants/:id", handlers.GetTenant(proxyService))
-		adminGroup.PATCH("/tenants/:id", handlers.UpdateTenant(proxyService))
+		adminGroup.PATCH("/tenants/:id", patch, handlers.UpdateTenant(proxyService))
 		adminGroup.GET("/users/stats", handlers.GetUserStats(proxyService))
-		adminGroup.GET("/system/health", handlers.SystemHealth(proxyService))
+		adminGroup.GET("/system/health", handlers.SystemHealth(proxyService, backendNames, cfg.Services.HealthCheckTimeout))
 		adminGroup.GET("/system/metrics", handlers.SystemMetrics(proxyService))
 		adminGroup.POST("/cache/clear", handlers.ClearCache(redisClient))
+
+		overrideGroup := superAdminGroup("/ratelimit/overrides/:scope/:id")
+		{
+			overrideGroup.GET("", handlers.GetRateLimitOverride(rateLimitOverrides))
+			overrideGroup.PUT("", handlers.SetRateLimitOverride(rateLimitOverrides, auditClient))
+			overrideGroup.DELETE("", handlers.DeleteRateLimitOverride(rateLimitOverrides, auditClient))
+		}
+
+		usageGroup := superAdminGroup("/usage")
+		{
+			usageGroup.GET("", handlers.ExportUsage(usageMeter))
+			usageGroup.GET("/:key_id", handlers.GetKeyUsage(usageMeter))
+		}
+
+		superAdminGroup("/shadow").GET("/diffs", handlers.ListShadowDiffs(shadowDiffs))
+		routeGroup := superAdminGroup("/routes")
+		routeGroup.GET("", handlers.ListRoutes(routeInventory, router, schemaRegistry))
+
+		// Take single routes out of service during an incident
+		routeSwitchGroup := routeGroup.Group("/disabled")
+		{
+			routeSwitchGroup.GET("", handlers.ListDisabledRoutes(routeSwitches))
+			routeSwitchGroup.PUT("", handlers.DisableRoute(routeSwitches, router, auditClient))
+			routeSwitchGroup.DELETE("", handlers.EnableRoute(routeSwitches, auditClient))
+		}
+		superAdminGroup("/circuit-breakers").GET("", handlers.ListCircuitBreakers(proxyService, breakerFleet))
+		superAdminGroup("/warmup").POST("", handlers.RunWarmup(warmer))
+
+		quotaGroup := superAdminGroup("/quotas/:scope/:id")
+		{
+			quotaGroup.GET("", handlers.GetQuota(quotaStore))
+			quotaGroup.PUT("", handlers.SetQuota(quotaStore, auditClient))
+			quotaGroup.DELETE("", handlers.DeleteQuota(quotaStore, auditClient))
+		}
+
+		// API key to tenant mappings for the api_key tenant resolver
+		tenantKeyGroup := superAdminGroup("/tenant-keys/:key_id")
+		{
+			tenantKeyGroup.GET("", handlers.GetTenantKey(tenantKeys))
+			tenantKeyGroup.PUT("", handlers.SetTenantKey(tenantKeys, auditClient))
+			tenantKeyGroup.DELETE("", handlers.DeleteTenantKey(tenantKeys, auditClient))
+		}
+
+		// Custom hostname to tenant mappings for the host tenant resolver
+		tenantHostGroup := superAdminGroup("/tenant-hosts/:host")
+		{
+			tenantHostGroup.GET("", handlers.GetTenantHost(tenantHosts))
+			tenantHostGroup.PUT("", handlers.SetTenantHost(tenantHosts, auditClient))
+			tenantHostGroup.DELETE("", handlers.DeleteTenantHost(tenantHosts, auditClient))
+		}
 	}
 
 	// WebSocket endpoints for real-time features
-	wsGroup := router.Group("/ws")
+	wsconn.Configure(cfg.Server.WebSocket)
+	wsGroup := routeInventory.Group(&router.RouterGroup, "/ws", wsPolicy)
+	wsGroup.Use(middleware.WebSocketOrigin(cfg.Server.WebSocketOrigins, logger))
 	wsGroup.Use(middleware.WebSocketAuth(authService))
+	wsGroup.Use(middleware.RequireAudience(cfg.JWT.Audiences["ws"]...))
+	wsGroup.Use(resolveTenant)
+	wsGroup.Use(rateLimit)
+	wsGroup.Use(authorize)
+	wsGroup.Use(middleware.WarnTokenExpiry(cfg.Server.TokenExpiryWarning))
 	{
 		wsGroup.GET("/alerts", handlers.AlertsWebSocket(proxyService))
 		wsGroup.GET("/trades", handlers.TradesWebSocket(proxyService))
@@ -333,17 +1024,44 @@ func setupRouter() *gin.Engine {
 		wsGroup.GET("/surveillance", handlers.SurveillanceWebSocket(proxyService))
 	}
 
+	// Long-polling fallback for the real-time streams, for networks that
+	// block WebSockets and SSE. Same audience and tenant scoping as /ws.
+	pollGroup := routeInventory.Group(&router.RouterGroup, "/poll", wsPolicy)
+	pollGroup.Use(middleware.AuthRequired(authService, cfg.Session, cfg.Routes))
+	pollGroup.Use(middleware.RequireAudience(cfg.JWT.Audiences["ws"]...))
+	pollGroup.Use(resolveTenant)
+	pollGroup.Use(rateLimit)
+	pollGroup.Use(authorize)
+	{
+		pollGroup.GET("/alerts", handlers.AlertsLongPoll(proxyService))
+		pollGroup.GET("/trades", handlers.TradesLongPoll(proxyService))
+		pollGroup.GET("/notifications", handlers.NotificationsLongPoll(proxyService))
+		pollGroup.GET("/surveillance", handlers.SurveillanceLongPoll(proxyService))
+	}
+
 	// Static file serving for documentation
 	router.Static("/docs", "./docs")
 	router.StaticFile("/openapi.yaml", "./docs/api/openapi.yaml")
 
+	// Structured JSON for unmatched routes and methods
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handlers.NotFound())
+	router.NoMethod(handlers.MethodNotAllowed(router))
+
+	// Every route is listed with its access policy; one registered outside
+	// the declared groups would be listed without it
+	if unclassified := routeInventory.Unclassified(router); len(unclassified) > 0 {
+		logger.Fatal("Routes registered without an inventory policy", zap.Strings("routes", unclassified))
+	}
+
 	return router
 }
 
 func startMetricsServer() {
 	metricsRouter := gin.New()
 	metricsRouter.Use(gin.Recovery())
-	metricsRouter.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	metricsRouter.GET("/metrics", gin.WrapH(metrics.Handler()))
+	metricsRouter.GET("/internal/jwks.json", handlers.InternalJWKS(tokenSigner))
 
 	metricsServer := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Metrics.Port),